@@ -2,7 +2,10 @@ package microstellar
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"sort"
 
+	"github.com/pkg/errors"
 	"github.com/stellar/go/clients/horizon"
 )
 
@@ -24,6 +27,14 @@ type Balance struct {
 	Asset  *Asset `json:"asset"`
 	Amount string `json:"amount"`
 	Limit  string `json:"limit"`
+
+	// Amounts reserved by the account's open offers.
+	BuyingLiabilities  string `json:"buying_liabilities"`
+	SellingLiabilities string `json:"selling_liabilities"`
+
+	// Authorization state of the trust line (see Account.IsAuthorized.)
+	Authorized                      bool `json:"is_authorized"`
+	AuthorizedToMaintainLiabilities bool `json:"is_authorized_to_maintain_liabilities"`
 }
 
 // Signer represents a key that can sign for an account.
@@ -59,12 +70,23 @@ type Account struct {
 	Thresholds    Thresholds        `json:"thresholds"`
 	Data          map[string]string `json:"data"`
 	Sequence      string            `json:"seq"`
+
+	// Number of trust lines, offers, signers, and data entries on the account.
+	SubentryCount int32 `json:"subentry_count"`
+
+	// Number of reserves the account pays for others, and others pay for it (see SponsorReserves.)
+	NumSponsoring int32 `json:"num_sponsoring"`
+	NumSponsored  int32 `json:"num_sponsored"`
 }
 
+// baseReserve is the network's base reserve in stroops (0.5 XLM.) Accounts must hold two base
+// reserves, plus one for each subentry.
+const baseReserve = 5000000
+
 // newAccount creates a new initialized account
 func newAccount() *Account {
 	account := &Account{}
-	account.NativeBalance = Balance{NativeAsset, "0", ""}
+	account.NativeBalance = Balance{Asset: NativeAsset, Amount: "0"}
 	account.Signers = []Signer{
 		Signer{},
 	}
@@ -73,6 +95,41 @@ func newAccount() *Account {
 	return account
 }
 
+// horizonAccountExtras are the fields of a Horizon account record that the vendored Horizon
+// client doesn't decode.
+type horizonAccountExtras struct {
+	NumSponsoring int32 `json:"num_sponsoring"`
+	NumSponsored  int32 `json:"num_sponsored"`
+
+	Balances []struct {
+		AssetType                         string `json:"asset_type"`
+		AssetCode                         string `json:"asset_code"`
+		AssetIssuer                       string `json:"asset_issuer"`
+		IsAuthorized                      bool   `json:"is_authorized"`
+		IsAuthorizedToMaintainLiabilities bool   `json:"is_authorized_to_maintain_liabilities"`
+	} `json:"balances"`
+}
+
+// addExtras fills in the fields of account that come from the raw Horizon account record body.
+func (account *Account) addExtras(body []byte) error {
+	var extras horizonAccountExtras
+	if err := json.Unmarshal(body, &extras); err != nil {
+		return errors.Wrap(err, "error unmarshalling account")
+	}
+
+	account.NumSponsoring = extras.NumSponsoring
+	account.NumSponsored = extras.NumSponsored
+
+	for _, eb := range extras.Balances {
+		if b := account.balance(NewAsset(eb.AssetCode, eb.AssetIssuer, AssetType(eb.AssetType))); b != nil {
+			b.Authorized = eb.IsAuthorized
+			b.AuthorizedToMaintainLiabilities = eb.IsAuthorizedToMaintainLiabilities
+		}
+	}
+
+	return nil
+}
+
 // newAccountFromHorizon creates a new account from a Horizon JSON response.
 func newAccountFromHorizon(ha horizon.Account) *Account {
 	account := newAccount()
@@ -80,17 +137,25 @@ func newAccountFromHorizon(ha horizon.Account) *Account {
 	account.Address = ha.HistoryAccount.AccountID
 	account.HomeDomain = ha.HomeDomain
 	account.Sequence = ha.Sequence
+	account.SubentryCount = ha.SubentryCount
 
 	for _, b := range ha.Balances {
 		if b.Asset.Type == string(NativeType) {
-			account.NativeBalance = Balance{NativeAsset, b.Balance, ""}
+			account.NativeBalance = Balance{
+				Asset:              NativeAsset,
+				Amount:             b.Balance,
+				BuyingLiabilities:  b.BuyingLiabilities,
+				SellingLiabilities: b.SellingLiabilities,
+			}
 			continue
 		}
 
 		balance := Balance{
-			Asset:  NewAsset(b.Asset.Code, b.Asset.Issuer, AssetType(b.Asset.Type)),
-			Amount: b.Balance,
-			Limit:  b.Limit,
+			Asset:              NewAsset(b.Asset.Code, b.Asset.Issuer, AssetType(b.Asset.Type)),
+			Amount:             b.Balance,
+			Limit:              b.Limit,
+			BuyingLiabilities:  b.BuyingLiabilities,
+			SellingLiabilities: b.SellingLiabilities,
 		}
 
 		account.Balances = append(account.Balances, balance)
@@ -122,28 +187,178 @@ func newAccountFromHorizon(ha horizon.Account) *Account {
 	return account
 }
 
-// GetBalance returns the balance for asset in account. If no balance is
-// found for the asset, returns "".
+// GetBalance returns the balance for asset in account. Native assets are matched by type, and
+// credit assets by code and issuer. If no balance is found for the asset, returns "0".
 func (account *Account) GetBalance(asset *Asset) string {
 	if asset.IsNative() {
 		return account.GetNativeBalance()
 	}
 
 	for _, b := range account.Balances {
-		if asset.Equals(*b.Asset) {
+		if b.Asset != nil && asset.Equals(*b.Asset) {
 			return b.Amount
 		}
 	}
 
-	return ""
+	return "0"
+}
+
+// hasTrustline returns true if the account trusts the credit asset.
+func (account *Account) hasTrustline(asset *Asset) bool {
+	for _, b := range account.Balances {
+		if b.Asset != nil && asset.Equals(*b.Asset) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// balance returns the account's balance of the credit asset, or nil if the account doesn't trust
+// it.
+func (account *Account) balance(asset *Asset) *Balance {
+	for i, b := range account.Balances {
+		if b.Asset != nil && asset.Equals(*b.Asset) {
+			return &account.Balances[i]
+		}
+	}
+
+	return nil
+}
+
+// IsAuthorized returns true if the account's trust line to asset is authorized by the issuer, i.e.,
+// the account can send and receive asset. Returns false if the account doesn't hold asset.
+func (account *Account) IsAuthorized(asset *Asset) bool {
+	if b := account.balance(asset); b != nil {
+		return b.Authorized
+	}
+
+	return false
+}
+
+// IsAuthorizedToMaintainLiabilities returns true if the issuer of asset has authorized the account
+// to only maintain its existing offers (see TrustLineAuthorizedToMaintainLiabilities.) Returns false
+// if the account doesn't hold asset.
+func (account *Account) IsAuthorizedToMaintainLiabilities(asset *Asset) bool {
+	if b := account.balance(asset); b != nil {
+		return b.AuthorizedToMaintainLiabilities
+	}
+
+	return false
+}
+
+// wouldExceedLimit returns true if receiving amount of asset would exceed the account's trust
+// limit for the asset. Also returns the available headroom (limit minus current balance.)
+func (account *Account) wouldExceedLimit(asset *Asset, amount string) (bool, string, error) {
+	if asset.IsNative() {
+		return false, "", errors.Errorf("native assets have no trust limit")
+	}
+
+	want, err := ParseAmount(amount)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "invalid amount: %s", amount)
+	}
+
+	b := account.balance(asset)
+	if b == nil {
+		return false, "", errors.Errorf("no trustline to %s", asset.Code)
+	}
+
+	balance, err := ParseAmount(b.Amount)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "invalid balance: %s", b.Amount)
+	}
+
+	limit, err := ParseAmount(b.Limit)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "invalid limit: %s", b.Limit)
+	}
+
+	headroom := limit - balance
+	return want > headroom, ToAmountString(headroom), nil
 }
 
 // GetNativeBalance returns the balance of the native currency (typically lumens)
 // in the account.
 func (account *Account) GetNativeBalance() string {
+	if account.NativeBalance.Amount == "" {
+		return "0"
+	}
+
 	return account.NativeBalance.Amount
 }
 
+// MinimumBalance returns the number of lumens the account must hold: two base reserves, plus one
+// for each subentry (trust line, offer, signer, or data entry) and each reserve it sponsors for
+// other accounts, minus the reserves sponsored for it. This assumes a base reserve of 0.5 XLM.
+func (account *Account) MinimumBalance() string {
+	reserves := 2 + int64(account.SubentryCount) + int64(account.NumSponsoring) - int64(account.NumSponsored)
+	if reserves < 0 {
+		reserves = 0
+	}
+
+	return ToAmountString(reserves * baseReserve)
+}
+
+// SpendableBalance returns the number of lumens the account can send: its native balance, minus
+// its minimum balance (see MinimumBalance) and the lumens reserved by its open offers. Use this to
+// avoid tx_insufficient_balance errors, but leave room for the transaction fee.
+func (account *Account) SpendableBalance() string {
+	balance, err := ParseAmount(account.GetNativeBalance())
+	if err != nil {
+		return "0"
+	}
+
+	minimum, _ := ParseAmount(account.MinimumBalance())
+	spendable := balance - minimum
+
+	if account.NativeBalance.SellingLiabilities != "" {
+		liabilities, err := ParseAmount(account.NativeBalance.SellingLiabilities)
+		if err != nil {
+			return "0"
+		}
+		spendable -= liabilities
+	}
+
+	if spendable < 0 {
+		spendable = 0
+	}
+
+	return ToAmountString(spendable)
+}
+
+// spendableBalanceOf returns the amount of asset the account can send: SpendableBalance for
+// lumens, and the balance minus the amount reserved by open offers for credit assets.
+func (account *Account) spendableBalanceOf(asset *Asset) (string, error) {
+	if asset.IsNative() {
+		return account.SpendableBalance(), nil
+	}
+
+	b := account.balance(asset)
+	if b == nil {
+		return "", errors.Errorf("no trustline to %s", asset.Code)
+	}
+
+	balance, err := ParseAmount(b.Amount)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid balance: %s", b.Amount)
+	}
+
+	if b.SellingLiabilities != "" {
+		liabilities, err := ParseAmount(b.SellingLiabilities)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid selling liabilities: %s", b.SellingLiabilities)
+		}
+		balance -= liabilities
+	}
+
+	if balance < 0 {
+		balance = 0
+	}
+
+	return ToAmountString(balance), nil
+}
+
 // GetMasterWeight returns the weight of the primary key in the account.
 func (account *Account) GetMasterWeight() int32 {
 	for _, a := range account.Signers {
@@ -155,6 +370,24 @@ func (account *Account) GetMasterWeight() int32 {
 	return -1
 }
 
+// GetSigners returns the account's signers, with their keys, weights, and types (SignerTypeEd25519,
+// SignerTypePreAuthTx, or SignerTypeHashX.) The master key is included with the account's address
+// as its key.
+//
+//   for _, s := range account.GetSigners() {
+//       log.Printf("%s (%s): weight %d", s.Key, s.Type, s.Weight)
+//   }
+func (account *Account) GetSigners() []Signer {
+	signers := make([]Signer, len(account.Signers))
+	copy(signers, account.Signers)
+	return signers
+}
+
+// GetThresholds returns the account's low, medium, and high signing thresholds.
+func (account *Account) GetThresholds() (low, medium, high uint32) {
+	return uint32(account.Thresholds.Low), uint32(account.Thresholds.Medium), uint32(account.Thresholds.High)
+}
+
 // GetData decodes and returns the base-64 encoded data in "key"
 func (account *Account) GetData(key string) ([]byte, bool) {
 	v, ok := account.Data[key]
@@ -169,3 +402,15 @@ func (account *Account) GetData(key string) ([]byte, bool) {
 
 	return nil, false
 }
+
+// DataKeys returns the keys of the account's data entries, in sorted order. Use GetData to
+// read their values.
+func (account *Account) DataKeys() []string {
+	keys := make([]string, 0, len(account.Data))
+	for k := range account.Data {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
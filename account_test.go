@@ -1,6 +1,11 @@
 package microstellar
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
 
 func TestAccounts(t *testing.T) {
 	account := &Account{}
@@ -25,4 +30,199 @@ func TestAccounts(t *testing.T) {
 	if balance := account.GetBalance(asset); balance != "1" {
 		t.Errorf("wrong native balance: want %v, got %v", "1", balance)
 	}
+
+	otherIssuer := NewAsset("USD", "barfoo", Credit4Type)
+	if balance := account.GetBalance(otherIssuer); balance != "0" {
+		t.Errorf("wrong balance for unheld asset: want %v, got %v", "0", balance)
+	}
+
+	if balance := (&Account{}).GetNativeBalance(); balance != "0" {
+		t.Errorf("wrong native balance for empty account: want %v, got %v", "0", balance)
+	}
+}
+
+func TestWouldExceedLimit(t *testing.T) {
+	account := newAccount()
+	asset := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	if _, _, err := account.wouldExceedLimit(asset, "1"); err == nil {
+		t.Errorf("should fail without trustline")
+	}
+
+	// Balances without assets (e.g., in hand-built accounts) are skipped.
+	account.Balances = append(account.Balances, Balance{Amount: "5"}, Balance{
+		Asset:  asset,
+		Amount: "900",
+		Limit:  "1000",
+	})
+
+	exceeds, headroom, err := account.wouldExceedLimit(asset, "100")
+	if err != nil || exceeds {
+		t.Errorf("100 should fit: got exceeds=%v, err=%v", exceeds, err)
+	}
+
+	if headroom != "100.0000000" {
+		t.Errorf("wrong headroom: want %v, got %v", "100.0000000", headroom)
+	}
+
+	if exceeds, _, _ := account.wouldExceedLimit(asset, "100.0000001"); !exceeds {
+		t.Errorf("100.0000001 should exceed the limit")
+	}
+
+	if _, _, err := account.wouldExceedLimit(NativeAsset, "1"); err == nil {
+		t.Errorf("native assets have no limit")
+	}
+}
+
+func TestAccountData(t *testing.T) {
+	account := newAccount()
+	account.Data = map[string]string{
+		"name":  "bW9oaXQ=",
+		"color": "Ymx1ZQ==",
+		"bad":   "not base64!",
+	}
+
+	keys := account.DataKeys()
+	if len(keys) != 3 || keys[0] != "bad" || keys[1] != "color" || keys[2] != "name" {
+		t.Errorf("wrong data keys: %v", keys)
+	}
+
+	if v, ok := account.GetData("name"); !ok || string(v) != "mohit" {
+		t.Errorf("wrong data for name: %q, %v", v, ok)
+	}
+
+	if _, ok := account.GetData("missing"); ok {
+		t.Errorf("GetData should not find missing keys")
+	}
+
+	if _, ok := account.GetData("bad"); ok {
+		t.Errorf("GetData should not return undecodable values")
+	}
+
+	if keys := newAccount().DataKeys(); keys == nil || len(keys) != 0 {
+		t.Errorf("want no data keys, got %v", keys)
+	}
+}
+
+func TestIsAuthorized(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100", "balances": [
+			{"balance": "10.0000000", "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "USD",
+			 "asset_issuer": "%s", "is_authorized": true, "is_authorized_to_maintain_liabilities": true},
+			{"balance": "5.0000000", "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "EUR",
+			 "asset_issuer": "%s", "is_authorized": false, "is_authorized_to_maintain_liabilities": true},
+			{"balance": "100.0000000", "asset_type": "native"}]}`, address, address, issuer, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	account, err := ms.LoadAccount(address)
+	if err != nil {
+		t.Fatalf("LoadAccount failed: %v", ErrorString(err))
+	}
+
+	USD := NewAsset("USD", issuer, Credit4Type)
+	EUR := NewAsset("EUR", issuer, Credit4Type)
+	INR := NewAsset("INR", issuer, Credit4Type)
+
+	if !account.IsAuthorized(USD) || !account.IsAuthorizedToMaintainLiabilities(USD) {
+		t.Errorf("want USD authorized")
+	}
+
+	if account.IsAuthorized(EUR) || !account.IsAuthorizedToMaintainLiabilities(EUR) {
+		t.Errorf("want EUR authorized to maintain liabilities only")
+	}
+
+	if account.IsAuthorized(INR) || account.IsAuthorizedToMaintainLiabilities(INR) {
+		t.Errorf("want INR unauthorized, account doesn't hold it")
+	}
+
+	if account.GetBalance(USD) != "10.0000000" || account.GetNativeBalance() != "100.0000000" {
+		t.Errorf("unexpected balances: %+v", account.Balances)
+	}
+}
+
+func TestMinimumBalance(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100", "subentry_count": 3,
+			"num_sponsoring": 2, "num_sponsored": 1, "balances": [
+			{"balance": "10.0000000", "buying_liabilities": "0.0000000", "selling_liabilities": "1.5000000",
+			 "asset_type": "native"}]}`, address, address)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	account, err := ms.LoadAccount(address)
+	if err != nil {
+		t.Fatalf("LoadAccount failed: %v", ErrorString(err))
+	}
+
+	// (2 + 3 subentries + 2 sponsoring - 1 sponsored) * 0.5
+	if got := account.MinimumBalance(); got != "3.0000000" {
+		t.Errorf("wrong minimum balance: want 3.0000000, got %s", got)
+	}
+
+	// 10 - 3 - 1.5 selling liabilities
+	if got := account.SpendableBalance(); got != "5.5000000" {
+		t.Errorf("wrong spendable balance: want 5.5000000, got %s", got)
+	}
+
+	empty := &Account{}
+	if got := empty.MinimumBalance(); got != "1.0000000" {
+		t.Errorf("wrong minimum balance: want 1.0000000, got %s", got)
+	}
+
+	if got := empty.SpendableBalance(); got != "0.0000000" {
+		t.Errorf("wrong spendable balance: want 0.0000000, got %s", got)
+	}
+}
+
+func TestAccountSigners(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	cosigner := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	hashX := "XDRPF6NZRR7EEVO7ESIWUDXHAOMM2QSKIQQBJK6I2FB7YKDZES5UCLWD"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100",
+			"thresholds": {"low_threshold": 1, "med_threshold": 2, "high_threshold": 3},
+			"signers": [
+				{"public_key": "%s", "key": "%s", "weight": 1, "type": "ed25519_public_key"},
+				{"public_key": "%s", "key": "%s", "weight": 2, "type": "ed25519_public_key"},
+				{"key": "%s", "weight": 1, "type": "sha256_hash"}]}`,
+			address, address, cosigner, cosigner, address, address, hashX)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	account, err := ms.LoadAccount(address)
+	if err != nil {
+		t.Fatalf("LoadAccount failed: %v", ErrorString(err))
+	}
+
+	signers := account.GetSigners()
+	if len(signers) != 3 {
+		t.Fatalf("want 3 signers, got %d", len(signers))
+	}
+
+	if signers[1].Key != address || signers[1].Weight != 2 || signers[1].Type != SignerTypeEd25519 {
+		t.Errorf("unexpected master signer: %+v", signers[1])
+	}
+
+	if signers[2].Key != hashX || signers[2].Type != SignerTypeHashX {
+		t.Errorf("unexpected hash(x) signer: %+v", signers[2])
+	}
+
+	signers[0].Weight = 100
+	if account.Signers[0].Weight == 100 {
+		t.Errorf("GetSigners should return a copy")
+	}
+
+	if low, medium, high := account.GetThresholds(); low != 1 || medium != 2 || high != 3 {
+		t.Errorf("want thresholds 1, 2, 3, got %d, %d, %d", low, medium, high)
+	}
 }
@@ -1,8 +1,11 @@
 package microstellar
 
 import (
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/stellar/go/build"
+	"github.com/stellar/go/xdr"
 )
 
 // AssetType represents an asset type on the stellar network.
@@ -28,6 +31,20 @@ type Asset struct {
 // NativeAsset is a convenience const representing a native asset.
 var NativeAsset = &Asset{"XLM", "", NativeType}
 
+// newAssetFromXDR converts an XDR asset into an Asset.
+func newAssetFromXDR(a xdr.Asset) (*Asset, error) {
+	var assetType, code, issuer string
+	if err := a.Extract(&assetType, &code, &issuer); err != nil {
+		return nil, errors.Wrap(err, "invalid asset")
+	}
+
+	if AssetType(assetType) == NativeType {
+		return NativeAsset, nil
+	}
+
+	return NewAsset(code, issuer, AssetType(assetType)), nil
+}
+
 // NewAsset creates a new asset with the given code, issuer, and assetType. assetType
 // can be one of: NativeType, Credit4Type, or Credit12Type.
 //
@@ -39,6 +56,54 @@ func NewAsset(code string, issuer string, assetType AssetType) *Asset {
 	return &Asset{code, issuer, assetType}
 }
 
+// NewCreditAsset creates a new credit asset with the given code, issuer, and assetType (Credit4Type
+// or Credit12Type), and validates it. Unlike NewAsset, it returns an error if issuer isn't a valid
+// address, or code isn't 1 to 4 (for Credit4Type) or 5 to 12 (for Credit12Type) letters and digits.
+// Use this for assets built from user input.
+//
+//   USD, err := microstellar.NewCreditAsset("USD", "issuer_address", microstellar.Credit4Type)
+func NewCreditAsset(code string, issuer string, assetType AssetType) (*Asset, error) {
+	if assetType != Credit4Type && assetType != Credit12Type {
+		return nil, errors.Errorf("invalid credit asset type: %q", assetType)
+	}
+
+	if err := ValidAddress(issuer); err != nil {
+		return nil, errors.Errorf("invalid issuer: %s", issuer)
+	}
+
+	for _, c := range code {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return nil, errors.Errorf("invalid asset code: %q: must be letters and digits", code)
+		}
+	}
+
+	asset := NewAsset(code, issuer, assetType)
+	if err := asset.Validate(); err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// NewAssetAuto creates a new credit asset with the given code and issuer, picking Credit4Type or
+// Credit12Type based on the length of the code. Returns an error if the asset is invalid.
+//
+//   EURT, err := microstellar.NewAssetAuto("EURT", "issuer_address")   // Credit4Type
+//   BTCLN, err := microstellar.NewAssetAuto("BTCLN", "issuer_address") // Credit12Type
+func NewAssetAuto(code string, issuer string) (*Asset, error) {
+	assetType := Credit4Type
+	if len(code) > 4 {
+		assetType = Credit12Type
+	}
+
+	asset := NewAsset(code, issuer, assetType)
+	if err := asset.Validate(); err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
 // Equals returns true if "this" and "that" represent the same asset class.
 func (this Asset) Equals(that Asset) bool {
 	// For native assets, don't compare code or issuer
@@ -54,12 +119,21 @@ func (asset Asset) IsNative() bool {
 	return asset.Type == NativeType
 }
 
-// Validate returns error if the asset is not valid.
+// Validate returns error if the asset is not valid. Credit4Type codes must have 1 to 4 characters,
+// and Credit12Type codes 5 to 12 characters.
 func (asset Asset) Validate() error {
-	if asset.Type == Credit4Type && len(asset.Code) > 4 {
-		return errors.Errorf("invalid: Credit4Type assets must not have more than 4 characters")
-	} else if asset.Type == Credit12Type && len(asset.Code) > 12 {
-		return errors.Errorf("invalid: Credit12Type assets must not have more than 12 characters")
+	switch asset.Type {
+	case NativeType:
+	case Credit4Type:
+		if len(asset.Code) < 1 || len(asset.Code) > 4 {
+			return errors.Errorf("invalid: Credit4Type assets must have 1 to 4 characters: %q", asset.Code)
+		}
+	case Credit12Type:
+		if len(asset.Code) < 5 || len(asset.Code) > 12 {
+			return errors.Errorf("invalid: Credit12Type assets must have 5 to 12 characters: %q", asset.Code)
+		}
+	default:
+		return errors.Errorf("invalid asset type: %q", asset.Type)
 	}
 
 	if !asset.IsNative() && !ValidAddressOrSeed(asset.Issuer) {
@@ -69,6 +143,16 @@ func (asset Asset) Validate() error {
 	return nil
 }
 
+// String returns the canonical string representation of the asset: "native" for native
+// assets, and "CODE:ISSUER" for credit assets.
+func (asset Asset) String() string {
+	if asset.IsNative() {
+		return string(NativeType)
+	}
+
+	return asset.Code + ":" + asset.Issuer
+}
+
 // ToStellarAsset returns a stellar-go Asset from this one.
 func (asset Asset) ToStellarAsset() build.Asset {
 	if asset.IsNative() {
@@ -77,3 +161,17 @@ func (asset Asset) ToStellarAsset() build.Asset {
 
 	return build.CreditAsset(asset.Code, asset.Issuer)
 }
+
+// parseCanonicalAsset parses the canonical string representation of an asset (see String.)
+func parseCanonicalAsset(s string) (*Asset, error) {
+	if s == string(NativeType) {
+		return NativeAsset, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, errors.Errorf("invalid asset: %s", s)
+	}
+
+	return NewAssetAuto(parts[0], parts[1])
+}
@@ -37,3 +37,104 @@ func TestAssetTypes(t *testing.T) {
 		t.Errorf("asset.Validate() error: %v", err)
 	}
 }
+
+func TestAssetCodeLength(t *testing.T) {
+	issuer := "GDUAQWGIKQFET4BEUEA3ZUJ6WOBT3KCMZ7UG35UL5R37C5RIFQEAEZJ3"
+	tests := []struct {
+		code      string
+		assetType AssetType
+		valid     bool
+	}{
+		{"Q", Credit4Type, true},
+		{"QBIT", Credit4Type, true},
+		{"", Credit4Type, false},
+		{"QBITS", Credit4Type, false},
+		{"QBITS", Credit12Type, true},
+		{"QBITQBITQBIT", Credit12Type, true},
+		{"QBIT", Credit12Type, false},
+		{"QBITQBITQBITQ", Credit12Type, false},
+		{"QBIT", AssetType("credit_alphanum8"), false},
+	}
+
+	for _, test := range tests {
+		err := NewAsset(test.code, issuer, test.assetType).Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%q (%s): want valid=%v, got %v", test.code, test.assetType, test.valid, err)
+		}
+	}
+
+	if err := NativeAsset.Validate(); err != nil {
+		t.Errorf("NativeAsset.Validate() error: %v", err)
+	}
+
+	autoTests := []struct {
+		code      string
+		assetType AssetType
+	}{
+		{"USD", Credit4Type},
+		{"EURT", Credit4Type},
+		{"BTCLN", Credit12Type},
+		{"QBITQBITQBIT", Credit12Type},
+	}
+
+	for _, test := range autoTests {
+		asset, err := NewAssetAuto(test.code, issuer)
+		if err != nil || asset.Type != test.assetType || asset.Code != test.code {
+			t.Errorf("NewAssetAuto(%q): want %s, got %+v: %v", test.code, test.assetType, asset, err)
+		}
+	}
+
+	for _, code := range []string{"", "QBITQBITQBITQ"} {
+		if _, err := NewAssetAuto(code, issuer); err == nil {
+			t.Errorf("NewAssetAuto(%q): want error", code)
+		}
+	}
+
+	if _, err := NewAssetAuto("USD", "ISSUER"); err == nil {
+		t.Errorf("NewAssetAuto: want error for bad issuer")
+	}
+}
+
+func TestNewCreditAsset(t *testing.T) {
+	issuer := "GDUAQWGIKQFET4BEUEA3ZUJ6WOBT3KCMZ7UG35UL5R37C5RIFQEAEZJ3"
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+
+	tests := []struct {
+		code      string
+		issuer    string
+		assetType AssetType
+		valid     bool
+	}{
+		{"USD", issuer, Credit4Type, true},
+		{"BTCLN", issuer, Credit12Type, true},
+		{"USD", issuer, Credit12Type, false},
+		{"BTCLN", issuer, Credit4Type, false},
+		{"XLM", issuer, NativeType, false},
+		{"US$", issuer, Credit4Type, false},
+		{"USD", "ISSUER", Credit4Type, false},
+		{"USD", seed, Credit4Type, false},
+	}
+
+	for _, test := range tests {
+		asset, err := NewCreditAsset(test.code, test.issuer, test.assetType)
+		if (err == nil) != test.valid {
+			t.Errorf("NewCreditAsset(%q, %s, %s): want valid=%v, got %v", test.code, test.issuer, test.assetType, test.valid, err)
+			continue
+		}
+
+		if err == nil && !asset.Equals(*NewAsset(test.code, test.issuer, test.assetType)) {
+			t.Errorf("NewCreditAsset(%q): got %+v", test.code, asset)
+		}
+	}
+}
+
+func TestAssetString(t *testing.T) {
+	if s := NativeAsset.String(); s != "native" {
+		t.Errorf("wrong native asset string: want %v, got %v", "native", s)
+	}
+
+	issuer := "GDUAQWGIKQFET4BEUEA3ZUJ6WOBT3KCMZ7UG35UL5R37C5RIFQEAEZJ3"
+	if s := NewAsset("QBIT", issuer, Credit4Type).String(); s != "QBIT:"+issuer {
+		t.Errorf("wrong credit asset string: want %v, got %v", "QBIT:"+issuer, s)
+	}
+}
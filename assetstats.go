@@ -0,0 +1,84 @@
+package microstellar
+
+// AssetStat is a summary of an asset issued on the network.
+type AssetStat struct {
+	Asset *Asset `json:"asset"`
+	PT    string `json:"paging_token"` // use with WithCursor to fetch the next page
+
+	// NumAccounts is the number of accounts with authorized trustlines to the asset, and Amount
+	// the total amount they hold.
+	NumAccounts int32  `json:"num_accounts"`
+	Amount      string `json:"amount"`
+
+	// Flags are the issuer's auth flags (see AssetInfo.)
+	Flags Flags `json:"flags"`
+}
+
+// horizonAssetStat is a Horizon asset record. The vendored Horizon client can't load assets.
+type horizonAssetStat struct {
+	PT          string `json:"paging_token"`
+	AssetType   string `json:"asset_type"`
+	AssetCode   string `json:"asset_code"`
+	AssetIssuer string `json:"asset_issuer"`
+	NumAccounts int32  `json:"num_accounts"`
+	Amount      string `json:"amount"`
+	Flags       Flags  `json:"flags"`
+}
+
+type horizonAssetStatsPage struct {
+	Embedded struct {
+		Records []horizonAssetStat `json:"records"`
+	} `json:"_embedded"`
+}
+
+// LoadAssets returns the assets issued on the network, with the number of accounts that hold
+// each one, the total amount held, and the issuer's auth flags. Use WithAssetCode and
+// WithAssetIssuer to filter the results, and WithLimit, WithCursor and WithSortOrder to page
+// through them; the PT field of the last asset is the cursor for the next page. Returns an empty
+// slice if there are no matching assets.
+//
+//   stats, err := ms.LoadAssets(microstellar.Opts().WithAssetCode("USD").WithLimit(100))
+//   for _, s := range stats {
+//       log.Printf("%s: %d accounts hold %s", s.Asset.Issuer, s.NumAccounts, s.Amount)
+//   }
+func (ms *MicroStellar) LoadAssets(options ...*Options) ([]AssetStat, error) {
+	opt := mergeOptions(options)
+	query := pageQuery(opt)
+
+	if opt.assetCode != "" {
+		if len(opt.assetCode) > 12 {
+			return nil, ms.errorf("can't load assets: invalid asset code: %s", opt.assetCode)
+		}
+		query.Set("asset_code", opt.assetCode)
+	}
+
+	if opt.assetIssuer != "" {
+		if err := ValidAddress(opt.assetIssuer); err != nil {
+			return nil, ms.errorf("can't load assets: invalid issuer: %s", opt.assetIssuer)
+		}
+		query.Set("asset_issuer", opt.assetIssuer)
+	}
+
+	ms.debugf("LoadAssets", "loading assets with params %+v", query)
+	if ms.fake {
+		return []AssetStat{}, ms.success()
+	}
+
+	var page horizonAssetStatsPage
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), "/assets?"+query.Encode(), &page); err != nil {
+		return nil, ms.wrapf(err, "can't load assets")
+	}
+
+	stats := make([]AssetStat, len(page.Embedded.Records))
+	for i, hs := range page.Embedded.Records {
+		stats[i] = AssetStat{
+			Asset:       effectAsset(hs.AssetType, hs.AssetCode, hs.AssetIssuer),
+			PT:          hs.PT,
+			NumAccounts: hs.NumAccounts,
+			Amount:      hs.Amount,
+			Flags:       hs.Flags,
+		}
+	}
+
+	return stats, ms.success()
+}
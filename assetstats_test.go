@@ -0,0 +1,54 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadAssets(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fmt.Fprintf(w, `{"_embedded": {"records": [
+			{"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%s",
+			 "paging_token": "USD_%s_credit_alphanum4", "amount": "1000.0000000", "num_accounts": 42,
+			 "flags": {"auth_required": true, "auth_revocable": false}}]}}`, issuer, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	stats, err := ms.LoadAssets(Opts().WithAssetCode("USD").WithAssetIssuer(issuer).WithCursor("abc").WithLimit(10))
+	if err != nil {
+		t.Fatalf("LoadAssets failed: %v", ErrorString(err))
+	}
+
+	want := "asset_code=USD&asset_issuer=" + issuer + "&cursor=abc&limit=10&order=asc"
+	if query != want {
+		t.Errorf("unexpected query: %s", query)
+	}
+
+	if len(stats) != 1 {
+		t.Fatalf("want 1 asset, got %+v", stats)
+	}
+
+	s := stats[0]
+	if !s.Asset.Equals(*NewAsset("USD", issuer, Credit4Type)) || s.NumAccounts != 42 || s.Amount != "1000.0000000" {
+		t.Errorf("unexpected asset stat: %+v", s)
+	}
+
+	if !s.Flags.AuthRequired || s.Flags.AuthRevocable || s.PT != "USD_"+issuer+"_credit_alphanum4" {
+		t.Errorf("unexpected flags or paging token: %+v", s)
+	}
+
+	if _, err := ms.LoadAssets(Opts().WithAssetIssuer("BAD")); err == nil {
+		t.Errorf("want error for bad issuer")
+	}
+
+	if _, err := ms.LoadAssets(Opts().WithAssetCode("WAYTOOLONGCODE")); err == nil {
+		t.Errorf("want error for bad asset code")
+	}
+}
@@ -0,0 +1,235 @@
+package microstellar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxOpsPerTx is the maximum number of operations allowed in a single transaction.
+const maxOpsPerTx = 100
+
+// PaymentRequest is a single payment in a batch. Target can be an address or a federated
+// address (e.g., "bob*qubit.sh".)
+type PaymentRequest struct {
+	Target string
+	Amount string
+	Asset  *Asset
+}
+
+// PaymentResult is the outcome of a single PaymentRequest in a batch. Address is the resolved
+// account ID of the target, and Err is set if the payment could not be resolved or submitted.
+type PaymentResult struct {
+	Request PaymentRequest
+	Address string
+	Err     error
+}
+
+// BatchError is returned by PayMany when one of its transactions fails. Transactions before
+// Batch were submitted successfully, and the rest were not sent.
+type BatchError struct {
+	Batch int // index of the failed transaction
+	First int // index of the first payment in the failed transaction
+	Last  int // index of the last payment in the failed transaction
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("transaction %d (payments %d to %d) failed: %v", e.Batch, e.First, e.Last, e.Err)
+}
+
+// PayMany pays every entry in payments from sourceSeed, packing up to 100 payments into each
+// transaction. Targets must be addresses (use PayBatchFederated for federated addresses.) All
+// payments are validated before anything is submitted.
+//
+// Each entry is a PaymentRequest (the target, amount, and asset), shared with PayBatchFederated. It
+// isn't called Payment because that's the type WatchPayments streams payments from the ledger in.
+//
+// The transactions are submitted in order, and PayMany stops at the first one that fails,
+// returning a *BatchError that says which payments were in it.
+//
+//   err := ms.PayMany("source_seed", []microstellar.PaymentRequest{
+//       {Target: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", Amount: "10", Asset: USD},
+//       {Target: "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A", Amount: "10", Asset: USD},
+//   })
+//
+//   if batchErr, ok := errors.Cause(err).(*microstellar.BatchError); ok {
+//       log.Printf("payments %d onwards were not sent: %v", batchErr.First, batchErr.Err)
+//   }
+func (ms *MicroStellar) PayMany(sourceSeed string, payments []PaymentRequest, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't pay: invalid source address or seed: %s", sourceSeed)
+	}
+
+	for i, payment := range payments {
+		if err := ValidAddress(payment.Target); err != nil {
+			return ms.errorf("can't pay: payment %d: invalid address: %s", i, payment.Target)
+		}
+
+		if err := payment.Asset.Validate(); err != nil {
+			return ms.wrapf(err, "can't pay: payment %d", i)
+		}
+
+		if err := ValidAmount(payment.Amount); err != nil {
+			return ms.wrapf(err, "can't pay: payment %d", i)
+		}
+	}
+
+	opts := mergeOptions(options)
+	for start := 0; start < len(payments); start += maxOpsPerTx {
+		end := start + maxOpsPerTx
+		if end > len(payments) {
+			end = len(payments)
+		}
+
+		// Start() marks its options as multi-op, so don't modify the caller's.
+		groupOpts := *opts
+		ms.Start(sourceSeed, &groupOpts)
+
+		var err error
+		for _, payment := range payments[start:end] {
+			if err = ms.Pay(sourceSeed, payment.Target, payment.Amount, payment.Asset); err != nil {
+				ms.closeTx(ms.getLastTx())
+				break
+			}
+		}
+
+		if err == nil {
+			err = ms.Submit()
+		}
+
+		if err != nil {
+			return ms.err(&BatchError{Batch: start / maxOpsPerTx, First: start, Last: end - 1, Err: err})
+		}
+	}
+
+	return ms.success()
+}
+
+// PayBatchFederated pays a batch of (possibly federated) targets from sourceSeed. Federated
+// targets are resolved to their account IDs, and any memos required by their federation servers
+// are honored.
+//
+// Payments that don't need a memo are grouped into multi-op transactions (of up to 100 payments
+// each), while payments with a federation memo are each sent in their own transaction. Failures
+// are reported per entry in the returned results, and don't stop the rest of the batch. The
+// returned error is set if any payment failed.
+//
+//   results, err := ms.PayBatchFederated("source_seed", []microstellar.PaymentRequest{
+//       {Target: "bob*qubit.sh", Amount: "10", Asset: USD},
+//       {Target: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", Amount: "5", Asset: USD},
+//   })
+//
+//   for _, r := range results {
+//       if r.Err != nil {
+//           log.Printf("payment to %s failed: %v", r.Request.Target, r.Err)
+//       }
+//   }
+func (ms *MicroStellar) PayBatchFederated(sourceSeed string, payments []PaymentRequest, options ...*Options) ([]PaymentResult, error) {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return nil, ms.errorf("can't pay: invalid source address or seed: %s", sourceSeed)
+	}
+
+	opts := mergeOptions(options)
+	results := make([]PaymentResult, len(payments))
+
+	// Indexes of payments that can share a transaction, and of those that need their own.
+	var plain []int
+	memoOpts := map[int]*Options{}
+
+	for i, payment := range payments {
+		results[i] = PaymentResult{Request: payment, Address: payment.Target}
+
+		if err := payment.Asset.Validate(); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		// Catch bad amounts early, so they don't fail the rest of the transaction.
+		if err := ValidAmount(payment.Amount); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		if !strings.Contains(payment.Target, "*") {
+			plain = append(plain, i)
+			continue
+		}
+
+		resp, err := ms.lookupFederated(payment.Target)
+		if err != nil {
+			results[i].Err = &FederationError{Address: payment.Target, Err: err}
+			continue
+		}
+
+		results[i].Address = resp.AccountID
+		if resp.MemoType == "" {
+			plain = append(plain, i)
+			continue
+		}
+
+		payOpts, err := withFederatedMemo(opts, resp)
+		if err != nil {
+			results[i].Err = &FederationError{Address: payment.Target, Err: err}
+			continue
+		}
+
+		ms.debugf("PayBatchFederated", "resolved %s to %s with memo", payment.Target, resp.AccountID)
+		memoOpts[i] = payOpts
+	}
+
+	for start := 0; start < len(plain); start += maxOpsPerTx {
+		end := start + maxOpsPerTx
+		if end > len(plain) {
+			end = len(plain)
+		}
+
+		ms.payGroup(sourceSeed, payments, plain[start:end], results, opts)
+	}
+
+	for i := range payments {
+		if payOpts, ok := memoOpts[i]; ok {
+			results[i].Err = ms.Pay(sourceSeed, results[i].Address, payments[i].Amount, payments[i].Asset, payOpts)
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return results, ms.errorf("%d of %d payments failed", failed, len(payments))
+	}
+
+	return results, ms.success()
+}
+
+// payGroup submits the payments at indexes in a single multi-op transaction, and records the
+// outcome in results.
+func (ms *MicroStellar) payGroup(sourceSeed string, payments []PaymentRequest, indexes []int, results []PaymentResult, opts *Options) {
+	// Start() marks its options as multi-op, so don't modify the caller's.
+	groupOpts := *opts
+	ms.Start(sourceSeed, &groupOpts)
+
+	var queued []int
+	for _, i := range indexes {
+		if err := ms.Pay(sourceSeed, results[i].Address, payments[i].Amount, payments[i].Asset); err != nil {
+			results[i].Err = err
+			continue
+		}
+		queued = append(queued, i)
+	}
+
+	if len(queued) == 0 {
+		ms.closeTx(ms.getLastTx())
+		return
+	}
+
+	if err := ms.Submit(); err != nil {
+		for _, i := range queued {
+			results[i].Err = err
+		}
+	}
+}
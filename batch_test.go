@@ -0,0 +1,116 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPayBatchFederated(t *testing.T) {
+	ms := New("fake")
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	// More payments than fit in a single transaction.
+	payments := []PaymentRequest{}
+	for i := 0; i < 150; i++ {
+		payments = append(payments, PaymentRequest{Target: target, Amount: fmt.Sprintf("%d", i+1), Asset: USD})
+	}
+
+	results, err := ms.PayBatchFederated(source, payments)
+	if err != nil {
+		t.Fatalf("PayBatchFederated failed: %v", err)
+	}
+
+	for i, r := range results {
+		if r.Err != nil || r.Address != target {
+			t.Errorf("payment %d failed: %+v", i, r)
+		}
+	}
+
+	payments = []PaymentRequest{
+		{Target: target, Amount: "1", Asset: USD},
+		{Target: "bad address", Amount: "1", Asset: USD},
+		{Target: target, Amount: "bad amount", Asset: USD},
+		{Target: target, Amount: "1", Asset: NativeAsset},
+	}
+
+	results, err = ms.PayBatchFederated(source, payments)
+	if err == nil {
+		t.Errorf("PayBatchFederated should report failures")
+	}
+
+	for i, wantErr := range []bool{false, true, true, false} {
+		if (results[i].Err != nil) != wantErr {
+			t.Errorf("payment %d: want error %v, got: %v", i, wantErr, results[i].Err)
+		}
+	}
+}
+
+func TestPayMany(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	targets := []string{
+		"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+		"GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A",
+	}
+
+	payments := make([]PaymentRequest, 250)
+	for i := range payments {
+		payments[i] = PaymentRequest{Target: targets[i%2], Amount: "1", Asset: NativeAsset}
+	}
+
+	// Fail the second transaction.
+	var opCounts []int
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		txe, err := DecodeTx(r.FormValue("tx"))
+		if err != nil {
+			t.Errorf("bad transaction: %v", err)
+		} else {
+			opCounts = append(opCounts, len(txe.Tx.Operations))
+		}
+
+		if len(opCounts) == 2 {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type": "transaction_failed", "title": "Transaction Failed", "status": 400,
+				"extras": {"result_codes": {"transaction": "tx_insufficient_balance"}}}`)
+			return
+		}
+
+		fmt.Fprint(w, testTxSuccess)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	err := ms.PayMany(source, payments)
+
+	batchErr, ok := errors.Cause(err).(*BatchError)
+	if !ok {
+		t.Fatalf("want BatchError, got: %v", err)
+	}
+
+	if batchErr.Batch != 1 || batchErr.First != 100 || batchErr.Last != 199 {
+		t.Errorf("wrong batch error: %v", batchErr)
+	}
+
+	if len(opCounts) != 2 || opCounts[0] != 100 || opCounts[1] != 100 {
+		t.Errorf("wrong transactions submitted: %v", opCounts)
+	}
+
+	// Everything succeeds on the fake network.
+	fake := New("fake")
+	if err := fake.PayMany(source, payments); err != nil {
+		t.Errorf("PayMany failed: %v", err)
+	}
+
+	// Invalid payments fail before anything is submitted.
+	opCounts = nil
+	payments[249].Amount = "lots"
+	if err := ms.PayMany(source, payments); err == nil || len(opCounts) != 0 {
+		t.Errorf("PayMany should fail validation without submitting: %v, %v", err, opCounts)
+	}
+}
+
@@ -0,0 +1,431 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ClaimPredicateType is the type of a claim predicate.
+type ClaimPredicateType int
+
+// Claim predicate types (these match the XDR values.)
+const (
+	PredicateTypeUnconditional      = ClaimPredicateType(0)
+	PredicateTypeAnd                = ClaimPredicateType(1)
+	PredicateTypeOr                 = ClaimPredicateType(2)
+	PredicateTypeNot                = ClaimPredicateType(3)
+	PredicateTypeBeforeAbsoluteTime = ClaimPredicateType(4)
+	PredicateTypeBeforeRelativeTime = ClaimPredicateType(5)
+)
+
+// Claimable balance limits and XDR operation types.
+const (
+	maxClaimants             = 10
+	opCreateClaimableBalance = 14
+	opClaimClaimableBalance  = 15
+)
+
+// ClaimPredicate is a condition that must hold for a claimant to claim a balance. Use
+// the Predicate* functions to build them.
+type ClaimPredicate struct {
+	Type ClaimPredicateType
+
+	// PredicateTypeAnd and PredicateTypeOr have two predicates, PredicateTypeNot has one.
+	Predicates []*ClaimPredicate
+
+	// PredicateTypeBeforeAbsoluteTime
+	Before time.Time
+
+	// PredicateTypeBeforeRelativeTime (relative to the close time of the ledger that
+	// creates the balance.)
+	Within time.Duration
+}
+
+// Claimant is an account that can claim a claimable balance, once its predicate holds. A
+// nil predicate is unconditional.
+type Claimant struct {
+	Destination string
+	Predicate   *ClaimPredicate
+}
+
+// PredicateUnconditional returns a predicate that always holds.
+func PredicateUnconditional() *ClaimPredicate {
+	return &ClaimPredicate{Type: PredicateTypeUnconditional}
+}
+
+// PredicateBeforeAbsoluteTime returns a predicate that holds until time t.
+func PredicateBeforeAbsoluteTime(t time.Time) *ClaimPredicate {
+	return &ClaimPredicate{Type: PredicateTypeBeforeAbsoluteTime, Before: t}
+}
+
+// PredicateBeforeRelativeTime returns a predicate that holds until d has elapsed since the
+// balance was created.
+func PredicateBeforeRelativeTime(d time.Duration) *ClaimPredicate {
+	return &ClaimPredicate{Type: PredicateTypeBeforeRelativeTime, Within: d}
+}
+
+// PredicateAfterAbsoluteTime returns a predicate that holds from time t onwards.
+func PredicateAfterAbsoluteTime(t time.Time) *ClaimPredicate {
+	return PredicateNot(PredicateBeforeAbsoluteTime(t))
+}
+
+// PredicateAfterRelativeTime returns a predicate that holds once d has elapsed since the
+// balance was created.
+//
+//   // Claimable by Bob only after 24 hours.
+//   microstellar.Claimant{Destination: bob, Predicate: microstellar.PredicateAfterRelativeTime(24 * time.Hour)}
+func PredicateAfterRelativeTime(d time.Duration) *ClaimPredicate {
+	return PredicateNot(PredicateBeforeRelativeTime(d))
+}
+
+// PredicateAnd returns a predicate that holds when both a and b hold.
+func PredicateAnd(a, b *ClaimPredicate) *ClaimPredicate {
+	return &ClaimPredicate{Type: PredicateTypeAnd, Predicates: []*ClaimPredicate{a, b}}
+}
+
+// PredicateOr returns a predicate that holds when either a or b holds.
+func PredicateOr(a, b *ClaimPredicate) *ClaimPredicate {
+	return &ClaimPredicate{Type: PredicateTypeOr, Predicates: []*ClaimPredicate{a, b}}
+}
+
+// PredicateNot returns a predicate that holds when p doesn't.
+func PredicateNot(p *ClaimPredicate) *ClaimPredicate {
+	return &ClaimPredicate{Type: PredicateTypeNot, Predicates: []*ClaimPredicate{p}}
+}
+
+// Validate returns an error if the predicate is malformed.
+func (p *ClaimPredicate) Validate() error {
+	if p == nil {
+		return errors.Errorf("missing predicate")
+	}
+
+	switch p.Type {
+	case PredicateTypeUnconditional:
+	case PredicateTypeAnd, PredicateTypeOr:
+		if len(p.Predicates) != 2 {
+			return errors.Errorf("and/or predicates need two predicates, got %d", len(p.Predicates))
+		}
+	case PredicateTypeNot:
+		if len(p.Predicates) != 1 {
+			return errors.Errorf("not predicates need one predicate, got %d", len(p.Predicates))
+		}
+	case PredicateTypeBeforeAbsoluteTime:
+		if p.Before.IsZero() {
+			return errors.Errorf("missing absolute time")
+		}
+	case PredicateTypeBeforeRelativeTime:
+		if p.Within < 0 {
+			return errors.Errorf("negative relative time: %v", p.Within)
+		}
+	default:
+		return errors.Errorf("unknown predicate type: %d", p.Type)
+	}
+
+	for _, child := range p.Predicates {
+		if err := child.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encode writes the XDR ClaimPredicate union.
+func (p *ClaimPredicate) encode(w *xdrWriter) {
+	w.uint32(uint32(p.Type))
+
+	switch p.Type {
+	case PredicateTypeAnd, PredicateTypeOr:
+		w.uint32(uint32(len(p.Predicates)))
+		for _, child := range p.Predicates {
+			child.encode(w)
+		}
+	case PredicateTypeNot:
+		// Optional predicate; always present.
+		w.uint32(1)
+		p.Predicates[0].encode(w)
+	case PredicateTypeBeforeAbsoluteTime:
+		w.int64(p.Before.Unix())
+	case PredicateTypeBeforeRelativeTime:
+		w.int64(int64(p.Within / time.Second))
+	}
+}
+
+// createClaimableBalanceOp returns the raw XDR body of a CREATE_CLAIMABLE_BALANCE operation.
+func createClaimableBalanceOp(asset *Asset, amount int64, claimants []Claimant) ([]byte, error) {
+	w := &xdrWriter{}
+	w.uint32(opCreateClaimableBalance)
+	w.asset(asset)
+	w.int64(amount)
+
+	w.uint32(uint32(len(claimants)))
+	for _, c := range claimants {
+		predicate := c.Predicate
+		if predicate == nil {
+			predicate = PredicateUnconditional()
+		}
+
+		// CLAIMANT_TYPE_V0
+		w.uint32(0)
+		w.account(c.Destination)
+		predicate.encode(w)
+	}
+
+	return w.bytes()
+}
+
+// CreateClaimableBalance sends amount of asset from sourceSeed to a new claimable balance, which
+// any of the claimants can claim once their predicate holds. Claimants don't need to have a
+// trustline to the asset until they claim the balance. Up to 10 claimants are allowed.
+//
+//   // Claimable by Bob only after 24 hours, and by Alice (e.g., to reclaim it) any time.
+//   err := ms.CreateClaimableBalance(aliceSeed, microstellar.NativeAsset, "10", []microstellar.Claimant{
+//       {Destination: bob, Predicate: microstellar.PredicateAfterRelativeTime(24 * time.Hour)},
+//       {Destination: alice, Predicate: microstellar.PredicateUnconditional()},
+//   })
+//
+// The vendored XDR library doesn't support claimable balances, so these transactions are encoded
+// by hand, and can't be decoded with DecodeTx or InspectTransaction.
+func (ms *MicroStellar) CreateClaimableBalance(sourceSeed string, asset *Asset, amount string, claimants []Claimant, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't create claimable balance: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if err := asset.Validate(); err != nil {
+		return ms.wrapf(err, "can't create claimable balance")
+	}
+
+	if err := ValidAmount(amount); err != nil {
+		return ms.wrapf(err, "can't create claimable balance")
+	}
+
+	stroops, _ := ParseAmount(amount)
+	if stroops == 0 {
+		return ms.errorf("can't create claimable balance: amount must be positive")
+	}
+
+	if len(claimants) == 0 || len(claimants) > maxClaimants {
+		return ms.errorf("can't create claimable balance: want 1 to %d claimants, got %d", maxClaimants, len(claimants))
+	}
+
+	for _, c := range claimants {
+		if err := ValidAddress(c.Destination); err != nil {
+			return ms.errorf("can't create claimable balance: invalid claimant: %s", c.Destination)
+		}
+
+		if c.Predicate != nil {
+			if err := c.Predicate.Validate(); err != nil {
+				return ms.wrapf(err, "can't create claimable balance: invalid predicate for %s", c.Destination)
+			}
+		}
+	}
+
+	body, err := createClaimableBalanceOp(asset, stroops, claimants)
+	if err != nil {
+		return ms.wrapf(err, "can't create claimable balance")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), tx.rawOp("", body))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// ClaimableBalance is a claimable balance, as returned by LoadClaimableBalances.
+type ClaimableBalance struct {
+	ID                 string     `json:"id"`
+	PT                 string     `json:"paging_token"` // use with WithCursor to fetch the next page
+	Asset              *Asset     `json:"asset"`
+	Amount             string     `json:"amount"`
+	Sponsor            string     `json:"sponsor"`
+	LastModifiedLedger int32      `json:"last_modified_ledger"`
+	Claimants          []Claimant `json:"claimants"`
+}
+
+// horizonPredicate is a claim predicate in a Horizon claimable balance record.
+type horizonPredicate struct {
+	Unconditional bool               `json:"unconditional"`
+	And           []horizonPredicate `json:"and"`
+	Or            []horizonPredicate `json:"or"`
+	Not           *horizonPredicate  `json:"not"`
+	AbsBefore     *time.Time         `json:"abs_before"`
+	RelBefore     string             `json:"rel_before"` // seconds
+}
+
+type horizonClaimableBalance struct {
+	ID                 string `json:"id"`
+	PT                 string `json:"paging_token"`
+	Asset              string `json:"asset"`
+	Amount             string `json:"amount"`
+	Sponsor            string `json:"sponsor"`
+	LastModifiedLedger int32  `json:"last_modified_ledger"`
+	Claimants          []struct {
+		Destination string           `json:"destination"`
+		Predicate   horizonPredicate `json:"predicate"`
+	} `json:"claimants"`
+}
+
+type horizonClaimableBalancesPage struct {
+	Embedded struct {
+		Records []horizonClaimableBalance `json:"records"`
+	} `json:"_embedded"`
+}
+
+// newPredicateFromHorizon converts a Horizon claim predicate into a ClaimPredicate.
+func newPredicateFromHorizon(hp horizonPredicate) (*ClaimPredicate, error) {
+	switch {
+	case len(hp.And) == 2:
+		a, err := newPredicateFromHorizon(hp.And[0])
+		if err != nil {
+			return nil, err
+		}
+		b, err := newPredicateFromHorizon(hp.And[1])
+		return PredicateAnd(a, b), err
+	case len(hp.Or) == 2:
+		a, err := newPredicateFromHorizon(hp.Or[0])
+		if err != nil {
+			return nil, err
+		}
+		b, err := newPredicateFromHorizon(hp.Or[1])
+		return PredicateOr(a, b), err
+	case hp.Not != nil:
+		p, err := newPredicateFromHorizon(*hp.Not)
+		return PredicateNot(p), err
+	case hp.AbsBefore != nil:
+		return PredicateBeforeAbsoluteTime(*hp.AbsBefore), nil
+	case hp.RelBefore != "":
+		seconds, err := strconv.ParseInt(hp.RelBefore, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid relative time: %s", hp.RelBefore)
+		}
+		return PredicateBeforeRelativeTime(time.Duration(seconds) * time.Second), nil
+	case hp.Unconditional:
+		return PredicateUnconditional(), nil
+	}
+
+	return nil, errors.Errorf("unknown predicate: %+v", hp)
+}
+
+// newClaimableBalanceFromHorizon creates a new claimable balance from a Horizon record.
+func newClaimableBalanceFromHorizon(hb horizonClaimableBalance) (ClaimableBalance, error) {
+	balance := ClaimableBalance{
+		ID:                 hb.ID,
+		PT:                 hb.PT,
+		Amount:             hb.Amount,
+		Sponsor:            hb.Sponsor,
+		LastModifiedLedger: hb.LastModifiedLedger,
+	}
+
+	asset, err := parseCanonicalAsset(hb.Asset)
+	if err != nil {
+		return balance, err
+	}
+	balance.Asset = asset
+
+	for _, c := range hb.Claimants {
+		predicate, err := newPredicateFromHorizon(c.Predicate)
+		if err != nil {
+			return balance, errors.Wrapf(err, "invalid predicate for %s", c.Destination)
+		}
+
+		balance.Claimants = append(balance.Claimants, Claimant{Destination: c.Destination, Predicate: predicate})
+	}
+
+	return balance, nil
+}
+
+// parseBalanceID parses a hex-encoded claimable balance ID, as returned by Horizon. The leading
+// ID type (00000000) is optional.
+func parseBalanceID(balanceID string) ([]byte, error) {
+	if len(balanceID) == 64 {
+		balanceID = "00000000" + balanceID
+	}
+
+	id, err := hex.DecodeString(balanceID)
+	if err != nil || len(id) != 36 {
+		return nil, errors.Errorf("invalid balance ID: %s", balanceID)
+	}
+
+	if !bytes.Equal(id[:4], []byte{0, 0, 0, 0}) {
+		return nil, errors.Errorf("invalid balance ID: unsupported type: %x", id[:4])
+	}
+
+	return id, nil
+}
+
+// ClaimBalance claims the claimable balance with ID balanceID for sourceSeed, which must be one of
+// the balance's claimants, with a predicate that currently holds. Use LoadClaimableBalances to
+// find the balances available to an account. Non-native balances need a trustline to the asset.
+//
+//   err := ms.ClaimBalance(bobSeed, "00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be")
+func (ms *MicroStellar) ClaimBalance(sourceSeed string, balanceID string, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't claim balance: invalid source address or seed: %s", sourceSeed)
+	}
+
+	id, err := parseBalanceID(balanceID)
+	if err != nil {
+		return ms.wrapf(err, "can't claim balance")
+	}
+
+	w := &xdrWriter{}
+	w.uint32(opClaimClaimableBalance)
+	w.raw(id)
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), tx.rawOp("", w.buf.Bytes()))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// LoadClaimableBalances returns the claimable balances that the account at claimantAddress is a
+// claimant of. Check each claimant's predicate to see when the balance can be claimed. Use
+// WithLimit, WithCursor and WithSortOrder to page through the results. Returns an empty slice
+// if there are none.
+//
+//   balances, err := ms.LoadClaimableBalances("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A")
+//   for _, b := range balances {
+//       ms.ClaimBalance(bobSeed, b.ID)
+//   }
+func (ms *MicroStellar) LoadClaimableBalances(claimantAddress string, options ...*Options) ([]ClaimableBalance, error) {
+	if err := ValidAddress(claimantAddress); err != nil {
+		return nil, ms.errorf("invalid address: %s", claimantAddress)
+	}
+
+	opt := mergeOptions(options)
+	query := pageQuery(opt)
+	query.Set("claimant", claimantAddress)
+
+	ms.debugf("LoadClaimableBalances", "loading claimable balances with params %+v", query)
+	if ms.fake {
+		return []ClaimableBalance{}, ms.success()
+	}
+
+	var page horizonClaimableBalancesPage
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), "/claimable_balances?"+query.Encode(), &page); err != nil {
+		return nil, ms.wrapf(err, "can't load claimable balances")
+	}
+
+	balances := make([]ClaimableBalance, len(page.Embedded.Records))
+	for i, hb := range page.Embedded.Records {
+		balance, err := newClaimableBalanceFromHorizon(hb)
+		if err != nil {
+			return nil, ms.wrapf(err, "can't load claimable balances")
+		}
+		balances[i] = balance
+	}
+
+	return balances, ms.success()
+}
@@ -0,0 +1,186 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+)
+
+func TestClaimPredicateEncoding(t *testing.T) {
+	tests := []struct {
+		predicate *ClaimPredicate
+		want      string
+	}{
+		{PredicateUnconditional(), "00000000"},
+		{PredicateBeforeAbsoluteTime(time.Unix(1600000000, 0)), "00000004000000005f5e1000"},
+		{PredicateAfterRelativeTime(24 * time.Hour), "0000000300000001000000050000000000015180"},
+		{PredicateAnd(PredicateUnconditional(), PredicateBeforeRelativeTime(time.Minute)), "00000001000000020000000000000005000000000000003c"},
+	}
+
+	for i, test := range tests {
+		w := &xdrWriter{}
+		test.predicate.encode(w)
+		if got := hex.EncodeToString(w.buf.Bytes()); got != test.want {
+			t.Errorf("%d: want %s, got %s", i, test.want, got)
+		}
+	}
+
+	if err := PredicateNot(nil).Validate(); err == nil {
+		t.Errorf("nested nil predicates should be invalid")
+	}
+
+	if err := PredicateBeforeRelativeTime(-time.Hour).Validate(); err == nil {
+		t.Errorf("negative relative times should be invalid")
+	}
+}
+
+func TestCreateClaimableBalance(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	var submitted string
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		submitted = r.FormValue("tx")
+		fmt.Fprint(w, testTxSuccess)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	claimants := []Claimant{{Destination: bob, Predicate: PredicateAfterRelativeTime(24 * time.Hour)}}
+	if err := ms.CreateClaimableBalance(source, NativeAsset, "10", claimants); err != nil {
+		t.Fatalf("CreateClaimableBalance failed: %v", ErrorString(err))
+	}
+
+	envelope, err := base64.StdEncoding.DecodeString(submitted)
+	if err != nil || len(envelope) < 76 {
+		t.Fatalf("bad envelope: %q", submitted)
+	}
+
+	// The envelope ends with one decorated signature: count, hint, length, signature.
+	txBytes := envelope[:len(envelope)-76]
+	sig := envelope[len(envelope)-64:]
+	hash := rawHash(txBytes, "test")
+
+	kp, _ := keypair.Parse(source)
+	if err := kp.Verify(hash[:], sig); err != nil {
+		t.Errorf("bad signature: %v", err)
+	}
+
+	// The operation: no source account, CREATE_CLAIMABLE_BALANCE, native asset, 10 XLM, one claimant.
+	wantOp, _ := hex.DecodeString("00000000" + "0000000e" + "00000000" + "0000000005f5e100" + "00000001")
+	if !bytes.Contains(txBytes, wantOp) {
+		t.Errorf("operation not found in transaction: %x", txBytes)
+	}
+
+	if err := ms.CreateClaimableBalance(source, NativeAsset, "0", claimants); err == nil {
+		t.Errorf("zero amounts should fail")
+	}
+
+	if err := ms.CreateClaimableBalance(source, NativeAsset, "10", nil); err == nil {
+		t.Errorf("balances without claimants should fail")
+	}
+
+	if err := ms.CreateClaimableBalance(source, NativeAsset, "10", []Claimant{{Destination: "BAD"}}); err == nil {
+		t.Errorf("invalid claimants should fail")
+	}
+}
+
+func TestClaimBalance(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	balanceID := "00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be"
+
+	ms := New("fake")
+	if err := ms.ClaimBalance(source, balanceID); err != nil {
+		t.Errorf("ClaimBalance failed: %v", err)
+	}
+
+	for _, id := range []string{"", "da0d57", "00000001da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be", balanceID[:70] + "zz"} {
+		if err := ms.ClaimBalance(source, id); err == nil {
+			t.Errorf("want error for bad balance ID: %q", id)
+		}
+	}
+
+	// The type prefix is optional.
+	id, err := parseBalanceID(balanceID[8:])
+	if err != nil || hex.EncodeToString(id) != balanceID {
+		t.Errorf("want %s, got %x (%v)", balanceID, id, err)
+	}
+
+	var submitted string
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		submitted = r.FormValue("tx")
+		fmt.Fprint(w, testTxSuccess)
+	})
+	defer server.Close()
+
+	ms = New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.ClaimBalance(source, balanceID); err != nil {
+		t.Fatalf("ClaimBalance failed: %v", ErrorString(err))
+	}
+
+	envelope, _ := base64.StdEncoding.DecodeString(submitted)
+	wantOp, _ := hex.DecodeString("00000000" + "0000000f" + balanceID)
+	if !bytes.Contains(envelope, wantOp) {
+		t.Errorf("operation not found in transaction: %x", envelope)
+	}
+}
+
+func TestLoadClaimableBalances(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `{"_embedded": {"records": [{
+			"id": "00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be",
+			"paging_token": "1-00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be",
+			"asset": "USD:GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ",
+			"amount": "10.0000000",
+			"sponsor": "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+			"last_modified_ledger": 1234,
+			"claimants": [
+				{"destination": "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A", "predicate": {"not": {"rel_before": "86400"}}},
+				{"destination": "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "predicate": {"or": [
+					{"unconditional": true}, {"abs_before": "2021-01-01T00:00:00Z"}]}}
+			]}]}}`)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	balances, err := ms.LoadClaimableBalances("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A", Opts().WithLimit(5))
+	if err != nil {
+		t.Fatalf("LoadClaimableBalances failed: %v", ErrorString(err))
+	}
+
+	if query != "claimant=GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A&limit=5&order=asc" {
+		t.Errorf("unexpected query: %s", query)
+	}
+
+	if len(balances) != 1 || len(balances[0].Claimants) != 2 {
+		t.Fatalf("want 1 balance with 2 claimants, got %+v", balances)
+	}
+
+	b := balances[0]
+	if b.Amount != "10.0000000" || b.Asset.Code != "USD" || b.Asset.Type != Credit4Type || b.LastModifiedLedger != 1234 {
+		t.Errorf("unexpected balance: %+v", b)
+	}
+
+	p := b.Claimants[0].Predicate
+	if p.Type != PredicateTypeNot || p.Predicates[0].Within != 24*time.Hour {
+		t.Errorf("want not(rel_before 24h), got %+v", p)
+	}
+
+	p = b.Claimants[1].Predicate
+	if p.Type != PredicateTypeOr || p.Predicates[0].Type != PredicateTypeUnconditional || p.Predicates[1].Before.Year() != 2021 {
+		t.Errorf("want or(unconditional, abs_before 2021), got %+v", p)
+	}
+
+	if _, err := ms.LoadClaimableBalances("BAD"); err == nil {
+		t.Errorf("want error for bad address")
+	}
+}
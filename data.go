@@ -0,0 +1,236 @@
+package microstellar
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/stellar/go/build"
+)
+
+// maxDataSize is the maximum size of a data entry's key or value.
+const maxDataSize = 64
+
+// SetData lets you attach (or update) arbitrary data to an account. The lengths of the key and value must each be
+// less than 64 bytes.
+func (ms *MicroStellar) SetData(sourceSeed string, key string, val []byte, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't set thresholds: invalid source address or seed: %s", sourceSeed)
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	if key == "" {
+		return ms.errorf("data key must not be empty")
+	}
+
+	if len(key) > maxDataSize {
+		return ms.errorf("data key must be under %d bytes: %s", maxDataSize, key)
+	}
+
+	if len(val) > maxDataSize {
+		return ms.errorf("data value must be under %d bytes: %s", maxDataSize, string(val))
+	}
+
+	tx.Build(sourceAccount(sourceSeed), build.SetData(key, val))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// ClearData removes attached data from an account.
+func (ms *MicroStellar) ClearData(sourceSeed string, key string, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't set thresholds: invalid source address or seed: %s", sourceSeed)
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	if len(key) > maxDataSize {
+		return ms.errorf("data key must be under %d bytes: %s", maxDataSize, key)
+	}
+
+	tx.Build(sourceAccount(sourceSeed), build.ClearData(key))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// ClearDataError is returned by ClearAllData when one of its transactions fails. The entries in
+// Cleared were removed, and the ones in Remaining were not.
+type ClearDataError struct {
+	Cleared   []string
+	Remaining []string
+	Err       error
+}
+
+func (e *ClearDataError) Error() string {
+	return fmt.Sprintf("cleared %d of %d data entries: %v", len(e.Cleared), len(e.Cleared)+len(e.Remaining), e.Err)
+}
+
+// ClearAllData removes every data entry (see SetData) from sourceSeed's account, packing up to
+// 100 entries into each transaction. The transactions are submitted in order, and ClearAllData
+// stops at the first one that fails, returning a *ClearDataError that says which entries were
+// removed.
+//
+//   err := ms.ClearAllData("source_seed")
+//   if clearErr, ok := errors.Cause(err).(*microstellar.ClearDataError); ok {
+//       log.Printf("entries %v were not removed: %v", clearErr.Remaining, clearErr.Err)
+//   }
+func (ms *MicroStellar) ClearAllData(sourceSeed string, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't clear data: invalid source address or seed: %s", sourceSeed)
+	}
+
+	account, err := ms.LoadAccount(sourceSeed)
+	if err != nil {
+		return ms.wrapf(err, "can't clear data")
+	}
+
+	keys := make([]string, 0, len(account.Data))
+	for key := range account.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	opts := mergeOptions(options)
+	for start := 0; start < len(keys); start += maxOpsPerTx {
+		end := start + maxOpsPerTx
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		// Start() marks its options as multi-op, so don't modify the caller's.
+		groupOpts := *opts
+		ms.Start(sourceSeed, &groupOpts)
+
+		var err error
+		for _, key := range keys[start:end] {
+			if err = ms.ClearData(sourceSeed, key); err != nil {
+				ms.closeTx(ms.getLastTx())
+				break
+			}
+		}
+
+		if err == nil {
+			err = ms.Submit()
+		}
+
+		if err != nil {
+			return ms.err(&ClearDataError{Cleared: keys[:start], Remaining: keys[start:], Err: err})
+		}
+	}
+
+	ms.debugf("ClearAllData", "cleared %d data entries", len(keys))
+	return ms.success()
+}
+
+// MaxLargeDataSize is the largest value SetLargeData can store. Each 64-byte chunk takes one
+// operation, and every chunk is written in a single transaction.
+const MaxLargeDataSize = maxOpsPerTx * maxDataSize
+
+// largeDataKey returns the key of chunk i of the large data entry key.
+func largeDataKey(key string, i int) string {
+	return fmt.Sprintf("%s.%d", key, i)
+}
+
+// SetLargeData attaches val to sourceSeed's account under key, even if it's larger than the 64
+// bytes a single data entry can hold (see SetData.) The value is split into 64-byte chunks, stored
+// in the entries "key.0", "key.1", and so on, all in a single transaction. Use GetLargeData to
+// read it back.
+//
+//   err := ms.SetLargeData("source_seed", "cert", pemBytes)
+//   cert, err := ms.GetLargeData("source_address", "cert")
+//
+// Values can be at most MaxLargeDataSize (6400) bytes, because a transaction can have at most 100
+// operations. If the account has chunks left over from a longer value under the same key, they're
+// removed in the same transaction, and count towards the limit. Each chunk also raises the
+// account's minimum balance by one base reserve.
+func (ms *MicroStellar) SetLargeData(sourceSeed string, key string, val []byte, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't set large data: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if key == "" {
+		return ms.errorf("can't set large data: data key must not be empty")
+	}
+
+	if len(val) == 0 {
+		return ms.errorf("can't set large data: data value must not be empty")
+	}
+
+	if len(val) > MaxLargeDataSize {
+		return ms.errorf("can't set large data: value too large: %d bytes (max %d)", len(val), MaxLargeDataSize)
+	}
+
+	chunks := (len(val) + maxDataSize - 1) / maxDataSize
+	if len(largeDataKey(key, chunks-1)) > maxDataSize {
+		return ms.errorf("can't set large data: data key too long: %s", key)
+	}
+
+	account, err := ms.LoadAccount(sourceSeed)
+	if err != nil {
+		return ms.wrapf(err, "can't set large data")
+	}
+
+	muts := []build.TransactionMutator{}
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * maxDataSize
+		if end > len(val) {
+			end = len(val)
+		}
+		muts = append(muts, build.SetData(largeDataKey(key, i), val[i*maxDataSize:end]))
+	}
+
+	// Remove stale chunks, so GetLargeData stops at the end of the new value.
+	for i := chunks; ; i++ {
+		if _, ok := account.Data[largeDataKey(key, i)]; !ok {
+			break
+		}
+		muts = append(muts, build.ClearData(largeDataKey(key, i)))
+	}
+
+	if len(muts) > maxOpsPerTx {
+		return ms.errorf("can't set large data: %d chunks to write and %d to remove (max %d), clear %s first",
+			chunks, len(muts)-chunks, maxOpsPerTx, key)
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), muts...)
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// GetLargeData loads the account at address, and returns the value stored under key by
+// SetLargeData, reassembled from its chunks. Returns an error if the account has no chunks for key.
+func (ms *MicroStellar) GetLargeData(address string, key string) ([]byte, error) {
+	if !ValidAddressOrSeed(address) {
+		return nil, ms.errorf("can't get large data: invalid address or seed: %s", address)
+	}
+
+	account, err := ms.LoadAccount(address)
+	if err != nil {
+		return nil, ms.wrapf(err, "can't get large data")
+	}
+
+	val := []byte{}
+	for i := 0; ; i++ {
+		chunk, ok := account.GetData(largeDataKey(key, i))
+		if !ok {
+			if i == 0 {
+				return nil, ms.errorf("can't get large data: no data for key: %s", key)
+			}
+			break
+		}
+		val = append(val, chunk...)
+	}
+
+	return val, ms.success()
+}
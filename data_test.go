@@ -0,0 +1,178 @@
+package microstellar
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+)
+
+func TestClearAllData(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	address := "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R"
+
+	// More entries than fit in a single transaction.
+	data := []string{}
+	for i := 0; i < 150; i++ {
+		data = append(data, fmt.Sprintf(`"key%03d": "dmFsdWU="`, i))
+	}
+
+	var cleared []string
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100", "data": {%s}}`, address, address, strings.Join(data, ","))
+			return
+		}
+
+		if fail && len(cleared) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type": "transaction_failed", "title": "Transaction Failed", "status": 400}`)
+			return
+		}
+
+		txe, err := DecodeTx(r.FormValue("tx"))
+		if err != nil {
+			t.Errorf("bad transaction: %v", err)
+			return
+		}
+
+		for _, op := range txe.Tx.Operations {
+			mdo := op.Body.MustManageDataOp()
+			if mdo.DataValue != nil {
+				t.Errorf("want ClearData, got value for %s", mdo.DataName)
+			}
+			cleared = append(cleared, string(mdo.DataName))
+		}
+
+		fmt.Fprint(w, testTxSuccess)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.ClearAllData(source); err != nil {
+		t.Fatalf("ClearAllData failed: %v", ErrorString(err))
+	}
+
+	if len(cleared) != 150 || cleared[0] != "key000" || cleared[149] != "key149" {
+		t.Errorf("wrong entries cleared: %v", cleared)
+	}
+
+	// Fail the second transaction.
+	cleared, fail = nil, true
+	err := ms.ClearAllData(source)
+
+	clearErr, ok := errors.Cause(err).(*ClearDataError)
+	if !ok {
+		t.Fatalf("want ClearDataError, got: %v", err)
+	}
+
+	if len(clearErr.Cleared) != 100 || len(clearErr.Remaining) != 50 || clearErr.Remaining[0] != "key100" {
+		t.Errorf("wrong clear data error: %v", clearErr)
+	}
+
+	if err := New("fake").ClearAllData(source); err != nil {
+		t.Errorf("ClearAllData failed: %v", err)
+	}
+}
+
+func TestLargeData(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	address := "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R"
+
+	// Left over from a longer value.
+	data := map[string]string{
+		"cert.0": base64.StdEncoding.EncodeToString([]byte("old")),
+		"cert.1": base64.StdEncoding.EncodeToString([]byte("old")),
+		"cert.2": base64.StdEncoding.EncodeToString([]byte("old")),
+		"cert.3": base64.StdEncoding.EncodeToString([]byte("old")),
+		"other":  base64.StdEncoding.EncodeToString([]byte("keep")),
+	}
+
+	var ops []xdr.ManageDataOp
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			body, _ := json.Marshal(data)
+			fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100", "data": %s}`, address, address, body)
+			return
+		}
+
+		txe, err := DecodeTx(r.FormValue("tx"))
+		if err != nil {
+			t.Errorf("bad transaction: %v", err)
+			return
+		}
+
+		ops = nil
+		for _, op := range txe.Tx.Operations {
+			mdo := op.Body.MustManageDataOp()
+			ops = append(ops, mdo)
+			if mdo.DataValue == nil {
+				delete(data, string(mdo.DataName))
+			} else {
+				data[string(mdo.DataName)] = base64.StdEncoding.EncodeToString(*mdo.DataValue)
+			}
+		}
+
+		fmt.Fprint(w, testTxSuccess)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	val := []byte(strings.Repeat("0123456789", 13)) // 130 bytes: three chunks
+	if err := ms.SetLargeData(source, "cert", val); err != nil {
+		t.Fatalf("SetLargeData failed: %v", ErrorString(err))
+	}
+
+	if len(ops) != 4 || ops[0].DataName != "cert.0" || len(*ops[0].DataValue) != 64 ||
+		ops[2].DataName != "cert.2" || len(*ops[2].DataValue) != 2 ||
+		ops[3].DataName != "cert.3" || ops[3].DataValue != nil {
+		t.Errorf("wrong operations: %+v", ops)
+	}
+
+	got, err := ms.GetLargeData(address, "cert")
+	if err != nil {
+		t.Fatalf("GetLargeData failed: %v", err)
+	}
+
+	if string(got) != string(val) {
+		t.Errorf("wrong value: got %q, want %q", got, val)
+	}
+
+	if _, err := ms.GetLargeData(address, "missing"); err == nil {
+		t.Errorf("GetLargeData should fail for missing keys")
+	}
+
+	// Validation fails before anything is submitted.
+	ops = nil
+	tests := []struct {
+		key string
+		val []byte
+	}{
+		{"", val},
+		{"cert", nil},
+		{"cert", make([]byte, MaxLargeDataSize+1)},
+		{strings.Repeat("k", 63), val},
+	}
+
+	for _, test := range tests {
+		if err := ms.SetLargeData(source, test.key, test.val); err == nil || ops != nil {
+			t.Errorf("SetLargeData(%q, %d bytes) should fail: %v", test.key, len(test.val), err)
+		}
+	}
+
+	if err := ms.SetLargeData(source, "cert", make([]byte, MaxLargeDataSize)); err != nil {
+		t.Errorf("SetLargeData failed at the size limit: %v", err)
+	}
+
+	if len(ops) != maxOpsPerTx {
+		t.Errorf("wrong number of operations: %d", len(ops))
+	}
+}
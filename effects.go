@@ -0,0 +1,327 @@
+package microstellar
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// EffectType is the type of an effect, as reported by Horizon.
+type EffectType string
+
+// Common effect types. See the Horizon documentation for the full list.
+const (
+	EffectAccountCreated           = EffectType("account_created")
+	EffectAccountRemoved           = EffectType("account_removed")
+	EffectAccountCredited          = EffectType("account_credited")
+	EffectAccountDebited           = EffectType("account_debited")
+	EffectAccountThresholdsUpdated = EffectType("account_thresholds_updated")
+	EffectAccountHomeDomainUpdated = EffectType("account_home_domain_updated")
+	EffectAccountFlagsUpdated      = EffectType("account_flags_updated")
+	EffectSignerCreated            = EffectType("signer_created")
+	EffectSignerRemoved            = EffectType("signer_removed")
+	EffectSignerUpdated            = EffectType("signer_updated")
+	EffectTrustlineCreated         = EffectType("trustline_created")
+	EffectTrustlineRemoved         = EffectType("trustline_removed")
+	EffectTrustlineUpdated         = EffectType("trustline_updated")
+	EffectTrustlineAuthorized      = EffectType("trustline_authorized")
+	EffectTrustlineDeauthorized    = EffectType("trustline_deauthorized")
+	EffectTrade                    = EffectType("trade")
+	EffectDataCreated              = EffectType("data_created")
+	EffectDataRemoved              = EffectType("data_removed")
+	EffectDataUpdated              = EffectType("data_updated")
+)
+
+// Effect is a single change to an account caused by an operation. Only the fields relevant
+// to the effect's Type are set.
+type Effect struct {
+	ID        string     `json:"id"`
+	PT        string     `json:"paging_token"` // use with WithCursor to fetch the next page
+	Type      EffectType `json:"type"`
+	Account   string     `json:"account"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// account_created (starting balance), account_credited, account_debited
+	Amount string `json:"amount"`
+
+	// account_credited, account_debited, trustline_*
+	Asset *Asset `json:"asset"`
+
+	// trustline_created, trustline_updated
+	Limit string `json:"limit"`
+
+	// trustline_authorized, trustline_deauthorized
+	Trustor string `json:"trustor"`
+
+	// signer_*
+	PublicKey string `json:"public_key"`
+	Weight    int32  `json:"weight"`
+
+	// account_thresholds_updated
+	Thresholds Thresholds `json:"thresholds"`
+
+	// account_home_domain_updated
+	HomeDomain string `json:"home_domain"`
+
+	// trade
+	Seller       string `json:"seller"`
+	OfferID      string `json:"offer_id"`
+	SoldAmount   string `json:"sold_amount"`
+	SoldAsset    *Asset `json:"sold_asset"`
+	BoughtAmount string `json:"bought_amount"`
+	BoughtAsset  *Asset `json:"bought_asset"`
+
+	// data_*
+	Name  string `json:"name"`
+	Value string `json:"value"` // base-64 encoded
+}
+
+// horizonEffect is a Horizon effect record. The vendored Horizon client doesn't
+// decode effects.
+type horizonEffect struct {
+	ID                string      `json:"id"`
+	PT                string      `json:"paging_token"`
+	Type              string      `json:"type"`
+	Account           string      `json:"account"`
+	CreatedAt         time.Time   `json:"created_at"`
+	Amount            string      `json:"amount"`
+	StartingBalance   string      `json:"starting_balance"`
+	AssetType         string      `json:"asset_type"`
+	AssetCode         string      `json:"asset_code"`
+	AssetIssuer       string      `json:"asset_issuer"`
+	Limit             string      `json:"limit"`
+	Trustor           string      `json:"trustor"`
+	PublicKey         string      `json:"public_key"`
+	Weight            int32       `json:"weight"`
+	LowThreshold      byte        `json:"low_threshold"`
+	MedThreshold      byte        `json:"med_threshold"`
+	HighThreshold     byte        `json:"high_threshold"`
+	HomeDomain        string      `json:"home_domain"`
+	Seller            string      `json:"seller"`
+	OfferID           interface{} `json:"offer_id"` // number in older Horizons, string in newer ones
+	SoldAmount        string      `json:"sold_amount"`
+	SoldAssetType     string      `json:"sold_asset_type"`
+	SoldAssetCode     string      `json:"sold_asset_code"`
+	SoldAssetIssuer   string      `json:"sold_asset_issuer"`
+	BoughtAmount      string      `json:"bought_amount"`
+	BoughtAssetType   string      `json:"bought_asset_type"`
+	BoughtAssetCode   string      `json:"bought_asset_code"`
+	BoughtAssetIssuer string      `json:"bought_asset_issuer"`
+	Name              string      `json:"name"`
+	Value             string      `json:"value"`
+}
+
+type horizonEffectsPage struct {
+	Embedded struct {
+		Records []horizonEffect `json:"records"`
+	} `json:"_embedded"`
+}
+
+// effectAsset returns the asset described by the Horizon asset fields, or nil if
+// there's none.
+func effectAsset(assetType, code, issuer string) *Asset {
+	switch assetType {
+	case "":
+		return nil
+	case string(NativeType):
+		return NativeAsset
+	default:
+		return NewAsset(code, issuer, AssetType(assetType))
+	}
+}
+
+// newEffectFromHorizon creates a new effect from a Horizon effect record.
+func newEffectFromHorizon(he horizonEffect) Effect {
+	effect := Effect{
+		ID:           he.ID,
+		PT:           he.PT,
+		Type:         EffectType(he.Type),
+		Account:      he.Account,
+		CreatedAt:    he.CreatedAt,
+		Amount:       he.Amount,
+		Asset:        effectAsset(he.AssetType, he.AssetCode, he.AssetIssuer),
+		Limit:        he.Limit,
+		Trustor:      he.Trustor,
+		PublicKey:    he.PublicKey,
+		Weight:       he.Weight,
+		HomeDomain:   he.HomeDomain,
+		Seller:       he.Seller,
+		OfferID:      horizonID(he.OfferID),
+		SoldAmount:   he.SoldAmount,
+		SoldAsset:    effectAsset(he.SoldAssetType, he.SoldAssetCode, he.SoldAssetIssuer),
+		BoughtAmount: he.BoughtAmount,
+		BoughtAsset:  effectAsset(he.BoughtAssetType, he.BoughtAssetCode, he.BoughtAssetIssuer),
+		Name:         he.Name,
+		Value:        he.Value,
+	}
+
+	if effect.Type == EffectAccountCreated {
+		effect.Amount = he.StartingBalance
+	}
+
+	effect.Thresholds.Low = he.LowThreshold
+	effect.Thresholds.Medium = he.MedThreshold
+	effect.Thresholds.High = he.HighThreshold
+
+	return effect
+}
+
+// LoadEffects returns the effects (balance changes, new trustlines, signer changes, trades, etc.)
+// on the account at address. Use WithLimit, WithCursor and WithSortOrder to page through the
+// results; the PT field of the last effect is the cursor for the next page. Returns an empty
+// slice if the account has no effects.
+//
+//   effects, err := ms.LoadEffects("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+//     microstellar.Opts().WithLimit(10).WithSortOrder(microstellar.SortDescending))
+func (ms *MicroStellar) LoadEffects(address string, options ...*Options) ([]Effect, error) {
+	if err := ValidAddress(address); err != nil {
+		return nil, ms.errorf("invalid address: %s", address)
+	}
+
+	opt := mergeOptions(options)
+	query := pageQuery(opt)
+
+	ms.debugf("LoadEffects", "loading effects for %s, with params %+v", address, query)
+	if ms.fake {
+		return []Effect{}, ms.success()
+	}
+
+	var page horizonEffectsPage
+	path := fmt.Sprintf("/accounts/%s/effects?%s", address, query.Encode())
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), path, &page); err != nil {
+		return nil, ms.wrapf(err, "can't load effects")
+	}
+
+	effects := make([]Effect, len(page.Embedded.Records))
+	for i, he := range page.Embedded.Records {
+		effects[i] = newEffectFromHorizon(he)
+	}
+
+	return effects, ms.success()
+}
+
+// effectStreamRetry is how long streamEffects waits before reconnecting when Horizon closes the
+// stream.
+var effectStreamRetry = time.Second
+
+// streamEffects streams the effects on the account at address from Horizon, starting at cursor
+// (if set), and calls handler with each one. It reconnects from the last effect seen whenever
+// Horizon closes the stream, and returns when ctx is done or the stream fails. The vendored
+// Horizon client can't stream effects.
+func streamEffects(ctx context.Context, client *horizon.Client, params Params, address string, cursor *horizon.Cursor, handler func(Effect)) error {
+	query := url.Values{}
+	if cursor != nil {
+		query.Set("cursor", string(*cursor))
+	}
+
+	// Don't use a client Timeout, which would end the stream.
+	httpClient := &http.Client{}
+	if c := httpClientFromParams(params); c != nil {
+		httpClient.Transport = c.Transport
+	}
+
+	for {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/accounts/%s/effects?%s", client.URL, address, query.Encode()), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return errors.Errorf("can't stream effects: status %d", resp.StatusCode)
+		}
+
+		err = readEvents(resp.Body, func(id string, data string) error {
+			var he horizonEffect
+			if err := json.Unmarshal([]byte(data), &he); err != nil {
+				return errors.Wrap(err, "can't decode effect")
+			}
+
+			if id != "" {
+				query.Set("cursor", id)
+			}
+
+			handler(newEffectFromHorizon(he))
+			return nil
+		})
+		resp.Body.Close()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(effectStreamRetry):
+		}
+	}
+}
+
+// readEvents reads server-sent events from r until EOF, and calls handler with the ID and data
+// of each "message" event.
+func readEvents(r io.Reader, handler func(id string, data string) error) error {
+	reader := bufio.NewReader(r)
+	event, id, data := "", "", []string{}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			field, value := line, ""
+			if i := strings.Index(line, ":"); i >= 0 {
+				field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+			}
+
+			switch field {
+			case "event":
+				event = value
+			case "id":
+				id = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+
+		// A blank line (or EOF) dispatches the event.
+		if line == "" || err == io.EOF {
+			if len(data) > 0 && (event == "" || event == "message") {
+				if err := handler(id, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+
+			event, data = "", []string{}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadEffects(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/"+address+"/effects" {
+			fmt.Fprint(w, `{"_embedded": {"records": []}}`)
+			return
+		}
+
+		if r.URL.Query().Get("order") != "desc" || r.URL.Query().Get("cursor") != "100" || r.URL.Query().Get("limit") != "3" {
+			t.Errorf("wrong query: %s", r.URL.RawQuery)
+		}
+
+		fmt.Fprintf(w, `{"_embedded": {"records": [
+			{"id": "3", "paging_token": "3", "type": "account_credited", "account": "%[1]s",
+			 "created_at": "2018-05-01T10:00:00Z", "amount": "10.0000000", "asset_type": "credit_alphanum4",
+			 "asset_code": "USD", "asset_issuer": "%[2]s"},
+			{"id": "2", "paging_token": "2", "type": "trustline_created", "account": "%[1]s",
+			 "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%[2]s"},
+			{"id": "1", "paging_token": "1", "type": "account_created", "account": "%[1]s", "starting_balance": "100.0000000"},
+			{"id": "0", "paging_token": "0", "type": "signer_updated", "account": "%[1]s", "public_key": "%[2]s", "weight": 2},
+			{"id": "4", "paging_token": "4", "type": "trade", "account": "%[1]s", "seller": "%[2]s", "offer_id": 42,
+			 "sold_amount": "1.0000000", "sold_asset_type": "native", "bought_amount": "2.0000000",
+			 "bought_asset_type": "credit_alphanum4", "bought_asset_code": "USD", "bought_asset_issuer": "%[2]s"}
+		]}}`, address, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	effects, err := ms.LoadEffects(address, Opts().WithLimit(3).WithCursor("100").WithSortOrder(SortDescending))
+	if err != nil {
+		t.Fatalf("LoadEffects failed: %v", err)
+	}
+
+	if len(effects) != 5 {
+		t.Fatalf("want 5 effects, got %d", len(effects))
+	}
+
+	usd := NewAsset("USD", issuer, Credit4Type)
+	credited := effects[0]
+	if credited.Type != EffectAccountCredited || credited.Amount != "10.0000000" || !credited.Asset.Equals(*usd) || credited.CreatedAt.Year() != 2018 {
+		t.Errorf("wrong account_credited effect: %+v", credited)
+	}
+
+	if effects[1].Type != EffectTrustlineCreated || effects[1].Limit != "1000.0000000" || !effects[1].Asset.Equals(*usd) {
+		t.Errorf("wrong trustline_created effect: %+v", effects[1])
+	}
+
+	if effects[2].Type != EffectAccountCreated || effects[2].Amount != "100.0000000" || effects[2].Asset != nil {
+		t.Errorf("wrong account_created effect: %+v", effects[2])
+	}
+
+	if effects[3].Type != EffectSignerUpdated || effects[3].PublicKey != issuer || effects[3].Weight != 2 {
+		t.Errorf("wrong signer_updated effect: %+v", effects[3])
+	}
+
+	trade := effects[4]
+	if trade.OfferID != "42" || !trade.SoldAsset.IsNative() || !trade.BoughtAsset.Equals(*usd) || trade.BoughtAmount != "2.0000000" {
+		t.Errorf("wrong trade effect: %+v", trade)
+	}
+
+	effects, err = ms.LoadEffects("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A")
+	if err != nil || effects == nil || len(effects) != 0 {
+		t.Errorf("want empty effects, got %v: %v", effects, err)
+	}
+
+	if _, err := ms.LoadEffects("bad address"); err == nil {
+		t.Errorf("LoadEffects should fail on invalid address")
+	}
+}
@@ -0,0 +1,472 @@
+package microstellar
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// FakeLedger is an in-memory ledger for the fake network. It tracks the lumen balances, trust
+// lines, and sequence numbers of its accounts, so that funding, paying, and creating or removing
+// trust lines on the fake network change what LoadAccount returns. Use MicroStellar.FakeLedger
+// to get one, and set up the initial state with its methods.
+type FakeLedger struct {
+	mu       sync.Mutex
+	accounts fakeAccounts
+}
+
+// fakeAccount is an account on the fake ledger. Amounts are in stroops.
+type fakeAccount struct {
+	native   int64
+	sequence int64
+	lines    map[string]*fakeTrustLine // by Asset.String()
+}
+
+// fakeTrustLine is a trust line on the fake ledger.
+type fakeTrustLine struct {
+	asset   *Asset
+	balance int64
+	limit   int64
+}
+
+// fakeAccounts are the accounts on the fake ledger, by address.
+type fakeAccounts map[string]*fakeAccount
+
+// fakeOp applies an operation sourced from source to accounts, and returns its Horizon result
+// code (e.g., "op_success".)
+type fakeOp func(accounts fakeAccounts, source string) string
+
+// fakeTxOp is an operation in a transaction on the fake ledger.
+type fakeTxOp struct {
+	source string
+	apply  fakeOp
+}
+
+// FakeLedger returns the client's fake ledger, creating it on the first call. Returns nil unless
+// the client is on the fake network.
+//
+//   ms := microstellar.New("fake")
+//   ms.FakeLedger().AddAccount("source_address", "1000")
+//
+//   ms.FundAccount("source_seed", "target_address", "100")
+//   ms.Pay("source_seed", "target_address", "10", microstellar.NativeAsset)
+//
+//   account, _ := ms.LoadAccount("target_address")
+//   log.Print(account.GetNativeBalance()) // 110.0000000
+//
+// Until FakeLedger is called, the fake network accepts every transaction, and LoadAccount returns
+// an empty account. After, LoadAccount fails for accounts that aren't on the ledger, and
+// transactions fail (with the result codes Horizon would return) if their source account is
+// missing, or if an operation can't be applied. Funding (FundAccount), payments, and trust
+// lines change the ledger; other operations succeed without changing it. Fees and reserves are
+// not charged, and path payments don't move funds.
+func (ms *MicroStellar) FakeLedger() *FakeLedger {
+	if !ms.fake {
+		return nil
+	}
+
+	ms.ledgerMu.Lock()
+	defer ms.ledgerMu.Unlock()
+
+	if ms.ledger == nil {
+		ms.ledger = &FakeLedger{accounts: fakeAccounts{}}
+	}
+
+	return ms.ledger
+}
+
+// fakeLedger returns the client's fake ledger, or nil if FakeLedger hasn't been called.
+func (ms *MicroStellar) fakeLedger() *FakeLedger {
+	ms.ledgerMu.Lock()
+	defer ms.ledgerMu.Unlock()
+	return ms.ledger
+}
+
+// fakeAddress returns the account ID of addressOrSeed, which can also be a multiplexed address.
+func fakeAddress(addressOrSeed string) (string, error) {
+	if isMuxedAddress(addressOrSeed) {
+		base, _, err := ParseMuxedAddress(addressOrSeed)
+		return base, err
+	}
+
+	return addressOf(addressOrSeed)
+}
+
+// AddAccount adds the account at address (an address or seed) to the ledger, with nativeBalance
+// lumens and no trust lines. Replaces the account if it's already on the ledger.
+func (l *FakeLedger) AddAccount(address string, nativeBalance string) error {
+	key, err := fakeAddress(address)
+	if err != nil {
+		return errors.Wrap(err, "can't add fake account")
+	}
+
+	if err := ValidAmount(nativeBalance); err != nil {
+		return errors.Wrap(err, "can't add fake account")
+	}
+
+	balance, _ := ParseAmount(nativeBalance)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.accounts[key] = &fakeAccount{native: balance, lines: map[string]*fakeTrustLine{}}
+	return nil
+}
+
+// SetBalance sets the balance of asset in the account at address, which must be on the ledger.
+// For credit assets, a trust line (with no limit) is created if the account doesn't have one.
+//
+//   ledger.SetBalance("bob_address", USD, "250")
+func (l *FakeLedger) SetBalance(address string, asset *Asset, amount string) error {
+	key, err := fakeAddress(address)
+	if err != nil {
+		return errors.Wrap(err, "can't set fake balance")
+	}
+
+	if err := asset.Validate(); err != nil {
+		return errors.Wrap(err, "can't set fake balance")
+	}
+
+	if err := ValidAmount(amount); err != nil {
+		return errors.Wrap(err, "can't set fake balance")
+	}
+
+	balance, _ := ParseAmount(amount)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[key]
+	if !ok {
+		return errors.Errorf("can't set fake balance: no such account: %s", key)
+	}
+
+	if asset.IsNative() {
+		account.native = balance
+		return nil
+	}
+
+	if asset.Issuer == key {
+		return errors.Errorf("can't set fake balance: issuers don't hold their own assets: %s", asset)
+	}
+
+	line, ok := account.lines[asset.String()]
+	if !ok {
+		line = &fakeTrustLine{asset: asset, limit: math.MaxInt64}
+		account.lines[asset.String()] = line
+	}
+
+	if balance > line.limit {
+		return errors.Errorf("can't set fake balance: %s is over the trust line limit", amount)
+	}
+
+	line.balance = balance
+	return nil
+}
+
+// SetSequence sets the sequence number of the account at address, which must be on the ledger.
+// Accounts start at sequence 0.
+func (l *FakeLedger) SetSequence(address string, sequence int64) error {
+	key, err := fakeAddress(address)
+	if err != nil {
+		return errors.Wrap(err, "can't set fake sequence")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	account, ok := l.accounts[key]
+	if !ok {
+		return errors.Errorf("can't set fake sequence: no such account: %s", key)
+	}
+
+	account.sequence = sequence
+	return nil
+}
+
+// account returns the account at address, as LoadAccount would, or a Horizon "Resource Missing"
+// error if it's not on the ledger.
+func (l *FakeLedger) account(address string) (*Account, error) {
+	key, err := fakeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fa, ok := l.accounts[key]
+	if !ok {
+		return nil, &horizon.Error{Problem: horizon.Problem{
+			Type:   "https://stellar.org/horizon-errors/not_found",
+			Title:  "Resource Missing",
+			Status: http.StatusNotFound,
+		}}
+	}
+
+	zero := ToAmountString(0)
+	account := newAccount()
+	account.Address = key
+	account.Sequence = strconv.FormatInt(fa.sequence, 10)
+	account.NativeBalance = Balance{Asset: NativeAsset, Amount: ToAmountString(fa.native), BuyingLiabilities: zero, SellingLiabilities: zero}
+	account.Signers = []Signer{{PublicKey: key, Weight: 1, Key: key, Type: "ed25519_public_key"}}
+	account.Data = map[string]string{}
+	account.SubentryCount = int32(len(fa.lines))
+
+	keys := make([]string, 0, len(fa.lines))
+	for k := range fa.lines {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		line := fa.lines[k]
+		account.Balances = append(account.Balances, Balance{
+			Asset:              line.asset,
+			Amount:             ToAmountString(line.balance),
+			Limit:              ToAmountString(line.limit),
+			BuyingLiabilities:  zero,
+			SellingLiabilities: zero,
+			Authorized:         true,
+		})
+	}
+
+	return account, nil
+}
+
+// clone returns a deep copy of accounts.
+func (accounts fakeAccounts) clone() fakeAccounts {
+	copied := fakeAccounts{}
+	for address, account := range accounts {
+		ca := *account
+		ca.lines = map[string]*fakeTrustLine{}
+		for k, line := range account.lines {
+			cl := *line
+			ca.lines[k] = &cl
+		}
+		copied[address] = &ca
+	}
+
+	return copied
+}
+
+// apply applies the operations of a transaction from source (an address or seed) to the ledger,
+// and bumps the source's sequence number. If sequence is not zero, it must be the next sequence
+// number for the source. Either every operation is applied, or (if any of them fail) none are,
+// and the returned Horizon error has the result codes.
+func (l *FakeLedger) apply(source string, sequence int64, ops []fakeTxOp) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	txSource, _ := fakeAddress(source)
+	account, ok := l.accounts[txSource]
+	if !ok {
+		return fakeTxFailure("tx_no_account", nil)
+	}
+
+	if sequence != 0 && sequence != account.sequence+1 {
+		return fakeTxFailure("tx_bad_seq", nil)
+	}
+
+	next := l.accounts.clone()
+	next[txSource].sequence++
+
+	codes := make([]string, len(ops))
+	failed := false
+	for i, op := range ops {
+		opSource, _ := fakeAddress(op.source)
+		if _, ok := next[opSource]; !ok {
+			codes[i] = "op_no_source_account"
+		} else {
+			codes[i] = op.apply(next, opSource)
+		}
+
+		failed = failed || codes[i] != "op_success"
+	}
+
+	if failed {
+		return fakeTxFailure("tx_failed", codes)
+	}
+
+	l.accounts = next
+	return nil
+}
+
+// fakeTxFailure returns the Horizon error for a failed transaction, with the transaction and
+// operation result codes.
+func fakeTxFailure(txCode string, opCodes []string) error {
+	codes, _ := json.Marshal(horizon.TransactionResultCodes{TransactionCode: txCode, OperationCodes: opCodes})
+	return &horizon.Error{Problem: horizon.Problem{
+		Type:   "https://stellar.org/horizon-errors/transaction_failed",
+		Title:  "Transaction Failed",
+		Status: http.StatusBadRequest,
+		Extras: map[string]json.RawMessage{"result_codes": codes},
+	}}
+}
+
+// fakeCreateAccount returns the fake operation that funds target with amount lumens.
+func fakeCreateAccount(target string, amount string) fakeOp {
+	return func(accounts fakeAccounts, source string) string {
+		address, _ := fakeAddress(target)
+		stroops, _ := ParseAmount(amount)
+
+		if _, ok := accounts[address]; ok {
+			return "op_already_exists"
+		}
+
+		if accounts[source].native < stroops {
+			return "op_underfunded"
+		}
+
+		accounts[source].native -= stroops
+		accounts[address] = &fakeAccount{native: stroops, lines: map[string]*fakeTrustLine{}}
+		return "op_success"
+	}
+}
+
+// fakePayment returns the fake operation that pays amount of asset to target. Issuers can send
+// and receive their own assets without trust lines.
+func fakePayment(target string, amount string, asset *Asset) fakeOp {
+	return func(accounts fakeAccounts, source string) string {
+		address, _ := fakeAddress(target)
+		stroops, _ := ParseAmount(amount)
+
+		dest, ok := accounts[address]
+		if !ok {
+			return "op_no_destination"
+		}
+
+		from := accounts[source]
+		if asset.IsNative() {
+			if from.native < stroops {
+				return "op_underfunded"
+			}
+
+			from.native -= stroops
+			dest.native += stroops
+			return "op_success"
+		}
+
+		// The issuer's side of the payment has no trust line.
+		var fromLine, destLine *fakeTrustLine
+		if source != asset.Issuer {
+			if fromLine, ok = from.lines[asset.String()]; !ok {
+				return "op_src_no_trust"
+			}
+
+			if fromLine.balance < stroops {
+				return "op_underfunded"
+			}
+		}
+
+		if address != asset.Issuer {
+			if destLine, ok = dest.lines[asset.String()]; !ok {
+				return "op_no_trust"
+			}
+
+			if destLine.limit-destLine.balance < stroops {
+				return "op_line_full"
+			}
+		}
+
+		if fromLine != nil {
+			fromLine.balance -= stroops
+		}
+
+		if destLine != nil {
+			destLine.balance += stroops
+		}
+
+		return "op_success"
+	}
+}
+
+// fakeChangeTrust returns the fake operation that creates or updates the source's trust line to
+// asset. A limit of "" means no limit, and "0" removes the trust line.
+func fakeChangeTrust(asset *Asset, limit string) fakeOp {
+	return func(accounts fakeAccounts, source string) string {
+		if source == asset.Issuer {
+			return "op_self_not_allowed"
+		}
+
+		stroops := int64(math.MaxInt64)
+		if limit != "" {
+			stroops, _ = ParseAmount(limit)
+		}
+
+		account := accounts[source]
+		line, ok := account.lines[asset.String()]
+		if stroops == 0 {
+			if !ok || line.balance > 0 {
+				return "op_invalid_limit"
+			}
+
+			delete(account.lines, asset.String())
+			return "op_success"
+		}
+
+		if !ok {
+			account.lines[asset.String()] = &fakeTrustLine{asset: asset, limit: stroops}
+			return "op_success"
+		}
+
+		if line.balance > stroops {
+			return "op_invalid_limit"
+		}
+
+		line.limit = stroops
+		return "op_success"
+	}
+}
+
+// addFakeOp adds op, sourced from source (or the account set with Options.WithSourceAccount), to
+// the transaction's changes to the fake ledger. Call it before Build, which clears the operation
+// source of multi-op transactions.
+func (tx *Tx) addFakeOp(source string, op fakeOp) {
+	if tx.ledger == nil {
+		return
+	}
+
+	if tx.options != nil && tx.options.opSourceAccount != "" {
+		source = tx.options.opSourceAccount
+	}
+
+	tx.fakeOps = append(tx.fakeOps, fakeTxOp{source: source, apply: op})
+}
+
+// applyFake applies the transaction to the fake ledger, if there is one.
+func (tx *Tx) applyFake() error {
+	if tx.ledger == nil {
+		return nil
+	}
+
+	source, sequence := tx.fakeSource, int64(0)
+	if tx.isMultiOp {
+		// Start sets the source account, network, and sequence number first, and later options
+		// can replace the ones it was called with.
+		if sa, ok := tx.ops[0].(build.SourceAccount); ok {
+			source = sa.AddressOrSeed
+		}
+
+		if seq, ok := tx.ops[2].(build.Sequence); ok {
+			sequence = int64(seq.Sequence)
+		}
+	} else if tx.options != nil {
+		if tx.options.channelSeed != "" {
+			source = tx.options.channelSeed
+		}
+
+		if tx.options.hasSequence {
+			sequence = tx.options.sequence
+		}
+	}
+
+	return tx.ledger.apply(source, sequence, tx.fakeOps)
+}
@@ -0,0 +1,150 @@
+package microstellar
+
+import (
+	"testing"
+)
+
+func TestFakeLedger(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	sourceAddress := "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R"
+
+	ms := New("fake")
+	pair, _ := ms.CreateKeyPair()
+	issuer, _ := ms.CreateKeyPair()
+	USD := NewAsset("USD", issuer.Address, Credit4Type)
+
+	// Without a ledger, everything succeeds.
+	if err := ms.Pay(source, pair.Address, "10", NativeAsset); err != nil {
+		t.Fatalf("Pay failed: %v", err)
+	}
+
+	ledger := ms.FakeLedger()
+	if ledger != ms.FakeLedger() || New("test").FakeLedger() != nil {
+		t.Fatalf("wrong fake ledger")
+	}
+
+	if _, err := ms.LoadAccount(sourceAddress); err == nil {
+		t.Errorf("LoadAccount should fail for missing accounts")
+	}
+
+	if err := ms.Pay(source, pair.Address, "10", NativeAsset); !hasResultCode(err, "tx_no_account") {
+		t.Errorf("want tx_no_account, got: %v", ErrorString(err))
+	}
+
+	if err := ledger.AddAccount(source, "1000"); err != nil {
+		t.Fatalf("AddAccount failed: %v", err)
+	}
+
+	if err := ledger.AddAccount(issuer.Address, "10"); err != nil {
+		t.Fatalf("AddAccount failed: %v", err)
+	}
+
+	if err := ms.Pay(source, pair.Address, "10", NativeAsset); !hasResultCode(err, "op_no_destination") {
+		t.Errorf("want op_no_destination, got: %v", ErrorString(err))
+	}
+
+	if err := ms.FundAccount(source, pair.Address, "100"); err != nil {
+		t.Fatalf("FundAccount failed: %v", ErrorString(err))
+	}
+
+	if err := ms.Pay(source, pair.Address, "10", NativeAsset); err != nil {
+		t.Fatalf("Pay failed: %v", ErrorString(err))
+	}
+
+	if err := ms.Pay(source, pair.Address, "1000", NativeAsset); !IsInsufficientBalance(err) {
+		t.Errorf("want op_underfunded, got: %v", ErrorString(err))
+	}
+
+	account, err := ms.LoadAccount(pair.Address)
+	if err != nil {
+		t.Fatalf("LoadAccount failed: %v", err)
+	}
+
+	if account.GetNativeBalance() != "110.0000000" || account.Sequence != "0" {
+		t.Errorf("wrong target account: %+v", account)
+	}
+
+	account, _ = ms.LoadAccount(sourceAddress)
+	if account.GetNativeBalance() != "890.0000000" || account.Sequence != "2" {
+		t.Errorf("wrong source account: %+v", account)
+	}
+
+	// Credit assets need trust lines.
+	if err := ms.Pay(issuer.Seed, pair.Address, "50", USD); !IsNoTrust(err) {
+		t.Errorf("want op_no_trust, got: %v", ErrorString(err))
+	}
+
+	if err := ms.CreateTrustLine(pair.Seed, USD, "100"); err != nil {
+		t.Fatalf("CreateTrustLine failed: %v", ErrorString(err))
+	}
+
+	if err := ms.Pay(issuer.Seed, pair.Address, "50", USD); err != nil {
+		t.Fatalf("Pay failed: %v", ErrorString(err))
+	}
+
+	if err := ms.Pay(issuer.Seed, pair.Address, "60", USD); !hasResultCode(err, "op_line_full") {
+		t.Errorf("want op_line_full, got: %v", ErrorString(err))
+	}
+
+	if err := ms.Pay(pair.Seed, sourceAddress, "1", USD); !IsNoTrust(err) {
+		t.Errorf("want op_no_trust, got: %v", ErrorString(err))
+	}
+
+	if err := ms.RemoveTrustLine(pair.Seed, USD); !hasResultCode(err, "op_invalid_limit") {
+		t.Errorf("want op_invalid_limit, got: %v", ErrorString(err))
+	}
+
+	// Multi-op transactions are applied atomically.
+	ms.Start(pair.Seed)
+	ms.Pay(pair.Seed, issuer.Address, "50", USD)
+	ms.RemoveTrustLine(pair.Seed, USD)
+	ms.Pay(pair.Seed, sourceAddress, "500", NativeAsset)
+	if err := ms.Submit(); !hasResultCode(err, "op_underfunded") {
+		t.Errorf("want op_underfunded, got: %v", ErrorString(err))
+	}
+
+	account, _ = ms.LoadAccount(pair.Address)
+	if account.GetBalance(USD) != "50.0000000" || account.Sequence != "1" {
+		t.Errorf("failed transaction changed the account: %+v", account)
+	}
+
+	ms.Start(pair.Seed)
+	ms.Pay(pair.Seed, issuer.Address, "50", USD)
+	ms.RemoveTrustLine(pair.Seed, USD)
+	ms.Pay(pair.Seed, sourceAddress, "5", NativeAsset)
+	if err := ms.Submit(); err != nil {
+		t.Fatalf("Submit failed: %v", ErrorString(err))
+	}
+
+	account, _ = ms.LoadAccount(pair.Address)
+	if len(account.Balances) != 0 || account.GetNativeBalance() != "105.0000000" || account.Sequence != "2" {
+		t.Errorf("wrong account: %+v", account)
+	}
+
+	// Fixed sequence numbers must be next.
+	if err := ms.Pay(pair.Seed, sourceAddress, "1", NativeAsset, Opts().WithSequence(2)); !IsBadSequence(err) {
+		t.Errorf("want tx_bad_seq, got: %v", ErrorString(err))
+	}
+
+	if err := ms.Pay(pair.Seed, sourceAddress, "1", NativeAsset, Opts().WithSequence(3)); err != nil {
+		t.Errorf("Pay failed: %v", ErrorString(err))
+	}
+
+	// Initial state.
+	if err := ledger.SetBalance(sourceAddress, USD, "20"); err != nil {
+		t.Fatalf("SetBalance failed: %v", err)
+	}
+
+	if err := ledger.SetSequence(sourceAddress, 100); err != nil {
+		t.Fatalf("SetSequence failed: %v", err)
+	}
+
+	if err := ledger.SetBalance(issuer.Address, USD, "20"); err == nil {
+		t.Errorf("SetBalance should fail for issuers")
+	}
+
+	account, _ = ms.LoadAccount(sourceAddress)
+	if account.GetBalance(USD) != "20.0000000" || account.Sequence != "100" || account.SubentryCount != 1 {
+		t.Errorf("wrong account: %+v", account)
+	}
+}
@@ -0,0 +1,103 @@
+package microstellar
+
+// FeeStats contains statistics about the fees accepted in recent ledgers, as reported by
+// Horizon's /fee_stats endpoint. All fees are in stroops, per operation.
+type FeeStats struct {
+	LastLedger          uint32  `json:"last_ledger,string"`
+	LastLedgerBaseFee   uint32  `json:"last_ledger_base_fee,string"`
+	LedgerCapacityUsage float64 `json:"ledger_capacity_usage,string"`
+	MinAcceptedFee      uint32  `json:"min_accepted_fee,string"`
+	ModeAcceptedFee     uint32  `json:"mode_accepted_fee,string"`
+	P10AcceptedFee      uint32  `json:"p10_accepted_fee,string"`
+	P20AcceptedFee      uint32  `json:"p20_accepted_fee,string"`
+	P30AcceptedFee      uint32  `json:"p30_accepted_fee,string"`
+	P40AcceptedFee      uint32  `json:"p40_accepted_fee,string"`
+	P50AcceptedFee      uint32  `json:"p50_accepted_fee,string"`
+	P60AcceptedFee      uint32  `json:"p60_accepted_fee,string"`
+	P70AcceptedFee      uint32  `json:"p70_accepted_fee,string"`
+	P80AcceptedFee      uint32  `json:"p80_accepted_fee,string"`
+	P90AcceptedFee      uint32  `json:"p90_accepted_fee,string"`
+	P95AcceptedFee      uint32  `json:"p95_accepted_fee,string"`
+	P99AcceptedFee      uint32  `json:"p99_accepted_fee,string"`
+}
+
+// fakeFeeStats are the fee stats returned on the fake network.
+var fakeFeeStats = FeeStats{
+	LastLedger:          1,
+	LastLedgerBaseFee:   100,
+	LedgerCapacityUsage: 0.5,
+	MinAcceptedFee:      100,
+	ModeAcceptedFee:     100,
+	P10AcceptedFee:      100,
+	P20AcceptedFee:      100,
+	P30AcceptedFee:      100,
+	P40AcceptedFee:      100,
+	P50AcceptedFee:      100,
+	P60AcceptedFee:      150,
+	P70AcceptedFee:      200,
+	P80AcceptedFee:      250,
+	P90AcceptedFee:      300,
+	P95AcceptedFee:      400,
+	P99AcceptedFee:      500,
+}
+
+// FeeStats returns statistics about the fees accepted by the network in recent ledgers. Use
+// this to pick a base fee for Options.WithBaseFee.
+//
+//   stats, err := ms.FeeStats()
+//   ms.PayNative("source_seed", "target_address", "10", microstellar.Opts().WithBaseFee(stats.P90AcceptedFee))
+func (ms *MicroStellar) FeeStats() (*FeeStats, error) {
+	if ms.fake {
+		stats := fakeFeeStats
+		return &stats, ms.success()
+	}
+
+	var stats FeeStats
+	if err := getJSON(ms.logger(), ms.getTx().GetClient(), "/fee_stats", &stats); err != nil {
+		return nil, ms.wrapf(err, "can't load fee stats")
+	}
+
+	return &stats, ms.success()
+}
+
+// minBaseFee is the network's minimum base fee, in stroops per operation.
+const minBaseFee = 100
+
+// EstimateFee returns the fee, in stroops, of a transaction with opCount operations. The base fee
+// (per operation) is the one set with Options.WithBaseFee, or the most common fee accepted in
+// recent ledgers (see FeeStats) if there's none. Nothing is built or submitted.
+//
+//   fee, err := ms.EstimateFee(3)
+//   log.Printf("three payments will cost %s XLM", microstellar.LumensFromStroops(fee))
+//
+// When a channel or fee account pays (see Options.WithChannelAccount), the estimate includes the
+// fee-bump overhead of one extra base fee, i.e., baseFee * (opCount + 1).
+func (ms *MicroStellar) EstimateFee(opCount int, options ...*Options) (int64, error) {
+	if opCount < 1 || opCount > maxOpsPerTx {
+		return 0, ms.errorf("can't estimate fee: operation count must be between 1 and %d: %d", maxOpsPerTx, opCount)
+	}
+
+	opts := mergeOptions(options)
+
+	baseFee := int64(minBaseFee)
+	if opts.hasFee {
+		baseFee = int64(opts.fee)
+	} else {
+		stats, err := ms.FeeStats()
+		if err != nil {
+			return 0, ms.wrapf(err, "can't estimate fee")
+		}
+
+		if fee := int64(stats.ModeAcceptedFee); fee > baseFee {
+			baseFee = fee
+		}
+	}
+
+	units := int64(opCount)
+	if opts.channelSeed != "" {
+		units++
+	}
+
+	ms.debugf("EstimateFee", "%d operations at %d stroops each (%d fee units)", opCount, baseFee, units)
+	return baseFee * units, ms.success()
+}
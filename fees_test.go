@@ -0,0 +1,105 @@
+package microstellar
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// This example picks a fee from recent fee stats.
+func ExampleMicroStellar_FeeStats() {
+	// Create a new MicroStellar client connected to a fake network. To
+	// use a real network replace "fake" below with "test" or "public".
+	ms := New("fake")
+
+	stats, err := ms.FeeStats()
+
+	if err != nil {
+		log.Fatalf("FeeStats: %v", err)
+	}
+
+	// Pay with a fee that would have been accepted in 90% of recent transactions.
+	err = ms.PayNative("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK",
+		"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "10",
+		Opts().WithBaseFee(stats.P90AcceptedFee))
+
+	if err != nil {
+		log.Fatalf("PayNative: %v", err)
+	}
+
+	fmt.Printf("base fee: %d, p90 fee: %d", stats.LastLedgerBaseFee, stats.P90AcceptedFee)
+	// Output: base fee: 100, p90 fee: 300
+}
+
+func TestFeeStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fee_stats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprint(w, `{"last_ledger": "22606298", "last_ledger_base_fee": "100", "ledger_capacity_usage": "0.97",
+			"min_accepted_fee": "100", "mode_accepted_fee": "250", "p10_accepted_fee": "100", "p20_accepted_fee": "100",
+			"p30_accepted_fee": "100", "p40_accepted_fee": "100", "p50_accepted_fee": "100", "p60_accepted_fee": "150",
+			"p70_accepted_fee": "200", "p80_accepted_fee": "250", "p90_accepted_fee": "300", "p95_accepted_fee": "400",
+			"p99_accepted_fee": "2000"}`)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	stats, err := ms.FeeStats()
+	if err != nil {
+		t.Fatalf("FeeStats failed: %v", err)
+	}
+
+	if stats.LastLedger != 22606298 || stats.ModeAcceptedFee != 250 || stats.P99AcceptedFee != 2000 || stats.LedgerCapacityUsage != 0.97 {
+		t.Errorf("wrong fee stats: %+v", stats)
+	}
+}
+
+func TestEstimateFee(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_ledger_base_fee": "100", "mode_accepted_fee": "250"}`)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	tests := []struct {
+		opCount int
+		opts    *Options
+		want    int64
+	}{
+		{1, Opts(), 250},
+		{4, Opts(), 1000},
+		{3, Opts().WithBaseFee(300), 900},
+		{100, Opts().WithBaseFee(100), 10000},
+	}
+
+	for _, test := range tests {
+		fee, err := ms.EstimateFee(test.opCount, test.opts)
+		if err != nil || fee != test.want {
+			t.Errorf("%d ops: want fee %d, got %d (%v)", test.opCount, test.want, fee, err)
+		}
+	}
+
+	// Channel accounts add the fee-bump's extra base fee.
+	channel := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	plain, _ := ms.EstimateFee(3, Opts().WithBaseFee(200))
+	bumped, err := ms.EstimateFee(3, Opts().WithBaseFee(200).WithChannelAccount(channel))
+	if err != nil || plain != 600 || bumped != 800 {
+		t.Errorf("want fees 600 and 800 with a channel account, got %d and %d (%v)", plain, bumped, err)
+	}
+
+	for _, opCount := range []int{0, -1, 101} {
+		if _, err := ms.EstimateFee(opCount); err == nil {
+			t.Errorf("want error for %d ops", opCount)
+		}
+	}
+
+	if fee, err := New("fake").EstimateFee(2); err != nil || fee != 200 {
+		t.Errorf("want fee 200 on the fake network, got %d (%v)", fee, err)
+	}
+}
@@ -0,0 +1,103 @@
+package microstellar
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// ErrHorizonLagging is returned when a health gate (see Options.WithHealthGate) refuses to submit
+// a transaction because Horizon's ingestion is too far behind Stellar Core. Use errors.Cause to
+// check for it.
+var ErrHorizonLagging = errors.New("horizon is lagging")
+
+// ErrNetworkMismatch is returned by Ping when Horizon is on a different network than the client
+// (e.g., a testnet client pointed at a public network Horizon.) Use errors.Cause to check for it.
+var ErrNetworkMismatch = errors.New("horizon is on a different network")
+
+// healthCacheTTL is how long a Horizon lag measurement is reused for, so health-gated
+// submissions in a tight loop don't each cost an extra round-trip.
+const healthCacheTTL = 5 * time.Second
+
+type horizonHealth struct {
+	checkedAt time.Time
+	lag       int32
+}
+
+var (
+	healthCacheMu sync.Mutex
+	healthCache   = map[string]horizonHealth{}
+)
+
+// horizonLag returns the number of ledgers Horizon's ingestion is behind Stellar Core. Results
+// are cached per Horizon URL for healthCacheTTL.
+func horizonLag(logger Logger, client *horizon.Client) (int32, error) {
+	url := client.URL
+
+	healthCacheMu.Lock()
+	cached, ok := healthCache[url]
+	healthCacheMu.Unlock()
+
+	if ok && time.Since(cached.checkedAt) < healthCacheTTL {
+		return cached.lag, nil
+	}
+
+	root, err := client.Root()
+	if err != nil {
+		return 0, errors.Wrap(err, "can't check horizon health")
+	}
+
+	lag := root.CoreSequence - root.HorizonSequence
+	if lag < 0 {
+		lag = 0
+	}
+
+	healthCacheMu.Lock()
+	healthCache[url] = horizonHealth{checkedAt: time.Now(), lag: lag}
+	healthCacheMu.Unlock()
+
+	debugf(logger, "horizonLag", "horizon %s is %d ledgers behind core", url, lag)
+	return lag, nil
+}
+
+// checkHealthGate returns ErrHorizonLagging (wrapped) if Horizon is more than maxLag ledgers
+// behind Stellar Core.
+func checkHealthGate(logger Logger, client *horizon.Client, maxLag int32) error {
+	lag, err := horizonLag(logger, client)
+	if err != nil {
+		return err
+	}
+
+	if lag > maxLag {
+		return errors.Wrapf(ErrHorizonLagging, "%d ledgers behind core (max %d)", lag, maxLag)
+	}
+
+	return nil
+}
+
+// Ping checks that Horizon is reachable, and that it's on the client's network, i.e., its network
+// passphrase matches NetworkPassphrase. Returns an error with cause ErrNetworkMismatch if it
+// isn't. Always succeeds on the fake network.
+//
+//   if err := ms.Ping(); err != nil {
+//       log.Fatalf("horizon is not ready: %v", err)
+//   }
+func (ms *MicroStellar) Ping() error {
+	if ms.fake {
+		return ms.success()
+	}
+
+	root, err := ms.getTx().GetClient().Root()
+	if err != nil {
+		return ms.wrapf(err, "can't reach horizon")
+	}
+
+	if want := ms.NetworkPassphrase(); root.NetworkPassphrase != want {
+		return ms.wrapf(ErrNetworkMismatch, "horizon passphrase %q doesn't match %q", root.NetworkPassphrase, want)
+	}
+
+	ms.debugf("Ping", "horizon %s is up, at ledger %d", root.HorizonVersion, root.HorizonSequence)
+	return ms.success()
+}
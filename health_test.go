@@ -0,0 +1,72 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestHealthGate(t *testing.T) {
+	var rootHits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			atomic.AddInt32(&rootHits, 1)
+			fmt.Fprint(w, `{"history_latest_ledger": 100, "core_latest_ledger": 110}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	for i := 0; i < 3; i++ {
+		_, err := ms.SubmitTransaction("AAAA", Opts().WithHealthGate(5))
+		if errors.Cause(err) != ErrHorizonLagging {
+			t.Errorf("want ErrHorizonLagging, got: %v", err)
+		}
+	}
+
+	if hits := atomic.LoadInt32(&rootHits); hits != 1 {
+		t.Errorf("health check should be cached, got %d requests", hits)
+	}
+
+	_, err := ms.SubmitTransaction("AAAA", Opts().WithHealthGate(10))
+	if err == nil || errors.Cause(err) == ErrHorizonLagging {
+		t.Errorf("health gate should pass, got: %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, `{"horizon_version": "1.0.0", "history_latest_ledger": 100, "network_passphrase": "Test SDF Network ; September 2015"}`)
+	}))
+	defer server.Close()
+
+	if err := New("custom", Params{"url": server.URL, "passphrase": "Test SDF Network ; September 2015"}).Ping(); err != nil {
+		t.Errorf("Ping failed: %v", ErrorString(err))
+	}
+
+	err := New("custom", Params{"url": server.URL, "passphrase": "Public Global Stellar Network ; September 2015"}).Ping()
+	if errors.Cause(err) != ErrNetworkMismatch {
+		t.Errorf("want ErrNetworkMismatch, got: %v", err)
+	}
+
+	if err := New("custom", Params{"url": server.URL + "/missing", "passphrase": "test"}).Ping(); err == nil {
+		t.Errorf("Ping should fail when horizon is down")
+	}
+
+	if err := New("fake").Ping(); err != nil {
+		t.Errorf("Ping should always succeed on the fake network: %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package microstellar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// httpClientParam is the Params key for a custom *http.Client. See MicroStellar.WithHTTPClient.
+const httpClientParam = "http_client"
+
+// httpClientFromParams returns the custom HTTP client in params, or nil if there isn't one.
+func httpClientFromParams(params ...Params) *http.Client {
+	if len(params) == 0 {
+		return nil
+	}
+
+	client, _ := params[0][httpClientParam].(*http.Client)
+	return client
+}
+
+// maxRetriesParam is the Params key for the number of times requests rate limited by Horizon
+// (429 Too Many Requests) are retried.
+const maxRetriesParam = "maxRetries"
+
+// retryBackoff is the delay before the first retry of a rate limited request, if Horizon doesn't
+// send Retry-After. It doubles with each retry.
+var retryBackoff = 500 * time.Millisecond
+
+// maxRetryDelay caps the delay between retries, including Retry-After delays.
+const maxRetryDelay = time.Minute
+
+// maxRetriesFromParams returns the number of retries for rate limited requests set in params.
+func maxRetriesFromParams(params ...Params) int {
+	if len(params) == 0 {
+		return 0
+	}
+
+	maxRetries, _ := params[0][maxRetriesParam].(int)
+	return maxRetries
+}
+
+// retryHTTP is a horizon.HTTP implementation that retries requests rejected with 429 Too Many
+// Requests, up to maxRetries times. It waits for as long as the Retry-After header says, or
+// backs off exponentially if there's none. Rate limited requests aren't processed by Horizon,
+// so it's safe to retry submissions.
+type retryHTTP struct {
+	client     horizon.HTTP
+	maxRetries int
+	logger     Logger
+}
+
+// Do sends req, and retries it while it's rate limited.
+func (r *retryHTTP) Do(req *http.Request) (*http.Response, error) {
+	backoff := retryBackoff
+	for i := 0; ; i++ {
+		resp, err := r.client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || i >= r.maxRetries {
+			return resp, err
+		}
+
+		// Requests with bodies can only be retried if the body can be replayed.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		delay := retryAfter(resp, backoff)
+		resp.Body.Close()
+		backoff *= 2
+
+		debugf(r.logger, "retryHTTP.Do", "rate limited, retrying %s in %v (%d of %d)", req.URL, delay, i+1, r.maxRetries)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// Get issues a GET to url, and retries it while it's rate limited.
+func (r *retryHTTP) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Do(req)
+}
+
+// PostForm issues a form-encoded POST to url, and retries it while it's rate limited.
+func (r *retryHTTP) PostForm(url string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.Do(req)
+}
+
+// retryAfter returns the delay requested by the Retry-After header of resp (in seconds, or as an
+// HTTP date), or backoff if there's none.
+func retryAfter(resp *http.Response, backoff time.Duration) time.Duration {
+	delay := backoff
+
+	if header := resp.Header.Get("Retry-After"); header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+			delay = time.Duration(seconds) * time.Second
+		} else if t, err := http.ParseTime(header); err == nil {
+			delay = time.Until(t)
+		}
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+// HorizonClient returns the Horizon client used by ms, configured with the client's network URL and
+// HTTP client. Use it for Horizon features that microstellar doesn't wrap.
+//
+//   root, err := ms.HorizonClient().Root()
+//
+// The client is shared, so don't modify it. On the fake network, this is the testnet client.
+func (ms *MicroStellar) HorizonClient() *horizon.Client {
+	return ms.newTx().GetClient()
+}
+
+// newTx returns a new Tx for the client's network that uses the client's cached Horizon client.
+func (ms *MicroStellar) newTx() *Tx {
+	tx := NewTx(ms.networkName, ms.params)
+	tx.client = ms.horizonClient(tx.client)
+	tx.ledger = ms.fakeLedger()
+	return tx
+}
+
+// horizonClient returns the client's cached Horizon client if it talks to the same server, over
+// the same HTTP client, as fresh. Otherwise, e.g., if the custom URL or HTTP client in the
+// parameters changed, fresh replaces the cached client.
+func (ms *MicroStellar) horizonClient(fresh *horizon.Client) *horizon.Client {
+	ms.clientMu.Lock()
+	defer ms.clientMu.Unlock()
+
+	if ms.client != nil && ms.client.URL == fresh.URL && sameHTTP(ms.client.HTTP, fresh.HTTP) {
+		return ms.client
+	}
+
+	ms.client = fresh
+	return fresh
+}
+
+// sameHTTP returns true if a and b send requests the same way.
+func sameHTTP(a, b horizon.HTTP) bool {
+	ra, okA := a.(*retryHTTP)
+	rb, okB := b.(*retryHTTP)
+	if okA && okB {
+		return ra.client == rb.client && ra.maxRetries == rb.maxRetries && sameLogger(ra.logger, rb.logger)
+	}
+
+	return okA == okB && a == b
+}
+
+// sameLogger returns true if a and b are the same logger. Loggers of uncomparable types are
+// never the same.
+func sameLogger(a, b Logger) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+// contextHTTP is a horizon.HTTP implementation that binds every request to ctx, so
+// in-flight requests are aborted as soon as ctx is cancelled or its deadline expires.
+type contextHTTP struct {
+	ctx    context.Context
+	client horizon.HTTP
+}
+
+// Do sends req bound to the context.
+func (c *contextHTTP) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req.WithContext(c.ctx))
+}
+
+// Get issues a GET to url bound to the context.
+func (c *contextHTTP) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+// PostForm issues a form-encoded POST to url bound to the context.
+func (c *contextHTTP) PostForm(url string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req)
+}
+
+// recordingHTTP is a horizon.HTTP implementation that keeps the body of the last response, so
+// fields the vendored Horizon client doesn't know about can be decoded.
+type recordingHTTP struct {
+	client horizon.HTTP
+	body   []byte
+}
+
+// record reads and keeps the body of resp, and replaces it with a copy.
+func (r *recordingHTTP) record(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return resp, err
+	}
+
+	r.body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(r.body))
+	return resp, nil
+}
+
+// Do sends req and records the response.
+func (r *recordingHTTP) Do(req *http.Request) (*http.Response, error) {
+	return r.record(r.client.Do(req))
+}
+
+// Get issues a GET to url and records the response.
+func (r *recordingHTTP) Get(url string) (*http.Response, error) {
+	return r.record(r.client.Get(url))
+}
+
+// PostForm issues a form-encoded POST to url and records the response.
+func (r *recordingHTTP) PostForm(url string, data url.Values) (*http.Response, error) {
+	return r.record(r.client.PostForm(url, data))
+}
+
+// clientWithContext returns a copy of client whose requests are bound to ctx.
+func clientWithContext(ctx context.Context, client *horizon.Client) *horizon.Client {
+	if ctx == nil || ctx.Done() == nil {
+		// Context can never be cancelled.
+		return client
+	}
+
+	return &horizon.Client{
+		URL:  client.URL,
+		HTTP: &contextHTTP{ctx: ctx, client: client.HTTP},
+	}
+}
+
+// optionsWithContext returns options with ctx attached, without modifying the caller's
+// *Options.
+func optionsWithContext(ctx context.Context, options []*Options) []*Options {
+	opts := *mergeOptions(options)
+	opts.ctx = ctx
+	return []*Options{&opts}
+}
+
+// getJSON fetches path (relative to the Horizon root, including any query string) and
+// decodes the JSON response into v. Use this for endpoints the vendored Horizon client
+// doesn't support.
+func getJSON(logger Logger, client *horizon.Client, path string, v interface{}) error {
+	endpoint := strings.TrimRight(client.URL, "/") + path
+	if _, err := url.Parse(endpoint); err != nil {
+		return errors.Wrapf(err, "endpoint parse error")
+	}
+
+	debugf(logger, "getJSON", "querying endpoint: %s", endpoint)
+	resp, err := client.HTTP.Get(endpoint)
+	if err != nil {
+		return errors.Wrapf(err, "failed to query server")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to query server: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "error unmarshalling response")
+	}
+
+	return nil
+}
+
+// pageQuery returns the Horizon paging parameters (limit, cursor and order) set in opt.
+func pageQuery(opt *Options) url.Values {
+	query := url.Values{}
+
+	if opt.hasLimit {
+		query.Add("limit", fmt.Sprintf("%d", opt.limit))
+	}
+
+	if opt.hasCursor {
+		query.Add("cursor", opt.cursor)
+	}
+
+	if opt.sortDescending {
+		query.Add("order", "desc")
+	} else {
+		query.Add("order", "asc")
+	}
+
+	return query
+}
@@ -0,0 +1,206 @@
+package microstellar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newSlowServer returns a test server that doesn't respond to requests until stop is closed.
+func newSlowServer(stop chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stop
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+}
+
+func TestContextCancelsRequests(t *testing.T) {
+	stop := make(chan struct{})
+	server := newSlowServer(stop)
+	defer server.Close()
+	defer close(stop)
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := ms.SubmitTransactionWithContext(ctx, "AAAA"); err == nil {
+		t.Errorf("SubmitTransactionWithContext should fail when context expires")
+	}
+
+	if _, err := ms.LoadAccountWithContext(ctx, "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"); err == nil {
+		t.Errorf("LoadAccountWithContext should fail when context expires")
+	}
+
+	err := ms.PayWithContext(ctx, "SAED4QHN3USETFHECASIM2LRI3H4QTVKZK44D2RC27IICZPZQEGXGXFC",
+		"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "1", NativeAsset)
+	if err == nil {
+		t.Errorf("PayWithContext should fail when context expires")
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("requests were not aborted: took %v", elapsed)
+	}
+}
+
+// countingTransport counts the requests it forwards.
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	transport := &countingTransport{}
+	params := Params{"url": server.URL, "passphrase": "test"}
+	ms := New("custom", params).WithHTTPClient(&http.Client{Transport: transport, Timeout: time.Second})
+
+	if _, err := ms.LoadAccount("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"); err != nil {
+		t.Fatalf("LoadAccount failed: %v", err)
+	}
+
+	if transport.requests != 1 {
+		t.Errorf("want 1 request through custom client, got %d", transport.requests)
+	}
+
+	if _, ok := params[httpClientParam]; ok {
+		t.Errorf("WithHTTPClient should not modify the caller's params")
+	}
+
+	// The client should carry over to other networks.
+	ms.OnNetwork("custom").LoadAccount("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E")
+	if transport.requests != 2 {
+		t.Errorf("want 2 requests through custom client, got %d", transport.requests)
+	}
+}
+
+func TestHorizonClientReuse(t *testing.T) {
+	params := Params{"url": "http://horizon-1", "passphrase": "test"}
+	ms := New("custom", params)
+
+	client := ms.getTx().GetClient()
+	if ms.getTx().GetClient() != client {
+		t.Errorf("want Horizon client reused across calls")
+	}
+
+	params["url"] = "http://horizon-2"
+	if got := ms.getTx().GetClient(); got == client || got.URL != "http://horizon-2" {
+		t.Errorf("want new Horizon client after URL change, got %s", got.URL)
+	}
+
+	client = ms.getTx().GetClient()
+	ms.WithHTTPClient(&http.Client{Timeout: time.Second})
+	if got := ms.getTx().GetClient(); got == client || got.HTTP == http.DefaultClient {
+		t.Errorf("want new Horizon client after HTTP client change")
+	}
+
+	if New("public").getTx().GetClient() != New("public").getTx().GetClient() {
+		t.Errorf("want shared Horizon client on the public network")
+	}
+}
+
+func TestHorizonClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"horizon_version": "1.2.3", "network_passphrase": "test"}`)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	root, err := ms.HorizonClient().Root()
+	if err != nil {
+		t.Fatalf("can't load root: %v", err)
+	}
+
+	if root.HorizonVersion != "1.2.3" {
+		t.Errorf("want horizon version 1.2.3, got %s", root.HorizonVersion)
+	}
+
+	if ms.HorizonClient() != ms.getTx().GetClient() {
+		t.Errorf("want the client's own Horizon client")
+	}
+}
+
+func TestRateLimitRetries(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	limited := 0   // requests to rate limit before each success
+	remaining := 0 // rate limited requests left
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if remaining > 0 {
+			remaining--
+			if remaining%2 == 0 {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		remaining = limited
+
+		if r.Method == "POST" {
+			if r.FormValue("tx") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, testTxSuccess)
+			return
+		}
+
+		fmt.Fprint(w, `{"id": "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "sequence": "100"}`)
+	}))
+	defer server.Close()
+
+	limited, remaining = 2, 2
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test", "maxRetries": 3})
+
+	if _, err := ms.LoadAccount(target); err != nil {
+		t.Errorf("LoadAccount failed: %v", ErrorString(err))
+	}
+
+	if requests != 3 {
+		t.Errorf("want 3 requests, got %d", requests)
+	}
+
+	// Loads the sequence number, then submits.
+	if err := ms.Pay(source, target, "1", NativeAsset); err != nil {
+		t.Errorf("Pay failed: %v", ErrorString(err))
+	}
+
+	txe, _ := ms.LastPayload()
+	if _, err := ms.SubmitTransaction(txe); err != nil {
+		t.Errorf("SubmitTransaction failed: %v", ErrorString(err))
+	}
+
+	if requests != 12 {
+		t.Errorf("want 12 requests, got %d", requests)
+	}
+
+	// Give up after maxRetries.
+	limited, remaining = 5, 5
+	if _, err := ms.LoadAccount(target); err == nil {
+		t.Errorf("want error when retries run out")
+	}
+
+	// No retries by default.
+	limited, remaining = 1, 1
+	if _, err := New("custom", Params{"url": server.URL, "passphrase": "test"}).LoadAccount(target); err == nil {
+		t.Errorf("want error without retries")
+	}
+}
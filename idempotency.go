@@ -0,0 +1,98 @@
+package microstellar
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// idempotencyWindow is the number of recent transactions on the source account checked for an
+// idempotency memo (the most Horizon returns in one page.)
+const idempotencyWindow = 200
+
+// idempotencyMemo returns the hash memo for the idempotency key key.
+func idempotencyMemo(key string) [32]byte {
+	return sha256.Sum256([]byte(key))
+}
+
+// findIdempotent returns the recent transaction on tx's source account with tx's idempotency
+// memo, or nil if there isn't one (or tx doesn't have an idempotency memo.)
+func (ms *MicroStellar) findIdempotent(tx *Tx) (*Transaction, error) {
+	opts := tx.options
+	if opts == nil || !opts.idempotent || opts.dryRun || tx.err != nil || tx.fake {
+		return nil, nil
+	}
+
+	if opts.memoType != MemoHash || opts.memoHash != idempotencyMemo(opts.idempotencyKey) {
+		return nil, ms.errorf("can't check idempotency: memo replaced after WithIdempotencyMemo")
+	}
+
+	source, err := idempotencySource(tx)
+	if err != nil {
+		return nil, ms.wrapf(err, "can't check idempotency")
+	}
+
+	txs, err := ms.LoadTransactions(source, Opts().WithContext(opts.ctx).WithLimit(idempotencyWindow).WithSortOrder(SortDescending))
+	if err != nil {
+		return nil, ms.wrapf(err, "can't check idempotency")
+	}
+
+	memo := base64.StdEncoding.EncodeToString(opts.memoHash[:])
+	for i, t := range txs {
+		if t.MemoType == "hash" && t.Memo == memo {
+			return &txs[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// idempotencySource returns the address of tx's source account, which pays for (and sequences)
+// the transaction: the channel account, if there is one.
+func idempotencySource(tx *Tx) (string, error) {
+	if tx.options.channelSeed != "" {
+		return addressOf(tx.options.channelSeed)
+	}
+
+	if tx.isMultiOp {
+		return addressOf(tx.sourceAccount)
+	}
+
+	if tx.builder == nil {
+		return "", errors.Errorf("transaction not built")
+	}
+
+	return tx.builder.TX.SourceAccount.Address(), nil
+}
+
+// skipIdempotent returns true if tx has an idempotency memo that's already on a recent
+// transaction, in which case tx is closed with that transaction as its response. Returns false
+// and the error if the check failed.
+func (ms *MicroStellar) skipIdempotent(tx *Tx) (bool, error) {
+	found, err := ms.findIdempotent(tx)
+	if err != nil {
+		tx.err = err
+		return false, err
+	}
+
+	if found == nil {
+		return false, nil
+	}
+
+	ms.debugf("skipIdempotent", "transaction %s already has idempotency memo, not submitting", found.Hash)
+	tx.response = &TxResponse{
+		TransactionSuccess: horizon.TransactionSuccess{
+			Hash:   found.Hash,
+			Ledger: found.Ledger,
+			Env:    found.EnvelopeXdr,
+			Result: found.ResultXdr,
+			Meta:   found.ResultMetaXdr,
+		},
+		Ledger:    int64(found.Ledger),
+		CreatedAt: found.LedgerCloseTime,
+	}
+	tx.submitted = true
+	return true, nil
+}
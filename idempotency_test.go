@@ -0,0 +1,78 @@
+package microstellar
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithIdempotencyMemo(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	sourceAddress := "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	memo := idempotencyMemo("invoice-1")
+	submissions := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			submissions++
+			fmt.Fprint(w, testTxSuccess)
+		case strings.HasSuffix(r.URL.Path, "/transactions"):
+			if r.URL.Path != "/accounts/"+sourceAddress+"/transactions" || r.URL.Query().Get("order") != "desc" {
+				t.Errorf("wrong transactions query: %s", r.URL)
+			}
+			fmt.Fprintf(w, `{"_embedded": {"records": [
+				{"hash": "aaaa", "ledger": 8, "memo_type": "text", "memo": "invoice-1"},
+				{"hash": "bbbb", "ledger": 7, "memo_type": "hash", "memo": "%s"}]}}`, base64.StdEncoding.EncodeToString(memo[:]))
+		default:
+			fmt.Fprintf(w, `{"id": "%s", "sequence": "100"}`, sourceAddress)
+		}
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	// Already paid: nothing is submitted, and the earlier transaction is the response.
+	if err := ms.PayNative(source, target, "10", Opts().WithIdempotencyMemo("invoice-1")); err != nil {
+		t.Fatalf("idempotent payment failed: %v", ErrorString(err))
+	}
+
+	if submissions != 0 {
+		t.Errorf("want no submissions, got %d", submissions)
+	}
+
+	if resp := ms.Response(); resp.Hash != "bbbb" || resp.Ledger != 7 {
+		t.Errorf("want earlier transaction as response, got %+v", resp)
+	}
+
+	// Not paid yet.
+	if err := ms.PayNative(source, target, "10", Opts().WithIdempotencyMemo("invoice-2")); err != nil {
+		t.Fatalf("idempotent payment failed: %v", ErrorString(err))
+	}
+
+	if submissions != 1 {
+		t.Errorf("want 1 submission, got %d", submissions)
+	}
+
+	payload, _ := ms.LastPayload()
+	txe, _ := DecodeTx(payload)
+	if hash, ok := txe.Tx.Memo.GetHash(); !ok || [32]byte(hash) != idempotencyMemo("invoice-2") {
+		t.Errorf("want idempotency memo, got %+v", txe.Tx.Memo)
+	}
+
+	// Multi-op transactions are checked too.
+	ms.Start(source, Opts().WithIdempotencyMemo("invoice-1"))
+	ms.PayNative(source, target, "1")
+	ms.PayNative(source, target, "2")
+	if err := ms.Submit(); err != nil || submissions != 1 {
+		t.Errorf("multi-op idempotent payment: err %v, %d submissions", err, submissions)
+	}
+
+	if err := ms.PayNative(source, target, "10", Opts().WithIdempotencyMemo("invoice-1").WithMemoText("oops")); err == nil {
+		t.Errorf("want error for replaced idempotency memo")
+	}
+}
@@ -0,0 +1,456 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// Envelope types that precede the transaction in newer (protocol 13+) envelopes. Legacy
+// envelopes start with the source account's key type (0) instead.
+const (
+	envelopeTypeTx        = 2
+	envelopeTypeTxFeeBump = 5
+)
+
+// OperationInfo is a human-readable summary of a single operation in a transaction. Type is the
+// Horizon name of the operation (e.g., "payment", "manage_offer"), and Fields has its key
+// parameters, with amounts formatted as decimal strings and assets as "CODE:ISSUER" or "native".
+type OperationInfo struct {
+	Type   string            `json:"type"`
+	Source string            `json:"source,omitempty"`
+	Fields map[string]string `json:"fields"`
+}
+
+// FeeBumpInfo describes the outer transaction of a fee-bump envelope, which pays the fee for the
+// inner transaction.
+type FeeBumpInfo struct {
+	FeeSource  string `json:"fee_source"`
+	Fee        int64  `json:"fee"`
+	Signatures int    `json:"signatures"`
+}
+
+// TransactionInfo is a human-readable summary of a transaction envelope, as returned by
+// InspectTransaction. MinTime and MaxTime are zero if unbounded. For fee-bump envelopes, FeeBump
+// describes the outer transaction, and the other fields describe the inner one.
+type TransactionInfo struct {
+	Source     string          `json:"source"`
+	Fee        uint32          `json:"fee"`
+	Sequence   string          `json:"seq"`
+	MemoType   string          `json:"memo_type"`
+	Memo       string          `json:"memo,omitempty"`
+	MinTime    time.Time       `json:"min_time"`
+	MaxTime    time.Time       `json:"max_time"`
+	Operations []OperationInfo `json:"operations"`
+	Signatures int             `json:"signatures"`
+	FeeBump    *FeeBumpInfo    `json:"fee_bump,omitempty"`
+}
+
+// assetString returns the "CODE:ISSUER" (or "native") form of an XDR asset.
+func assetString(a xdr.Asset) string {
+	asset, err := newAssetFromXDR(a)
+	if err != nil {
+		return "invalid"
+	}
+
+	return asset.String()
+}
+
+// amountString formats an XDR amount as a decimal string.
+func amountString(v xdr.Int64) string {
+	return ToAmountString(int64(v))
+}
+
+// newMemoInfo returns the memo type and a printable value for the memo.
+func newMemoInfo(memo xdr.Memo) (string, string) {
+	switch memo.Type {
+	case xdr.MemoTypeMemoText:
+		return "text", memo.MustText()
+	case xdr.MemoTypeMemoId:
+		return "id", fmt.Sprintf("%d", memo.MustId())
+	case xdr.MemoTypeMemoHash:
+		hash := memo.MustHash()
+		return "hash", hex.EncodeToString(hash[:])
+	case xdr.MemoTypeMemoReturn:
+		hash := memo.MustRetHash()
+		return "return", hex.EncodeToString(hash[:])
+	}
+
+	return "none", ""
+}
+
+// newOperationInfo summarizes an XDR operation.
+func newOperationInfo(op xdr.Operation) OperationInfo {
+	info := OperationInfo{Fields: map[string]string{}}
+	if op.SourceAccount != nil {
+		info.Source = op.SourceAccount.Address()
+	}
+
+	f := info.Fields
+	body := op.Body
+
+	switch body.Type {
+	case xdr.OperationTypeCreateAccount:
+		o := body.MustCreateAccountOp()
+		info.Type = "create_account"
+		f["destination"] = o.Destination.Address()
+		f["starting_balance"] = amountString(o.StartingBalance)
+	case xdr.OperationTypePayment:
+		o := body.MustPaymentOp()
+		info.Type = "payment"
+		f["destination"] = o.Destination.Address()
+		f["asset"] = assetString(o.Asset)
+		f["amount"] = amountString(o.Amount)
+	case xdr.OperationTypePathPayment:
+		o := body.MustPathPaymentOp()
+		info.Type = "path_payment"
+		f["destination"] = o.Destination.Address()
+		f["send_asset"] = assetString(o.SendAsset)
+		f["send_max"] = amountString(o.SendMax)
+		f["dest_asset"] = assetString(o.DestAsset)
+		f["dest_amount"] = amountString(o.DestAmount)
+
+		path := []string{}
+		for _, a := range o.Path {
+			path = append(path, assetString(a))
+		}
+		f["path"] = strings.Join(path, ",")
+	case xdr.OperationTypeManageOffer:
+		o := body.MustManageOfferOp()
+		info.Type = "manage_offer"
+		f["selling"] = assetString(o.Selling)
+		f["buying"] = assetString(o.Buying)
+		f["amount"] = amountString(o.Amount)
+		f["price"] = o.Price.String()
+		f["offer_id"] = fmt.Sprintf("%d", o.OfferId)
+	case xdr.OperationTypeCreatePassiveOffer:
+		o := body.MustCreatePassiveOfferOp()
+		info.Type = "create_passive_offer"
+		f["selling"] = assetString(o.Selling)
+		f["buying"] = assetString(o.Buying)
+		f["amount"] = amountString(o.Amount)
+		f["price"] = o.Price.String()
+	case xdr.OperationTypeSetOptions:
+		o := body.MustSetOptionsOp()
+		info.Type = "set_options"
+		if o.InflationDest != nil {
+			f["inflation_dest"] = o.InflationDest.Address()
+		}
+		if o.ClearFlags != nil {
+			f["clear_flags"] = fmt.Sprintf("%d", *o.ClearFlags)
+		}
+		if o.SetFlags != nil {
+			f["set_flags"] = fmt.Sprintf("%d", *o.SetFlags)
+		}
+		if o.MasterWeight != nil {
+			f["master_weight"] = fmt.Sprintf("%d", *o.MasterWeight)
+		}
+		if o.LowThreshold != nil {
+			f["low_threshold"] = fmt.Sprintf("%d", *o.LowThreshold)
+		}
+		if o.MedThreshold != nil {
+			f["med_threshold"] = fmt.Sprintf("%d", *o.MedThreshold)
+		}
+		if o.HighThreshold != nil {
+			f["high_threshold"] = fmt.Sprintf("%d", *o.HighThreshold)
+		}
+		if o.HomeDomain != nil {
+			f["home_domain"] = string(*o.HomeDomain)
+		}
+		if o.Signer != nil {
+			f["signer_key"] = o.Signer.Key.Address()
+			f["signer_weight"] = fmt.Sprintf("%d", o.Signer.Weight)
+		}
+	case xdr.OperationTypeChangeTrust:
+		o := body.MustChangeTrustOp()
+		info.Type = "change_trust"
+		f["asset"] = assetString(o.Line)
+		f["limit"] = amountString(o.Limit)
+	case xdr.OperationTypeAllowTrust:
+		o := body.MustAllowTrustOp()
+		info.Type = "allow_trust"
+		f["trustor"] = o.Trustor.Address()
+		switch o.Asset.Type {
+		case xdr.AssetTypeAssetTypeCreditAlphanum4:
+			code := o.Asset.MustAssetCode4()
+			f["asset_code"] = strings.TrimRight(string(code[:]), "\x00")
+		case xdr.AssetTypeAssetTypeCreditAlphanum12:
+			code := o.Asset.MustAssetCode12()
+			f["asset_code"] = strings.TrimRight(string(code[:]), "\x00")
+		}
+		f["authorize"] = fmt.Sprintf("%v", o.Authorize)
+	case xdr.OperationTypeAccountMerge:
+		destination := body.MustDestination()
+		info.Type = "account_merge"
+		f["destination"] = destination.Address()
+	case xdr.OperationTypeInflation:
+		info.Type = "inflation"
+	case xdr.OperationTypeManageData:
+		o := body.MustManageDataOp()
+		info.Type = "manage_data"
+		f["name"] = string(o.DataName)
+		if o.DataValue != nil {
+			f["value"] = base64.StdEncoding.EncodeToString(*o.DataValue)
+		}
+	case xdr.OperationTypeBumpSequence:
+		o := body.MustBumpSequenceOp()
+		info.Type = "bump_sequence"
+		f["bump_to"] = fmt.Sprintf("%d", o.BumpTo)
+	default:
+		info.Type = fmt.Sprintf("unknown(%d)", body.Type)
+	}
+
+	return info
+}
+
+// checkEnvelopeType returns an error if b64Tx is a newer (v1 or fee-bump) envelope, which this
+// version of the XDR library can't decode.
+func checkEnvelopeType(b64Tx string) error {
+	raw, err := base64.StdEncoding.DecodeString(b64Tx)
+	if err != nil || len(raw) < 4 {
+		// Let the decoder report the error.
+		return nil
+	}
+
+	switch binary.BigEndian.Uint32(raw[:4]) {
+	case envelopeTypeTx:
+		return errors.Errorf("unsupported envelope type: v1 transaction envelopes are not supported")
+	case envelopeTypeTxFeeBump:
+		return errors.Errorf("unsupported envelope type: fee-bump envelopes are not supported")
+	}
+
+	return nil
+}
+
+// InspectTransaction decodes the base64-encoded transaction envelope b64Tx and returns a
+// human-readable summary of it. Use this to show users what they're about to sign.
+//
+//   info, err := ms.InspectTransaction(b64Tx)
+//   for _, op := range info.Operations {
+//       log.Printf("%s: %v", op.Type, op.Fields)
+//   }
+//
+// Legacy, v1, and fee-bump envelopes are supported.
+func (ms *MicroStellar) InspectTransaction(b64Tx string) (*TransactionInfo, error) {
+	info, err := inspectEnvelope(b64Tx)
+	if err != nil {
+		return nil, ms.wrapf(err, "can't inspect transaction")
+	}
+
+	return info, ms.success()
+}
+
+// inspectEnvelope decodes the base64-encoded transaction envelope b64Tx into a TransactionInfo.
+func inspectEnvelope(b64Tx string) (*TransactionInfo, error) {
+	raw, err := base64.StdEncoding.DecodeString(b64Tx)
+	if err == nil && len(raw) >= 4 {
+		switch binary.BigEndian.Uint32(raw[:4]) {
+		case envelopeTypeTx, envelopeTypeTxFeeBump:
+			return inspectNewEnvelope(raw)
+		}
+	}
+
+	txe, err := DecodeTx(b64Tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := txe.Tx
+	info := &TransactionInfo{
+		Source:     tx.SourceAccount.Address(),
+		Fee:        uint32(tx.Fee),
+		Sequence:   fmt.Sprintf("%d", tx.SeqNum),
+		Signatures: len(txe.Signatures),
+	}
+
+	info.MemoType, info.Memo = newMemoInfo(tx.Memo)
+	info.setTimeBounds(tx.TimeBounds)
+	info.setOperations(tx.Operations)
+	return info, nil
+}
+
+// setTimeBounds sets the time bounds of info from tb, which is nil if unbounded.
+func (info *TransactionInfo) setTimeBounds(tb *xdr.TimeBounds) {
+	if tb == nil {
+		return
+	}
+
+	if tb.MinTime > 0 {
+		info.MinTime = time.Unix(int64(tb.MinTime), 0).UTC()
+	}
+	if tb.MaxTime > 0 {
+		info.MaxTime = time.Unix(int64(tb.MaxTime), 0).UTC()
+	}
+}
+
+// setOperations sets the operations of info from ops.
+func (info *TransactionInfo) setOperations(ops []xdr.Operation) {
+	info.Operations = make([]OperationInfo, len(ops))
+	for i, op := range ops {
+		info.Operations[i] = newOperationInfo(op)
+	}
+}
+
+// envelopeReader decodes v1 and fee-bump envelopes, which the vendored XDR package predates,
+// from their parts. The first error sticks.
+type envelopeReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (er *envelopeReader) decode(v interface{}) {
+	if er.err == nil {
+		_, er.err = xdr.Unmarshal(er.r, v)
+	}
+}
+
+func (er *envelopeReader) uint32() uint32 {
+	var v xdr.Uint32
+	er.decode(&v)
+	return uint32(v)
+}
+
+func (er *envelopeReader) fail(format string, args ...interface{}) {
+	if er.err == nil {
+		er.err = errors.Errorf(format, args...)
+	}
+}
+
+// muxedAccount decodes an XDR MuxedAccount into a G... or M... address.
+func (er *envelopeReader) muxedAccount() string {
+	keyType := er.uint32()
+
+	var id xdr.Uint64
+	if keyType == keyTypeMuxedEd25519 {
+		er.decode(&id)
+	} else if keyType != 0 {
+		er.fail("unsupported account type: %d", keyType)
+	}
+
+	var key xdr.Uint256
+	er.decode(&key)
+	if er.err != nil {
+		return ""
+	}
+
+	address, err := strkey.Encode(strkey.VersionByteAccountID, key[:])
+	if err == nil && keyType == keyTypeMuxedEd25519 {
+		address, err = MuxedAddress(address, uint64(id))
+	}
+
+	if err != nil {
+		er.err = err
+	}
+
+	return address
+}
+
+// v1Envelope decodes a TransactionV1Envelope (without its envelope type) into info.
+func (er *envelopeReader) v1Envelope(info *TransactionInfo) {
+	info.Source = er.muxedAccount()
+	info.Fee = er.uint32()
+
+	var seq xdr.SequenceNumber
+	er.decode(&seq)
+	info.Sequence = fmt.Sprintf("%d", seq)
+
+	// Preconditions: none, or time bounds (which encode like the optional time bounds of older
+	// protocols.)
+	switch cond := er.uint32(); cond {
+	case 0:
+	case 1:
+		var tb xdr.TimeBounds
+		er.decode(&tb)
+		info.setTimeBounds(&tb)
+	default:
+		er.fail("unsupported transaction preconditions: %d", cond)
+	}
+
+	var memo xdr.Memo
+	er.decode(&memo)
+	info.MemoType, info.Memo = newMemoInfo(memo)
+
+	var ops []xdr.Operation
+	er.decode(&ops)
+	info.setOperations(ops)
+
+	if ext := er.uint32(); ext != 0 {
+		er.fail("unsupported transaction extension: %d", ext)
+	}
+
+	var sigs []xdr.DecoratedSignature
+	er.decode(&sigs)
+	info.Signatures = len(sigs)
+}
+
+// inspectNewEnvelope decodes the v1 or fee-bump envelope in raw into a TransactionInfo.
+func inspectNewEnvelope(raw []byte) (*TransactionInfo, error) {
+	er := &envelopeReader{r: bytes.NewReader(raw)}
+	info := &TransactionInfo{}
+
+	if er.uint32() == envelopeTypeTxFeeBump {
+		feeBump := &FeeBumpInfo{FeeSource: er.muxedAccount()}
+
+		var fee xdr.Int64
+		er.decode(&fee)
+		feeBump.Fee = int64(fee)
+
+		if innerType := er.uint32(); innerType != envelopeTypeTx {
+			er.fail("unsupported inner envelope type: %d", innerType)
+		}
+
+		er.v1Envelope(info)
+
+		if ext := er.uint32(); ext != 0 {
+			er.fail("unsupported fee-bump extension: %d", ext)
+		}
+
+		var sigs []xdr.DecoratedSignature
+		er.decode(&sigs)
+		feeBump.Signatures = len(sigs)
+		info.FeeBump = feeBump
+	} else {
+		er.v1Envelope(info)
+	}
+
+	if er.err == nil && er.r.Len() != 0 {
+		er.fail("input not fully consumed: %d bytes left", er.r.Len())
+	}
+
+	if er.err != nil {
+		return nil, errors.Wrap(er.err, "error decoding base64 transaction")
+	}
+
+	return info, nil
+}
+
+// IsTransactionExpired returns true if the base64-encoded transaction envelope b64Tx has a
+// maximum time bound that has already passed, so Horizon would reject it with tx_too_late.
+// Returns false if the transaction has no maximum time bound.
+//
+//   if expired, err := ms.IsTransactionExpired(b64Tx); err == nil && !expired {
+//       ms.SubmitTransaction(b64Tx)
+//   }
+//
+// Validators check the bound against the close time of the ledger the transaction lands in, which
+// may be a few seconds after now, so transactions close to their maximum time can still fail.
+func (ms *MicroStellar) IsTransactionExpired(b64Tx string) (bool, error) {
+	info, err := inspectEnvelope(b64Tx)
+	if err != nil {
+		return false, ms.wrapf(err, "can't check transaction expiry")
+	}
+
+	if info.MaxTime.IsZero() {
+		return false, ms.success()
+	}
+
+	return time.Now().After(info.MaxTime), ms.success()
+}
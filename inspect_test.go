@@ -0,0 +1,151 @@
+package microstellar
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/build"
+)
+
+func TestInspectTransaction(t *testing.T) {
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	target := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	maxTime := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tx, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: source},
+		build.Sequence{Sequence: 101},
+		build.TestNetwork,
+		build.MemoText{Value: "rent"},
+		build.Timebounds{MaxTime: uint64(maxTime.Unix())},
+		build.Payment(build.Destination{AddressOrSeed: target}, build.CreditAmount{Code: "USD", Issuer: issuer, Amount: "12.5"}),
+		build.SetOptions(build.HomeDomain("qubit.sh")),
+	)
+	if err != nil {
+		t.Fatalf("can't build transaction: %v", err)
+	}
+
+	txe, _ := tx.Sign()
+	b64Tx, _ := txe.Base64()
+
+	info, err := New("fake").InspectTransaction(b64Tx)
+	if err != nil {
+		t.Fatalf("InspectTransaction failed: %v", err)
+	}
+
+	if info.Source != source || info.Sequence != "101" || info.Fee != 200 || info.MemoType != "text" || info.Memo != "rent" {
+		t.Errorf("wrong transaction info: %+v", info)
+	}
+
+	if !info.MinTime.IsZero() || !info.MaxTime.Equal(maxTime) {
+		t.Errorf("wrong time bounds: %v - %v", info.MinTime, info.MaxTime)
+	}
+
+	if len(info.Operations) != 2 {
+		t.Fatalf("want 2 operations, got %d", len(info.Operations))
+	}
+
+	payment := info.Operations[0]
+	if payment.Type != "payment" || payment.Fields["destination"] != target ||
+		payment.Fields["asset"] != "USD:"+issuer || payment.Fields["amount"] != "12.5000000" {
+		t.Errorf("wrong payment: %+v", payment)
+	}
+
+	if op := info.Operations[1]; op.Type != "set_options" || op.Fields["home_domain"] != "qubit.sh" {
+		t.Errorf("wrong set_options: %+v", op)
+	}
+
+	// A v1 envelope encodes like a legacy one (with no muxed accounts), after its envelope type.
+	legacy, _ := base64.StdEncoding.DecodeString(b64Tx)
+	v1 := append([]byte{0, 0, 0, 2}, legacy...)
+
+	info, err = New("fake").InspectTransaction(base64.StdEncoding.EncodeToString(v1))
+	if err != nil {
+		t.Fatalf("InspectTransaction failed for v1 envelope: %v", err)
+	}
+
+	if info.Source != source || info.Sequence != "101" || len(info.Operations) != 2 || !info.MaxTime.Equal(maxTime) || info.FeeBump != nil {
+		t.Errorf("wrong v1 transaction info: %+v", info)
+	}
+
+	feeSource, _ := MuxedAddress(target, 7)
+	w := &xdrWriter{}
+	w.uint32(envelopeTypeTxFeeBump)
+	w.muxedAccount(feeSource)
+	w.int64(1000)
+	w.raw(v1)
+	w.uint32(0) // ext
+	w.uint32(0) // no signatures
+	feeBump, _ := w.bytes()
+
+	info, err = New("fake").InspectTransaction(base64.StdEncoding.EncodeToString(feeBump))
+	if err != nil {
+		t.Fatalf("InspectTransaction failed for fee-bump envelope: %v", err)
+	}
+
+	if info.FeeBump == nil || info.FeeBump.FeeSource != feeSource || info.FeeBump.Fee != 1000 || info.FeeBump.Signatures != 0 {
+		t.Errorf("wrong fee bump: %+v", info.FeeBump)
+	}
+
+	if info.Source != source || info.Fee != 200 || info.Memo != "rent" || len(info.Operations) != 2 || info.Signatures != 0 {
+		t.Errorf("wrong inner transaction info: %+v", info)
+	}
+
+	if _, err := New("fake").InspectTransaction(base64.StdEncoding.EncodeToString(feeBump[:len(feeBump)-2])); err == nil {
+		t.Errorf("InspectTransaction should reject truncated envelopes")
+	}
+}
+
+func TestIsTransactionExpired(t *testing.T) {
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	target := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	envelope := func(muts ...build.TransactionMutator) string {
+		muts = append(muts,
+			build.SourceAccount{AddressOrSeed: source},
+			build.Sequence{Sequence: 101},
+			build.TestNetwork,
+			build.Payment(build.Destination{AddressOrSeed: target}, build.NativeAmount{Amount: "1"}),
+		)
+
+		tx, err := build.Transaction(muts...)
+		if err != nil {
+			t.Fatalf("can't build transaction: %v", err)
+		}
+
+		txe, _ := tx.Sign()
+		b64Tx, _ := txe.Base64()
+		return b64Tx
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		b64Tx   string
+		expired bool
+	}{
+		{"unbounded", envelope(), false},
+		{"min time only", envelope(build.Timebounds{MinTime: uint64(now.Add(-time.Hour).Unix())}), false},
+		{"future", envelope(build.Timebounds{MaxTime: uint64(now.Add(time.Hour).Unix())}), false},
+		{"past", envelope(build.Timebounds{MaxTime: uint64(now.Add(-time.Hour).Unix())}), true},
+	}
+
+	ms := New("fake")
+	for _, test := range tests {
+		expired, err := ms.IsTransactionExpired(test.b64Tx)
+		if err != nil {
+			t.Errorf("%s: IsTransactionExpired failed: %v", test.name, err)
+			continue
+		}
+
+		if expired != test.expired {
+			t.Errorf("%s: want expired=%v, got %v", test.name, test.expired, expired)
+		}
+	}
+
+	if _, err := ms.IsTransactionExpired("not a transaction"); err == nil {
+		t.Errorf("IsTransactionExpired should fail for bad envelopes")
+	}
+}
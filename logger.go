@@ -0,0 +1,108 @@
+package microstellar
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// loggerParam is the parameter used to pass a custom Logger to Tx.
+const loggerParam = "logger"
+
+// Logger is the interface used by microstellar for logging. It's satisfied by logrus loggers, and
+// is easy to adapt to others (e.g., zap's SugaredLogger.)
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// SetLogger routes all of the client's logs to logger, instead of the logrus standard logger.
+// Messages are prefixed with the name of the method that logged them. Set logger to nil to go back
+// to logrus. Call this before sharing the client across goroutines.
+//
+//   ms := microstellar.New("public")
+//   ms.SetLogger(myLogger)
+//
+// At debug level, signed transactions are logged as base64-encoded envelopes, followed by their
+// decoded JSON (see DecodeTxToJSON.) Seeds are never logged.
+func (ms *MicroStellar) SetLogger(logger Logger) {
+	// Copy the parameters so we don't modify the caller's map.
+	p := Params{}
+	for k, v := range ms.params {
+		p[k] = v
+	}
+
+	if logger == nil {
+		delete(p, loggerParam)
+	} else {
+		p[loggerParam] = logger
+	}
+
+	ms.params = p
+}
+
+// loggerFromParams returns the custom logger in params, or nil if there isn't one.
+func loggerFromParams(params ...Params) Logger {
+	if len(params) == 0 {
+		return nil
+	}
+
+	logger, _ := params[0][loggerParam].(Logger)
+	return logger
+}
+
+// debugEnabled returns false if debug messages sent to logger would be dropped. Custom loggers
+// do their own filtering, so they're always enabled.
+func debugEnabled(logger Logger) bool {
+	return logger != nil || logrus.GetLevel() >= logrus.DebugLevel
+}
+
+// debugf logs msg at debug level to logger, or to logrus if logger is nil.
+func debugf(logger Logger, method string, msg string, args ...interface{}) {
+	if logger == nil {
+		logrus.WithFields(logrus.Fields{"lib": "microstellar", "method": method}).Debugf(msg, args...)
+		return
+	}
+
+	logger.Debugf(method+": "+msg, args...)
+}
+
+// infof logs msg at info level to logger. Without a custom logger, msg goes to logrus at debug
+// level, as it did before SetLogger, so the default output doesn't change.
+func infof(logger Logger, method string, msg string, args ...interface{}) {
+	if logger == nil {
+		debugf(nil, method, msg, args...)
+		return
+	}
+
+	logger.Infof(method+": "+msg, args...)
+}
+
+// errorf logs msg at error level to logger, or to logrus if logger is nil.
+func errorf(logger Logger, method string, msg string, args ...interface{}) {
+	if logger == nil {
+		logrus.WithFields(logrus.Fields{"lib": "microstellar", "method": method}).Errorf(msg, args...)
+		return
+	}
+
+	logger.Errorf(method+": "+msg, args...)
+}
+
+// logger returns the client's custom logger, or nil if there isn't one.
+func (ms *MicroStellar) logger() Logger {
+	return loggerFromParams(ms.params)
+}
+
+// debugf logs msg at debug level to the client's logger.
+func (ms *MicroStellar) debugf(method string, msg string, args ...interface{}) {
+	debugf(ms.logger(), method, msg, args...)
+}
+
+// debugf logs msg at debug level to the transaction's logger.
+func (tx *Tx) debugf(method string, msg string, args ...interface{}) {
+	debugf(tx.logger, method, msg, args...)
+}
+
+// infof logs msg at info level to the transaction's logger.
+func (tx *Tx) infof(method string, msg string, args ...interface{}) {
+	infof(tx.logger, method, msg, args...)
+}
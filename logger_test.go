@@ -0,0 +1,96 @@
+package microstellar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, "debug: "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.messages = append(l.messages, "info: "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.messages = append(l.messages, "error: "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) find(prefix string) string {
+	for _, m := range l.messages {
+		if strings.HasPrefix(m, prefix) {
+			return m
+		}
+	}
+
+	return ""
+}
+
+func TestSetLogger(t *testing.T) {
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	params := Params{"url": server.URL, "passphrase": "test"}
+	ms := New("custom", params)
+
+	logger := &recordingLogger{}
+	ms.SetLogger(logger)
+
+	if _, ok := params[loggerParam]; ok {
+		t.Errorf("SetLogger modified the caller's params")
+	}
+
+	if err := ms.Pay(seed, bob, "10", NativeAsset); err != nil {
+		t.Fatalf("pay failed: %v", ErrorString(err))
+	}
+
+	if logger.find("debug: Tx.Sign: signed transaction, payload: ") == "" {
+		t.Errorf("payload not logged: %v", logger.messages)
+	}
+
+	if logger.find("info: Tx.Submit: transaction submitted to ledger 10 with hash 3389e9f0") == "" {
+		t.Errorf("submission not logged: %v", logger.messages)
+	}
+
+	envelope := logger.find("debug: Tx.Sign: signed transaction, envelope: ")
+	if envelope == "" || !strings.Contains(envelope, `"Amount":100000000`) {
+		t.Errorf("decoded envelope not logged: %v", logger.messages)
+	}
+
+	for _, m := range logger.messages {
+		if strings.Contains(m, seed) {
+			t.Errorf("seed logged: %s", m)
+		}
+	}
+
+	// Views share the logger.
+	logger.messages = nil
+	ms.OnNetwork("custom").LoadAccount(bob)
+	if logger.find("debug: LoadAccount: loading account: "+bob) == "" {
+		t.Errorf("view didn't use logger: %v", logger.messages)
+	}
+
+	// Errors go to the logger too.
+	logger.messages = nil
+	NewTx("custom", Params{loggerParam: logger})
+	if logger.find("error: NewTx: missing url or passphrase") == "" {
+		t.Errorf("error not logged: %v", logger.messages)
+	}
+
+	logger.messages = nil
+	ms.SetLogger(nil)
+	ms.LoadAccount(bob)
+	if len(logger.messages) > 0 {
+		t.Errorf("logger used after reset: %v", logger.messages)
+	}
+}
@@ -72,7 +72,7 @@ func createFundedAccount(ms *microstellar.MicroStellar, fundSourceSeed string, u
 		err := ms.PayNative(keyPair.Seed, homeAddress, "5000", microstellar.Opts().WithMemoText("friendbot payback"))
 
 		if err != nil {
-			log.Fatalf(microstellar.ErrorString(err))
+			log.Fatal(microstellar.ErrorString(err))
 		}
 	}
 
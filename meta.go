@@ -0,0 +1,343 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ChangeType describes what happened to a ledger entry.
+type ChangeType string
+
+// Supported change types.
+const (
+	ChangeCreated = ChangeType("created") // entry was created
+	ChangeUpdated = ChangeType("updated") // entry was updated, the new state is included
+	ChangeRemoved = ChangeType("removed") // entry was removed, only its key is included
+	ChangeState   = ChangeType("state")   // state of the entry before it was updated or removed
+)
+
+// EntryType is the type of ledger entry in an EntryChange.
+type EntryType string
+
+// Supported ledger entry types.
+const (
+	EntryAccount   = EntryType("account")
+	EntryTrustline = EntryType("trustline")
+	EntryOffer     = EntryType("offer")
+	EntryData      = EntryType("data")
+)
+
+// AccountEntry is the ledger state of an account.
+type AccountEntry struct {
+	Address       string `json:"address"`
+	Balance       string `json:"balance,omitempty"`
+	Sequence      string `json:"seq,omitempty"`
+	SubentryCount uint32 `json:"subentry_count,omitempty"`
+	HomeDomain    string `json:"home_domain,omitempty"`
+}
+
+// TrustlineEntry is the ledger state of an account's trustline.
+type TrustlineEntry struct {
+	Address    string `json:"address"`
+	Asset      *Asset `json:"asset"`
+	Balance    string `json:"balance,omitempty"`
+	Limit      string `json:"limit,omitempty"`
+	Authorized bool   `json:"authorized,omitempty"`
+}
+
+// OfferEntry is the ledger state of an offer on the DEX.
+type OfferEntry struct {
+	Seller  string `json:"seller"`
+	OfferID string `json:"offer_id"`
+	Selling *Asset `json:"selling,omitempty"`
+	Buying  *Asset `json:"buying,omitempty"`
+	Amount  string `json:"amount,omitempty"`
+	Price   string `json:"price,omitempty"`
+	Passive bool   `json:"passive,omitempty"`
+}
+
+// DataEntry is the ledger state of a data entry on an account.
+type DataEntry struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+	Value   []byte `json:"value,omitempty"`
+}
+
+// EntryChange represents a change to a single ledger entry. Exactly one of Account, Trustline,
+// Offer, or Data is set, depending on Type. For removed entries, only the fields that identify
+// the entry are populated.
+type EntryChange struct {
+	Change    ChangeType      `json:"change"`
+	Type      EntryType       `json:"type"`
+	Account   *AccountEntry   `json:"account,omitempty"`
+	Trustline *TrustlineEntry `json:"trustline,omitempty"`
+	Offer     *OfferEntry     `json:"offer,omitempty"`
+	Data      *DataEntry      `json:"data,omitempty"`
+}
+
+// OperationMeta contains the ledger entry changes made by a single operation.
+type OperationMeta struct {
+	Changes []EntryChange `json:"changes"`
+}
+
+// TxMeta contains the ledger entry changes made by a transaction. TxChanges has the transaction
+// level changes (e.g., fees and sequence numbers), and Operations has the changes made by each
+// operation, in order. TxChangesAfter has the transaction level changes made after the operations
+// were applied, and is only set for v2 meta.
+type TxMeta struct {
+	TxChanges      []EntryChange   `json:"tx_changes"`
+	Operations     []OperationMeta `json:"operations"`
+	TxChangesAfter []EntryChange   `json:"tx_changes_after,omitempty"`
+}
+
+// newEntryChangeFromEntry converts an XDR ledger entry into an EntryChange.
+func newEntryChangeFromEntry(change ChangeType, entry xdr.LedgerEntry) (EntryChange, error) {
+	result := EntryChange{Change: change}
+
+	switch entry.Data.Type {
+	case xdr.LedgerEntryTypeAccount:
+		a := entry.Data.MustAccount()
+		result.Type = EntryAccount
+		result.Account = &AccountEntry{
+			Address:       a.AccountId.Address(),
+			Balance:       ToAmountString(int64(a.Balance)),
+			Sequence:      fmt.Sprintf("%d", a.SeqNum),
+			SubentryCount: uint32(a.NumSubEntries),
+			HomeDomain:    string(a.HomeDomain),
+		}
+	case xdr.LedgerEntryTypeTrustline:
+		t := entry.Data.MustTrustLine()
+		asset, err := newAssetFromXDR(t.Asset)
+		if err != nil {
+			return result, err
+		}
+
+		result.Type = EntryTrustline
+		result.Trustline = &TrustlineEntry{
+			Address:    t.AccountId.Address(),
+			Asset:      asset,
+			Balance:    ToAmountString(int64(t.Balance)),
+			Limit:      ToAmountString(int64(t.Limit)),
+			Authorized: t.Flags&xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag) != 0,
+		}
+	case xdr.LedgerEntryTypeOffer:
+		o := entry.Data.MustOffer()
+		selling, err := newAssetFromXDR(o.Selling)
+		if err != nil {
+			return result, err
+		}
+
+		buying, err := newAssetFromXDR(o.Buying)
+		if err != nil {
+			return result, err
+		}
+
+		result.Type = EntryOffer
+		result.Offer = &OfferEntry{
+			Seller:  o.SellerId.Address(),
+			OfferID: fmt.Sprintf("%d", o.OfferId),
+			Selling: selling,
+			Buying:  buying,
+			Amount:  ToAmountString(int64(o.Amount)),
+			Price:   o.Price.String(),
+			Passive: o.Flags&xdr.Uint32(xdr.OfferEntryFlagsPassiveFlag) != 0,
+		}
+	case xdr.LedgerEntryTypeData:
+		d := entry.Data.MustData()
+		result.Type = EntryData
+		result.Data = &DataEntry{
+			Address: d.AccountId.Address(),
+			Name:    string(d.DataName),
+			Value:   []byte(d.DataValue),
+		}
+	default:
+		return result, errors.Errorf("unknown ledger entry type: %v", entry.Data.Type)
+	}
+
+	return result, nil
+}
+
+// newEntryChangeFromKey converts the XDR key of a removed ledger entry into an EntryChange.
+func newEntryChangeFromKey(key xdr.LedgerKey) (EntryChange, error) {
+	result := EntryChange{Change: ChangeRemoved}
+
+	switch key.Type {
+	case xdr.LedgerEntryTypeAccount:
+		a := key.MustAccount()
+		result.Type = EntryAccount
+		result.Account = &AccountEntry{Address: a.AccountId.Address()}
+	case xdr.LedgerEntryTypeTrustline:
+		t := key.MustTrustLine()
+		asset, err := newAssetFromXDR(t.Asset)
+		if err != nil {
+			return result, err
+		}
+
+		result.Type = EntryTrustline
+		result.Trustline = &TrustlineEntry{Address: t.AccountId.Address(), Asset: asset}
+	case xdr.LedgerEntryTypeOffer:
+		o := key.MustOffer()
+		result.Type = EntryOffer
+		result.Offer = &OfferEntry{Seller: o.SellerId.Address(), OfferID: fmt.Sprintf("%d", o.OfferId)}
+	case xdr.LedgerEntryTypeData:
+		d := key.MustData()
+		result.Type = EntryData
+		result.Data = &DataEntry{Address: d.AccountId.Address(), Name: string(d.DataName)}
+	default:
+		return result, errors.Errorf("unknown ledger entry type: %v", key.Type)
+	}
+
+	return result, nil
+}
+
+// newEntryChanges converts a list of XDR ledger entry changes.
+func newEntryChanges(changes xdr.LedgerEntryChanges) ([]EntryChange, error) {
+	results := make([]EntryChange, len(changes))
+
+	for i, c := range changes {
+		var err error
+
+		switch c.Type {
+		case xdr.LedgerEntryChangeTypeLedgerEntryCreated:
+			results[i], err = newEntryChangeFromEntry(ChangeCreated, c.MustCreated())
+		case xdr.LedgerEntryChangeTypeLedgerEntryUpdated:
+			results[i], err = newEntryChangeFromEntry(ChangeUpdated, c.MustUpdated())
+		case xdr.LedgerEntryChangeTypeLedgerEntryState:
+			results[i], err = newEntryChangeFromEntry(ChangeState, c.MustState())
+		case xdr.LedgerEntryChangeTypeLedgerEntryRemoved:
+			results[i], err = newEntryChangeFromKey(c.MustRemoved())
+		default:
+			err = errors.Errorf("unknown change type: %v", c.Type)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// decodeTxMetaV2 decodes the body of a TransactionMetaV2, which the vendored XDR package predates.
+// It has the same fields as TransactionMetaV1, followed by the changes made after the operations.
+func decodeTxMetaV2(body []byte) (txChanges xdr.LedgerEntryChanges, operations []xdr.OperationMeta, txChangesAfter xdr.LedgerEntryChanges, err error) {
+	r := bytes.NewReader(body)
+	for _, v := range []interface{}{&txChanges, &operations, &txChangesAfter} {
+		if _, err = xdr.Unmarshal(r, v); err != nil {
+			return
+		}
+	}
+
+	if r.Len() != 0 {
+		err = errors.Errorf("input not fully consumed: %d bytes left", r.Len())
+	}
+
+	return
+}
+
+// DecodeTxMeta decodes the base64-encoded TransactionMeta XDR (e.g., the result_meta_xdr field
+// of a transaction) into a TxMeta. Versions 0, 1, and 2 are supported.
+func DecodeTxMeta(b64Meta string) (*TxMeta, error) {
+	raw, err := base64.StdEncoding.DecodeString(b64Meta)
+	if err != nil || len(raw) < 4 {
+		return nil, errors.Errorf("error decoding transaction meta: invalid base64")
+	}
+
+	var txChanges, txChangesAfter xdr.LedgerEntryChanges
+	var operations []xdr.OperationMeta
+
+	if version := binary.BigEndian.Uint32(raw); version == 2 {
+		txChanges, operations, txChangesAfter, err = decodeTxMetaV2(raw[4:])
+		if err != nil {
+			return nil, errors.Wrap(err, "error decoding transaction meta")
+		}
+	} else {
+		var meta xdr.TransactionMeta
+		if err := xdr.SafeUnmarshal(raw, &meta); err != nil {
+			return nil, errors.Wrap(err, "error decoding transaction meta")
+		}
+
+		switch meta.V {
+		case 0:
+			operations = meta.MustOperations()
+		case 1:
+			v1 := meta.MustV1()
+			txChanges = v1.TxChanges
+			operations = v1.Operations
+		default:
+			return nil, errors.Errorf("unsupported transaction meta version: %d", meta.V)
+		}
+	}
+
+	result := &TxMeta{Operations: make([]OperationMeta, len(operations))}
+
+	if result.TxChanges, err = newEntryChanges(txChanges); err != nil {
+		return nil, errors.Wrap(err, "error decoding transaction changes")
+	}
+
+	if len(txChangesAfter) > 0 {
+		if result.TxChangesAfter, err = newEntryChanges(txChangesAfter); err != nil {
+			return nil, errors.Wrap(err, "error decoding transaction changes")
+		}
+	}
+
+	for i, op := range operations {
+		if result.Operations[i].Changes, err = newEntryChanges(op.Changes); err != nil {
+			return nil, errors.Wrapf(err, "error decoding changes for operation %d", i)
+		}
+	}
+
+	return result, nil
+}
+
+// ResultMeta decodes the ledger entry changes (result_meta_xdr) for the transaction. Use this
+// to reconstruct the exact state changes a transaction made, e.g., the new balances after
+// a path payment. Returns an error if response is nil (e.g., ms.Response() when nothing was
+// submitted.)
+//
+//   meta, err := ms.Response().ResultMeta()
+//   if err != nil {
+//       log.Fatalf("no result meta: %v", err)
+//   }
+//
+//   for _, change := range meta.Operations[0].Changes {
+//       if change.Type == microstellar.EntryTrustline && change.Change == microstellar.ChangeUpdated {
+//           log.Print(change.Trustline.Address, change.Trustline.Balance)
+//       }
+//   }
+func (response *TxResponse) ResultMeta() (*TxMeta, error) {
+	if response == nil {
+		return nil, errors.Errorf("no response")
+	}
+
+	if response.Meta == "" {
+		return nil, errors.Errorf("no result meta in response")
+	}
+
+	return DecodeTxMeta(response.Meta)
+}
+
+// LastResultMeta decodes the ledger entry changes made by the last submitted transaction (see
+// TxResponse.ResultMeta.) Use this to index the balance changes a transaction made without
+// querying Horizon again.
+//
+//   err := ms.Pay("source_seed", "target_address", "3", USD)
+//   meta, err := ms.LastResultMeta()
+func (ms *MicroStellar) LastResultMeta() (*TxMeta, error) {
+	lastTx := ms.getLastTx()
+	if lastTx == nil || lastTx.response == nil {
+		return nil, ms.errorf("no transaction submitted")
+	}
+
+	meta, err := lastTx.Response().ResultMeta()
+	if err != nil {
+		return nil, ms.wrapf(err, "can't get result meta")
+	}
+
+	return meta, ms.success()
+}
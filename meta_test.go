@@ -0,0 +1,160 @@
+package microstellar
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// newTestTxMeta returns a base64-encoded TransactionMeta with a fee charge, a trustline update,
+// and a removed offer.
+func newTestTxMeta(t *testing.T, address string, issuer string) string {
+	var accountID, issuerID xdr.AccountId
+	accountID.SetAddress(address)
+	issuerID.SetAddress(issuer)
+
+	var usd xdr.Asset
+	usd.SetCredit("USD", issuerID)
+
+	mustChange := func(changeType xdr.LedgerEntryChangeType, value interface{}) xdr.LedgerEntryChange {
+		change, err := xdr.NewLedgerEntryChange(changeType, value)
+		if err != nil {
+			t.Fatalf("can't create change: %v", err)
+		}
+		return change
+	}
+
+	account, _ := xdr.NewLedgerEntryData(xdr.LedgerEntryTypeAccount, xdr.AccountEntry{
+		AccountId: accountID, Balance: 99999900, SeqNum: 42})
+	trustline, _ := xdr.NewLedgerEntryData(xdr.LedgerEntryTypeTrustline, xdr.TrustLineEntry{
+		AccountId: accountID, Asset: usd, Balance: 250000000, Limit: 1000000000,
+		Flags: xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag)})
+	offer, _ := xdr.NewLedgerKey(xdr.LedgerEntryTypeOffer, xdr.LedgerKeyOffer{SellerId: accountID, OfferId: 7})
+
+	meta, err := xdr.NewTransactionMeta(1, xdr.TransactionMetaV1{
+		TxChanges: xdr.LedgerEntryChanges{
+			mustChange(xdr.LedgerEntryChangeTypeLedgerEntryUpdated, xdr.LedgerEntry{Data: account}),
+		},
+		Operations: []xdr.OperationMeta{{Changes: xdr.LedgerEntryChanges{
+			mustChange(xdr.LedgerEntryChangeTypeLedgerEntryUpdated, xdr.LedgerEntry{Data: trustline}),
+			mustChange(xdr.LedgerEntryChangeTypeLedgerEntryRemoved, offer),
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("can't create meta: %v", err)
+	}
+
+	b64, err := xdr.MarshalBase64(meta)
+	if err != nil {
+		t.Fatalf("can't marshal meta: %v", err)
+	}
+	return b64
+}
+
+func TestResultMeta(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	response := &TxResponse{TransactionSuccess: horizon.TransactionSuccess{Meta: newTestTxMeta(t, address, issuer)}}
+	meta, err := response.ResultMeta()
+	if err != nil {
+		t.Fatalf("ResultMeta failed: %v", err)
+	}
+
+	if len(meta.TxChanges) != 1 || meta.TxChanges[0].Account.Balance != "9.9999900" || meta.TxChanges[0].Account.Sequence != "42" {
+		t.Errorf("wrong tx changes: %+v", meta.TxChanges)
+	}
+
+	if len(meta.Operations) != 1 || len(meta.Operations[0].Changes) != 2 {
+		t.Fatalf("wrong operations: %+v", meta.Operations)
+	}
+
+	trustline := meta.Operations[0].Changes[0]
+	if trustline.Change != ChangeUpdated || trustline.Type != EntryTrustline {
+		t.Errorf("wrong change: %+v", trustline)
+	}
+
+	if !trustline.Trustline.Asset.Equals(*NewAsset("USD", issuer, Credit4Type)) ||
+		trustline.Trustline.Balance != "25.0000000" || trustline.Trustline.Limit != "100.0000000" || !trustline.Trustline.Authorized {
+		t.Errorf("wrong trustline: %+v", trustline.Trustline)
+	}
+
+	offer := meta.Operations[0].Changes[1]
+	if offer.Change != ChangeRemoved || offer.Type != EntryOffer || offer.Offer.OfferID != "7" || offer.Offer.Seller != address {
+		t.Errorf("wrong offer change: %+v", offer)
+	}
+
+	if _, err := (&TxResponse{}).ResultMeta(); err == nil {
+		t.Errorf("ResultMeta should fail without meta")
+	}
+
+	if _, err := New("fake").Response().ResultMeta(); err == nil {
+		t.Errorf("ResultMeta should fail without a response")
+	}
+}
+
+func TestLastResultMeta(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	// Build v2 meta from the body of the v1 meta, with the same changes after the operations.
+	v1, _ := base64.StdEncoding.DecodeString(newTestTxMeta(t, address, issuer))
+	var v1Meta xdr.TransactionMeta
+	xdr.SafeUnmarshal(v1, &v1Meta)
+	after, _ := xdr.MarshalBase64(v1Meta.MustV1().TxChanges)
+	afterBytes, _ := base64.StdEncoding.DecodeString(after)
+
+	v2 := append([]byte{0, 0, 0, 2}, v1[4:]...)
+	v2 = append(v2, afterBytes...)
+
+	meta := ""
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"hash": "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889", "ledger": 10,
+			"result_meta_xdr": "%s"}`, meta)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if _, err := ms.LastResultMeta(); err == nil {
+		t.Errorf("want error with no submitted transaction")
+	}
+
+	for _, meta = range []string{base64.StdEncoding.EncodeToString(v1), base64.StdEncoding.EncodeToString(v2)} {
+		if err := ms.Pay(source, issuer, "1", NativeAsset); err != nil {
+			t.Fatalf("Pay failed: %v", ErrorString(err))
+		}
+
+		got, err := ms.LastResultMeta()
+		if err != nil {
+			t.Fatalf("LastResultMeta failed: %v", err)
+		}
+
+		if len(got.TxChanges) != 1 || len(got.Operations) != 1 || len(got.Operations[0].Changes) != 2 ||
+			got.Operations[0].Changes[0].Trustline.Balance != "25.0000000" {
+			t.Errorf("wrong meta: %+v", got)
+		}
+
+		wantAfter := 0
+		if meta == base64.StdEncoding.EncodeToString(v2) {
+			wantAfter = 1
+		}
+
+		if len(got.TxChangesAfter) != wantAfter {
+			t.Errorf("want %d changes after operations, got %+v", wantAfter, got.TxChangesAfter)
+		}
+	}
+
+	bad := base64.StdEncoding.EncodeToString(append(v2, 0))
+	if _, err := DecodeTxMeta(bad); err == nil {
+		t.Errorf("want error for trailing bytes")
+	}
+
+	if _, err := DecodeTxMeta(base64.StdEncoding.EncodeToString([]byte{0, 0, 0, 3})); err == nil {
+		t.Errorf("want error for unsupported version")
+	}
+}
@@ -33,8 +33,18 @@
 package microstellar
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stellar/go/build"
@@ -43,18 +53,37 @@ import (
 	"github.com/stellar/go/clients/stellartoml"
 	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/network"
+	fedproto "github.com/stellar/go/protocols/federation"
+	"github.com/stellar/go/strkey"
 	"github.com/stellar/go/xdr"
 )
 
 // MicroStellar is the user handle to the Stellar network. Use the New function
 // to create a new instance.
+//
+// Single-shot operations (e.g., Pay, LoadAccount, SetHomeDomain) are safe to call concurrently.
+// However, Err(), Response(), and LastTxHash() report on whichever call finished last, so use the
+// *WithResponse variants (e.g., PayWithResponse) to get per-call results. Multi-op sessions
+// (Start ... Submit) are not thread-safe: while a session is open, all operations on the client
+// are added to it, so don't share a client across goroutines during a session.
 type MicroStellar struct {
 	networkName string
 	params      Params
 	fake        bool
-	tx          *Tx
-	lastTx      *Tx
-	lastErr     error
+
+	// mu protects the fields below.
+	mu      sync.Mutex
+	tx      *Tx
+	lastTx  *Tx
+	lastErr error
+
+	// clientMu protects the cached Horizon client. See horizonClient.
+	clientMu sync.Mutex
+	client   *horizon.Client
+
+	// ledgerMu protects the fake network's ledger. See FakeLedger.
+	ledgerMu sync.Mutex
+	ledger   *FakeLedger
 }
 
 // Error wraps underlying errors (e.g., horizon)
@@ -80,8 +109,12 @@ type Params map[string]interface{}
 //        "url": "https://my-horizon-server.com",
 //        "passphrase": "foobar"})
 //
-// The microstellar client is not thread-safe, however you can create as many clients
-// as you need.
+// To use your own HTTP client (e.g., to set timeouts or a proxy), set "http_client" in the
+// parameters, or call WithHTTPClient.
+//
+// Single-shot operations on the client are thread-safe, but multi-op sessions (see Start) are
+// not. You can create as many clients as you need. Each client reuses its Horizon client (and
+// HTTP connections) across calls, until the URL or HTTP client in the parameters changes.
 func New(networkName string, params ...Params) *MicroStellar {
 	var p Params
 
@@ -97,6 +130,33 @@ func New(networkName string, params ...Params) *MicroStellar {
 	}
 }
 
+// WithHTTPClient makes the client use httpClient for all requests to Horizon and federation
+// servers, instead of http.DefaultClient. Use this to configure timeouts, proxies, and connection
+// pooling. Returns the client for chaining. Call this before sharing the client across goroutines.
+//
+//   ms := microstellar.New("public").WithHTTPClient(&http.Client{Timeout: 10 * time.Second})
+func (ms *MicroStellar) WithHTTPClient(httpClient *http.Client) *MicroStellar {
+	// Copy the parameters so we don't modify the caller's map.
+	p := Params{}
+	for k, v := range ms.params {
+		p[k] = v
+	}
+	p[httpClientParam] = httpClient
+
+	ms.params = p
+	return ms
+}
+
+// NetworkPassphrase returns the passphrase of the network the client signs transactions for.
+// Set "passphrase" in the parameters to override the passphrase of a named network (e.g., for a
+// private network behind a Horizon that shadows the public one.)
+//
+//   ms := microstellar.New("public", microstellar.Params{"passphrase": "My Private Network"})
+//   log.Print(ms.NetworkPassphrase()) // My Private Network
+func (ms *MicroStellar) NetworkPassphrase() string {
+	return NewTx(ms.networkName, ms.params).network.Passphrase
+}
+
 // NewFromSpec is a helper that creates a new MicroStellar client based on
 // spec, which is a semicolon-separated string.
 //
@@ -123,17 +183,77 @@ func NewFromSpec(spec string) *MicroStellar {
 	return New(network, params)
 }
 
+// validNetwork returns an error if networkName is not a supported network, or if it's a custom network
+// with missing parameters.
+func validNetwork(networkName string, params Params) error {
+	switch networkName {
+	case "public", "test", "fake":
+		return nil
+	case "custom":
+		_, ok1 := params["url"]
+		_, ok2 := params["passphrase"]
+		if !(ok1 && ok2) {
+			return errors.Errorf("custom network requires url and passphrase parameters")
+		}
+		return nil
+	}
+
+	return errors.Errorf("unsupported network: %s", networkName)
+}
+
+// OnNetwork returns a lightweight view of the client bound to the network specified by networkName,
+// sharing this client's parameters. Calls on the returned view use that network's URL and passphrase. The
+// view is independent of this client, so the two can be used concurrently.
+//
+//   ms := microstellar.New("test")
+//   ms.OnNetwork("public").LoadAccount("GAUYTZ24ATLEBIV63MXMPOPQO2T6NHI6TQYEXRTFYXWYZ3JOCVO6UYUM")
+//
+// To switch to a custom network, pass in its URL and passphrase parameters, which override the shared ones.
+// The URL and passphrase parameters are not shared when switching to a named network.
+//
+// If the network is not supported, the returned view's Err() is set, and it operates on the test
+// network (just like New.)
+func (ms *MicroStellar) OnNetwork(networkName string, params ...Params) *MicroStellar {
+	p := Params{}
+	for k, v := range ms.params {
+		p[k] = v
+	}
+
+	if networkName != ms.networkName && networkName != "custom" {
+		// Passphrases (and custom URLs) belong to a network, so don't carry them over.
+		delete(p, "url")
+		delete(p, "passphrase")
+	}
+
+	if len(params) > 0 {
+		for k, v := range params[0] {
+			p[k] = v
+		}
+	}
+
+	view := New(networkName, p)
+
+	if err := validNetwork(networkName, p); err != nil {
+		view.wrapf(err, "can't switch networks")
+	}
+
+	return view
+}
+
 // getTx is a helper that builds a transaction based on the current context -- if we're in
 // the middle of a multi-op transaction, it returns an existing tx.
 func (ms *MicroStellar) getTx() *Tx {
 	var tx *Tx
 
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	// If this is a multi-op transaction, then tx
 	// contains the
 	if ms.tx != nil {
 		tx = ms.tx
 	} else {
-		tx = NewTx(ms.networkName, ms.params)
+		tx = ms.newTx()
 	}
 
 	return tx
@@ -142,47 +262,123 @@ func (ms *MicroStellar) getTx() *Tx {
 // signAndSubmit signs tx and submits it to the current Stellar network.
 func (ms *MicroStellar) signAndSubmit(tx *Tx, signers ...string) error {
 	if !tx.isMultiOp {
-		tx.Sign(signers...)
-		tx.Submit()
+		if skip, _ := ms.skipIdempotent(tx); !skip {
+			tx.signAndSubmit(signers...)
+		}
 	}
 
 	// Save last tx to keep response and error
-	ms.lastTx = tx
+	ms.setLastTx(tx)
 	return ms.err(tx.Err())
 }
 
+// setLastTx saves tx as the last transaction.
+func (ms *MicroStellar) setLastTx(tx *Tx) {
+	ms.mu.Lock()
+	ms.lastTx = tx
+	ms.mu.Unlock()
+}
+
+// getLastTx returns the last transaction, or nil if there isn't one.
+func (ms *MicroStellar) getLastTx() *Tx {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.lastTx
+}
+
+// closeTx ends the current multi-op transaction (if any), saving it as the last transaction.
+func (ms *MicroStellar) closeTx(tx *Tx) {
+	ms.mu.Lock()
+	ms.lastTx = tx
+	ms.tx = nil
+	ms.mu.Unlock()
+}
+
 // success is a helper that sets the last error to nil
 func (ms *MicroStellar) success() error {
-	ms.lastErr = nil
-	return ms.lastErr
+	return ms.err(nil)
 }
 
 // err is a helper function to save the last error and return it.
 func (ms *MicroStellar) err(err error) error {
+	ms.mu.Lock()
 	ms.lastErr = err
-	return ms.lastErr
+	ms.mu.Unlock()
+	return err
 }
 
 // errorf is a helper function to build and save an error
 func (ms *MicroStellar) errorf(msg string, args ...interface{}) error {
-	ms.lastErr = errors.Errorf(msg, args...)
-	return ms.lastErr
+	return ms.err(errors.Errorf(msg, args...))
 }
 
 // errorf is a helper function to wrap and safe an error
 func (ms *MicroStellar) wrapf(err error, msg string, args ...interface{}) error {
-	ms.lastErr = errors.Wrapf(err, msg, args...)
-	return ms.lastErr
+	return ms.err(errors.Wrapf(err, msg, args...))
 }
 
 // Err returns the last error on the transaction.
 func (ms *MicroStellar) Err() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	return ms.lastErr
 }
 
-// Response returns the response from the last submission.
+// Response returns the response from the last submission, or nil if nothing was submitted (e.g.,
+// after a dry run.)
 func (ms *MicroStellar) Response() *TxResponse {
-	return ms.lastTx.Response()
+	return ms.getLastTx().Response()
+}
+
+// LastTxHash returns the hex-encoded hash of the last submitted transaction (or the last
+// transaction closed with Payload()), e.g., for building block explorer links. To compute the
+// hash of an arbitrary base64-encoded transaction, use TxTrackingID.
+func (ms *MicroStellar) LastTxHash() (string, error) {
+	lastTx := ms.getLastTx()
+	if lastTx == nil {
+		return "", ms.errorf("no transaction submitted")
+	}
+
+	hash, err := lastTx.Hash()
+	if err != nil {
+		return "", ms.wrapf(err, "can't get transaction hash")
+	}
+
+	return hash, ms.success()
+}
+
+// LastPayload returns the base64-encoded envelope of the last transaction. For transactions
+// built with Options.WithDryRun, this is the unsigned envelope, ready for external signing.
+func (ms *MicroStellar) LastPayload() (string, error) {
+	lastTx := ms.getLastTx()
+	if lastTx == nil {
+		return "", ms.errorf("no transaction built")
+	}
+
+	if err := lastTx.Err(); err != nil {
+		return "", ms.wrapf(err, "can't get payload")
+	}
+
+	payload, err := lastTx.Payload()
+	if err != nil {
+		return "", ms.wrapf(err, "can't get payload")
+	}
+
+	return payload, ms.success()
+}
+
+// LastEnvelopeXDR returns the signed, base64-encoded envelope most recently sent to Horizon for
+// the last transaction, e.g., for audit logs. Unlike LastPayload, it's only set once the
+// transaction is submitted (successfully or not), and not for dry runs or transactions closed
+// with Payload(). If a tx_bad_seq failure was retried (see Options.WithAutoSequenceRetry), this is
+// the envelope of the final attempt.
+func (ms *MicroStellar) LastEnvelopeXDR() (string, error) {
+	lastTx := ms.getLastTx()
+	if lastTx == nil || lastTx.sentPayload == "" {
+		return "", ms.errorf("no transaction submitted")
+	}
+
+	return lastTx.sentPayload, ms.success()
 }
 
 // Start begins a new multi-op transaction. This lets you lump a set of operations into
@@ -207,7 +403,11 @@ func (ms *MicroStellar) Response() *TxResponse {
 //   ms.Submit()
 //
 func (ms *MicroStellar) Start(sourceSeed string, options ...*Options) *MicroStellar {
-	ms.tx = NewTx(ms.networkName, ms.params).WithOptions(mergeOptions(options).MultiOp(sourceSeed))
+	tx := ms.newTx().WithOptions(mergeOptions(options).MultiOp(sourceSeed))
+
+	ms.mu.Lock()
+	ms.tx = tx
+	ms.mu.Unlock()
 	return ms
 }
 
@@ -220,17 +420,18 @@ func (ms *MicroStellar) Submit() error {
 		return ms.errorf("can't submit, not a multi-op transaction")
 	}
 
-	ms.tx.Sign()
-	ms.tx.Submit()
+	if skip, _ := ms.skipIdempotent(tx); !skip {
+		tx.signAndSubmit()
+	}
 
 	// Save last tx to keep response and error
-	ms.lastTx = tx
-	ms.tx = nil
-	return ms.err(ms.lastTx.Err())
+	ms.closeTx(tx)
+	return ms.err(tx.Err())
 }
 
 // Payload returns the payload for the current transaction without submitting it to the network. This
 // only works for transactions started with Start(). This method closes the transaction like Submit().
+// The payload is unsigned: use SignPayload to collect signatures, and SubmitTransaction to submit it.
 func (ms *MicroStellar) Payload() (string, error) {
 	tx := ms.getTx()
 
@@ -239,8 +440,7 @@ func (ms *MicroStellar) Payload() (string, error) {
 	}
 
 	payload, err := tx.Payload()
-	ms.lastTx = tx
-	ms.tx = nil
+	ms.closeTx(tx)
 	return payload, ms.err(err)
 }
 
@@ -251,7 +451,7 @@ func (ms *MicroStellar) CreateKeyPair() (*KeyPair, error) {
 		return nil, ms.err(err)
 	}
 
-	debugf("CreateKeyPair", "created address: %s, seed: <redacted>", pair.Address())
+	ms.debugf("CreateKeyPair", "created address: %s, seed: <redacted>", pair.Address())
 	return &KeyPair{pair.Seed(), pair.Address()}, ms.success()
 }
 
@@ -266,6 +466,10 @@ func (ms *MicroStellar) FundAccount(sourceSeed string, addressOrSeed string, amo
 		return ms.errorf("invalid target address or seed: %s", addressOrSeed)
 	}
 
+	if err := ValidAmount(amount); err != nil {
+		return ms.wrapf(err, "can't fund account")
+	}
+
 	payment := build.CreateAccount(
 		build.Destination{AddressOrSeed: addressOrSeed},
 		build.NativeAmount{Amount: amount})
@@ -276,52 +480,343 @@ func (ms *MicroStellar) FundAccount(sourceSeed string, addressOrSeed string, amo
 		tx.SetOptions(options[0])
 	}
 
+	tx.addFakeOp(sourceSeed, fakeCreateAccount(addressOrSeed, amount))
 	tx.Build(sourceAccount(sourceSeed), payment)
 	return ms.signAndSubmit(tx, sourceSeed)
 }
 
-// LoadAccount loads the account information for the given address.
+// ErrAlreadyFunded is returned by Fund if friendbot has already funded the account. Use
+// errors.Cause to check for it.
+var ErrAlreadyFunded = errors.New("account already funded")
+
+// friendbotProblem is the subset of a friendbot error response needed to tell why it failed.
+type friendbotProblem struct {
+	Detail string `json:"detail"`
+	Extras struct {
+		ResultCodes struct {
+			Operations []string `json:"operations"`
+		} `json:"result_codes"`
+	} `json:"extras"`
+}
+
+// alreadyFunded returns true if the friendbot error means the account was already funded.
+func (p friendbotProblem) alreadyFunded() bool {
+	for _, code := range p.Extras.ResultCodes.Operations {
+		if code == "op_already_exists" {
+			return true
+		}
+	}
+
+	return strings.Contains(p.Detail, "already funded")
+}
+
+// Fund funds address with test lumens from friendbot. This only works on the test network (and
+// does nothing on the fake network.) If the account has already been funded, Fund returns an error
+// whose cause is ErrAlreadyFunded.
+func (ms *MicroStellar) Fund(address string) error {
+	if err := ValidAddress(address); err != nil {
+		return ms.errorf("can't fund: invalid address: %s", address)
+	}
+
+	if ms.fake {
+		return ms.success()
+	}
+
+	if ms.networkName != "test" {
+		return ms.errorf("can't fund: friendbot is only available on the test network, not %s", ms.networkName)
+	}
+
+	httpClient := httpClientFromParams(ms.params)
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	ms.debugf("Fund", "funding address: %s", address)
+	resp, err := httpClient.Get(friendbotURL + "?addr=" + url.QueryEscape(address))
+	if err != nil {
+		return ms.wrapf(err, "can't fund: friendbot request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return ms.success()
+	}
+
+	var problem friendbotProblem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err == nil && problem.alreadyFunded() {
+		return ms.wrapf(ErrAlreadyFunded, "can't fund %s", address)
+	}
+
+	return ms.errorf("can't fund: friendbot failed: %s %s", resp.Status, problem.Detail)
+}
+
+// LoadAccount loads the account information for the given address. For multiplexed (M...)
+// addresses, it loads the base account.
 func (ms *MicroStellar) LoadAccount(address string) (*Account, error) {
-	if !ValidAddressOrSeed(address) {
+	return ms.LoadAccountWithContext(context.Background(), address)
+}
+
+// LoadAccountWithContext is like LoadAccount, but aborts the request to Horizon when
+// ctx is cancelled.
+func (ms *MicroStellar) LoadAccountWithContext(ctx context.Context, address string) (*Account, error) {
+	if !ValidAddressOrSeed(address) && ValidMuxedAddress(address) != nil {
 		return nil, ms.errorf("can't load account: invalid address or seed: %v", address)
 	}
 
 	if ms.fake {
+		if ledger := ms.fakeLedger(); ledger != nil {
+			account, err := ledger.account(address)
+			if err != nil {
+				return nil, ms.wrapf(err, "could not load account")
+			}
+			return account, ms.success()
+		}
 		return newAccount(), ms.success()
 	}
 
-	debugf("LoadAccount", "loading account: %s", address)
-	tx := NewTx(ms.networkName, ms.params)
-	account, err := tx.GetClient().LoadAccount(address)
+	if isMuxedAddress(address) {
+		base, _, err := ParseMuxedAddress(address)
+		if err != nil {
+			return nil, ms.wrapf(err, "can't load account")
+		}
+		address = base
+	}
+
+	ms.debugf("LoadAccount", "loading account: %s", address)
+	client := clientWithContext(ctx, ms.newTx().GetClient())
+
+	// Keep the response body, for the fields the Horizon client doesn't decode.
+	recorder := &recordingHTTP{client: client.HTTP}
+	ha, err := (&horizon.Client{URL: client.URL, HTTP: recorder}).LoadAccount(address)
 
 	if err != nil {
 		return nil, ms.wrapf(err, "could not load account")
 	}
 
-	return newAccountFromHorizon(account), ms.success()
+	account := newAccountFromHorizon(ha)
+	if err := account.addExtras(recorder.body); err != nil {
+		return nil, ms.wrapf(err, "could not load account")
+	}
+
+	return account, ms.success()
+}
+
+// GetSequenceNumber returns the current sequence number of the account at address. The next
+// transaction from the account must use the number after it (see Options.WithSequence.)
+func (ms *MicroStellar) GetSequenceNumber(address string) (int64, error) {
+	if !ValidAddressOrSeed(address) {
+		return 0, ms.errorf("can't get sequence number: invalid address or seed: %v", address)
+	}
+
+	if ms.fake {
+		return 0, ms.success()
+	}
+
+	kp, _ := keypair.Parse(address)
+	ms.debugf("GetSequenceNumber", "loading sequence number: %s", kp.Address())
+	seq, err := ms.getTx().GetClient().SequenceForAccount(kp.Address())
+	if err != nil {
+		return 0, ms.wrapf(err, "could not load sequence number")
+	}
+
+	return int64(seq), ms.success()
 }
 
-// Resolve looks up a federated address
+// WouldExceedLimit loads the account at destAddress and returns true if paying it amount of asset
+// would exceed its trust limit for the asset (which would fail with op_line_full.) Also returns the
+// available headroom, i.e., how much more of the asset the account can receive.
+//
+// Returns an error if the account has no trustline to the asset.
+func (ms *MicroStellar) WouldExceedLimit(destAddress string, asset *Asset, amount string) (bool, string, error) {
+	if err := asset.Validate(); err != nil {
+		return false, "", ms.wrapf(err, "can't check limit")
+	}
+
+	account, err := ms.LoadAccount(destAddress)
+	if err != nil {
+		return false, "", ms.wrapf(err, "can't check limit")
+	}
+
+	exceeds, headroom, err := account.wouldExceedLimit(asset, amount)
+	if err != nil {
+		return false, "", ms.wrapf(err, "can't check limit")
+	}
+
+	return exceeds, headroom, ms.success()
+}
+
+// Resolve looks up a federated address, and returns its account ID. Use ResolveFull to also
+// get the memo that payments to the address must carry.
 func (ms *MicroStellar) Resolve(address string) (string, error) {
-	debugf("Resolve", "looking up: %s", address)
+	ms.debugf("Resolve", "looking up: %s", address)
 	if !strings.Contains(address, "*") {
 		return "", ms.errorf("not a fedaration address: %s", address)
 	}
 
-	// Create a new federation client and lookup address
+	resp, err := ms.lookupFederated(address)
+
+	if err != nil {
+		return "", ms.wrapf(err, "resolve error")
+	}
+
+	return resp.AccountID, ms.success()
+}
+
+// FederationRecord is a resolved federated address. Payments to AccountID must carry the memo
+// described by the Memo* fields, if MemoType is not MemoNone. Pass the record to
+// Options.WithFederationMemo to attach the memo to a payment.
+type FederationRecord struct {
+	Address   string   // the federated address, e.g., "bob*qubit.sh"
+	AccountID string   // the account ID the address resolves to
+	MemoType  MemoType // MemoNone, MemoText, MemoID, or MemoHash
+	MemoText  string
+	MemoID    uint64
+	MemoHash  [32]byte
+}
+
+// newFederationRecord creates a federation record from the federation server's response,
+// decoding its memo.
+func newFederationRecord(resp *fedproto.NameResponse) (*FederationRecord, error) {
+	record := &FederationRecord{AccountID: resp.AccountID, MemoType: MemoNone}
+
+	switch resp.MemoType {
+	case "":
+	case "text":
+		record.MemoType = MemoText
+		record.MemoText = resp.Memo.Value
+	case "id":
+		id, err := strconv.ParseUint(resp.Memo.Value, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad memo id: %s", resp.Memo.Value)
+		}
+		record.MemoType = MemoID
+		record.MemoID = id
+	case "hash":
+		decoded, err := base64.StdEncoding.DecodeString(resp.Memo.Value)
+		if err != nil || len(decoded) != 32 {
+			return nil, errors.Errorf("bad memo hash: %s", resp.Memo.Value)
+		}
+		record.MemoType = MemoHash
+		copy(record.MemoHash[:], decoded)
+	default:
+		return nil, errors.Errorf("unsupported memo type: %s", resp.MemoType)
+	}
+
+	return record, nil
+}
+
+// ResolveFull looks up a federated address, and returns the full federation record, including
+// the memo that payments to it must carry (e.g., for exchange deposits.)
+//
+//   record, err := ms.ResolveFull("bob*qubit.sh")
+//   err = ms.Pay("source_seed", record.AccountID, "3", USD, microstellar.Opts().WithFederationMemo(record))
+//
+// Returns an error if the federation server returns a memo that can't be decoded.
+func (ms *MicroStellar) ResolveFull(address string) (*FederationRecord, error) {
+	ms.debugf("ResolveFull", "looking up: %s", address)
+	if !strings.Contains(address, "*") {
+		return nil, ms.errorf("not a federation address: %s", address)
+	}
+
+	resp, err := ms.lookupFederated(address)
+	if err != nil {
+		return nil, ms.wrapf(err, "resolve error")
+	}
+
+	record, err := newFederationRecord(resp)
+	if err != nil {
+		return nil, ms.wrapf(err, "resolve error")
+	}
+
+	record.Address = address
+	return record, ms.success()
+}
+
+// federationClient returns a federation client that uses the configured HTTP client, if any.
+func (ms *MicroStellar) federationClient() *federation.Client {
 	var fedClient = &federation.Client{
 		HTTP:        http.DefaultClient,
-		Horizon:     NewTx(ms.networkName, ms.params).GetClient(),
+		Horizon:     ms.newTx().GetClient(),
 		StellarTOML: stellartoml.DefaultClient,
 	}
 
-	resp, err := fedClient.LookupByAddress(address)
+	if httpClient := httpClientFromParams(ms.params); httpClient != nil {
+		fedClient.HTTP = httpClient
+		fedClient.StellarTOML = &stellartoml.Client{HTTP: httpClient}
+	}
+
+	return fedClient
+}
+
+// lookupFederated resolves the federated address, returning the account ID along with
+// any memo the federation server requires.
+func (ms *MicroStellar) lookupFederated(address string) (*fedproto.NameResponse, error) {
+	return ms.federationClient().LookupByAddress(address)
+}
+
+// ErrNoFederationServer is returned by ReverseResolve if the domain's stellar.toml doesn't
+// have a federation server. Use errors.Cause to check for it.
+var ErrNoFederationServer = errors.New("no federation server")
 
+// ErrAccountNotRegistered is returned by ReverseResolve if the federation server doesn't have
+// a name for the account. Use errors.Cause to check for it.
+var ErrAccountNotRegistered = errors.New("account not registered with federation server")
+
+// ReverseResolve looks up the federated address (e.g., "bob*qubit.sh") of the account accountID
+// on the federation server in domain's stellar.toml, e.g., for display.
+//
+//   name, err := ms.ReverseResolve("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "qubit.sh")
+//
+// Returns an error with cause ErrNoFederationServer if the domain has no federation server, and
+// ErrAccountNotRegistered if the server doesn't know the account.
+func (ms *MicroStellar) ReverseResolve(accountID string, domain string) (string, error) {
+	if err := ValidAddress(accountID); err != nil {
+		return "", ms.errorf("invalid address: %s", accountID)
+	}
+
+	if domain == "" {
+		return "", ms.errorf("can't reverse resolve: missing domain")
+	}
+
+	stoml, err := ms.LoadStellarTOML(domain)
 	if err != nil {
-		return "", ms.wrapf(err, "resolve error")
+		return "", ms.wrapf(err, "can't reverse resolve %s on %s", accountID, domain)
 	}
 
-	return resp.AccountID, ms.success()
+	if stoml.FederationServer == "" {
+		return "", ms.wrapf(ErrNoFederationServer, "can't reverse resolve %s on %s", accountID, domain)
+	}
+
+	if !strings.HasPrefix(stoml.FederationServer, "https://") {
+		return "", ms.errorf("can't reverse resolve %s on %s: non-https federation server: %s", accountID, domain, stoml.FederationServer)
+	}
+
+	query := url.Values{"type": {"id"}, "q": {accountID}}
+	ms.debugf("ReverseResolve", "looking up %s on %s", accountID, stoml.FederationServer)
+
+	resp, err := ms.federationClient().HTTP.Get(stoml.FederationServer + "?" + query.Encode())
+	if err != nil {
+		return "", ms.wrapf(err, "can't reverse resolve %s on %s", accountID, domain)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ms.wrapf(ErrAccountNotRegistered, "can't reverse resolve %s on %s", accountID, domain)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return "", ms.errorf("can't reverse resolve %s on %s: status %d", accountID, domain, resp.StatusCode)
+	}
+
+	var idResp fedproto.IDResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, federation.FederationResponseMaxSize)).Decode(&idResp); err != nil {
+		return "", ms.wrapf(err, "can't reverse resolve %s on %s: bad federation response", accountID, domain)
+	}
+
+	if idResp.Address == "" {
+		return "", ms.wrapf(ErrAccountNotRegistered, "can't reverse resolve %s on %s", accountID, domain)
+	}
+
+	return idResp.Address, ms.success()
 }
 
 // PayNative makes a native asset payment of amount from source to target.
@@ -329,6 +824,12 @@ func (ms *MicroStellar) PayNative(sourceSeed string, targetAddress string, amoun
 	return ms.Pay(sourceSeed, targetAddress, amount, NativeAsset, options...)
 }
 
+// PayWithContext is like Pay, but aborts all requests to Horizon (including the submission)
+// when ctx is cancelled.
+func (ms *MicroStellar) PayWithContext(ctx context.Context, sourceAddressOrSeed string, targetAddress string, amount string, asset *Asset, options ...*Options) error {
+	return ms.Pay(sourceAddressOrSeed, targetAddress, amount, asset, optionsWithContext(ctx, options)...)
+}
+
 // Pay lets you make payments with credit assets.
 //
 //   USD := microstellar.NewAsset("USD", "ISSUERSEED", microstellar.Credit4Type)
@@ -350,17 +851,99 @@ func (ms *MicroStellar) PayNative(sourceSeed string, targetAddress string, amoun
 //
 //   ms.Pay("marys_seed", "bobs_address", "2000", INR,
 //       microstellar.Opts().WithAsset(XLM, "20").Through(USD, EUR).FindPathFrom("marys_address"))
+//
+// targetAddress can be a multiplexed (M...) address (see MuxedAddress), in which case the payment
+// goes to the base account, and carries the muxed ID. Path payments to muxed addresses are not
+// supported.
+//
+// Zero amounts are rejected, and so are payments to the source account (with ErrSelfPayment),
+// unless they're path payments or Options.AllowSelfPay is set.
 func (ms *MicroStellar) Pay(sourceAddressOrSeed string, targetAddress string, amount string, asset *Asset, options ...*Options) error {
+	_, err := ms.pay(sourceAddressOrSeed, targetAddress, amount, asset, options...)
+	return err
+}
+
+// PayWithResponse is like Pay, but also returns the response for this payment. Unlike Response(),
+// this is safe to use when the client is shared across goroutines. It can't be used within a
+// multi-op session (see Start.) For dry runs (see Options.WithDryRun), nothing is submitted, and
+// the response is nil: use LastPayload to get the envelope.
+func (ms *MicroStellar) PayWithResponse(sourceAddressOrSeed string, targetAddress string, amount string, asset *Asset, options ...*Options) (*TxResponse, error) {
+	tx, err := ms.pay(sourceAddressOrSeed, targetAddress, amount, asset, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.isMultiOp {
+		return nil, ms.errorf("can't get response from a multi-op transaction before Submit()")
+	}
+
+	return tx.Response(), nil
+}
+
+// ErrSelfPayment is returned by Pay if the source and target are the same account, which is
+// almost always a mistake. Use Options.AllowSelfPay to allow it. Use errors.Cause to check for it.
+var ErrSelfPayment = errors.New("source and target are the same account")
+
+// checkPayment returns an error if amount is zero, or if the payment pays the source account
+// (the operation source, if it's set with WithSourceAccount) unless opts allows it. Path payments
+// can pay the source, since they convert between assets. Invalid keys and amounts are reported
+// by the caller.
+func checkPayment(sourceAddressOrSeed string, targetAddress string, amount string, opts *Options) error {
+	if v, err := ParseAmount(amount); err == nil && v == 0 {
+		return errors.Errorf("invalid amount: must be positive: %s", amount)
+	}
+
+	if opts.allowSelfPay || opts.sendAsset != nil {
+		return nil
+	}
+
+	if opts.opSourceAccount != "" {
+		sourceAddressOrSeed = opts.opSourceAccount
+	}
+
+	source, err := addressOf(sourceAddressOrSeed)
+	if err != nil {
+		return nil
+	}
+
+	target := targetAddress
+	if isMuxedAddress(targetAddress) {
+		target, _, err = ParseMuxedAddress(targetAddress)
+	} else {
+		target, err = addressOf(targetAddress)
+	}
+
+	if err == nil && source == target {
+		return errors.Wrapf(ErrSelfPayment, "%s", source)
+	}
+
+	return nil
+}
+
+// pay builds, signs, and submits a payment, and returns its transaction.
+func (ms *MicroStellar) pay(sourceAddressOrSeed string, targetAddress string, amount string, asset *Asset, options ...*Options) (*Tx, error) {
 	if err := asset.Validate(); err != nil {
-		return ms.wrapf(err, "can't pay")
+		return nil, ms.wrapf(err, "can't pay")
 	}
 
 	if !ValidAddressOrSeed(sourceAddressOrSeed) {
-		return ms.errorf("can't pay: invalid source address or seed: %s", sourceAddressOrSeed)
+		return nil, ms.errorf("can't pay: invalid source address or seed: %s", sourceAddressOrSeed)
+	}
+
+	if err := checkPayment(sourceAddressOrSeed, targetAddress, amount, mergeOptions(options)); err != nil {
+		return nil, ms.wrapf(err, "can't pay")
+	}
+
+	if isMuxedAddress(targetAddress) {
+		return ms.payMuxed(sourceAddressOrSeed, targetAddress, amount, asset, options...)
 	}
 
 	if !ValidAddressOrSeed(targetAddress) {
-		return ms.errorf("can't pay: invalid address: %v", targetAddress)
+		return nil, ms.errorf("can't pay: invalid address: %v", targetAddress)
+	}
+
+	if err := ValidAmount(amount); err != nil {
+		return nil, ms.wrapf(err, "can't pay")
 	}
 
 	paymentMuts := []interface{}{
@@ -382,31 +965,35 @@ func (ms *MicroStellar) Pay(sourceAddressOrSeed string, targetAddress string, am
 
 		// Is this a path payment?
 		if opts.sendAsset != nil {
-			debugf("Pay", "path payment: deposit %s with %s", asset.Code, opts.sendAsset.Code)
+			if err := ValidAmount(opts.maxAmount); err != nil {
+				return nil, ms.wrapf(err, "can't pay: bad max amount")
+			}
+
+			ms.debugf("Pay", "path payment: deposit %s with %s", asset.Code, opts.sendAsset.Code)
 			payPath := build.PayWith(opts.sendAsset.ToStellarAsset(), opts.maxAmount)
 
 			if len(opts.path) > 0 {
 				for _, through := range opts.path {
-					debugf("Pay", "path payment: through %s", through.Code)
+					ms.debugf("Pay", "path payment: through %s", through.Code)
 					payPath = payPath.Through(through.ToStellarAsset())
 				}
 			} else {
-				debugf("Pay", "no path specified, searching for paths from: %s", opts.sourceAddress)
+				ms.debugf("Pay", "no path specified, searching for paths from: %s", opts.sourceAddress)
 				if err := ValidAddress(opts.sourceAddress); err != nil {
-					return ms.wrapf(err, "not a valid source address: %s", opts.sourceAddress)
+					return nil, ms.wrapf(err, "not a valid source address: %s", opts.sourceAddress)
 				}
 
 				paths, err := ms.FindPaths(opts.sourceAddress, targetAddress, asset, amount, Opts().WithAsset(opts.sendAsset, opts.maxAmount))
 				if err != nil {
-					return ms.wrapf(err, "path finding error")
+					return nil, ms.wrapf(err, "path finding error")
 				}
 
 				if len(paths) < 1 {
-					return ms.errorf("no paths found from %s to %s", opts.sendAsset.Code, asset.Code)
+					return nil, ms.errorf("no paths found from %s to %s", opts.sendAsset.Code, asset.Code)
 				}
 
 				for _, hop := range paths[0].Hops {
-					debugf("Pay", "path payment: through %s", hop.Code)
+					ms.debugf("Pay", "path payment: through %s", hop.Code)
 					payPath = payPath.Through(hop.ToStellarAsset())
 				}
 			}
@@ -415,12 +1002,20 @@ func (ms *MicroStellar) Pay(sourceAddressOrSeed string, targetAddress string, am
 		}
 	}
 
+	if opts := mergeOptions(options); opts.sendAsset == nil {
+		tx.addFakeOp(sourceAddressOrSeed, fakePayment(targetAddress, amount, asset))
+	}
+
 	tx.Build(sourceAccount(sourceAddressOrSeed), build.Payment(paymentMuts...))
-	return ms.signAndSubmit(tx, sourceAddressOrSeed)
+	return tx, ms.signAndSubmit(tx, sourceAddressOrSeed)
 }
 
 // CreateTrustLine creates a trustline from sourceSeed to asset, with the specified trust limit. An empty
 // limit string indicates no limit.
+//
+// Trust line flags can't be set by the account creating the trust line: new trust lines are clawback
+// enabled if the issuer has FlagAuthClawbackEnabled set, and the issuer sets the rest with
+// AllowTrustWithFlags or SetTrustLineFlags.
 func (ms *MicroStellar) CreateTrustLine(sourceSeed string, asset *Asset, limit string, options ...*Options) error {
 	if !ValidAddressOrSeed(sourceSeed) {
 		return ms.errorf("can't create trust line: invalid source address or seed: %s", sourceSeed)
@@ -430,12 +1025,19 @@ func (ms *MicroStellar) CreateTrustLine(sourceSeed string, asset *Asset, limit s
 		return ms.wrapf(err, "can't create trust line")
 	}
 
+	if limit != "" {
+		if err := ValidAmount(limit); err != nil {
+			return ms.wrapf(err, "can't create trust line: bad limit")
+		}
+	}
+
 	tx := ms.getTx()
 
 	if len(options) > 0 {
 		tx.SetOptions(options[0])
 	}
 
+	tx.addFakeOp(sourceSeed, fakeChangeTrust(asset, limit))
 	if limit == "" {
 		tx.Build(sourceAccount(sourceSeed), build.Trust(asset.Code, asset.Issuer))
 	} else {
@@ -461,15 +1063,37 @@ func (ms *MicroStellar) RemoveTrustLine(sourceSeed string, asset *Asset, options
 		tx.SetOptions(options[0])
 	}
 
+	tx.addFakeOp(sourceSeed, fakeChangeTrust(asset, "0"))
 	tx.Build(sourceAccount(sourceSeed), build.RemoveTrust(asset.Code, asset.Issuer))
 	return ms.signAndSubmit(tx, sourceSeed)
 }
 
 // AllowTrust authorizes an trustline that was just created by an account to an asset. This can be used
 // by issuers that have the AUTH_REQUIRED flag. The flag can be cleared if the issuer has the
-// AUTH_REVOCABLE flag. The assetCode field must be an asset issued by sourceSeed.
+// AUTH_REVOCABLE flag. The assetCode field must be an asset issued by sourceSeed. To authorize the
+// account to only maintain its existing liabilities, use AllowTrustWithFlags.
 func (ms *MicroStellar) AllowTrust(sourceSeed string, address string, assetCode string, authorized bool, options ...*Options) error {
-	if !ValidAddressOrSeed(sourceSeed) {
+	source, err := keypair.Parse(sourceSeed)
+	if err != nil {
+		return ms.errorf("can't authorize trust line: invalid source address or seed: %s", sourceSeed)
+	}
+
+	asset, err := NewAssetAuto(assetCode, source.Address())
+	if err != nil {
+		return ms.wrapf(err, "can't authorize trust line")
+	}
+
+	return ms.AllowTrustForAsset(sourceSeed, address, asset, authorized, options...)
+}
+
+// AllowTrustForAsset is like AllowTrust, but takes the asset instead of its code. The asset must
+// be issued by sourceSeed.
+//
+//   USD := microstellar.NewAsset("USD", issuerAddress, microstellar.Credit4Type)
+//   err := ms.AllowTrustForAsset(issuerSeed, bobAddress, USD, true)
+func (ms *MicroStellar) AllowTrustForAsset(sourceSeed string, address string, asset *Asset, authorized bool, options ...*Options) error {
+	source, err := keypair.Parse(sourceSeed)
+	if err != nil {
 		return ms.errorf("can't authorize trust line: invalid source address or seed: %s", sourceSeed)
 	}
 
@@ -477,6 +1101,18 @@ func (ms *MicroStellar) AllowTrust(sourceSeed string, address string, assetCode
 		return ms.errorf("can't authorize trust line: invalid account address: %s", address)
 	}
 
+	if err := asset.Validate(); err != nil {
+		return ms.wrapf(err, "can't authorize trust line")
+	}
+
+	if asset.IsNative() {
+		return ms.errorf("can't authorize trust line: native assets have no trust lines")
+	}
+
+	if asset.Issuer != source.Address() {
+		return ms.errorf("can't authorize trust line: asset %s is issued by %s, not %s", asset.Code, asset.Issuer, source.Address())
+	}
+
 	tx := ms.getTx()
 
 	if len(options) > 0 {
@@ -485,7 +1121,7 @@ func (ms *MicroStellar) AllowTrust(sourceSeed string, address string, assetCode
 
 	tx.Build(sourceAccount(sourceSeed), build.AllowTrust(
 		build.Trustor{Address: address},
-		build.AllowTrustAsset{Code: assetCode},
+		build.AllowTrustAsset{Code: asset.Code},
 		build.Authorize{Value: authorized}))
 
 	return ms.signAndSubmit(tx, sourceSeed)
@@ -525,6 +1161,10 @@ const (
 	// FlagAuthImmutable means that the other auth parameters can never be set
 	// and the issuer's account can never be deleted.
 	FlagAuthImmutable = AccountFlags(4)
+
+	// FlagAuthClawbackEnabled allows the issuer to claw back the asset from trust
+	// lines created after the flag is set. It requires FlagAuthRevocable.
+	FlagAuthClawbackEnabled = AccountFlags(8)
 )
 
 // SetFlags sets flags on the account.
@@ -615,10 +1255,41 @@ func (ms *MicroStellar) RemoveSigner(sourceSeed string, signerAddress string, op
 	return ms.signAndSubmit(tx, sourceSeed)
 }
 
-// SetThresholds sets the signing thresholds for the account.
-func (ms *MicroStellar) SetThresholds(sourceSeed string, low, medium, high uint32, options ...*Options) error {
-	if !ValidAddressOrSeed(sourceSeed) {
-		return ms.errorf("can't set thresholds: invalid source address or seed: %s", sourceSeed)
+// ReplaceSigner rotates a signer on sourceSeed's account: it adds newSigner with weight weight,
+// and removes oldSigner, in a single transaction. The new signer is added before the old one is
+// removed, and both operations succeed or fail together, so the account never loses the signing
+// weight it needs (e.g., to rotate a compromised key.)
+//
+//   err := ms.ReplaceSigner("source_seed", "old_signer_address", "new_signer_address", 1)
+//
+// Signatures are checked against the account's signers before the transaction is applied, so the
+// old signer can still sign the rotation. Use SetMasterWeight to change the master key's weight.
+func (ms *MicroStellar) ReplaceSigner(sourceSeed, oldSigner, newSigner string, weight uint32, options ...*Options) error {
+	source, err := addressOf(sourceSeed)
+	if err != nil {
+		return ms.errorf("can't replace signer: invalid source address or seed: %s", sourceSeed)
+	}
+
+	oldAddress, err := addressOf(oldSigner)
+	if err != nil {
+		return ms.errorf("can't replace signer: invalid old signer address or seed: %s", oldSigner)
+	}
+
+	newAddress, err := addressOf(newSigner)
+	if err != nil {
+		return ms.errorf("can't replace signer: invalid new signer address or seed: %s", newSigner)
+	}
+
+	if oldAddress == newAddress {
+		return ms.errorf("can't replace signer: old and new signers are the same: %s", newAddress)
+	}
+
+	if oldAddress == source || newAddress == source {
+		return ms.errorf("can't replace signer: the master key isn't a signer, use SetMasterWeight")
+	}
+
+	if weight == 0 || weight > 255 {
+		return ms.errorf("can't replace signer: weight must be between 1 and 255: %d", weight)
 	}
 
 	tx := ms.getTx()
@@ -627,41 +1298,177 @@ func (ms *MicroStellar) SetThresholds(sourceSeed string, low, medium, high uint3
 		tx.SetOptions(options[0])
 	}
 
-	tx.Build(sourceAccount(sourceSeed), build.SetThresholds(low, medium, high))
+	tx.Build(sourceAccount(sourceSeed), build.AddSigner(newAddress, weight), build.RemoveSigner(oldAddress))
 	return ms.signAndSubmit(tx, sourceSeed)
 }
 
-// SetData lets you attach (or update) arbitrary data to an account. The lengths of the key and value must each be
-// less than 64 bytes.
-func (ms *MicroStellar) SetData(sourceSeed string, key string, val []byte, options ...*Options) error {
+// AddPreAuthTxSigner adds the hash of a pre-authorized transaction as a signer on sourceSeed's
+// account, with weight signerWeight. The transaction can then be submitted without any other
+// signatures, and the signer is automatically removed once it's applied. Use ParseTxHash to
+// convert a hex or base64 hash (e.g., from TxTrackingID) to bytes.
+//
+//   hash, err := ms.TxTrackingID(b64Tx)
+//   txHash, err := microstellar.ParseTxHash(hash)
+//   ms.AddPreAuthTxSigner("source_seed", txHash, 1)
+func (ms *MicroStellar) AddPreAuthTxSigner(sourceSeed string, txHash [32]byte, signerWeight uint32, options ...*Options) error {
 	if !ValidAddressOrSeed(sourceSeed) {
-		return ms.errorf("can't set thresholds: invalid source address or seed: %s", sourceSeed)
+		return ms.errorf("can't add pre-auth signer: invalid source address or seed: %s", sourceSeed)
+	}
+
+	signerKey, err := strkey.Encode(strkey.VersionByteHashTx, txHash[:])
+	if err != nil {
+		return ms.wrapf(err, "can't add pre-auth signer")
 	}
 
 	tx := ms.getTx()
 
 	if len(options) > 0 {
-		tx.SetOptions(options[0])
+		tx.SetOptions(mergeOptions(options))
 	}
 
-	if key == "" {
-		return ms.errorf("data key must not be empty")
+	tx.Build(sourceAccount(sourceSeed), build.AddSigner(signerKey, signerWeight))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// RemovePreAuthTxSigner removes the pre-authorized transaction hash txHash as a signer from
+// sourceSeed's account.
+func (ms *MicroStellar) RemovePreAuthTxSigner(sourceSeed string, txHash [32]byte, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't remove pre-auth signer: invalid source address or seed: %s", sourceSeed)
 	}
 
-	if len(key) > 64 {
-		return ms.errorf("data key must be under 64 bytes: %s", key)
+	signerKey, err := strkey.Encode(strkey.VersionByteHashTx, txHash[:])
+	if err != nil {
+		return ms.wrapf(err, "can't remove pre-auth signer")
 	}
 
-	if len(val) > 64 {
-		return ms.errorf("data value must be under 64 bytes: %s", string(val))
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(mergeOptions(options))
 	}
 
-	tx.Build(sourceAccount(sourceSeed), build.SetData(key, val))
+	tx.Build(sourceAccount(sourceSeed), build.RemoveSigner(signerKey))
 	return ms.signAndSubmit(tx, sourceSeed)
 }
 
-// ClearData removes attached data from an account.
-func (ms *MicroStellar) ClearData(sourceSeed string, key string, options ...*Options) error {
+// HashX returns the sha256 hash of preimage, for use with AddHashXSigner.
+func HashX(preimage []byte) [32]byte {
+	return sha256.Sum256(preimage)
+}
+
+// AddHashXSigner adds a hash(x) signer on sourceSeed's account, with weight signerWeight. A
+// transaction can then be signed by anyone who knows x, the preimage of hashX. Use HashX to
+// compute the hash from the preimage.
+//
+//   ms.AddHashXSigner("source_seed", microstellar.HashX([]byte("secret")), 1)
+func (ms *MicroStellar) AddHashXSigner(sourceSeed string, hashX [32]byte, signerWeight uint32, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't add hash(x) signer: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if signerWeight > 255 {
+		return ms.errorf("can't add hash(x) signer: weight must be between 0 and 255: %d", signerWeight)
+	}
+
+	signerKey, err := strkey.Encode(strkey.VersionByteHashX, hashX[:])
+	if err != nil {
+		return ms.wrapf(err, "can't add hash(x) signer")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(mergeOptions(options))
+	}
+
+	tx.Build(sourceAccount(sourceSeed), build.AddSigner(signerKey, signerWeight))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// RemoveHashXSigner removes the hash(x) signer hashX from sourceSeed's account.
+func (ms *MicroStellar) RemoveHashXSigner(sourceSeed string, hashX [32]byte, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't remove hash(x) signer: invalid source address or seed: %s", sourceSeed)
+	}
+
+	signerKey, err := strkey.Encode(strkey.VersionByteHashX, hashX[:])
+	if err != nil {
+		return ms.wrapf(err, "can't remove hash(x) signer")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(mergeOptions(options))
+	}
+
+	tx.Build(sourceAccount(sourceSeed), build.RemoveSigner(signerKey))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// SignerSpec is a signer in a SetSigners call. Key is an account address (G...), a
+// pre-authorized transaction hash (T...), or a hash(x) (X...), and Weight is between 0 and 255.
+// A weight of 0 removes the signer.
+type SignerSpec struct {
+	Key    string
+	Weight uint32
+}
+
+// SetSigners adds, updates, or removes every signer in signers on sourceSeed's account, in a
+// single transaction. Signers with weight 0 are removed, and the rest are added (or have their
+// weights updated.) Either every change is applied, or none are. Signers not in signers are left
+// alone.
+//
+//   err := ms.SetSigners("source_seed", []microstellar.SignerSpec{
+//       {Key: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", Weight: 1},
+//       {Key: "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A", Weight: 0},
+//   })
+func (ms *MicroStellar) SetSigners(sourceSeed string, signers []SignerSpec, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't set signers: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if len(signers) == 0 {
+		return ms.errorf("can't set signers: no signers")
+	}
+
+	if len(signers) > maxOpsPerTx {
+		return ms.errorf("can't set signers: too many signers: %d (max %d)", len(signers), maxOpsPerTx)
+	}
+
+	seen := map[string]bool{}
+	muts := make([]build.TransactionMutator, len(signers))
+	for i, signer := range signers {
+		var key xdr.SignerKey
+		if err := key.SetAddress(signer.Key); err != nil {
+			return ms.errorf("can't set signers: invalid signer key: %s", signer.Key)
+		}
+
+		if signer.Weight > 255 {
+			return ms.errorf("can't set signers: weight must be between 0 and 255: %d", signer.Weight)
+		}
+
+		if seen[signer.Key] {
+			return ms.errorf("can't set signers: duplicate signer: %s", signer.Key)
+		}
+		seen[signer.Key] = true
+
+		muts[i] = build.AddSigner(signer.Key, signer.Weight)
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), muts...)
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// SetThresholds sets the signing thresholds for the account.
+func (ms *MicroStellar) SetThresholds(sourceSeed string, low, medium, high uint32, options ...*Options) error {
 	if !ValidAddressOrSeed(sourceSeed) {
 		return ms.errorf("can't set thresholds: invalid source address or seed: %s", sourceSeed)
 	}
@@ -672,26 +1479,134 @@ func (ms *MicroStellar) ClearData(sourceSeed string, key string, options ...*Opt
 		tx.SetOptions(options[0])
 	}
 
-	if len(key) > 64 {
-		return ms.errorf("data key must be under 64 bytes: %s", key)
+	tx.Build(sourceAccount(sourceSeed), build.SetThresholds(low, medium, high))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// AccountOptionsSpec is a set of account settings for UpdateAccountOptions. Only the fields that
+// are set (non-nil) are changed.
+type AccountOptionsSpec struct {
+	HomeDomain      *string
+	MasterWeight    *uint32
+	LowThreshold    *uint32
+	MediumThreshold *uint32
+	HighThreshold   *uint32
+	SetFlags        *AccountFlags
+	ClearFlags      *AccountFlags
+	InflationDest   *string
+}
+
+// UpdateAccountOptions changes the settings in spec on sourceSeed's account, in a single
+// set_options operation, so they're all applied or none are. Fields of spec that are nil are left
+// unchanged.
+//
+//   domain, weight, high := "qubit.sh", uint32(1), uint32(2)
+//   err := ms.UpdateAccountOptions(sourceSeed, microstellar.AccountOptionsSpec{
+//       HomeDomain:    &domain,
+//       MasterWeight:  &weight,
+//       HighThreshold: &high,
+//   })
+func (ms *MicroStellar) UpdateAccountOptions(sourceSeed string, spec AccountOptionsSpec, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't update account options: invalid source address or seed: %s", sourceSeed)
+	}
+
+	muts := []interface{}{}
+
+	if spec.HomeDomain != nil {
+		if len(*spec.HomeDomain) > 32 {
+			return ms.errorf("can't update account options: home domain longer than 32 bytes: %s", *spec.HomeDomain)
+		}
+		muts = append(muts, build.HomeDomain(*spec.HomeDomain))
+	}
+
+	for _, w := range []*uint32{spec.MasterWeight, spec.LowThreshold, spec.MediumThreshold, spec.HighThreshold} {
+		if w != nil && *w > 255 {
+			return ms.errorf("can't update account options: weights and thresholds must be at most 255, got %d", *w)
+		}
+	}
+
+	if spec.MasterWeight != nil {
+		muts = append(muts, build.MasterWeight(*spec.MasterWeight))
+	}
+
+	if spec.LowThreshold != nil || spec.MediumThreshold != nil || spec.HighThreshold != nil {
+		muts = append(muts, build.Thresholds{Low: spec.LowThreshold, Medium: spec.MediumThreshold, High: spec.HighThreshold})
+	}
+
+	if spec.InflationDest != nil {
+		if err := ValidAddress(*spec.InflationDest); err != nil {
+			return ms.errorf("can't update account options: invalid inflation destination: %s", *spec.InflationDest)
+		}
+		muts = append(muts, build.InflationDest(*spec.InflationDest))
+	}
+
+	allFlags := FlagAuthRequired | FlagAuthRevocable | FlagAuthImmutable | FlagAuthClawbackEnabled
+	for _, flags := range []*AccountFlags{spec.SetFlags, spec.ClearFlags} {
+		if flags != nil && *flags&^allFlags != 0 {
+			return ms.errorf("can't update account options: unknown flags: %d", *flags)
+		}
+	}
+
+	if spec.SetFlags != nil && spec.ClearFlags != nil && *spec.SetFlags&*spec.ClearFlags != 0 {
+		return ms.errorf("can't update account options: can't set and clear the same flags")
+	}
+
+	if len(muts) == 0 && spec.SetFlags == nil && spec.ClearFlags == nil {
+		return ms.errorf("can't update account options: no options set")
+	}
+
+	op := build.SetOptions(muts...)
+	if op.Err != nil {
+		return ms.wrapf(op.Err, "can't update account options")
+	}
+
+	// The builder's flag mutators only take one flag at a time.
+	if spec.SetFlags != nil {
+		flags := xdr.Uint32(*spec.SetFlags)
+		op.SO.SetFlags = &flags
+	}
+
+	if spec.ClearFlags != nil {
+		flags := xdr.Uint32(*spec.ClearFlags)
+		op.SO.ClearFlags = &flags
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
 	}
 
-	tx.Build(sourceAccount(sourceSeed), build.ClearData(key))
+	tx.Build(sourceAccount(sourceSeed), op)
 	return ms.signAndSubmit(tx, sourceSeed)
 }
 
 // SignTransaction signs a base64-encoded transaction envelope with the specified seeds
 // for the current network.
 func (ms *MicroStellar) SignTransaction(b64Tx string, seeds ...string) (string, error) {
-	tx := ms.getTx()
+	return ms.SignTransactionForNetwork(b64Tx, ms.getTx().network.Passphrase, seeds...)
+}
+
+// SignTransactionForNetwork is like SignTransaction, but signs for the network with the given
+// passphrase, instead of the client's network. Signatures are only valid on the network they're
+// made for, so use this when the client is configured for a different network than the
+// transaction (e.g., to sign a testnet transaction on a public network client.)
+//
+//   signed, err := ms.SignTransactionForNetwork(b64Tx, network.TestNetworkPassphrase, "source_seed")
+func (ms *MicroStellar) SignTransactionForNetwork(b64Tx, passphrase string, seeds ...string) (string, error) {
+	if passphrase == "" {
+		return "", ms.errorf("can't sign transaction: missing network passphrase")
+	}
+
 	xdrTxe, err := DecodeTx(b64Tx)
 
 	if err != nil {
 		return "", ms.wrapf(err, "DecodeTx")
 	}
 
-	debugf("SignTransaction", "decoded transaction: %+v", xdrTxe)
-	hash, err := network.HashTransaction(&xdrTxe.Tx, tx.network.Passphrase)
+	ms.debugf("SignTransaction", "decoded transaction: %+v", xdrTxe)
+	hash, err := network.HashTransaction(&xdrTxe.Tx, passphrase)
 
 	if err != nil {
 		return "", ms.wrapf(err, "hash failed")
@@ -708,7 +1623,7 @@ func (ms *MicroStellar) SignTransaction(b64Tx string, seeds ...string) (string,
 			return "", ms.wrapf(err, "sign failed")
 		}
 
-		debugf("SignTransaction", "adding signature: %+v", sig)
+		ms.debugf("SignTransaction", "adding signature: %+v", sig)
 		xdrTxe.Signatures = append(xdrTxe.Signatures, sig)
 	}
 
@@ -720,10 +1635,126 @@ func (ms *MicroStellar) SignTransaction(b64Tx string, seeds ...string) (string,
 	return signedTx, ms.success()
 }
 
-// SubmitTransaction submits a base64-encoded transaction envelope to the Stellar network
-func (ms *MicroStellar) SubmitTransaction(b64Tx string) (*TxResponse, error) {
+// TxTrackingID returns a stable ID for the base64-encoded transaction envelope b64Tx, which can be used
+// to correlate the transaction across logs and Horizon. The ID is the hex-encoded transaction hash
+// under the current network, so it's the same for signed and unsigned envelopes, and also matches the
+// hash Horizon reports once the transaction is submitted.
+//
+// Rebuilding a transaction with the same inputs (source, sequence number, fee, memo, time bounds, and
+// operations) yields the same ID.
+func (ms *MicroStellar) TxTrackingID(b64Tx string) (string, error) {
+	tx := ms.getTx()
+	xdrTxe, err := DecodeTx(b64Tx)
+
+	if err != nil {
+		return "", ms.wrapf(err, "DecodeTx")
+	}
+
+	hash, err := network.HashTransaction(&xdrTxe.Tx, tx.network.Passphrase)
+	if err != nil {
+		return "", ms.wrapf(err, "hash failed")
+	}
+
+	return hex.EncodeToString(hash[:]), ms.success()
+}
+
+// SubmitTransaction submits a base64-encoded transaction envelope to the Stellar network. Use
+// Opts().WithHealthGate(...) to refuse to submit to a lagging Horizon server.
+func (ms *MicroStellar) SubmitTransaction(b64Tx string, options ...*Options) (*TxResponse, error) {
+	return ms.SubmitTransactionWithContext(context.Background(), b64Tx, options...)
+}
+
+// SubmitTransactionWithContext is like SubmitTransaction, but aborts the submission when ctx
+// is cancelled. Note that a transaction that has already reached Horizon may still be applied
+// to the ledger.
+func (ms *MicroStellar) SubmitTransactionWithContext(ctx context.Context, b64Tx string, options ...*Options) (*TxResponse, error) {
+	ops := -1
+	if txe, err := DecodeTx(b64Tx); err == nil {
+		ops = len(txe.Tx.Operations)
+	}
+
+	if err := validEnvelopeSize(b64Tx, ops); err != nil {
+		return nil, ms.wrapf(err, "could not submit transaction")
+	}
+
 	tx := ms.getTx()
-	resp, err := tx.GetClient().SubmitTransaction(b64Tx)
-	txResponse := TxResponse(resp)
-	return &txResponse, ms.err(err)
+	client := clientWithContext(ctx, tx.GetClient())
+
+	if opts := mergeOptions(options); opts.hasHealthGate {
+		if err := checkHealthGate(ms.logger(), client, opts.maxLag); err != nil {
+			return nil, ms.wrapf(err, "could not submit transaction")
+		}
+	}
+
+	resp, err := submitTransaction(client, b64Tx)
+	return &resp, ms.err(err)
+}
+
+// confirmPollInterval is how often SubmitTransactionAndConfirm checks for the transaction.
+var confirmPollInterval = time.Second
+
+// SubmitTransactionAndConfirm submits the base64-encoded transaction envelope b64Tx, and waits
+// until the transaction is included in a closed ledger, or ctx is done. Horizon may accept a
+// submission before applying it (e.g., with asynchronous submission), so SubmitTransaction alone
+// doesn't guarantee the transaction is final.
+//
+//   ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//   defer cancel()
+//
+//   resp, err := ms.SubmitTransactionAndConfirm(ctx, signedTx)
+//   log.Printf("transaction %s confirmed in ledger %d", resp.Hash, resp.Ledger)
+//
+// The returned response has the ledger sequence the transaction was included in, and its result
+// and meta XDR. If the transaction was included but failed, the response is returned along with
+// an error.
+func (ms *MicroStellar) SubmitTransactionAndConfirm(ctx context.Context, b64Tx string, options ...*Options) (*TxResponse, error) {
+	resp, err := ms.SubmitTransactionWithContext(ctx, b64Tx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Ledger > 0 {
+		// Horizon applied the transaction before responding.
+		return resp, ms.success()
+	}
+
+	if resp.Hash == "" {
+		return nil, ms.errorf("can't confirm transaction: no hash in submission response")
+	}
+
+	client := clientWithContext(ctx, ms.getTx().GetClient())
+	for {
+		ht, err := getHorizonTransaction(client, resp.Hash)
+		if err == nil && ht.Ledger > 0 {
+			confirmed := &TxResponse{
+				TransactionSuccess: horizon.TransactionSuccess{
+					Hash:   ht.Hash,
+					Ledger: ht.Ledger,
+					Env:    ht.EnvelopeXdr,
+					Result: ht.ResultXdr,
+					Meta:   ht.ResultMetaXdr,
+				},
+				Ledger:    int64(ht.Ledger),
+				CreatedAt: ht.LedgerCloseTime,
+			}
+
+			if ht.Successful != nil && !*ht.Successful {
+				return confirmed, ms.errorf("transaction %s failed in ledger %d", ht.Hash, ht.Ledger)
+			}
+
+			return confirmed, ms.success()
+		}
+
+		if err != nil && errors.Cause(err) != ErrTransactionNotFound {
+			return nil, ms.wrapf(err, "can't confirm transaction %s", resp.Hash)
+		}
+
+		ms.debugf("SubmitTransactionAndConfirm", "waiting for transaction %s", resp.Hash)
+
+		select {
+		case <-ctx.Done():
+			return nil, ms.wrapf(ctx.Err(), "transaction %s not confirmed", resp.Hash)
+		case <-time.After(confirmPollInterval):
+		}
+	}
 }
@@ -3,12 +3,23 @@ package microstellar
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
 	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/network"
+	fedproto "github.com/stellar/go/protocols/federation"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
 )
 
 func Example() {
-	// Create a new MicroStellar client connected to a mock network. The client is not
-	// thread-safe, however you can create as many instances as you need.
+	// Create a new MicroStellar client connected to a mock network. Single-shot operations
+	// on the client are thread-safe, however multi-op sessions (Start/Submit) are not.
 	ms := New("fake")
 
 	// Generate a new random keypair.
@@ -641,3 +652,575 @@ func ExampleMicroStellar_Start() {
 	fmt.Printf("ok")
 	// Output: ok
 }
+
+func TestOnNetwork(t *testing.T) {
+	ms := New("test", Params{"foo": "bar"})
+	view := ms.OnNetwork("fake")
+
+	if !view.fake || view.networkName != "fake" {
+		t.Errorf("view should be on the fake network: got %v", view.networkName)
+	}
+
+	if ms.fake || ms.networkName != "test" {
+		t.Errorf("OnNetwork should not modify the original client: got %v", ms.networkName)
+	}
+
+	if view.params["foo"] != "bar" {
+		t.Errorf("view should share params: got %v", view.params)
+	}
+
+	custom := ms.OnNetwork("custom", Params{"url": "https://foo.bar", "passphrase": "baz"})
+	if err := custom.Err(); err != nil {
+		t.Errorf("custom view should be valid: %v", err)
+	}
+
+	if passphrase := custom.getTx().network.Passphrase; passphrase != "baz" {
+		t.Errorf("wrong passphrase on custom view: want %v, got %v", "baz", passphrase)
+	}
+
+	if _, ok := ms.params["url"]; ok {
+		t.Errorf("custom view should not leak params into the original client")
+	}
+
+	if ms.OnNetwork("bogus").Err() == nil {
+		t.Errorf("unsupported networks should set Err()")
+	}
+
+	if ms.OnNetwork("custom").Err() == nil {
+		t.Errorf("custom networks without parameters should set Err()")
+	}
+}
+
+func TestAddPreAuthTxSigner(t *testing.T) {
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	txHash, _ := ParseTxHash("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+
+	ms.Start(source, Opts().SkipSignatures())
+	ms.AddPreAuthTxSigner(source, txHash, 1)
+	ms.RemovePreAuthTxSigner(source, txHash)
+	payload, err := ms.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("DecodeTx failed: %v", err)
+	}
+
+	ops := txe.Tx.Operations
+	if len(ops) != 2 {
+		t.Fatalf("want 2 operations, got %d", len(ops))
+	}
+
+	for i, weight := range []xdr.Uint32{1, 0} {
+		signer := ops[i].Body.MustSetOptionsOp().Signer
+		if signer.Key.Type != xdr.SignerKeyTypeSignerKeyTypePreAuthTx || signer.Key.MustPreAuthTx() != xdr.Uint256(txHash) || signer.Weight != weight {
+			t.Errorf("wrong signer in op %d: %+v", i, signer)
+		}
+	}
+
+	// Every option applies, not just the first.
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	for _, signer := range []func(...*Options) error{
+		func(opts ...*Options) error { return ms.AddPreAuthTxSigner(seed, txHash, 1, opts...) },
+		func(opts ...*Options) error { return ms.RemovePreAuthTxSigner(seed, txHash, opts...) },
+	} {
+		if err := signer(Opts().WithMemoText("preauth"), Opts().WithDryRun()); err != nil {
+			t.Fatalf("signer update failed: %v", ErrorString(err))
+		}
+
+		payload, _ := ms.LastPayload()
+		txe, err := DecodeTx(payload)
+		if err != nil || ms.Response() != nil || txe.Tx.Memo.MustText() != "preauth" {
+			t.Errorf("want dry run with memo, got %+v (%v)", txe, err)
+		}
+	}
+
+	if err := New("fake").AddPreAuthTxSigner("bad seed", txHash, 1); err == nil {
+		t.Errorf("AddPreAuthTxSigner should reject invalid seeds")
+	}
+}
+
+func TestAddHashXSigner(t *testing.T) {
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	hashX := HashX([]byte("secret"))
+
+	ms.Start(source, Opts().SkipSignatures())
+	ms.AddHashXSigner(source, hashX, 5)
+	ms.RemoveHashXSigner(source, hashX)
+	payload, err := ms.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("DecodeTx failed: %v", err)
+	}
+
+	ops := txe.Tx.Operations
+	if len(ops) != 2 {
+		t.Fatalf("want 2 operations, got %d", len(ops))
+	}
+
+	for i, weight := range []xdr.Uint32{5, 0} {
+		signer := ops[i].Body.MustSetOptionsOp().Signer
+		if signer.Key.Type != xdr.SignerKeyTypeSignerKeyTypeHashX || signer.Key.MustHashX() != xdr.Uint256(hashX) || signer.Weight != weight {
+			t.Errorf("wrong signer in op %d: %+v", i, signer)
+		}
+	}
+
+	// Every option applies, not just the first.
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	for _, signer := range []func(...*Options) error{
+		func(opts ...*Options) error { return ms.AddHashXSigner(seed, hashX, 5, opts...) },
+		func(opts ...*Options) error { return ms.RemoveHashXSigner(seed, hashX, opts...) },
+	} {
+		if err := signer(Opts().WithMemoText("hashx"), Opts().WithDryRun()); err != nil {
+			t.Fatalf("signer update failed: %v", ErrorString(err))
+		}
+
+		payload, _ := ms.LastPayload()
+		txe, err := DecodeTx(payload)
+		if err != nil || ms.Response() != nil || txe.Tx.Memo.MustText() != "hashx" {
+			t.Errorf("want dry run with memo, got %+v (%v)", txe, err)
+		}
+	}
+
+	if err := New("fake").AddHashXSigner(source, hashX, 256); err == nil {
+		t.Errorf("AddHashXSigner should reject weights over 255")
+	}
+}
+
+func TestSetSigners(t *testing.T) {
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	hashX, _ := strkey.Encode(strkey.VersionByteHashX, []byte("0123456789abcdef0123456789abcdef"))
+
+	signers := []SignerSpec{
+		{Key: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", Weight: 1},
+		{Key: "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A", Weight: 0},
+		{Key: hashX, Weight: 2},
+	}
+
+	if err := ms.SetSigners(source, signers, Opts().WithDryRun()); err != nil {
+		t.Fatalf("SetSigners failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("DecodeTx failed: %v", err)
+	}
+
+	ops := txe.Tx.Operations
+	if len(ops) != len(signers) {
+		t.Fatalf("want %d operations, got %d", len(signers), len(ops))
+	}
+
+	for i, want := range signers {
+		signer := ops[i].Body.MustSetOptionsOp().Signer
+		if signer == nil || signer.Key.Address() != want.Key || uint32(signer.Weight) != want.Weight {
+			t.Errorf("wrong signer in op %d: %+v", i, signer)
+		}
+	}
+
+	bad := [][]SignerSpec{
+		nil,
+		{{Key: "bad key", Weight: 1}},
+		{{Key: "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK", Weight: 1}},
+		{{Key: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", Weight: 256}},
+		{signers[0], signers[0]},
+	}
+
+	for i, specs := range bad {
+		if err := New("fake").SetSigners(source, specs); err == nil {
+			t.Errorf("%d: SetSigners should fail for %+v", i, specs)
+		}
+	}
+}
+
+func TestReplaceSigner(t *testing.T) {
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	oldSigner := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	newSigner := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	if err := ms.ReplaceSigner(source, oldSigner, newSigner, 2, Opts().WithDryRun()); err != nil {
+		t.Fatalf("ReplaceSigner failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("DecodeTx failed: %v", err)
+	}
+
+	ops := txe.Tx.Operations
+	if len(ops) != 2 {
+		t.Fatalf("want 2 operations, got %d", len(ops))
+	}
+
+	// The new signer is added first.
+	added, removed := ops[0].Body.MustSetOptionsOp().Signer, ops[1].Body.MustSetOptionsOp().Signer
+	if added == nil || added.Key.Address() != newSigner || added.Weight != 2 {
+		t.Errorf("wrong added signer: %+v", added)
+	}
+
+	if removed == nil || removed.Key.Address() != oldSigner || removed.Weight != 0 {
+		t.Errorf("wrong removed signer: %+v", removed)
+	}
+
+	bad := []struct {
+		oldSigner, newSigner string
+		weight               uint32
+	}{
+		{oldSigner, oldSigner, 1},
+		{oldSigner, newSigner, 0},
+		{oldSigner, newSigner, 256},
+		{"bad signer", newSigner, 1},
+		{"GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R", newSigner, 1},
+	}
+
+	for i, test := range bad {
+		if err := New("fake").ReplaceSigner(source, test.oldSigner, test.newSigner, test.weight); err == nil {
+			t.Errorf("%d: ReplaceSigner should fail for %+v", i, test)
+		}
+	}
+}
+
+func TestUpdateAccountOptions(t *testing.T) {
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+
+	domain, weight, high := "qubit.sh", uint32(0), uint32(2)
+	flags := FlagAuthRequired | FlagAuthRevocable
+	spec := AccountOptionsSpec{HomeDomain: &domain, MasterWeight: &weight, HighThreshold: &high, SetFlags: &flags}
+
+	if err := ms.UpdateAccountOptions(source, spec, Opts().WithDryRun()); err != nil {
+		t.Fatalf("UpdateAccountOptions failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("DecodeTx failed: %v", err)
+	}
+
+	if len(txe.Tx.Operations) != 1 {
+		t.Fatalf("want 1 operation, got %d", len(txe.Tx.Operations))
+	}
+
+	op := txe.Tx.Operations[0].Body.MustSetOptionsOp()
+	if op.HomeDomain == nil || string(*op.HomeDomain) != domain {
+		t.Errorf("wrong home domain: %v", op.HomeDomain)
+	}
+
+	// A zero weight is set, not omitted.
+	if op.MasterWeight == nil || *op.MasterWeight != 0 {
+		t.Errorf("wrong master weight: %v", op.MasterWeight)
+	}
+
+	if op.HighThreshold == nil || *op.HighThreshold != 2 {
+		t.Errorf("wrong high threshold: %v", op.HighThreshold)
+	}
+
+	if op.SetFlags == nil || *op.SetFlags != 3 {
+		t.Errorf("wrong flags: %v", op.SetFlags)
+	}
+
+	if op.LowThreshold != nil || op.MedThreshold != nil || op.ClearFlags != nil || op.InflationDest != nil || op.Signer != nil {
+		t.Errorf("unset options should be omitted: %+v", op)
+	}
+
+	long, heavy, unknown := "a-very-long-home-domain-for-qubit.sh", uint32(256), AccountFlags(16)
+	bad := []AccountOptionsSpec{
+		{},
+		{HomeDomain: &long},
+		{MasterWeight: &heavy},
+		{SetFlags: &unknown},
+		{SetFlags: &flags, ClearFlags: &flags},
+		{InflationDest: &domain},
+	}
+
+	for i, spec := range bad {
+		if err := New("fake").UpdateAccountOptions(source, spec); err == nil {
+			t.Errorf("%d: UpdateAccountOptions should fail for %+v", i, spec)
+		}
+	}
+}
+
+func TestPayChecks(t *testing.T) {
+	ms := New("fake")
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	address := "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	muxed, _ := MuxedAddress(address, 42)
+
+	for _, amount := range []string{"0", "0.0000000", "-1"} {
+		if err := ms.PayNative(seed, target, amount); err == nil {
+			t.Errorf("want error for amount %s", amount)
+		}
+	}
+
+	for _, self := range []string{address, seed, muxed} {
+		if err := ms.PayNative(seed, self, "1"); errors.Cause(err) != ErrSelfPayment {
+			t.Errorf("want ErrSelfPayment paying %s, got %v", self, err)
+		}
+	}
+
+	if err := ms.PayNative(target, address, "1", Opts().WithSourceAccount(seed)); errors.Cause(err) != ErrSelfPayment {
+		t.Errorf("want ErrSelfPayment from operation source, got %v", err)
+	}
+
+	if err := ms.PayNative(seed, address, "1", Opts().AllowSelfPay()); err != nil {
+		t.Errorf("self payment with AllowSelfPay failed: %v", err)
+	}
+
+	if err := ms.Pay(seed, address, "1", NativeAsset, Opts().WithAsset(NativeAsset, "2").Through(NativeAsset)); err != nil {
+		t.Errorf("path payment to self failed: %v", err)
+	}
+
+	if err := ms.PayNative(seed, target, "0.0000001"); err != nil {
+		t.Errorf("payment failed: %v", err)
+	}
+}
+
+func TestConcurrentPayments(t *testing.T) {
+	ms := New("fake")
+	done := make(chan error)
+
+	for i := 0; i < 20; i++ {
+		go func() {
+			resp, err := ms.PayWithResponse("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK",
+				"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "1", NativeAsset)
+
+			if err == nil && resp.Result != "fake_ok" {
+				err = fmt.Errorf("unexpected response: %+v", resp)
+			}
+
+			ms.Err()
+			done <- err
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("PayWithResponse failed: %v", err)
+		}
+	}
+
+	ms.Start("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK")
+	if _, err := ms.PayWithResponse("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK",
+		"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "1", NativeAsset); err == nil {
+		t.Errorf("PayWithResponse should fail in a multi-op session")
+	}
+	ms.Submit()
+}
+
+func TestPayWithResponseDryRun(t *testing.T) {
+	ms := New("fake")
+	if ms.Response() != nil {
+		t.Errorf("want no response before any submission")
+	}
+
+	resp, err := ms.PayWithResponse("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK",
+		"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "1", NativeAsset, Opts().WithDryRun())
+
+	if err != nil || resp != nil {
+		t.Errorf("want no response for dry runs, got: %+v, %v", resp, err)
+	}
+
+	if ms.Response() != nil {
+		t.Errorf("want no response after a dry run")
+	}
+}
+
+func TestFund(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("addr") != address {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"detail": "bad address"}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"detail": "createAccountAlreadyExist",
+			"extras": {"result_codes": {"transaction": "tx_failed", "operations": ["op_already_exists"]}}}`)
+	}))
+	defer server.Close()
+
+	oldURL := friendbotURL
+	friendbotURL = server.URL + "/"
+	defer func() { friendbotURL = oldURL }()
+
+	ms := New("test")
+	err := ms.Fund(address)
+	if errors.Cause(err) != ErrAlreadyFunded {
+		t.Errorf("want ErrAlreadyFunded, got: %v", err)
+	}
+
+	if err := ms.Fund("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"); err == nil || errors.Cause(err) == ErrAlreadyFunded {
+		t.Errorf("want friendbot failure, got: %v", err)
+	}
+
+	if err := New("public").Fund(address); err == nil {
+		t.Errorf("Fund should fail on the public network")
+	}
+
+	if err := New("fake").Fund(address); err != nil {
+		t.Errorf("Fund should succeed on the fake network: %v", err)
+	}
+}
+
+func TestFederationRecord(t *testing.T) {
+	account := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	hash := "XDO/Nt6W+lbofx3HWp0ZOfmXBGgrd7V8KZP2neHMuN8="
+
+	tests := []struct {
+		memoType string
+		memo     string
+		want     MemoType
+	}{
+		{"", "", MemoNone},
+		{"text", "deposit 42", MemoText},
+		{"id", "12345", MemoID},
+		{"hash", hash, MemoHash},
+	}
+
+	for _, test := range tests {
+		record, err := newFederationRecord(&fedproto.NameResponse{AccountID: account, MemoType: test.memoType, Memo: fedproto.Memo{Value: test.memo}})
+		if err != nil {
+			t.Fatalf("%s: newFederationRecord failed: %v", test.memoType, err)
+		}
+
+		if record.AccountID != account || record.MemoType != test.want {
+			t.Errorf("%s: unexpected record: %+v", test.memoType, record)
+		}
+
+		opts := Opts().WithFederationMemo(record)
+		if opts.memoType != test.want {
+			t.Errorf("%s: want memo type %d, got %d", test.memoType, test.want, opts.memoType)
+		}
+	}
+
+	record, _ := newFederationRecord(&fedproto.NameResponse{AccountID: account, MemoType: "id", Memo: fedproto.Memo{Value: "12345"}})
+	if opts := Opts().WithFederationMemo(record); opts.memoID != 12345 {
+		t.Errorf("want memo ID 12345, got %d", opts.memoID)
+	}
+
+	if opts := Opts().WithMemoText("keep").WithFederationMemo(nil); opts.memoType != MemoText || opts.memoText != "keep" {
+		t.Errorf("nil record should leave the memo unchanged, got type %d", opts.memoType)
+	}
+
+	for _, bad := range []fedproto.NameResponse{{MemoType: "id", Memo: fedproto.Memo{Value: "abc"}}, {MemoType: "hash", Memo: fedproto.Memo{Value: "c2hvcnQ="}}, {MemoType: "return"}} {
+		if _, err := newFederationRecord(&bad); err == nil {
+			t.Errorf("want error for bad memo: %+v", bad)
+		}
+	}
+
+	if _, err := New("fake").ResolveFull("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"); err == nil {
+		t.Errorf("want error for non-federated address")
+	}
+}
+
+// redirectTransport sends all requests to target over plain HTTP, keeping the original Host.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestReverseResolve(t *testing.T) {
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/.well-known/stellar.toml" && r.Host == "qubit.sh":
+			fmt.Fprint(w, `FEDERATION_SERVER="https://fed.qubit.sh/federation"`)
+		case r.URL.Path == "/.well-known/stellar.toml":
+			fmt.Fprint(w, `VERSION="2.0.0"`)
+		case r.URL.Path == "/federation" && r.URL.Query().Get("type") == "id" && r.URL.Query().Get("q") == bob:
+			fmt.Fprint(w, `{"stellar_address": "bob*qubit.sh", "account_id": "`+bob+`"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"}).WithHTTPClient(&http.Client{Transport: &redirectTransport{target}})
+
+	name, err := ms.ReverseResolve(bob, "qubit.sh")
+	if err != nil || name != "bob*qubit.sh" {
+		t.Errorf("want bob*qubit.sh, got %q (%v)", name, ErrorString(err))
+	}
+
+	if _, err := ms.ReverseResolve("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "qubit.sh"); errors.Cause(err) != ErrAccountNotRegistered {
+		t.Errorf("want ErrAccountNotRegistered, got %v", err)
+	}
+
+	if _, err := ms.ReverseResolve(bob, "example.com"); errors.Cause(err) != ErrNoFederationServer {
+		t.Errorf("want ErrNoFederationServer, got %v", err)
+	}
+
+	if _, err := ms.ReverseResolve("BAD", "qubit.sh"); err == nil {
+		t.Errorf("want error for bad address")
+	}
+}
+
+func TestNetworkPassphrase(t *testing.T) {
+	tests := []struct {
+		ms   *MicroStellar
+		want string
+	}{
+		{New("public"), network.PublicNetworkPassphrase},
+		{New("test"), network.TestNetworkPassphrase},
+		{New("custom", Params{"url": "https://foo.bar", "passphrase": "baz"}), "baz"},
+		{New("public", Params{"passphrase": "My Private Network"}), "My Private Network"},
+		{New("test", Params{"passphrase": ""}), network.TestNetworkPassphrase},
+		{New("custom", Params{"url": "https://foo.bar", "passphrase": "baz"}).OnNetwork("public"), network.PublicNetworkPassphrase},
+	}
+
+	for i, test := range tests {
+		if got := test.ms.NetworkPassphrase(); got != test.want {
+			t.Errorf("%d: want passphrase %q, got %q", i, test.want, got)
+		}
+	}
+
+	// Transactions are signed for the overridden passphrase.
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	ms := New("public", Params{"passphrase": "My Private Network"})
+	tx := ms.getTx()
+	tx.SetOptions(Opts().WithSequence(1))
+	tx.Build(sourceAccount(source), build.Payment(build.Destination{AddressOrSeed: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"}, build.NativeAmount{Amount: "1"}))
+
+	if tx.builder.NetworkPassphrase != "My Private Network" {
+		t.Errorf("want transaction built for the private network, got %q", tx.builder.NetworkPassphrase)
+	}
+}
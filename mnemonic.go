@@ -0,0 +1,170 @@
+package microstellar
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+)
+
+// SEP-5 derivation parameters: m/44'/148'/index'
+const (
+	bip39Iterations   = 2048
+	bip44Purpose      = 44
+	stellarCoinType   = 148
+	hardenedKeyOffset = 0x80000000
+)
+
+// pbkdf2SHA512 derives a 64-byte key from password and salt using PBKDF2 with HMAC-SHA512.
+func pbkdf2SHA512(password []byte, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha512.New, password)
+
+	// The key is exactly one SHA-512 block long, so there's only one PBKDF2 block to compute.
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+
+	return key
+}
+
+// mnemonicEntropy validates the BIP-39 mnemonic and returns the entropy it encodes.
+func mnemonicEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errors.Errorf("invalid mnemonic: want 12, 15, 18, 21, or 24 words, got %d", len(words))
+	}
+
+	index := make(map[string]int64, len(bip39English))
+	for i, w := range bip39English {
+		index[w] = int64(i)
+	}
+
+	// Each word encodes 11 bits; the last len(words)/3 bits are the checksum.
+	bits := new(big.Int)
+	for _, w := range words {
+		i, ok := index[w]
+		if !ok {
+			return nil, errors.Errorf("invalid mnemonic: unknown word: %s", w)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(i))
+	}
+
+	checksumBits := uint(len(words) / 3)
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1))
+	bits.Rsh(bits, checksumBits)
+
+	entropy := make([]byte, len(words)*4/3)
+	b := bits.Bytes()
+	copy(entropy[len(entropy)-len(b):], b)
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum.Int64() {
+		return nil, errors.Errorf("invalid mnemonic: bad checksum")
+	}
+
+	return entropy, nil
+}
+
+// slip10Derive derives the ed25519 private key at the given hardened path from seed, as
+// specified by SLIP-0010.
+func slip10Derive(seed []byte, path ...uint32) [32]byte {
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key, chainCode := sum[:32], sum[32:]
+	for _, i := range path {
+		data := make([]byte, 37)
+		copy(data[1:33], key)
+		binary.BigEndian.PutUint32(data[33:], i+hardenedKeyOffset)
+
+		mac = hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	var rawSeed [32]byte
+	copy(rawSeed[:], key)
+	return rawSeed
+}
+
+// GenerateMnemonic returns a new random BIP-39 mnemonic with bits of entropy. Use 128 bits
+// for a 12-word phrase, or 256 bits for a 24-word phrase (bits must be a multiple of 32 between
+// 128 and 256.) Use KeyPairFromMnemonic to derive keys from it.
+func (ms *MicroStellar) GenerateMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ms.errorf("invalid entropy size: want 128, 160, 192, 224, or 256 bits, got %d", bits)
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", ms.wrapf(err, "can't generate mnemonic")
+	}
+
+	hash := sha256.Sum256(entropy)
+	checksumBits := uint(bits / 32)
+
+	// Append the checksum to the entropy, and split the result into 11-bit word indexes.
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, checksumBits)
+	value.Or(value, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	words := make([]string, (bits+int(checksumBits))/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = bip39English[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, 11)
+	}
+
+	return strings.Join(words, " "), ms.success()
+}
+
+// KeyPairFromMnemonic derives the key pair at index from the BIP-39 mnemonic and (optional)
+// passphrase, using the SEP-5 derivation path m/44'/148'/index'. The same mnemonic, passphrase,
+// and index always derive the same key pair, so users can back up all their accounts with
+// just the phrase.
+//
+//   pair, err := ms.KeyPairFromMnemonic("illness spike retreat truth genius clock brain pass fit cave bargain toe", "", 0)
+//
+// Returns an error if the phrase has an unknown word or a bad checksum. Passphrases are used
+// as-is, so normalize non-ASCII passphrases to Unicode NFKD first.
+func (ms *MicroStellar) KeyPairFromMnemonic(mnemonic string, passphrase string, index uint32) (*KeyPair, error) {
+	if _, err := mnemonicEntropy(mnemonic); err != nil {
+		return nil, ms.err(err)
+	}
+
+	if index >= hardenedKeyOffset {
+		return nil, ms.errorf("invalid index: %d", index)
+	}
+
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	seed := pbkdf2SHA512([]byte(normalized), []byte("mnemonic"+passphrase), bip39Iterations)
+
+	pair, err := keypair.FromRawSeed(slip10Derive(seed, bip44Purpose, stellarCoinType, index))
+	if err != nil {
+		return nil, ms.wrapf(err, "can't derive key pair")
+	}
+
+	ms.debugf("KeyPairFromMnemonic", "derived address: %s, seed: <redacted>", pair.Address())
+	return &KeyPair{pair.Seed(), pair.Address()}, ms.success()
+}
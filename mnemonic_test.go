@@ -0,0 +1,83 @@
+package microstellar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyPairFromMnemonic(t *testing.T) {
+	// SEP-5 test vector 1.
+	mnemonic := "illness spike retreat truth genius clock brain pass fit cave bargain toe"
+	want := []KeyPair{
+		{"SBGWSG6BTNCKCOB3DIFBGCVMUPQFYPA2G4O34RMTB343OYPXU5DJDVMN", "GDRXE2BQUC3AZNPVFSCEZ76NJ3WWL25FYFK6RGZGIEKWE4SOOHSUJUJ6"},
+		{"SCEPFFWGAG5P2VX5DHIYK3XEMZYLTYWIPWYEKXFHSK25RVMIUNJ7CTIS", "GBAW5XGWORWVFE2XTJYDTLDHXTY2Q2MO73HYCGB3XMFMQ562Q2W2GJQX"},
+	}
+
+	ms := New("fake")
+	for i, w := range want {
+		pair, err := ms.KeyPairFromMnemonic(mnemonic, "", uint32(i))
+		if err != nil {
+			t.Fatalf("KeyPairFromMnemonic(%d) failed: %v", i, err)
+		}
+
+		if *pair != w {
+			t.Errorf("KeyPairFromMnemonic(%d): want %+v, got %+v", i, w, *pair)
+		}
+	}
+
+	withPassphrase, err := ms.KeyPairFromMnemonic(mnemonic, "secret", 0)
+	if err != nil || withPassphrase.Address == want[0].Address {
+		t.Errorf("passphrase should change the derived key: %+v, %v", withPassphrase, err)
+	}
+
+	invalid := []string{
+		"",
+		"illness spike retreat truth genius clock brain pass fit cave bargain",
+		"illness spike retreat truth genius clock brain pass fit cave bargain tea",
+		"illness spike retreat truth genius clock brain pass fit cave bargain bitcoin",
+	}
+
+	for _, m := range invalid {
+		if _, err := ms.KeyPairFromMnemonic(m, "", 0); err == nil {
+			t.Errorf("KeyPairFromMnemonic should fail for %q", m)
+		}
+	}
+}
+
+func TestGenerateMnemonic(t *testing.T) {
+	ms := New("fake")
+
+	for bits, words := range map[int]int{128: 12, 160: 15, 192: 18, 224: 21, 256: 24} {
+		mnemonic, err := ms.GenerateMnemonic(bits)
+		if err != nil {
+			t.Fatalf("GenerateMnemonic(%d) failed: %v", bits, err)
+		}
+
+		if n := len(strings.Fields(mnemonic)); n != words {
+			t.Errorf("GenerateMnemonic(%d): want %d words, got %d", bits, words, n)
+		}
+
+		if _, err := ms.KeyPairFromMnemonic(mnemonic, "", 0); err != nil {
+			t.Errorf("generated mnemonic is invalid: %q: %v", mnemonic, err)
+		}
+	}
+
+	if _, err := ms.GenerateMnemonic(100); err == nil {
+		t.Errorf("GenerateMnemonic should fail for invalid entropy sizes")
+	}
+}
+
+func TestMnemonicEntropy(t *testing.T) {
+	entropy, err := mnemonicEntropy("legal winner thank year wave sausage worth useful legal winner thank yellow")
+	if err != nil {
+		t.Fatalf("mnemonicEntropy failed: %v", err)
+	}
+
+	if strings.Repeat("\x7f", 16) != string(entropy) {
+		t.Errorf("wrong entropy: %x", entropy)
+	}
+
+	if _, err := mnemonicEntropy("legal winner thank year wave sausage worth useful legal winner thank year"); err == nil {
+		t.Errorf("mnemonicEntropy should fail on bad checksum")
+	}
+}
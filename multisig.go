@@ -0,0 +1,230 @@
+package microstellar
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// Signer types, as reported by Horizon in Signer.Type.
+const (
+	SignerTypeEd25519   = "ed25519_public_key" // an account's key (G...)
+	SignerTypePreAuthTx = "preauth_tx"         // a pre-authorized transaction hash (T...)
+	SignerTypeHashX     = "sha256_hash"        // the SHA-256 hash of a secret (X...)
+)
+
+// maxSignatures is the most signatures a transaction envelope can have.
+const maxSignatures = 20
+
+// requiredThreshold returns the account threshold that op needs to be authorized.
+func requiredThreshold(op xdr.Operation, thresholds Thresholds) byte {
+	switch op.Body.Type {
+	case xdr.OperationTypeAllowTrust, xdr.OperationTypeBumpSequence, xdr.OperationTypeInflation:
+		return thresholds.Low
+	case xdr.OperationTypeAccountMerge:
+		return thresholds.High
+	case xdr.OperationTypeSetOptions:
+		so := op.Body.MustSetOptionsOp()
+		if so.MasterWeight != nil || so.LowThreshold != nil || so.MedThreshold != nil ||
+			so.HighThreshold != nil || so.Signer != nil {
+			return thresholds.High
+		}
+	}
+
+	return thresholds.Medium
+}
+
+// signerWeight returns the weight of the account signer that produced sig over hash, or
+// 0 if sig wasn't produced by signer.
+func signerWeight(signer Signer, hash [32]byte, sig xdr.DecoratedSignature) uint32 {
+	switch signer.Type {
+	case SignerTypeEd25519, "":
+		key := signer.Key
+		if key == "" {
+			// Older Horizons only set the public key.
+			key = signer.PublicKey
+		}
+
+		kp, err := keypair.Parse(key)
+		if err != nil || kp.Hint() != sig.Hint {
+			return 0
+		}
+
+		if kp.Verify(hash[:], sig.Signature) != nil {
+			return 0
+		}
+	case SignerTypeHashX:
+		// The "signature" is the preimage of the hash.
+		key, err := strkey.Decode(strkey.VersionByteHashX, signer.Key)
+		preimageHash := sha256.Sum256(sig.Signature)
+		if err != nil || !bytes.Equal(key, preimageHash[:]) || !bytes.Equal(key[28:], sig.Hint[:]) {
+			return 0
+		}
+	default:
+		return 0
+	}
+
+	return uint32(signer.Weight)
+}
+
+// VerifySignatures checks the signatures on the base64-encoded transaction envelope b64Tx against
+// the signers of account (as returned by LoadAccount), and returns whether their total weight meets
+// the threshold required by the account's operations, along with the total weight. Use this to
+// tell users how much more signing weight a multi-sig transaction needs before submitting it.
+//
+//   account, _ := ms.LoadAccount("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E")
+//   ok, weight, err := ms.VerifySignatures(payload, account)
+//   if !ok {
+//       log.Printf("not enough signatures: have weight %d, need %d", weight, account.Thresholds.Medium)
+//   }
+//
+// Only operations sourced from the account are considered. The operation with the highest
+// threshold determines the required weight, which is at least 1. Pre-authorized transaction
+// signers count if the transaction's hash matches. Signatures are checked against the
+// client's network passphrase.
+func (ms *MicroStellar) VerifySignatures(b64Tx string, account *Account) (bool, uint32, error) {
+	if err := checkEnvelopeType(b64Tx); err != nil {
+		return false, 0, ms.wrapf(err, "can't verify signatures")
+	}
+
+	txe, err := DecodeTx(b64Tx)
+	if err != nil {
+		return false, 0, ms.wrapf(err, "can't verify signatures")
+	}
+
+	passphrase := ms.NetworkPassphrase()
+	hash, err := network.HashTransaction(&txe.Tx, passphrase)
+	if err != nil {
+		return false, 0, ms.wrapf(err, "can't hash transaction")
+	}
+
+	// Find the highest threshold required by the account. The fee and sequence number of the
+	// transaction source need the low threshold.
+	var required byte
+	sourcesAccount := false
+	if txe.Tx.SourceAccount.Address() == account.Address {
+		required = account.Thresholds.Low
+		sourcesAccount = true
+	}
+
+	for _, op := range txe.Tx.Operations {
+		if op.SourceAccount != nil && op.SourceAccount.Address() != account.Address {
+			continue
+		}
+
+		if op.SourceAccount == nil && !sourcesAccount {
+			continue
+		}
+
+		if t := requiredThreshold(op, account.Thresholds); t > required {
+			required = t
+		}
+	}
+
+	if required == 0 {
+		required = 1
+	}
+
+	// Add up the weights of the signers, counting each at most once.
+	var weight uint32
+	for _, signer := range account.Signers {
+		if signer.Type == SignerTypePreAuthTx {
+			key, err := strkey.Decode(strkey.VersionByteHashTx, signer.Key)
+			if err == nil && bytes.Equal(key, hash[:]) {
+				weight += uint32(signer.Weight)
+			}
+			continue
+		}
+
+		for _, sig := range txe.Signatures {
+			if w := signerWeight(signer, hash, sig); w > 0 {
+				weight += w
+				break
+			}
+		}
+	}
+
+	ms.debugf("VerifySignatures", "signature weight: %d, required: %d", weight, required)
+	return weight >= uint32(required), weight, ms.success()
+}
+
+// SignPayload adds signatures from seeds to the base64-encoded transaction envelope b64Tx, and
+// returns the partially (or fully) signed envelope. Unlike SignTransaction, it skips seeds that
+// already signed the envelope, so each co-signer can run it on the envelope they were forwarded
+// without duplicating signatures.
+//
+//   ms.Start(sourceAddress)
+//   ms.Pay(sourceAddress, targetAddress, "10", USD)
+//   payload, err := ms.Payload()
+//
+//   // Each co-signer adds their signature and forwards the envelope.
+//   payload, err = ms.SignPayload(payload, "cosigner1_seed")
+//   payload, err = ms.SignPayload(payload, "cosigner2_seed")
+//
+//   // The final party submits it.
+//   account, err := ms.LoadAccount(sourceAddress)
+//   if ok, _, _ := ms.VerifySignatures(payload, account); ok {
+//       _, err = ms.SubmitTransaction(payload)
+//   }
+//
+// Signatures are made for the client's network. Returns an error if the envelope would have more
+// than 20 signatures.
+func (ms *MicroStellar) SignPayload(b64Tx string, seeds ...string) (string, error) {
+	if err := checkEnvelopeType(b64Tx); err != nil {
+		return "", ms.wrapf(err, "can't sign payload")
+	}
+
+	txe, err := DecodeTx(b64Tx)
+	if err != nil {
+		return "", ms.wrapf(err, "can't sign payload")
+	}
+
+	hash, err := network.HashTransaction(&txe.Tx, ms.NetworkPassphrase())
+	if err != nil {
+		return "", ms.wrapf(err, "can't hash transaction")
+	}
+
+	for _, seed := range seeds {
+		if err := ValidSeed(seed); err != nil {
+			return "", ms.errorf("can't sign payload: invalid seed")
+		}
+
+		kp, _ := keypair.Parse(seed)
+		signer := Signer{Key: kp.Address(), Type: SignerTypeEd25519, Weight: 1}
+
+		signed := false
+		for _, sig := range txe.Signatures {
+			if signerWeight(signer, hash, sig) > 0 {
+				signed = true
+				break
+			}
+		}
+
+		if signed {
+			ms.debugf("SignPayload", "%s already signed, skipping", kp.Address())
+			continue
+		}
+
+		if len(txe.Signatures) >= maxSignatures {
+			return "", ms.errorf("can't sign payload: envelope already has %d signatures", maxSignatures)
+		}
+
+		sig, err := kp.SignDecorated(hash[:])
+		if err != nil {
+			return "", ms.wrapf(err, "can't sign payload")
+		}
+
+		txe.Signatures = append(txe.Signatures, sig)
+	}
+
+	signed, err := xdr.MarshalBase64(txe)
+	if err != nil {
+		return "", ms.wrapf(err, "could not marshal transaction")
+	}
+
+	return signed, ms.success()
+}
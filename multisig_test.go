@@ -0,0 +1,173 @@
+package microstellar
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+func TestVerifySignatures(t *testing.T) {
+	source, _ := keypair.Random()
+	cosigner, _ := keypair.Random()
+	stranger, _ := keypair.Random()
+	target := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	account := newAccount()
+	account.Address = source.Address()
+	account.Thresholds = Thresholds{Low: 1, Medium: 2, High: 3}
+	account.Signers = []Signer{
+		{Key: source.Address(), Weight: 1, Type: "ed25519_public_key"},
+		{Key: cosigner.Address(), Weight: 1, Type: "ed25519_public_key"},
+	}
+
+	sign := func(tx *build.TransactionBuilder, seeds ...string) string {
+		txe, err := tx.Sign(seeds...)
+		if err != nil {
+			t.Fatalf("can't sign: %v", err)
+		}
+		b64, _ := txe.Base64()
+		return b64
+	}
+
+	payment, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: source.Address()},
+		build.Sequence{Sequence: 101},
+		build.TestNetwork,
+		build.Payment(build.Destination{AddressOrSeed: target}, build.NativeAmount{Amount: "10"}),
+	)
+	if err != nil {
+		t.Fatalf("can't build transaction: %v", err)
+	}
+
+	ms := New("test")
+	tests := []struct {
+		seeds  []string
+		ok     bool
+		weight uint32
+	}{
+		{[]string{source.Seed(), cosigner.Seed()}, true, 2},
+		{[]string{source.Seed()}, false, 1},
+		{[]string{source.Seed(), stranger.Seed()}, false, 1},
+		{[]string{source.Seed(), source.Seed()}, false, 1},
+	}
+
+	for i, test := range tests {
+		ok, weight, err := ms.VerifySignatures(sign(payment, test.seeds...), account)
+		if err != nil || ok != test.ok || weight != test.weight {
+			t.Errorf("test %d: want %v/%d, got %v/%d: %v", i, test.ok, test.weight, ok, weight, err)
+		}
+	}
+
+	// Adding a signer needs the high threshold.
+	addSigner, _ := build.Transaction(
+		build.SourceAccount{AddressOrSeed: source.Address()},
+		build.Sequence{Sequence: 101},
+		build.TestNetwork,
+		build.SetOptions(build.AddSigner(stranger.Address(), 1)),
+	)
+
+	if ok, _, _ := ms.VerifySignatures(sign(addSigner, source.Seed(), cosigner.Seed()), account); ok {
+		t.Errorf("adding a signer should need the high threshold")
+	}
+
+	// Hash(x) and pre-authorized transaction signers.
+	preimage := []byte("open sesame")
+	hashX := sha256.Sum256(preimage)
+	hashXKey, _ := strkey.Encode(strkey.VersionByteHashX, hashX[:])
+	txHash, _ := network.HashTransaction(payment.TX, network.TestNetworkPassphrase)
+	preAuthKey, _ := strkey.Encode(strkey.VersionByteHashTx, txHash[:])
+
+	account.Signers = append(account.Signers,
+		Signer{Key: hashXKey, Weight: 1, Type: "sha256_hash"},
+		Signer{Key: preAuthKey, Weight: 5, Type: "preauth_tx"})
+
+	txe, _ := payment.Sign(source.Seed())
+	var hint xdr.SignatureHint
+	copy(hint[:], hashX[28:])
+	txe.E.Signatures = append(txe.E.Signatures, xdr.DecoratedSignature{Hint: hint, Signature: preimage})
+	b64, _ := txe.Base64()
+
+	if ok, weight, err := ms.VerifySignatures(b64, account); !ok || weight != 7 || err != nil {
+		t.Errorf("want hash(x) and pre-auth signers to count, got %v/%d: %v", ok, weight, err)
+	}
+
+	// Signatures for a different network don't count.
+	account.Signers = account.Signers[:2]
+	if ok, weight, _ := New("public").VerifySignatures(sign(payment, source.Seed(), cosigner.Seed()), account); ok || weight != 0 {
+		t.Errorf("signatures should not verify on another network, got %v/%d", ok, weight)
+	}
+
+	if _, _, err := ms.VerifySignatures("bad transaction", account); err == nil {
+		t.Errorf("VerifySignatures should fail on bad transactions")
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	source, _ := keypair.Random()
+	cosigner, _ := keypair.Random()
+
+	account := newAccount()
+	account.Address = source.Address()
+	account.Thresholds = Thresholds{Low: 1, Medium: 2, High: 3}
+	account.Signers = []Signer{
+		{Key: source.Address(), Weight: 1, Type: "ed25519_public_key"},
+		{Key: cosigner.Address(), Weight: 1, Type: "ed25519_public_key"},
+	}
+
+	payment, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: source.Address()},
+		build.Sequence{Sequence: 101},
+		build.TestNetwork,
+		build.Payment(build.Destination{AddressOrSeed: "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"}, build.NativeAmount{Amount: "10"}),
+	)
+	if err != nil {
+		t.Fatalf("can't build transaction: %v", err)
+	}
+
+	var txe build.TransactionEnvelopeBuilder
+	txe.Mutate(payment)
+	unsigned, _ := txe.Base64()
+
+	ms := New("test")
+	payload, err := ms.SignPayload(unsigned, source.Seed())
+	if err != nil {
+		t.Fatalf("SignPayload: %v", err)
+	}
+
+	if ok, weight, _ := ms.VerifySignatures(payload, account); ok || weight != 1 {
+		t.Errorf("want weight 1 after first signer, got %v (ok: %v)", weight, ok)
+	}
+
+	// The co-signer's envelope already has the source's signature.
+	payload, err = ms.SignPayload(payload, source.Seed(), cosigner.Seed())
+	if err != nil {
+		t.Fatalf("SignPayload: %v", err)
+	}
+
+	if decoded, _ := DecodeTx(payload); len(decoded.Signatures) != 2 {
+		t.Errorf("want 2 signatures, got %d", len(decoded.Signatures))
+	}
+
+	if ok, weight, _ := ms.VerifySignatures(payload, account); !ok || weight != 2 {
+		t.Errorf("want weight 2 after co-signer, got %v (ok: %v)", weight, ok)
+	}
+
+	seeds := []string{}
+	for i := 0; i < maxSignatures-1; i++ {
+		kp, _ := keypair.Random()
+		seeds = append(seeds, kp.Seed())
+	}
+
+	if _, err := ms.SignPayload(payload, seeds...); err == nil {
+		t.Errorf("want error for more than %d signatures", maxSignatures)
+	}
+
+	if _, err := ms.SignPayload(unsigned, source.Address()); err == nil {
+		t.Errorf("want error for invalid seed")
+	}
+}
@@ -0,0 +1,147 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/crc16"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// The vendored strkey package predates multiplexed accounts (SEP-23), so M-addresses are
+// encoded here.
+
+// versionByteMuxedAccount is the strkey version byte for muxed accounts ('M...')
+const versionByteMuxedAccount = 12 << 3
+
+// keyTypeMuxedEd25519 is the XDR CryptoKeyType of a muxed account.
+const keyTypeMuxedEd25519 = 0x100
+
+// muxedEncoding is the unpadded base32 encoding used by M-addresses.
+var muxedEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MuxedAddress returns the multiplexed (M...) address for the account at baseAddress with ID id
+// (SEP-23). Payments to the muxed address go to the base account, and carry id, so custodial
+// services can tell their users apart without memos.
+//
+//   m, err := microstellar.MuxedAddress("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", 42)
+func MuxedAddress(baseAddress string, id uint64) (string, error) {
+	key, err := strkey.Decode(strkey.VersionByteAccountID, baseAddress)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid address: %s", baseAddress)
+	}
+
+	var raw bytes.Buffer
+	raw.WriteByte(versionByteMuxedAccount)
+	raw.Write(key)
+	binary.Write(&raw, binary.BigEndian, id)
+	raw.Write(crc16.Checksum(raw.Bytes()))
+
+	return muxedEncoding.EncodeToString(raw.Bytes()), nil
+}
+
+// ParseMuxedAddress returns the base (G...) address and the ID of the multiplexed account at m.
+//
+//   base, id, err := microstellar.ParseMuxedAddress(m)
+func ParseMuxedAddress(m string) (string, uint64, error) {
+	raw, err := muxedEncoding.DecodeString(m)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid muxed address: %s", m)
+	}
+
+	// version byte, ed25519 key, ID, checksum
+	if len(raw) != 1+32+8+2 || raw[0] != versionByteMuxedAccount {
+		return "", 0, errors.Errorf("invalid muxed address: %s", m)
+	}
+
+	if err := crc16.Validate(raw[:len(raw)-2], raw[len(raw)-2:]); err != nil {
+		return "", 0, errors.Wrapf(err, "invalid muxed address: %s", m)
+	}
+
+	// Reject non-canonical encodings, whose unused trailing bits are set.
+	if muxedEncoding.EncodeToString(raw) != m {
+		return "", 0, errors.Errorf("invalid muxed address: %s", m)
+	}
+
+	base, err := strkey.Encode(strkey.VersionByteAccountID, raw[1:33])
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid muxed address: %s", m)
+	}
+
+	return base, binary.BigEndian.Uint64(raw[33:41]), nil
+}
+
+// ValidMuxedAddress returns an error if m is not a valid multiplexed (M...) address.
+func ValidMuxedAddress(m string) error {
+	_, _, err := ParseMuxedAddress(m)
+	return err
+}
+
+// isMuxedAddress returns true if address looks like a multiplexed address. Use ValidMuxedAddress
+// to validate it.
+func isMuxedAddress(address string) bool {
+	return strings.HasPrefix(address, "M")
+}
+
+// muxedAccount encodes the XDR MuxedAccount for address, which can be a G... or M... address.
+func (w *xdrWriter) muxedAccount(address string) {
+	if !isMuxedAddress(address) {
+		// KEY_TYPE_ED25519 muxed accounts encode exactly like AccountIds.
+		w.account(address)
+		return
+	}
+
+	base, id, err := ParseMuxedAddress(address)
+	if err != nil {
+		w.err = err
+		return
+	}
+
+	key, _ := strkey.Decode(strkey.VersionByteAccountID, base)
+	w.uint32(keyTypeMuxedEd25519)
+	binary.Write(&w.buf, binary.BigEndian, id)
+	w.raw(key)
+}
+
+// payMuxed pays the multiplexed account at targetAddress. The vendored XDR package can't encode
+// muxed destinations, so the payment is a raw operation.
+func (ms *MicroStellar) payMuxed(sourceAddressOrSeed string, targetAddress string, amount string, asset *Asset, options ...*Options) (*Tx, error) {
+	if err := ValidMuxedAddress(targetAddress); err != nil {
+		return nil, ms.wrapf(err, "can't pay")
+	}
+
+	if err := ValidAmount(amount); err != nil {
+		return nil, ms.wrapf(err, "can't pay")
+	}
+
+	if opts := mergeOptions(options); opts.sendAsset != nil {
+		return nil, ms.errorf("can't pay: path payments to muxed addresses are not supported")
+	}
+
+	amountInt, _ := ParseAmount(amount)
+
+	w := &xdrWriter{}
+	w.uint32(uint32(xdr.OperationTypePayment))
+	w.muxedAccount(targetAddress)
+	w.asset(asset)
+	w.int64(amountInt)
+
+	body, err := w.bytes()
+	if err != nil {
+		return nil, ms.wrapf(err, "can't pay")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.addFakeOp(sourceAddressOrSeed, fakePayment(targetAddress, amount, asset))
+	tx.Build(sourceAccount(sourceAddressOrSeed), tx.rawOp("", body))
+	return tx, ms.signAndSubmit(tx, sourceAddressOrSeed)
+}
@@ -0,0 +1,95 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+func TestMuxedAddress(t *testing.T) {
+	// Test vectors from SEP-23.
+	base := "GA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJVSGZ"
+	tests := []struct {
+		id   uint64
+		want string
+	}{
+		{0, "MA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJUAAAAAAAAAAAACJUQ"},
+		{9223372036854775808, "MA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJVAAAAAAAAAAAAAJLK"},
+	}
+
+	for _, test := range tests {
+		m, err := MuxedAddress(base, test.id)
+		if err != nil || m != test.want {
+			t.Errorf("MuxedAddress(%d): want %s, got %s: %v", test.id, test.want, m, err)
+		}
+
+		gotBase, gotID, err := ParseMuxedAddress(test.want)
+		if err != nil || gotBase != base || gotID != test.id {
+			t.Errorf("ParseMuxedAddress(%s): got %s, %d: %v", test.want, gotBase, gotID, err)
+		}
+	}
+
+	invalid := []string{
+		base,
+		"MA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJVAAAAAAAAAAAAAJLL", // bad checksum
+		"MA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJVAAAAAAAAAAAAAJLKA",
+		"MA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJVAAAAAAAAAAAAAJ",
+		"",
+	}
+
+	for _, m := range invalid {
+		if err := ValidMuxedAddress(m); err == nil {
+			t.Errorf("want error for %q", m)
+		}
+	}
+
+	if _, err := MuxedAddress("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK", 1); err == nil {
+		t.Errorf("want error for seed")
+	}
+}
+
+func TestPayMuxed(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	base := "GA7QYNF7SOWQ3GLR2BGMZEHXAVIRZA4KVWLTJJFC7MGXUA74P7UJVSGZ"
+	m, _ := MuxedAddress(base, 1234)
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.Pay(source, m, "10", NativeAsset); err != nil {
+		t.Fatalf("Pay failed: %v", ErrorString(err))
+	}
+
+	w := &xdrWriter{}
+	w.account(base)
+	key, _ := w.bytes()
+
+	var want bytes.Buffer
+	binary.Write(&want, binary.BigEndian, uint32(1))     // PAYMENT
+	binary.Write(&want, binary.BigEndian, uint32(0x100)) // KEY_TYPE_MUXED_ED25519
+	binary.Write(&want, binary.BigEndian, uint64(1234))
+	want.Write(key[4:])
+	binary.Write(&want, binary.BigEndian, uint32(0)) // native
+	binary.Write(&want, binary.BigEndian, int64(100000000))
+
+	payload, _ := ms.LastPayload()
+	envelope, _ := base64.StdEncoding.DecodeString(payload)
+	if !bytes.Contains(envelope, want.Bytes()) || submissions != 1 {
+		t.Errorf("operation %x not found in %x (submissions: %d)", want.Bytes(), envelope, submissions)
+	}
+
+	if _, err := ms.LoadAccount(m); err != nil {
+		t.Errorf("LoadAccount failed for muxed address: %v", err)
+	}
+
+	if err := ms.Pay(source, m, "10", NativeAsset, Opts().WithAsset(NativeAsset, "10")); err == nil {
+		t.Errorf("want error for path payment to muxed address")
+	}
+
+	if err := ms.Pay(source, m[:len(m)-1]+"A", "10", NativeAsset); err == nil {
+		t.Errorf("want error for bad muxed address")
+	}
+}
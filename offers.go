@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/stellar/go/build"
 	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/price"
 )
 
 // OfferType tells ManagedOffer what operation to perform
@@ -51,6 +55,29 @@ func newOfferFromHorizon(offer horizon.Offer) Offer {
 	return Offer(offer)
 }
 
+// OfferID returns the ID of the offer as a string, for use with UpdateOffer and DeleteOffer.
+func (offer Offer) OfferID() string {
+	return strconv.FormatInt(offer.ID, 10)
+}
+
+// SellingAsset returns the asset being sold in the offer.
+func (offer Offer) SellingAsset() *Asset {
+	if offer.Selling.Type == string(NativeType) {
+		return NativeAsset
+	}
+
+	return NewAsset(offer.Selling.Code, offer.Selling.Issuer, AssetType(offer.Selling.Type))
+}
+
+// BuyingAsset returns the asset being bought in the offer.
+func (offer Offer) BuyingAsset() *Asset {
+	if offer.Buying.Type == string(NativeType) {
+		return NativeAsset
+	}
+
+	return NewAsset(offer.Buying.Code, offer.Buying.Issuer, AssetType(offer.Buying.Type))
+}
+
 // horizonAsset is an asset returned by the horizon server.
 type horizonAsset struct {
 	Code   string `json:"asset_code"`
@@ -98,7 +125,13 @@ type Path struct {
 	Hops         []*Asset
 }
 
-// LoadOffers returns all existing trade offers made by address.
+// LoadOffers returns all existing trade offers made by address. Use Options.WithCursor and
+// Options.WithLimit to page through the results. Returns an empty slice if the account has no
+// offers.
+//
+// To cancel an offer returned by LoadOffers:
+//
+//   ms.DeleteOffer("seed", offer.OfferID(), offer.SellingAsset(), offer.BuyingAsset(), offer.Price)
 func (ms *MicroStellar) LoadOffers(address string, options ...*Options) ([]Offer, error) {
 	if err := ValidAddress(address); err != nil {
 		return nil, ms.errorf("invalid address: %s", address)
@@ -121,7 +154,7 @@ func (ms *MicroStellar) LoadOffers(address string, options ...*Options) ([]Offer
 		params = append(params, horizon.Order("asc"))
 	}
 
-	debugf("LoadOffers", "loading offers for %s, with params +%v", address, params)
+	ms.debugf("LoadOffers", "loading offers for %s, with params +%v", address, params)
 	if ms.fake {
 		return []Offer{}, ms.success()
 	}
@@ -135,11 +168,63 @@ func (ms *MicroStellar) LoadOffers(address string, options ...*Options) ([]Offer
 
 	results := make([]Offer, len(horizonOffers.Embedded.Records))
 	for i, o := range horizonOffers.Embedded.Records {
-		results[i] = Offer(o)
+		results[i] = newOfferFromHorizon(o)
 	}
 	return results, ms.success()
 }
 
+// offersPageSize is the number of offers requested per page when paging through all
+// of an account's offers.
+const offersPageSize = 200
+
+// LoadOffersByPair returns all existing trade offers made by address, grouped by trading pair. The
+// map keys are of the form "SELLING/BUYING", where SELLING and BUYING are the canonical Asset.String()
+// representations of the assets, e.g., "USD:GAIUIQNM.../native".
+//
+// Unlike LoadOffers, this pages through all the account's offers.
+func (ms *MicroStellar) LoadOffersByPair(address string) (map[string][]Offer, error) {
+	pairs := map[string][]Offer{}
+	opts := Opts().WithLimit(offersPageSize)
+
+	for {
+		offers, err := ms.LoadOffers(address, opts)
+		if err != nil {
+			return nil, ms.wrapf(err, "can't load offers by pair")
+		}
+
+		for _, o := range offers {
+			pair := o.SellingAsset().String() + "/" + o.BuyingAsset().String()
+			pairs[pair] = append(pairs[pair], o)
+		}
+
+		if len(offers) < offersPageSize {
+			break
+		}
+
+		opts = opts.WithCursor(offers[len(offers)-1].PT)
+	}
+
+	return pairs, ms.success()
+}
+
+// validPrice returns an error if p is not a positive decimal string.
+func validPrice(p string) error {
+	if strings.HasPrefix(p, "-") {
+		return errors.Errorf("price must be positive: %s", p)
+	}
+
+	xdrPrice, err := price.Parse(p)
+	if err != nil {
+		return errors.Wrapf(err, "invalid price: %s", p)
+	}
+
+	if xdrPrice.N <= 0 || xdrPrice.D <= 0 {
+		return errors.Errorf("price must be positive: %s", p)
+	}
+
+	return nil
+}
+
 // ManageOffer lets you trade on the DEX. See the Create/Update/DeleteOffer methods below
 // to see how this is used.
 func (ms *MicroStellar) ManageOffer(sourceSeed string, params *OfferParams, options ...*Options) error {
@@ -155,6 +240,25 @@ func (ms *MicroStellar) ManageOffer(sourceSeed string, params *OfferParams, opti
 		return ms.wrapf(err, "ManageOffer")
 	}
 
+	if err := validPrice(params.Price); err != nil {
+		return ms.wrapf(err, "ManageOffer")
+	}
+
+	if params.OfferType != OfferDelete {
+		if err := ValidAmount(params.SellAmount); err != nil {
+			return ms.wrapf(err, "ManageOffer: bad SellAmount: %v", params.SellAmount)
+		}
+
+		sellAmount, err := ParseAmount(params.SellAmount)
+		if err != nil {
+			return ms.wrapf(err, "ManageOffer: bad SellAmount: %v", params.SellAmount)
+		}
+
+		if sellAmount < 0 || (sellAmount == 0 && params.OfferType != OfferUpdate) {
+			return ms.errorf("ManageOffer: SellAmount must be positive: %v", params.SellAmount)
+		}
+	}
+
 	rate := build.Rate{
 		Selling: params.SellAsset.ToStellarAsset(),
 		Buying:  params.BuyAsset.ToStellarAsset(),
@@ -218,6 +322,19 @@ func (ms *MicroStellar) CreateOffer(sourceSeed string, sellAsset *Asset, buyAsse
 	}, options...)
 }
 
+// CreatePassiveOffer creates a passive offer to trade amount of selling held by sourceSeed for buying
+// at price. Passive offers don't take (cross) existing offers at the same price, which makes them
+// useful for market making. This is the same as CreateOffer with Opts().MakePassive().
+func (ms *MicroStellar) CreatePassiveOffer(sourceSeed string, selling *Asset, buying *Asset, price string, amount string, options ...*Options) error {
+	return ms.ManageOffer(sourceSeed, &OfferParams{
+		OfferType:  OfferCreatePassive,
+		SellAsset:  selling,
+		SellAmount: amount,
+		BuyAsset:   buying,
+		Price:      price,
+	}, options...)
+}
+
 // UpdateOffer updates the existing offer with ID offerID on the DEX.
 func (ms *MicroStellar) UpdateOffer(sourceSeed string, offerID string, sellAsset *Asset, buyAsset *Asset, price string, sellAmount string, options ...*Options) error {
 	return ms.ManageOffer(sourceSeed, &OfferParams{
@@ -263,7 +380,7 @@ func (ms *MicroStellar) FindPaths(sourceAddress string, destAddress string, dest
 		return nil, ms.errorf("endpoint parse error: %v", err)
 	}
 
-	debugf("FindPaths", "querying endpoint: %s", endpoint)
+	ms.debugf("FindPaths", "querying endpoint: %s", endpoint)
 	resp, err := client.HTTP.Get(endpoint)
 	if err != nil {
 		return nil, ms.errorf("failed to query server: %v", err)
@@ -272,7 +389,7 @@ func (ms *MicroStellar) FindPaths(sourceAddress string, destAddress string, dest
 	var pathResponse horizonPathResponse
 	bytes, _ := ioutil.ReadAll(resp.Body)
 	body := string(bytes)
-	debugf("FindPaths", "Got Body: %+v", body)
+	ms.debugf("FindPaths", "Got Body: %+v", body)
 	err = json.Unmarshal(bytes, &pathResponse)
 	if err != nil {
 		return nil, ms.errorf("error unmarshalling response: %v", err)
@@ -305,24 +422,105 @@ func (ms *MicroStellar) FindPaths(sourceAddress string, destAddress string, dest
 			}
 		}
 
-		debugf("FindPaths", "cost: %s path source: %s(%s) %s", path.SourceAmount, sourceAsset.Code, sourceAsset.Type, sourceAsset.Issuer)
-		hops := []*Asset{}
-		for _, hop := range path.Path {
-			debugf("FindPaths", "hop: %s(%s) %s", hop.Code, hop.Type, hop.Issuer)
-			hops = append(hops, NewAsset(hop.Code, hop.Issuer, AssetType(hop.Type)))
+		ms.debugf("FindPaths", "cost: %s path source: %s(%s) %s", path.SourceAmount, sourceAsset.Code, sourceAsset.Type, sourceAsset.Issuer)
+		returnPath = append(returnPath, ms.newPathFromHorizon("FindPaths", path))
+	}
+
+	return returnPath, ms.success()
+}
+
+// newPathFromHorizon converts a Horizon path record to a Path.
+func (ms *MicroStellar) newPathFromHorizon(method string, path horizonPath) Path {
+	hops := []*Asset{}
+	for _, hop := range path.Path {
+		ms.debugf(method, "hop: %s(%s) %s", hop.Code, hop.Type, hop.Issuer)
+		hops = append(hops, NewAsset(hop.Code, hop.Issuer, AssetType(hop.Type)))
+	}
+
+	return Path{
+		SourceAsset:  NewAsset(path.SourceAssetCode, path.SourceAssetIssuer, AssetType(path.SourceAssetType)),
+		SourceAmount: path.SourceAmount,
+		DestAsset:    NewAsset(path.DestAssetCode, path.DestAssetIssuer, AssetType(path.DestAssetType)),
+		DestAmount:   path.DestAmount,
+		Hops:         hops,
+	}
+}
+
+// FindSendPaths finds strict-send payment paths from sendAsset to the assets that destAddress
+// trusts, when spending exactly sendAmount: each path's DestAmount is the amount that would arrive.
+// Use Options.WithAsset to filter the results by destination asset and minimum amount received.
+//
+//   paths, err := ms.FindSendPaths(sourceAddress, destAddress, USD, "10", Opts().WithAsset(EUR, "8"))
+//
+// Horizon finds paths from sendAsset regardless of what the source holds, so sourceAddress is only
+// validated. Use PayStrictSend to make the payment.
+func (ms *MicroStellar) FindSendPaths(sourceAddress string, destAddress string, sendAsset *Asset, sendAmount string, options ...*Options) ([]Path, error) {
+	if err := ValidAddress(sourceAddress); err != nil {
+		return nil, ms.errorf("can't find paths: invalid source address: %s", sourceAddress)
+	}
+
+	if err := ValidAddress(destAddress); err != nil {
+		return nil, ms.errorf("can't find paths: invalid destination address: %s", destAddress)
+	}
+
+	if err := sendAsset.Validate(); err != nil {
+		return nil, ms.wrapf(err, "can't find paths")
+	}
+
+	if err := ValidAmount(sendAmount); err != nil {
+		return nil, ms.wrapf(err, "can't find paths")
+	}
+
+	opts := mergeOptions(options)
+
+	var minAmount int64
+	if opts.maxAmount != "" {
+		var err error
+		if minAmount, err = ParseAmount(opts.maxAmount); err != nil {
+			return nil, ms.wrapf(err, "can't find paths: bad minimum amount: %s", opts.maxAmount)
 		}
+	}
 
-		returnPath = append(returnPath,
-			Path{
-				SourceAsset:  sourceAsset,
-				SourceAmount: path.SourceAmount,
-				DestAsset:    NewAsset(path.DestAssetCode, path.DestAssetIssuer, AssetType(path.DestAssetType)),
-				DestAmount:   path.DestAmount,
-				Hops:         hops,
-			})
+	query := url.Values{}
+	query.Add("source_asset_type", string(sendAsset.Type))
+	if !sendAsset.IsNative() {
+		query.Add("source_asset_code", sendAsset.Code)
+		query.Add("source_asset_issuer", sendAsset.Issuer)
 	}
+	query.Add("source_amount", sendAmount)
+	query.Add("destination_account", destAddress)
 
-	return returnPath, ms.success()
+	ms.debugf("FindSendPaths", "finding paths with params %+v", query)
+
+	var pathResponse horizonPathResponse
+	client := clientWithContext(opts.ctx, ms.getTx().GetClient())
+	if err := getJSON(ms.logger(), client, "/paths/strict-send?"+query.Encode(), &pathResponse); err != nil {
+		return nil, ms.wrapf(err, "can't find paths")
+	}
+
+	paths := []Path{}
+	for _, record := range pathResponse.Embedded.Records {
+		path := ms.newPathFromHorizon("FindSendPaths", record)
+		if opts.sendAsset != nil && !opts.sendAsset.Equals(*path.DestAsset) {
+			continue
+		}
+
+		if opts.maxAmount != "" {
+			destAmount, err := ParseAmount(record.DestAmount)
+			if err != nil {
+				return nil, ms.errorf("error parsing path.destination_amount: %s: %v", record.DestAmount, err)
+			}
+
+			if destAmount < minAmount {
+				// Not enough arrives, skip
+				continue
+			}
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, ms.success()
 }
 
 // HorizonOrderBook represents an a horzon order_book response.
@@ -356,6 +554,18 @@ type OrderBook struct {
 // LoadOrderBook returns the current orderbook for all trades between sellAsset and buyAsset. Use
 // Opts().WithLimit(limit) to limit the number of entries returned.
 func (ms *MicroStellar) LoadOrderBook(sellAsset *Asset, buyAsset *Asset, options ...*Options) (*OrderBook, error) {
+	if err := sellAsset.Validate(); err != nil {
+		return nil, ms.wrapf(err, "LoadOrderBook: invalid sell asset")
+	}
+
+	if err := buyAsset.Validate(); err != nil {
+		return nil, ms.wrapf(err, "LoadOrderBook: invalid buy asset")
+	}
+
+	if ms.fake {
+		return &OrderBook{Asks: []BidAsk{}, Bids: []BidAsk{}, Base: sellAsset, Counter: buyAsset}, ms.success()
+	}
+
 	tx := ms.getTx()
 	client := tx.GetClient()
 	baseURL := strings.TrimRight(client.URL, "/") + "/order_book"
@@ -379,16 +589,21 @@ func (ms *MicroStellar) LoadOrderBook(sellAsset *Asset, buyAsset *Asset, options
 		return nil, ms.errorf("endpoint parse error: %v", err)
 	}
 
-	debugf("LoadOrderBook", "querying endpoint: %s", endpoint)
+	ms.debugf("LoadOrderBook", "querying endpoint: %s", endpoint)
 	resp, err := client.HTTP.Get(endpoint)
 	if err != nil {
 		return nil, ms.errorf("failed to query server: %v", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ms.errorf("failed to query server: %s", resp.Status)
+	}
 
 	var orderBook horizonOrderBook
 	bytes, _ := ioutil.ReadAll(resp.Body)
 	body := string(bytes)
-	debugf("LoadOrderBook", "Got Body: %+v", body)
+	ms.debugf("LoadOrderBook", "Got Body: %+v", body)
 	err = json.Unmarshal(bytes, &orderBook)
 	if err != nil {
 		return nil, ms.errorf("error unmarshalling response: %v", err)
@@ -408,3 +623,97 @@ func (ms *MicroStellar) LoadOrderBook(sellAsset *Asset, buyAsset *Asset, options
 
 	return &returnOrderBook, ms.success()
 }
+
+// maxOrderBookLimit is the most price levels Horizon returns for each side of an order book.
+const maxOrderBookLimit = 200
+
+// OfferSimulation is returned by SimulateOffer. Amounts are in units of the asset, and prices in
+// units of the buying asset per unit of the selling asset.
+type OfferSimulation struct {
+	// Sold is the amount of the selling asset that would be sold immediately, and Bought is the
+	// amount of the buying asset that would be received for it.
+	Sold   string
+	Bought string
+
+	// AveragePrice is Bought over Sold, or empty if nothing would be sold.
+	AveragePrice string
+
+	// Remaining is the amount of the selling asset that would be left on the book as an offer.
+	Remaining string
+
+	// Filled is true if the whole offer would execute immediately.
+	Filled bool
+}
+
+// SimulateOffer returns how much of an offer to sell amount of selling for buying at price (see
+// CreateOffer) would execute immediately against the current order book, and at what average
+// price. It's read-only, and doesn't submit anything.
+//
+//   sim, err := ms.SimulateOffer(microstellar.NativeAsset, USD, "0.1", "1000")
+//   log.Printf("%s XLM would sell now for %s USD (%s USD/XLM)", sim.Sold, sim.Bought, sim.AveragePrice)
+//
+// The result is an estimate: only the best 200 price levels are considered, the book can change
+// before the offer is placed, and the network rounds each match in favor of the existing offers.
+func (ms *MicroStellar) SimulateOffer(selling, buying *Asset, price, amount string) (*OfferSimulation, error) {
+	if err := validPrice(price); err != nil {
+		return nil, ms.wrapf(err, "can't simulate offer")
+	}
+
+	if v, err := ParseAmount(amount); err != nil || v <= 0 {
+		return nil, ms.errorf("can't simulate offer: amount must be positive: %s", amount)
+	}
+
+	book, err := ms.LoadOrderBook(selling, buying, Opts().WithLimit(maxOrderBookLimit))
+	if err != nil {
+		return nil, ms.wrapf(err, "can't simulate offer")
+	}
+
+	limit, _ := new(big.Rat).SetString(price)
+	remaining, _ := new(big.Rat).SetString(amount)
+	sold, bought := new(big.Rat), new(big.Rat)
+
+	// Bids buy the selling asset, best (highest) price first, and their amounts are in units of
+	// the buying asset.
+	for _, bid := range book.Bids {
+		if remaining.Sign() <= 0 {
+			break
+		}
+
+		bidPrice, ok := new(big.Rat).SetString(bid.Price)
+		if !ok || bidPrice.Sign() <= 0 {
+			return nil, ms.errorf("can't simulate offer: bad price in order book: %s", bid.Price)
+		}
+
+		if bidPrice.Cmp(limit) < 0 {
+			break
+		}
+
+		bidAmount, ok := new(big.Rat).SetString(bid.Amount)
+		if !ok {
+			return nil, ms.errorf("can't simulate offer: bad amount in order book: %s", bid.Amount)
+		}
+
+		take := new(big.Rat).Quo(bidAmount, bidPrice)
+		if take.Cmp(remaining) > 0 {
+			take = new(big.Rat).Set(remaining)
+		}
+
+		sold.Add(sold, take)
+		bought.Add(bought, new(big.Rat).Mul(take, bidPrice))
+		remaining.Sub(remaining, take)
+	}
+
+	sim := &OfferSimulation{
+		Sold:      sold.FloatString(7),
+		Bought:    bought.FloatString(7),
+		Remaining: remaining.FloatString(7),
+		Filled:    remaining.Sign() <= 0,
+	}
+
+	if sold.Sign() > 0 {
+		sim.AveragePrice = new(big.Rat).Quo(bought, sold).FloatString(7)
+	}
+
+	ms.debugf("SimulateOffer", "selling %s: sold %s for %s, %s remaining", amount, sim.Sold, sim.Bought, sim.Remaining)
+	return sim, ms.success()
+}
@@ -3,6 +3,9 @@ package microstellar
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
 )
 
 // This example creates a passive offer on stellar's DEX.
@@ -28,6 +31,31 @@ func ExampleMicroStellar_CreateOffer() {
 	// Output: ok
 }
 
+// This example places passive offers on both sides of the USD/XLM market in a single transaction.
+func ExampleMicroStellar_CreatePassiveOffer() {
+	// Create a new MicroStellar client connected to a fake network. To
+	// use a real network replace "fake" below with "test" or "public".
+	ms := New("fake")
+
+	// Custom USD asset issued by specified issuer
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+
+	// Sell 100 USD at 2.1 lumens per USD, and buy 100 USD at 1.9 lumens per USD. Passive
+	// offers don't cross each other, or existing offers at the same price.
+	ms.Start(seed)
+	ms.CreatePassiveOffer(seed, USD, NativeAsset, "2.1", "100")
+	ms.CreatePassiveOffer(seed, NativeAsset, USD, "0.5263158", "190")
+	err := ms.Submit()
+
+	if err != nil {
+		log.Fatalf("CreatePassiveOffer: %v", err)
+	}
+
+	fmt.Printf("ok")
+	// Output: ok
+}
+
 // This example updates an existing offer on the DEX.
 func ExampleMicroStellar_UpdateOffer() {
 	// Create a new MicroStellar client connected to a fake network. To
@@ -119,6 +147,27 @@ func ExampleMicroStellar_LoadOffers() {
 	// Output: ok
 }
 
+// This example lists the offers currently out by an address, grouped by trading pair.
+func ExampleMicroStellar_LoadOffersByPair() {
+	// Create a new MicroStellar client connected to a fake network. To
+	// use a real network replace "fake" below with "test" or "public".
+	ms := New("fake")
+
+	// Get all offers made by address, grouped by "SELLING/BUYING" pair.
+	pairs, err := ms.LoadOffersByPair("GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ")
+
+	if err != nil {
+		log.Fatalf("LoadOffersByPair: %v", err)
+	}
+
+	for pair, offers := range pairs {
+		log.Printf("Pair: %s, Offers: %d", pair, len(offers))
+	}
+
+	fmt.Printf("ok")
+	// Output: ok
+}
+
 // This example lists all asks on the DEX between USD <-> XLM
 func ExampleMicroStellar_LoadOrderBook() {
 	// Create a new MicroStellar client connected to a fake network. To
@@ -144,3 +193,140 @@ func ExampleMicroStellar_LoadOrderBook() {
 	fmt.Printf("ok")
 	// Output: ok
 }
+
+func TestLoadOrderBookValidatesAssets(t *testing.T) {
+	ms := New("fake")
+	bad := NewAsset("USD", "notanissuer", Credit4Type)
+
+	if _, err := ms.LoadOrderBook(bad, NativeAsset); err == nil {
+		t.Errorf("LoadOrderBook should reject invalid sell asset")
+	}
+
+	if _, err := ms.LoadOrderBook(NativeAsset, bad); err == nil {
+		t.Errorf("LoadOrderBook should reject invalid buy asset")
+	}
+}
+
+func TestManageOfferValidation(t *testing.T) {
+	ms := New("fake")
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	for _, price := range []string{"", "-1", "0", "abc", "1.2.3"} {
+		if err := ms.CreateOffer(seed, USD, NativeAsset, price, "100"); err == nil {
+			t.Errorf("CreateOffer should reject price %q", price)
+		}
+	}
+
+	for _, amount := range []string{"", "-1", "0", "abc", "1.00000001"} {
+		if err := ms.CreateOffer(seed, USD, NativeAsset, "2", amount); err == nil {
+			t.Errorf("CreateOffer should reject amount %q", amount)
+		}
+	}
+
+	if err := ms.CreateOffer(seed, USD, NativeAsset, "0.5", "100.25"); err != nil {
+		t.Errorf("CreateOffer failed: %v", err)
+	}
+
+	if err := ms.UpdateOffer(seed, "23456", USD, NativeAsset, "1", "0"); err != nil {
+		t.Errorf("UpdateOffer with zero amount failed: %v", err)
+	}
+}
+
+func TestLoadOffers(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("cursor") == "100" {
+			fmt.Fprint(w, `{"_embedded": {"records": []}}`)
+			return
+		}
+
+		if query.Get("limit") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, `{"_embedded": {"records": [{"id": 100, "paging_token": "100", "amount": "20.0000000", "price": "2.0000000",
+			"selling": {"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%s"},
+			"buying": {"asset_type": "native"}}]}}`, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	offers, err := ms.LoadOffers(address, Opts().WithLimit(1))
+	if err != nil {
+		t.Fatalf("LoadOffers failed: %v", ErrorString(err))
+	}
+
+	if len(offers) != 1 {
+		t.Fatalf("want 1 offer, got %d", len(offers))
+	}
+
+	offer := offers[0]
+	if offer.OfferID() != "100" || offer.Price != "2.0000000" || offer.Amount != "20.0000000" ||
+		!offer.SellingAsset().Equals(*NewAsset("USD", issuer, Credit4Type)) || !offer.BuyingAsset().IsNative() {
+		t.Errorf("wrong offer: %+v", offer)
+	}
+
+	offers, err = ms.LoadOffers(address, Opts().WithCursor(offer.PT))
+	if err != nil {
+		t.Fatalf("LoadOffers failed: %v", ErrorString(err))
+	}
+
+	if offers == nil || len(offers) != 0 {
+		t.Errorf("want empty offers, got %+v", offers)
+	}
+}
+
+func TestSimulateOffer(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	USD := NewAsset("USD", issuer, Credit4Type)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/order_book" || r.URL.Query().Get("limit") != "200" {
+			t.Errorf("wrong order book query: %s", r.URL)
+		}
+
+		// Bid amounts are in the counter asset (USD.)
+		fmt.Fprintf(w, `{"bids": [{"price": "0.1200000", "amount": "12.0000000"}, {"price": "0.1100000", "amount": "22.0000000"},
+			{"price": "0.0900000", "amount": "100.0000000"}], "asks": [{"price": "0.1300000", "amount": "50.0000000"}],
+			"base": {"asset_type": "native"}, "counter": {"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%s"}}`, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	tests := []struct {
+		price, amount                string
+		sold, bought, avg, remaining string
+		filled                       bool
+	}{
+		{"0.1", "250", "250.0000000", "28.5000000", "0.1140000", "0.0000000", true},
+		{"0.1", "500", "300.0000000", "34.0000000", "0.1133333", "200.0000000", false},
+		{"0.115", "50", "50.0000000", "6.0000000", "0.1200000", "0.0000000", true},
+		{"0.13", "50", "0.0000000", "0.0000000", "", "50.0000000", false},
+	}
+
+	for i, test := range tests {
+		sim, err := ms.SimulateOffer(NativeAsset, USD, test.price, test.amount)
+		if err != nil {
+			t.Fatalf("%d: SimulateOffer failed: %v", i, ErrorString(err))
+		}
+
+		if sim.Sold != test.sold || sim.Bought != test.bought || sim.AveragePrice != test.avg ||
+			sim.Remaining != test.remaining || sim.Filled != test.filled {
+			t.Errorf("%d: wrong simulation: %+v", i, sim)
+		}
+	}
+
+	if _, err := ms.SimulateOffer(NativeAsset, USD, "0", "10"); err == nil {
+		t.Errorf("want error for zero price")
+	}
+
+	if _, err := ms.SimulateOffer(NativeAsset, USD, "0.1", "0"); err == nil {
+		t.Errorf("want error for zero amount")
+	}
+}
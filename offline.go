@@ -0,0 +1,140 @@
+package microstellar
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// errOffline is returned when an OfflineTx tries to reach the network.
+var errOffline = errors.New("offline transactions can't access the network")
+
+// offlineTransport is an http.RoundTripper that fails every request with errOffline.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.Wrapf(errOffline, "can't request %s", req.URL)
+}
+
+// OfflineTx builds an unsigned transaction without any network access, e.g., for signing on an
+// air-gapped machine. Use NewOfflineTx to create one, add operations with its methods, and get the
+// base64-encoded envelope with Payload.
+type OfflineTx struct {
+	ms     *MicroStellar
+	source string
+	err    error
+}
+
+// NewOfflineTx returns an OfflineTx with operations sourced from sourceAddress, and sequence as
+// its sequence number (i.e., one more than the account's current sequence number.) The network
+// passphrase comes from the client's network, and the base fee, memo, and time bounds from
+// options. Nothing is loaded from Horizon: operations that need the network (e.g., path payments
+// without an explicit path) fail.
+//
+//   otx := ms.NewOfflineTx("source_address", 1234567, microstellar.Opts().WithBaseFee(200))
+//   otx.CreateTrustLine(USD, "")
+//   otx.Pay("target_address", "10", USD)
+//   payload, err := otx.Payload()
+//
+// Sign the payload with SignTransaction.
+func (ms *MicroStellar) NewOfflineTx(sourceAddress string, sequence int64, options ...*Options) *OfflineTx {
+	offline := New(ms.networkName, ms.params).WithHTTPClient(&http.Client{Transport: offlineTransport{}})
+	otx := &OfflineTx{ms: offline, source: sourceAddress}
+
+	if !ValidAddressOrSeed(sourceAddress) {
+		otx.err = offline.errorf("can't build offline transaction: invalid source address: %s", sourceAddress)
+		return otx
+	}
+
+	// Copy the options so we don't modify the caller's.
+	opts := *mergeOptions(options)
+	offline.Start(sourceAddress, opts.WithSequence(sequence))
+	return otx
+}
+
+// record saves err if it's the first error on the transaction, and returns it.
+func (otx *OfflineTx) record(err error) error {
+	if otx.err == nil {
+		otx.err = err
+	}
+
+	return err
+}
+
+// Err returns the first error on the transaction, if any.
+func (otx *OfflineTx) Err() error {
+	return otx.err
+}
+
+// Pay adds a payment of amount units of asset to targetAddress. See MicroStellar.Pay for details.
+func (otx *OfflineTx) Pay(targetAddress string, amount string, asset *Asset, options ...*Options) error {
+	return otx.record(otx.ms.Pay(otx.source, targetAddress, amount, asset, options...))
+}
+
+// FundAccount adds an operation that creates the account at address with amount lumens.
+func (otx *OfflineTx) FundAccount(address string, amount string, options ...*Options) error {
+	return otx.record(otx.ms.FundAccount(otx.source, address, amount, options...))
+}
+
+// CreateTrustLine adds an operation that creates a trustline to asset, with the specified trust
+// limit. An empty limit string indicates no limit.
+func (otx *OfflineTx) CreateTrustLine(asset *Asset, limit string, options ...*Options) error {
+	return otx.record(otx.ms.CreateTrustLine(otx.source, asset, limit, options...))
+}
+
+// RemoveTrustLine adds an operation that removes the trustline to asset.
+func (otx *OfflineTx) RemoveTrustLine(asset *Asset, options ...*Options) error {
+	return otx.record(otx.ms.RemoveTrustLine(otx.source, asset, options...))
+}
+
+// SetData adds an operation that attaches a key-value pair to the account.
+func (otx *OfflineTx) SetData(key string, val []byte, options ...*Options) error {
+	return otx.record(otx.ms.SetData(otx.source, key, val, options...))
+}
+
+// AddSigner adds an operation that adds signerAddress as a signer to the account, with weight
+// signerWeight.
+func (otx *OfflineTx) AddSigner(signerAddress string, signerWeight uint32, options ...*Options) error {
+	return otx.record(otx.ms.AddSigner(otx.source, signerAddress, signerWeight, options...))
+}
+
+// Add adds the operations added by ops, which is called with the underlying MicroStellar instance
+// and the source address. Use this for operations OfflineTx doesn't have methods for.
+//
+//   otx.Add(func(ms *microstellar.MicroStellar, source string) {
+//       ms.SetHomeDomain(source, "qubit.sh")
+//   })
+//
+// ops must not call Start, Submit, or Payload.
+func (otx *OfflineTx) Add(ops func(ms *MicroStellar, source string)) error {
+	if otx.err != nil {
+		return otx.err
+	}
+
+	ops(otx.ms, otx.source)
+	return otx.record(otx.ms.Err())
+}
+
+// Payload returns the base64-encoded, unsigned transaction envelope. Returns the first error on
+// the transaction, if any operation failed.
+func (otx *OfflineTx) Payload() (string, error) {
+	if otx.err != nil {
+		return "", otx.err
+	}
+
+	tx := otx.ms.getTx()
+	if !tx.isMultiOp {
+		return "", otx.record(errors.Errorf("offline transaction already closed"))
+	}
+
+	if tx.opCount == 0 {
+		return "", otx.record(errors.Errorf("offline transaction has no operations"))
+	}
+
+	payload, err := tx.Payload()
+	if err != nil {
+		return "", otx.record(errors.Wrap(err, "can't build offline transaction"))
+	}
+
+	return payload, nil
+}
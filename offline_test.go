@@ -0,0 +1,69 @@
+package microstellar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+)
+
+func TestOfflineTx(t *testing.T) {
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	// Any network access would fail: the URL is unreachable.
+	ms := New("custom", Params{"url": "http://localhost:0", "passphrase": "offline"})
+
+	otx := ms.NewOfflineTx(source, 1234, Opts().WithBaseFee(200).WithMemoText("offline"))
+	if err := otx.CreateTrustLine(USD, ""); err != nil {
+		t.Fatalf("CreateTrustLine failed: %v", err)
+	}
+
+	if err := otx.Pay(bob, "10", USD); err != nil {
+		t.Fatalf("Pay failed: %v", err)
+	}
+
+	if err := otx.Add(func(ms *MicroStellar, source string) { ms.SetHomeDomain(source, "qubit.sh") }); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	payload, err := otx.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", ErrorString(err))
+	}
+
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("can't decode payload: %v", err)
+	}
+
+	if txe.Tx.SourceAccount.Address() != source || txe.Tx.SeqNum != 1234 || txe.Tx.Fee != 600 ||
+		txe.Tx.Memo.Type != xdr.MemoTypeMemoText || len(txe.Tx.Operations) != 3 || len(txe.Signatures) != 0 {
+		t.Errorf("wrong transaction: %+v", txe.Tx)
+	}
+
+	hash, err := ms.TxTrackingID(payload)
+	if err != nil || len(hash) != 64 {
+		t.Errorf("bad hash %s: %v", hash, err)
+	}
+
+	// Path finding needs the network.
+	otx = ms.NewOfflineTx(source, 1234)
+	err = otx.Pay(bob, "10", USD, Opts().WithAsset(NativeAsset, "100").FindPathFrom(source))
+	if err == nil || !strings.Contains(err.Error(), errOffline.Error()) {
+		t.Errorf("want offline error, got %v", err)
+	}
+
+	if _, err := otx.Payload(); err == nil {
+		t.Errorf("want error from Payload after failed operation")
+	}
+
+	if _, err := ms.NewOfflineTx(source, 1234).Payload(); err == nil {
+		t.Errorf("want error for no operations")
+	}
+
+	if _, err := ms.NewOfflineTx("bad", 1234).Payload(); err == nil {
+		t.Errorf("want error for bad source")
+	}
+}
@@ -0,0 +1,256 @@
+package microstellar
+
+import (
+	"fmt"
+	"time"
+)
+
+// OperationType is the type of an operation, as reported by Horizon.
+type OperationType string
+
+// Operation types.
+const (
+	OpCreateAccount      = OperationType("create_account")
+	OpPayment            = OperationType("payment")
+	OpPathPayment        = OperationType("path_payment")
+	OpManageOffer        = OperationType("manage_offer")
+	OpCreatePassiveOffer = OperationType("create_passive_offer")
+	OpSetOptions         = OperationType("set_options")
+	OpChangeTrust        = OperationType("change_trust")
+	OpAllowTrust         = OperationType("allow_trust")
+	OpAccountMerge       = OperationType("account_merge")
+	OpInflation          = OperationType("inflation")
+	OpManageData         = OperationType("manage_data")
+	OpBumpSequence       = OperationType("bump_sequence")
+)
+
+// Operation is a single operation in an account's history. Only the fields relevant to the
+// operation's Type are set.
+type Operation struct {
+	ID              string        `json:"id"`
+	PT              string        `json:"paging_token"` // use with WithCursor to resume from this operation
+	Type            OperationType `json:"type"`
+	Source          string        `json:"source_account"`
+	CreatedAt       time.Time     `json:"created_at"`
+	TransactionHash string        `json:"transaction_hash"`
+
+	// create_account
+	Funder          string `json:"funder"`
+	StartingBalance string `json:"starting_balance"`
+
+	// create_account (new account), account_merge (merged account)
+	Account string `json:"account"`
+
+	// account_merge
+	Into string `json:"into"`
+
+	// payment, path_payment
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"` // also the offer amount for manage_offer
+
+	// payment, path_payment (destination asset), change_trust, allow_trust
+	Asset *Asset `json:"asset"`
+
+	// path_payment
+	SourceAsset  *Asset  `json:"source_asset"`
+	SourceAmount string  `json:"source_amount"`
+	SourceMax    string  `json:"source_max"`
+	Path         []Asset `json:"path"`
+
+	// manage_offer, create_passive_offer
+	OfferID string `json:"offer_id"`
+	Price   string `json:"price"`
+	Selling *Asset `json:"selling"`
+	Buying  *Asset `json:"buying"`
+
+	// change_trust, allow_trust
+	Trustor   string `json:"trustor"`
+	Trustee   string `json:"trustee"`
+	Limit     string `json:"limit"`
+	Authorize bool   `json:"authorize"`
+
+	// set_options
+	HomeDomain      string   `json:"home_domain"`
+	InflationDest   string   `json:"inflation_dest"`
+	SignerKey       string   `json:"signer_key"`
+	SignerWeight    *int32   `json:"signer_weight"`
+	MasterKeyWeight *int32   `json:"master_key_weight"`
+	LowThreshold    *int32   `json:"low_threshold"`
+	MedThreshold    *int32   `json:"med_threshold"`
+	HighThreshold   *int32   `json:"high_threshold"`
+	SetFlags        []string `json:"set_flags"`
+	ClearFlags      []string `json:"clear_flags"`
+
+	// manage_data
+	Name  string `json:"name"`
+	Value string `json:"value"` // base-64 encoded
+
+	// bump_sequence
+	BumpTo string `json:"bump_to"`
+}
+
+// horizonPathAsset is an asset in a Horizon path payment path.
+type horizonPathAsset struct {
+	AssetType   string `json:"asset_type"`
+	AssetCode   string `json:"asset_code"`
+	AssetIssuer string `json:"asset_issuer"`
+}
+
+// horizonOperation is a Horizon operation record. The vendored Horizon client only
+// decodes payment-like operations.
+type horizonOperation struct {
+	ID                 string             `json:"id"`
+	PT                 string             `json:"paging_token"`
+	Type               string             `json:"type"`
+	SourceAccount      string             `json:"source_account"`
+	CreatedAt          time.Time          `json:"created_at"`
+	TransactionHash    string             `json:"transaction_hash"`
+	Funder             string             `json:"funder"`
+	StartingBalance    string             `json:"starting_balance"`
+	Account            string             `json:"account"`
+	Into               string             `json:"into"`
+	From               string             `json:"from"`
+	To                 string             `json:"to"`
+	Amount             string             `json:"amount"`
+	AssetType          string             `json:"asset_type"`
+	AssetCode          string             `json:"asset_code"`
+	AssetIssuer        string             `json:"asset_issuer"`
+	SourceAssetType    string             `json:"source_asset_type"`
+	SourceAssetCode    string             `json:"source_asset_code"`
+	SourceAssetIssuer  string             `json:"source_asset_issuer"`
+	SourceAmount       string             `json:"source_amount"`
+	SourceMax          string             `json:"source_max"`
+	Path               []horizonPathAsset `json:"path"`
+	OfferID            interface{}        `json:"offer_id"` // number in older Horizons, string in newer ones
+	Price              string             `json:"price"`
+	SellingAssetType   string             `json:"selling_asset_type"`
+	SellingAssetCode   string             `json:"selling_asset_code"`
+	SellingAssetIssuer string             `json:"selling_asset_issuer"`
+	BuyingAssetType    string             `json:"buying_asset_type"`
+	BuyingAssetCode    string             `json:"buying_asset_code"`
+	BuyingAssetIssuer  string             `json:"buying_asset_issuer"`
+	Trustor            string             `json:"trustor"`
+	Trustee            string             `json:"trustee"`
+	Limit              string             `json:"limit"`
+	Authorize          bool               `json:"authorize"`
+	HomeDomain         string             `json:"home_domain"`
+	InflationDest      string             `json:"inflation_dest"`
+	SignerKey          string             `json:"signer_key"`
+	SignerWeight       *int32             `json:"signer_weight"`
+	MasterKeyWeight    *int32             `json:"master_key_weight"`
+	LowThreshold       *int32             `json:"low_threshold"`
+	MedThreshold       *int32             `json:"med_threshold"`
+	HighThreshold      *int32             `json:"high_threshold"`
+	SetFlags           []string           `json:"set_flags_s"`
+	ClearFlags         []string           `json:"clear_flags_s"`
+	Name               string             `json:"name"`
+	Value              string             `json:"value"`
+	BumpTo             string             `json:"bump_to"`
+}
+
+type horizonOperationsPage struct {
+	Embedded struct {
+		Records []horizonOperation `json:"records"`
+	} `json:"_embedded"`
+}
+
+// horizonID returns an ID that Horizon sends either as a number or a string, as a string.
+func horizonID(id interface{}) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	}
+
+	return ""
+}
+
+// newOperationFromHorizon creates a new operation from a Horizon operation record.
+func newOperationFromHorizon(ho horizonOperation) Operation {
+	op := Operation{
+		ID:              ho.ID,
+		PT:              ho.PT,
+		Type:            OperationType(ho.Type),
+		Source:          ho.SourceAccount,
+		CreatedAt:       ho.CreatedAt,
+		TransactionHash: ho.TransactionHash,
+		Funder:          ho.Funder,
+		StartingBalance: ho.StartingBalance,
+		Account:         ho.Account,
+		Into:            ho.Into,
+		From:            ho.From,
+		To:              ho.To,
+		Amount:          ho.Amount,
+		Asset:           effectAsset(ho.AssetType, ho.AssetCode, ho.AssetIssuer),
+		SourceAsset:     effectAsset(ho.SourceAssetType, ho.SourceAssetCode, ho.SourceAssetIssuer),
+		SourceAmount:    ho.SourceAmount,
+		SourceMax:       ho.SourceMax,
+		OfferID:         horizonID(ho.OfferID),
+		Price:           ho.Price,
+		Selling:         effectAsset(ho.SellingAssetType, ho.SellingAssetCode, ho.SellingAssetIssuer),
+		Buying:          effectAsset(ho.BuyingAssetType, ho.BuyingAssetCode, ho.BuyingAssetIssuer),
+		Trustor:         ho.Trustor,
+		Trustee:         ho.Trustee,
+		Limit:           ho.Limit,
+		Authorize:       ho.Authorize,
+		HomeDomain:      ho.HomeDomain,
+		InflationDest:   ho.InflationDest,
+		SignerKey:       ho.SignerKey,
+		SignerWeight:    ho.SignerWeight,
+		MasterKeyWeight: ho.MasterKeyWeight,
+		LowThreshold:    ho.LowThreshold,
+		MedThreshold:    ho.MedThreshold,
+		HighThreshold:   ho.HighThreshold,
+		SetFlags:        ho.SetFlags,
+		ClearFlags:      ho.ClearFlags,
+		Name:            ho.Name,
+		Value:           ho.Value,
+		BumpTo:          ho.BumpTo,
+	}
+
+	if ho.Type == string(OpAccountMerge) && op.Account == "" {
+		op.Account = ho.SourceAccount
+	}
+
+	for _, a := range ho.Path {
+		op.Path = append(op.Path, *effectAsset(a.AssetType, a.AssetCode, a.AssetIssuer))
+	}
+
+	return op
+}
+
+// LoadOperations returns the operations in the history of the account at address, including
+// operations on other accounts that affected it. Use WithLimit, WithCursor and WithSortOrder to
+// page through the results; pass the PT field of the last operation to WithCursor to resume
+// from where you left off. Returns an empty slice if the account has no operations.
+//
+//   ops, err := ms.LoadOperations("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+//     microstellar.Opts().WithLimit(100).WithCursor(lastPT))
+func (ms *MicroStellar) LoadOperations(address string, options ...*Options) ([]Operation, error) {
+	if err := ValidAddress(address); err != nil {
+		return nil, ms.errorf("invalid address: %s", address)
+	}
+
+	opt := mergeOptions(options)
+	query := pageQuery(opt)
+
+	ms.debugf("LoadOperations", "loading operations for %s, with params %+v", address, query)
+	if ms.fake {
+		return []Operation{}, ms.success()
+	}
+
+	var page horizonOperationsPage
+	path := fmt.Sprintf("/accounts/%s/operations?%s", address, query.Encode())
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), path, &page); err != nil {
+		return nil, ms.wrapf(err, "can't load operations")
+	}
+
+	ops := make([]Operation, len(page.Embedded.Records))
+	for i, ho := range page.Embedded.Records {
+		ops[i] = newOperationFromHorizon(ho)
+	}
+
+	return ops, ms.success()
+}
@@ -0,0 +1,79 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadOperations(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/"+address+"/operations" {
+			fmt.Fprint(w, `{"_embedded": {"records": []}}`)
+			return
+		}
+
+		if r.URL.Query().Get("cursor") != "12" || r.URL.Query().Get("order") != "asc" {
+			t.Errorf("wrong query: %s", r.URL.RawQuery)
+		}
+
+		fmt.Fprintf(w, `{"_embedded": {"records": [
+			{"id": "13", "paging_token": "13", "type": "create_account", "source_account": "%[2]s",
+			 "created_at": "2018-05-01T10:00:00Z", "transaction_hash": "abcd", "funder": "%[2]s",
+			 "account": "%[1]s", "starting_balance": "100.0000000"},
+			{"id": "14", "paging_token": "14", "type": "payment", "source_account": "%[1]s", "from": "%[1]s",
+			 "to": "%[2]s", "amount": "5.0000000", "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%[2]s"},
+			{"id": "15", "paging_token": "15", "type": "manage_offer", "source_account": "%[1]s", "offer_id": 7,
+			 "amount": "10.0000000", "price": "0.5000000", "selling_asset_type": "native",
+			 "buying_asset_type": "credit_alphanum4", "buying_asset_code": "USD", "buying_asset_issuer": "%[2]s"},
+			{"id": "16", "paging_token": "16", "type": "path_payment", "source_account": "%[1]s", "from": "%[1]s",
+			 "to": "%[2]s", "amount": "1.0000000", "asset_type": "native", "source_asset_type": "credit_alphanum4",
+			 "source_asset_code": "USD", "source_asset_issuer": "%[2]s", "source_max": "3.0000000",
+			 "path": [{"asset_type": "credit_alphanum4", "asset_code": "EUR", "asset_issuer": "%[2]s"}]},
+			{"id": "17", "paging_token": "17", "type": "set_options", "source_account": "%[1]s",
+			 "signer_key": "%[2]s", "signer_weight": 0, "home_domain": "example.com"}
+		]}}`, address, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	ops, err := ms.LoadOperations(address, Opts().WithCursor("12"))
+	if err != nil {
+		t.Fatalf("LoadOperations failed: %v", err)
+	}
+
+	if len(ops) != 5 {
+		t.Fatalf("want 5 operations, got %d", len(ops))
+	}
+
+	usd := NewAsset("USD", issuer, Credit4Type)
+	if ops[0].Type != OpCreateAccount || ops[0].Account != address || ops[0].StartingBalance != "100.0000000" ||
+		ops[0].TransactionHash != "abcd" || ops[0].PT != "13" {
+		t.Errorf("wrong create_account operation: %+v", ops[0])
+	}
+
+	if ops[1].Type != OpPayment || ops[1].To != issuer || ops[1].Amount != "5.0000000" || !ops[1].Asset.Equals(*usd) {
+		t.Errorf("wrong payment operation: %+v", ops[1])
+	}
+
+	if ops[2].Type != OpManageOffer || ops[2].OfferID != "7" || !ops[2].Selling.IsNative() || !ops[2].Buying.Equals(*usd) {
+		t.Errorf("wrong manage_offer operation: %+v", ops[2])
+	}
+
+	if ops[3].Type != OpPathPayment || !ops[3].SourceAsset.Equals(*usd) || len(ops[3].Path) != 1 || ops[3].Path[0].Code != "EUR" {
+		t.Errorf("wrong path_payment operation: %+v", ops[3])
+	}
+
+	if ops[4].Type != OpSetOptions || ops[4].SignerWeight == nil || *ops[4].SignerWeight != 0 || ops[4].MasterKeyWeight != nil {
+		t.Errorf("wrong set_options operation: %+v", ops[4])
+	}
+
+	ops, err = ms.LoadOperations("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A")
+	if err != nil || ops == nil || len(ops) != 0 {
+		t.Errorf("want empty operations, got %v: %v", ops, err)
+	}
+}
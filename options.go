@@ -3,6 +3,8 @@ package microstellar
 import (
 	"context"
 	"time"
+
+	"github.com/stellar/go/xdr"
 )
 
 // SortOrder is used with WithSortOrder
@@ -39,6 +41,11 @@ const (
 // event immediately.
 type TxHandler func(data ...interface{}) (bool, error)
 
+// SignerFunc signs the transaction hash hash, e.g., with a key held in an HSM, and returns the
+// decorated signature (the signature and the last 4 bytes of the signer's public key.) See
+// Options.WithSignerFunc.
+type SignerFunc func(hash [32]byte) (xdr.DecoratedSignature, error)
+
 // Options are additional parameters for a transaction. Use Opts() or NewOptions()
 // to create a new instance.
 type Options struct {
@@ -52,6 +59,8 @@ type Options struct {
 	hasTimeBounds bool
 	minTimeBound  time.Time
 	maxTimeBound  time.Time
+	hasSequence   bool
+	sequence      int64
 
 	// Used by all transactions.
 	memoType MemoType // defaults to no memo
@@ -59,16 +68,52 @@ type Options struct {
 	memoID   uint64   // additional memo ID
 	memoHash [32]byte // additional memo ID
 
+	// Don't submit if a recent transaction has the idempotency memo for idempotencyKey.
+	idempotent     bool
+	idempotencyKey string
+
 	skipSignatures bool
 	signerSeeds    []string
+	signerFunc     SignerFunc
+
+	// Use this account as the transaction source, so it provides the sequence number.
+	channelSeed string
+
+	// Source account of the operation, if it's not the transaction's.
+	opSourceAccount string
+
+	// Allow payments to the source account.
+	allowSelfPay bool
+
+	// Seed of the sponsored account, which must co-sign sponsorships.
+	sponsoredSeed string
+
+	// Build the transaction, but don't sign or submit it.
+	dryRun bool
+
+	// Refuse to submit if Horizon lags Stellar Core by more than maxLag ledgers.
+	hasHealthGate bool
+	maxLag        int32
+
+	// Resubmit with a fresh sequence number on tx_bad_seq, up to seqRetries times.
+	seqRetries int
 
 	// Options for query methods (Watch*, Load*)
 	hasCursor      bool
 	cursor         string
 	hasLimit       bool
 	limit          uint
+	hasSortOrder   bool
 	sortDescending bool
 
+	// For trades.
+	baseAsset    *Asset
+	counterAsset *Asset
+
+	// For LoadAssets.
+	assetCode   string
+	assetIssuer string
+
 	// For offer management.
 	passiveOffer bool
 
@@ -93,6 +138,7 @@ func NewOptions() *Options {
 		memoType:       MemoNone,
 		hasCursor:      false,
 		hasLimit:       false,
+		hasSortOrder:   false,
 		sortDescending: false,
 		passiveOffer:   false,
 		sourceAddress:  "",
@@ -108,7 +154,10 @@ func Opts() *Options {
 
 // mergeOptions takes a slice of Options and merges them.
 func mergeOptions(opts []*Options) *Options {
-	// for now, just return the first option
+	if len(opts) > 1 {
+		return MergeOptions(opts...)
+	}
+
 	if len(opts) > 0 {
 		return opts[0]
 	}
@@ -116,6 +165,130 @@ func mergeOptions(opts []*Options) *Options {
 	return NewOptions()
 }
 
+// MergeOptions returns new Options with every option set in opts, e.g., to combine a base set of
+// options with per-call overrides. Later options override earlier ones: if two options set the
+// memo (or the cursor, fee, signers, and so on), the later one wins. Flags (e.g., SkipSignatures
+// and MakePassive) are set if any option sets them, and handlers are merged by event. nil options
+// are skipped, and opts isn't modified.
+//
+//   base := microstellar.Opts().WithMemoText("payroll").WithSigner(signerSeed)
+//   err := ms.PayNative(sourceSeed, targetAddress, "10",
+//       microstellar.MergeOptions(base, microstellar.Opts().WithMemoID(42)))
+func MergeOptions(opts ...*Options) *Options {
+	merged := NewOptions()
+
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+
+		if o.ctx != nil {
+			merged.ctx = o.ctx
+		}
+
+		for event, handler := range o.handlers {
+			merged.handlers[event] = handler
+		}
+
+		if o.hasFee {
+			merged.hasFee, merged.fee = true, o.fee
+		}
+
+		if o.hasTimeBounds {
+			merged.hasTimeBounds, merged.minTimeBound, merged.maxTimeBound = true, o.minTimeBound, o.maxTimeBound
+		}
+
+		if o.hasSequence {
+			merged.hasSequence, merged.sequence = true, o.sequence
+		}
+
+		if o.memoType != MemoNone {
+			merged.memoType, merged.memoText, merged.memoID, merged.memoHash = o.memoType, o.memoText, o.memoID, o.memoHash
+		}
+
+		if o.idempotent {
+			merged.idempotent, merged.idempotencyKey = true, o.idempotencyKey
+		}
+
+		merged.skipSignatures = merged.skipSignatures || o.skipSignatures
+		if len(o.signerSeeds) > 0 {
+			merged.signerSeeds = append([]string{}, o.signerSeeds...)
+		}
+
+		if o.signerFunc != nil {
+			merged.signerFunc = o.signerFunc
+		}
+
+		if o.channelSeed != "" {
+			merged.channelSeed = o.channelSeed
+		}
+
+		if o.opSourceAccount != "" {
+			merged.opSourceAccount = o.opSourceAccount
+		}
+
+		merged.allowSelfPay = merged.allowSelfPay || o.allowSelfPay
+
+		if o.sponsoredSeed != "" {
+			merged.sponsoredSeed = o.sponsoredSeed
+		}
+
+		merged.dryRun = merged.dryRun || o.dryRun
+
+		if o.hasHealthGate {
+			merged.hasHealthGate, merged.maxLag = true, o.maxLag
+		}
+
+		if o.seqRetries != 0 {
+			merged.seqRetries = o.seqRetries
+		}
+
+		if o.hasCursor {
+			merged.hasCursor, merged.cursor = true, o.cursor
+		}
+
+		if o.hasLimit {
+			merged.hasLimit, merged.limit = true, o.limit
+		}
+
+		if o.hasSortOrder {
+			merged.hasSortOrder, merged.sortDescending = true, o.sortDescending
+		}
+
+		if o.baseAsset != nil || o.counterAsset != nil {
+			merged.baseAsset, merged.counterAsset = o.baseAsset, o.counterAsset
+		}
+
+		if o.assetCode != "" {
+			merged.assetCode = o.assetCode
+		}
+
+		if o.assetIssuer != "" {
+			merged.assetIssuer = o.assetIssuer
+		}
+
+		merged.passiveOffer = merged.passiveOffer || o.passiveOffer
+
+		if o.sourceAddress != "" {
+			merged.sourceAddress = o.sourceAddress
+		}
+
+		if o.sendAsset != nil {
+			merged.sendAsset, merged.maxAmount = o.sendAsset, o.maxAmount
+		}
+
+		if len(o.path) > 0 {
+			merged.path = append([]*Asset{}, o.path...)
+		}
+
+		if o.isMultiOp {
+			merged.isMultiOp, merged.multiOpSource = true, o.multiOpSource
+		}
+	}
+
+	return merged
+}
+
 // WithMemoText sets the memoType and memoText fields on a Transaction. Used
 // with all transactions.
 func (o *Options) WithMemoText(text string) *Options {
@@ -148,14 +321,109 @@ func (o *Options) WithMemoReturn(hash [32]byte) *Options {
 	return o
 }
 
+// WithIdempotencyMemo sets a hash memo derived from key (its SHA-256 hash), and makes the
+// transaction a no-op if it was already submitted: before submitting, the source account's
+// recent transactions are checked for the memo, and if one has it, nothing is submitted and
+// Response returns that transaction. Use a key that's unique to the payment (e.g., an invoice
+// ID), and reuse it on retries.
+//
+//   err := ms.PayNative(sourceSeed, targetAddress, "10",
+//       microstellar.Opts().WithIdempotencyMemo("invoice-1234").WithTimeout(30*time.Second))
+//
+// This narrows the window for double payments, but doesn't close it: only the last 200
+// transactions are checked, and a transaction that's still in flight (e.g., a timed out
+// submission that hasn't been applied yet), or that Horizon hasn't ingested, isn't found. Set a
+// timeout with WithTimeout, and retry only after it passes, so the first attempt can't be applied
+// after the check. Don't override the memo in later options.
+func (o *Options) WithIdempotencyMemo(key string) *Options {
+	o.WithMemoHash(idempotencyMemo(key))
+	o.idempotent = true
+	o.idempotencyKey = key
+	return o
+}
+
+// WithFederationMemo sets the memo on a Transaction to the one required by the federation
+// record, as returned by ResolveFull. Records that need no memo (and nil records) leave the memo
+// unchanged.
+func (o *Options) WithFederationMemo(record *FederationRecord) *Options {
+	if record == nil {
+		return o
+	}
+
+	switch record.MemoType {
+	case MemoText:
+		o.WithMemoText(record.MemoText)
+	case MemoID:
+		o.WithMemoID(record.MemoID)
+	case MemoHash:
+		o.WithMemoHash(record.MemoHash)
+	}
+
+	return o
+}
+
 // WithSigner adds a signer to Payment. Used with all transactions.
 func (o *Options) WithSigner(signerSeed string) *Options {
 	o.signerSeeds = append(o.signerSeeds, signerSeed)
 	return o
 }
 
+// WithSignerFunc signs the transaction with signer, instead of with the source account's seed, so
+// the seed never has to be handed to microstellar. signer is called with the transaction hash, and
+// its signature is added to the envelope. Pass the source address (instead of its seed) to the
+// transaction method:
+//
+//   err := ms.PayNative("source_address", "target_address", "10",
+//       microstellar.Opts().WithSignerFunc(func(hash [32]byte) (xdr.DecoratedSignature, error) {
+//           return hsm.SignDecorated(hash[:])
+//       }))
+//
+// Seeds from WithSigner, WithChannelAccount, and WithSourceAccount still sign too.
+func (o *Options) WithSignerFunc(signer SignerFunc) *Options {
+	o.signerFunc = signer
+	return o
+}
+
+// WithSourceAccount sets the source account of the operation to addressOrSeed, instead of the
+// transaction's source account. Use this in multi-op transactions to operate on more than one
+// account:
+//
+//   ms.Start("payer_seed")
+//   ms.Pay("payer_seed", "target_address", "10", USD)
+//   ms.SetData("payer_seed", "foo", []byte("bar"), microstellar.Opts().WithSourceAccount("other_seed"))
+//   ms.Submit()
+//
+// If addressOrSeed is a seed, the transaction is also signed with it. Otherwise, add the signature
+// with WithSigner.
+func (o *Options) WithSourceAccount(addressOrSeed string) *Options {
+	o.opSourceAccount = addressOrSeed
+	return o
+}
+
+// WithSponsoredSeed sets the seed of the sponsored account, which must sign the transaction along
+// with the sponsor. Used with SponsorReserves and CreateSponsoredAccount.
+func (o *Options) WithSponsoredSeed(seed string) *Options {
+	o.sponsoredSeed = seed
+	return o
+}
+
+// WithChannelAccount makes channelSeed's account the source of the transaction, while its
+// operations stay sourced from the real account. The channel account provides the sequence number
+// (and pays the fee), so transactions from the same real account can be submitted in parallel, one
+// per channel account, without tx_bad_seq failures. The transaction is signed with channelSeed as
+// well as the real account.
+//
+//   ms.Pay("source_seed", "target_address", "10", USD, microstellar.Opts().WithChannelAccount("channel_seed"))
+//
+// For multi-op transactions, pass this to Start.
+func (o *Options) WithChannelAccount(channelSeed string) *Options {
+	o.channelSeed = channelSeed
+	return o
+}
+
 // WithContext sets the context.Context for the connection. Used with
-// Watch* methods.
+// Watch* methods, and with all methods that make requests to Horizon -- pending
+// requests are aborted when the context is cancelled.
 func (o *Options) WithContext(context context.Context) *Options {
 	o.ctx = context
 	return o
@@ -178,9 +446,27 @@ func (o *Options) WithLimit(limit uint) *Options {
 
 // WithSortOrder sets the sort order of the results. Used with LoadOffers.
 func (o *Options) WithSortOrder(order SortOrder) *Options {
-	if order == SortDescending {
-		o.sortDescending = true
-	}
+	o.hasSortOrder = true
+	o.sortDescending = order == SortDescending
+	return o
+}
+
+// WithAssetPair limits LoadTrades to trades between base and counter (in either direction.)
+func (o *Options) WithAssetPair(base *Asset, counter *Asset) *Options {
+	o.baseAsset = base
+	o.counterAsset = counter
+	return o
+}
+
+// WithAssetCode limits LoadAssets to assets with the given code.
+func (o *Options) WithAssetCode(code string) *Options {
+	o.assetCode = code
+	return o
+}
+
+// WithAssetIssuer limits LoadAssets to assets issued by the account at issuer.
+func (o *Options) WithAssetIssuer(issuer string) *Options {
+	o.assetIssuer = issuer
 	return o
 }
 
@@ -218,6 +504,24 @@ func (o *Options) FindPathFrom(sourceAddress string) *Options {
 	return o
 }
 
+// WithAutoSequenceRetry retries submissions that fail with tx_bad_seq (e.g., because another
+// transaction from the same account got in first) up to maxRetries times. Each retry reloads the
+// account's sequence number, and re-signs the transaction with the original signers. Other
+// failures are not retried.
+func (o *Options) WithAutoSequenceRetry(maxRetries int) *Options {
+	o.seqRetries = maxRetries
+	return o
+}
+
+// WithHealthGate makes submissions first check how far Horizon's ingestion lags behind Stellar
+// Core, and fail with ErrHorizonLagging if it's more than maxLag ledgers. The check is cached
+// for a few seconds, so it doesn't add a round-trip to every submission.
+func (o *Options) WithHealthGate(maxLag int) *Options {
+	o.hasHealthGate = true
+	o.maxLag = int32(maxLag)
+	return o
+}
+
 // MultiOp specifies that this is a multi-op transactions, and sets the fund source account
 // to sourceAccount.
 func (o *Options) MultiOp(sourceAccount string) *Options {
@@ -234,6 +538,13 @@ func (o *Options) On(event Event, handler *TxHandler) *Options {
 	return o
 }
 
+// AllowSelfPay lets Pay send a payment to its source account, which it otherwise rejects with
+// ErrSelfPayment (as it's almost always a mistake.)
+func (o *Options) AllowSelfPay() *Options {
+	o.allowSelfPay = true
+	return o
+}
+
 // SkipSignatures prevents Tx from signing transactions. This is typically done if the
 // transaction is not meant to be submitted.
 func (o *Options) SkipSignatures() *Options {
@@ -241,8 +552,46 @@ func (o *Options) SkipSignatures() *Options {
 	return o
 }
 
+// WithDryRun makes the transaction get built, but not signed or submitted. Use
+// MicroStellar.LastPayload to get the unsigned base64-encoded envelope, e.g., to sign it with
+// an external (hardware) signer and submit it with SubmitTransaction. Works with all
+// transactions, including multi-op ones.
+//
+//   err := ms.Pay("source_address", "target_address", "10", USD, microstellar.Opts().WithDryRun())
+//   payload, err := ms.LastPayload()
+func (o *Options) WithDryRun() *Options {
+	o.dryRun = true
+	return o
+}
+
+// WithBaseFee sets the base fee (in stroops) per operation for the transaction. Use
+// MicroStellar.FeeStats to pick a fee that's likely to be accepted.
+func (o *Options) WithBaseFee(fee uint32) *Options {
+	o.hasFee = true
+	o.fee = fee
+	return o
+}
+
+// WithSequence sets the transaction's sequence number to sequence, instead of loading the source
+// account's sequence number from Horizon. This is the number the transaction itself uses, i.e.,
+// one more than the account's current sequence number (see GetSequenceNumber.) Use this with
+// WithDryRun to build transactions offline.
+//
+//   seq, err := ms.GetSequenceNumber("source_address")
+//   err = ms.Pay("source_address", "target_address", "10", USD,
+//       microstellar.Opts().WithSequence(seq+1).WithDryRun())
+//
+// Fixed sequence numbers are never retried with WithAutoSequenceRetry. For multi-op
+// transactions, pass this to Start.
+func (o *Options) WithSequence(sequence int64) *Options {
+	o.hasSequence = true
+	o.sequence = sequence
+	return o
+}
+
 // WithTimeBounds attaches time bounds to the transaction. This means that the transaction
-// can only be submitted between min and max time (as determined by the ledger.)
+// can only be submitted between min and max time (as determined by the ledger.) A zero min or
+// max time leaves that end of the range unbounded.
 func (o *Options) WithTimeBounds(min time.Time, max time.Time) *Options {
 	o.hasTimeBounds = true
 	o.minTimeBound = min
@@ -250,5 +599,11 @@ func (o *Options) WithTimeBounds(min time.Time, max time.Time) *Options {
 	return o
 }
 
+// WithTimeout makes the transaction expire d from now. The transaction is rejected by the
+// network if it's not included in a ledger before then.
+func (o *Options) WithTimeout(d time.Duration) *Options {
+	return o.WithTimeBounds(time.Time{}, time.Now().Add(d))
+}
+
 // TxOptions is a deprecated alias for TxOptoins
 type TxOptions Options
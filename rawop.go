@@ -0,0 +1,213 @@
+package microstellar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// The vendored XDR library predates some operations (e.g., claimable balances.) Transactions
+// with these operations are built with a placeholder operation in their place, and encoded
+// by hand, splicing in the raw XDR of each new operation.
+
+// xdrWriter encodes XDR primitives.
+type xdrWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *xdrWriter) uint32(v uint32) {
+	binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *xdrWriter) int64(v int64) {
+	binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *xdrWriter) raw(b []byte) {
+	w.buf.Write(b)
+}
+
+// marshal encodes v with the vendored XDR library.
+func (w *xdrWriter) marshal(v interface{}) {
+	if w.err == nil {
+		_, w.err = xdr.Marshal(&w.buf, v)
+	}
+}
+
+// account encodes an account ID.
+func (w *xdrWriter) account(address string) {
+	var aid xdr.AccountId
+	if err := aid.SetAddress(address); err != nil {
+		w.err = errors.Wrapf(err, "invalid address: %s", address)
+		return
+	}
+
+	w.marshal(aid)
+}
+
+// asset encodes an asset.
+func (w *xdrWriter) asset(asset *Asset) {
+	xdrAsset, err := asset.ToStellarAsset().ToXDR()
+	if err != nil {
+		w.err = errors.Wrapf(err, "invalid asset: %s", asset.Code)
+		return
+	}
+
+	w.marshal(xdrAsset)
+}
+
+func (w *xdrWriter) bytes() ([]byte, error) {
+	return w.buf.Bytes(), errors.Wrap(w.err, "xdr encoding failed")
+}
+
+// rawOp is a transaction mutator that adds a placeholder operation to the transaction, and
+// records the raw XDR body (the operation type followed by the operation) to encode in its place.
+type rawOp struct {
+	tx     *Tx
+	source string
+	body   []byte
+}
+
+func (op rawOp) MutateTransaction(b *build.TransactionBuilder) error {
+	placeholder := xdr.Operation{Body: xdr.OperationBody{Type: xdr.OperationTypeInflation}}
+	if op.source != "" {
+		var aid xdr.AccountId
+		if err := aid.SetAddress(op.source); err != nil {
+			return errors.Wrapf(err, "invalid operation source: %s", op.source)
+		}
+		placeholder.SourceAccount = &aid
+	}
+
+	if op.tx.rawOps == nil {
+		op.tx.rawOps = map[int][]byte{}
+	}
+
+	op.tx.rawOps[len(b.TX.Operations)] = op.body
+	b.TX.Operations = append(b.TX.Operations, placeholder)
+	return nil
+}
+
+// rawOp returns a mutator that adds the operation with the raw XDR body to tx. The operation's
+// source is sourceAddress, or the transaction's source if it's empty.
+func (tx *Tx) rawOp(sourceAddress string, body []byte) build.TransactionMutator {
+	return rawOp{tx: tx, source: sourceAddress, body: body}
+}
+
+// hasRawOps returns true if the transaction has operations that must be encoded by hand.
+func (tx *Tx) hasRawOps() bool {
+	return len(tx.rawOps) > 0
+}
+
+// encodeTransaction encodes t, replacing the bodies of the operations at the indexes in
+// rawOps with the given raw operation bodies.
+func encodeTransaction(t *xdr.Transaction, rawOps map[int][]byte) ([]byte, error) {
+	w := &xdrWriter{}
+	w.marshal(t.SourceAccount)
+	w.marshal(t.Fee)
+	w.marshal(t.SeqNum)
+
+	if t.TimeBounds == nil {
+		w.uint32(0)
+	} else {
+		w.uint32(1)
+		w.marshal(t.TimeBounds)
+	}
+
+	w.marshal(t.Memo)
+
+	w.uint32(uint32(len(t.Operations)))
+	for i, op := range t.Operations {
+		body, ok := rawOps[i]
+		if !ok {
+			w.marshal(op)
+			continue
+		}
+
+		if op.SourceAccount == nil {
+			w.uint32(0)
+		} else {
+			w.uint32(1)
+			w.marshal(op.SourceAccount)
+		}
+		w.raw(body)
+	}
+
+	w.marshal(t.Ext)
+	return w.bytes()
+}
+
+// rawHash returns the network hash of the encoded transaction txBytes.
+func rawHash(txBytes []byte, passphrase string) [32]byte {
+	networkID := network.ID(passphrase)
+
+	w := &xdrWriter{}
+	w.raw(networkID[:])
+	w.uint32(uint32(xdr.EnvelopeTypeEnvelopeTypeTx))
+	w.raw(txBytes)
+
+	return sha256.Sum256(w.buf.Bytes())
+}
+
+// rawHashHex returns the hex-encoded hash of the transaction, for transactions with raw operations.
+func (tx *Tx) rawHashHex() (string, error) {
+	txBytes, err := encodeTransaction(tx.builder.TX, tx.rawOps)
+	if err != nil {
+		return "", err
+	}
+
+	hash := rawHash(txBytes, tx.builder.NetworkPassphrase)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// rawEnvelope encodes the transaction, signs it with every seed in seeds, adds the signatures in
+// sigs, and returns the base64-encoded envelope, for transactions with raw operations.
+func (tx *Tx) rawEnvelope(sigs []xdr.DecoratedSignature, seeds ...string) (string, error) {
+	txBytes, err := encodeTransaction(tx.builder.TX, tx.rawOps)
+	if err != nil {
+		return "", err
+	}
+
+	hash := rawHash(txBytes, tx.builder.NetworkPassphrase)
+
+	w := &xdrWriter{}
+	w.raw(txBytes)
+	w.uint32(uint32(len(seeds) + len(sigs)))
+	for _, seed := range seeds {
+		kp, err := keypair.Parse(seed)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid signer")
+		}
+
+		full, ok := kp.(*keypair.Full)
+		if !ok {
+			return "", errors.Errorf("can't sign with an address: %s", kp.Address())
+		}
+
+		sig, err := full.SignDecorated(hash[:])
+		if err != nil {
+			return "", errors.Wrap(err, "signing error")
+		}
+
+		w.marshal(sig)
+	}
+
+	for _, sig := range sigs {
+		w.marshal(sig)
+	}
+
+	envelope, err := w.bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(envelope), nil
+}
@@ -0,0 +1,44 @@
+package microstellar
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+func TestEncodeTransaction(t *testing.T) {
+	builder, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"},
+		build.Network{Passphrase: "test"},
+		build.Sequence{Sequence: 101},
+		build.MemoText{Value: "rent"},
+		build.Timebounds{MinTime: 10, MaxTime: 20},
+		build.Payment(build.Destination{AddressOrSeed: "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"}, build.NativeAmount{Amount: "10"}),
+		build.SetOptions(build.HomeDomain("qubit.sh")),
+	)
+	if err != nil {
+		t.Fatalf("could not build transaction: %v", err)
+	}
+
+	var want bytes.Buffer
+	if _, err := xdr.Marshal(&want, builder.TX); err != nil {
+		t.Fatalf("could not marshal transaction: %v", err)
+	}
+
+	got, err := encodeTransaction(builder.TX, nil)
+	if err != nil {
+		t.Fatalf("encodeTransaction failed: %v", err)
+	}
+
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("encodings differ:\n got %x\nwant %x", got, want.Bytes())
+	}
+
+	wantHash, _ := network.HashTransaction(builder.TX, "test")
+	if rawHash(got, "test") != wantHash {
+		t.Errorf("hashes differ")
+	}
+}
@@ -0,0 +1,180 @@
+package microstellar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	fedproto "github.com/stellar/go/protocols/federation"
+)
+
+// FederationError is returned by SafePay when a federated address can't be resolved, or when
+// the federation server returns a memo that can't be used.
+type FederationError struct {
+	Address string
+	Err     error
+}
+
+func (e *FederationError) Error() string {
+	return fmt.Sprintf("can't resolve %s: %v", e.Address, e.Err)
+}
+
+// MemoRequiredError is returned by SafePay when the destination account requires incoming
+// payments to carry a memo (SEP-29), and none was provided.
+type MemoRequiredError struct {
+	Address string
+}
+
+func (e *MemoRequiredError) Error() string {
+	return fmt.Sprintf("destination requires a memo: %s", e.Address)
+}
+
+// NoTrustlineError is returned by SafePay when the destination account does not trust the
+// asset being paid.
+type NoTrustlineError struct {
+	Address string
+	Asset   *Asset
+}
+
+func (e *NoTrustlineError) Error() string {
+	return fmt.Sprintf("destination %s has no trustline to %s", e.Address, e.Asset)
+}
+
+// NotAuthorizedError is returned by SafePay when the destination account trusts the asset, but
+// the issuer has not authorized it to hold the asset.
+type NotAuthorizedError struct {
+	Address string
+	Asset   *Asset
+}
+
+func (e *NotAuthorizedError) Error() string {
+	return fmt.Sprintf("destination %s is not authorized to hold %s", e.Address, e.Asset)
+}
+
+// ErrMemoConflict is returned (as the Err of a FederationError) when a payment to a federated
+// address already has a memo, and the federation server requires a different one.
+var ErrMemoConflict = errors.New("memo conflicts with the one required by the federation server")
+
+// memoRequiredKey is the account data key used by SEP-29 to flag accounts that require memos.
+const memoRequiredKey = "config.memo_required"
+
+// withFederatedMemo returns a copy of opts with the memo returned by the federation server.
+func withFederatedMemo(opts *Options, resp *fedproto.NameResponse) (*Options, error) {
+	newOpts := *opts
+
+	record, err := newFederationRecord(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.MemoType == MemoNone {
+		return &newOpts, nil
+	}
+
+	if opts.memoType != MemoNone {
+		return nil, ErrMemoConflict
+	}
+
+	return newOpts.WithFederationMemo(record), nil
+}
+
+// SafePay is like Pay, but runs a series of preflight checks against the destination before
+// submitting the payment. It:
+//
+//   - resolves federated addresses (e.g., "bob*qubit.sh"), and attaches the memo if the
+//     federation server requires one.
+//   - refuses to pay accounts that require a memo (SEP-29) if no memo is set.
+//   - verifies the destination trusts the asset, and is authorized to hold it.
+//
+// Each failed check returns a specific error type (FederationError, MemoRequiredError,
+// NoTrustlineError, or NotAuthorizedError), which you can inspect with errors.Cause.
+//
+//   err := ms.SafePay("source_seed", "bob*qubit.sh", "3", USD)
+//   if _, ok := errors.Cause(err).(*microstellar.MemoRequiredError); ok {
+//       // ask the user for a memo
+//   }
+func (ms *MicroStellar) SafePay(sourceSeed string, targetAddressOrFed string, amount string, asset *Asset, options ...*Options) error {
+	if err := asset.Validate(); err != nil {
+		return ms.wrapf(err, "can't pay")
+	}
+
+	opts := mergeOptions(options)
+	targetAddress := targetAddressOrFed
+
+	if strings.Contains(targetAddressOrFed, "*") {
+		resp, err := ms.lookupFederated(targetAddressOrFed)
+		if err != nil {
+			return ms.err(&FederationError{Address: targetAddressOrFed, Err: err})
+		}
+
+		opts, err = withFederatedMemo(opts, resp)
+		if err != nil {
+			return ms.err(&FederationError{Address: targetAddressOrFed, Err: err})
+		}
+
+		ms.debugf("SafePay", "resolved %s to %s", targetAddressOrFed, resp.AccountID)
+		targetAddress = resp.AccountID
+	}
+
+	if err := ValidAddress(targetAddress); err != nil {
+		return ms.errorf("can't pay: invalid address: %v", targetAddress)
+	}
+
+	if !ms.fake {
+		ctx := opts.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		account, err := ms.LoadAccountWithContext(ctx, targetAddress)
+		if err != nil {
+			return ms.wrapf(err, "can't pay")
+		}
+
+		if v, ok := account.GetData(memoRequiredKey); ok && string(v) == "1" && opts.memoType == MemoNone {
+			return ms.err(&MemoRequiredError{Address: targetAddress})
+		}
+
+		// Issuers can always receive their own assets.
+		if !asset.IsNative() && asset.Issuer != targetAddress {
+			if !account.hasTrustline(asset) {
+				return ms.err(&NoTrustlineError{Address: targetAddress, Asset: asset})
+			}
+
+			if !account.IsAuthorized(asset) {
+				return ms.err(&NotAuthorizedError{Address: targetAddress, Asset: asset})
+			}
+		}
+	}
+
+	return ms.Pay(sourceSeed, targetAddress, amount, asset, opts)
+}
+
+// PayToFederated resolves the federated address fedAddress (e.g., "bob*qubit.sh"), and pays amount
+// units of asset to the account it resolves to, with the memo that the federation server
+// requires, if any. Unlike SafePay, no other checks are made on the destination.
+//
+//   err := ms.PayToFederated("source_seed", "bob*qubit.sh", "3", USD)
+//
+// Returns a *FederationError if the address can't be resolved. If options already set a memo,
+// and the federation server requires one too, the payment is not sent, and the FederationError's
+// Err is ErrMemoConflict.
+func (ms *MicroStellar) PayToFederated(sourceSeed, fedAddress, amount string, asset *Asset, options ...*Options) error {
+	if !strings.Contains(fedAddress, "*") {
+		return ms.errorf("can't pay: not a federated address: %s", fedAddress)
+	}
+
+	resp, err := ms.lookupFederated(fedAddress)
+	if err != nil {
+		return ms.err(&FederationError{Address: fedAddress, Err: err})
+	}
+
+	opts, err := withFederatedMemo(mergeOptions(options), resp)
+	if err != nil {
+		return ms.err(&FederationError{Address: fedAddress, Err: err})
+	}
+
+	ms.debugf("PayToFederated", "resolved %s to %s", fedAddress, resp.AccountID)
+	return ms.Pay(sourceSeed, resp.AccountID, amount, asset, opts)
+}
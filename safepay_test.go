@@ -0,0 +1,141 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSafePayPreflight(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	memoRequired := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	untrusted := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	unauthorized := "GAD3LPHSTZHNZOJOPRS7OZ2P74VXFCP5J4QNYIGGHZ246XINHGKPJIQR"
+	USD := NewAsset("USD", issuer, Credit4Type)
+
+	accounts := map[string]string{
+		memoRequired: `"data": {"config.memo_required": "MQ=="}, "balances": [{"asset_type": "native", "balance": "10"}]`,
+		untrusted:    `"balances": [{"asset_type": "native", "balance": "10"}]`,
+		unauthorized: fmt.Sprintf(`"balances": [{"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%s",
+			"balance": "0", "limit": "100", "is_authorized": false}]`, issuer),
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		address := strings.TrimPrefix(r.URL.Path, "/accounts/")
+		account, ok := accounts[address]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "1", %s}`, address, address, account)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	err := ms.SafePay(source, memoRequired, "1", NativeAsset)
+	if _, ok := errors.Cause(err).(*MemoRequiredError); !ok {
+		t.Errorf("want MemoRequiredError, got: %v", err)
+	}
+
+	err = ms.SafePay(source, untrusted, "1", USD)
+	if _, ok := errors.Cause(err).(*NoTrustlineError); !ok {
+		t.Errorf("want NoTrustlineError, got: %v", err)
+	}
+
+	requests = 0
+	err = ms.SafePay(source, unauthorized, "1", USD)
+	if _, ok := errors.Cause(err).(*NotAuthorizedError); !ok {
+		t.Errorf("want NotAuthorizedError, got: %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("want the destination loaded once, got %d requests", requests)
+	}
+
+	if err := ms.SafePay(source, "not an address", "1", USD); err == nil {
+		t.Errorf("SafePay should reject invalid addresses")
+	}
+}
+
+func TestSafePayFake(t *testing.T) {
+	ms := New("fake")
+
+	err := ms.SafePay("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK",
+		"GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "1", NativeAsset)
+
+	if err != nil {
+		t.Errorf("SafePay failed: %v", err)
+	}
+}
+
+func TestPayToFederated(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	submissions := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/.well-known/stellar.toml":
+			fmt.Fprint(w, `FEDERATION_SERVER="https://fed.qubit.sh/federation"`)
+		case r.URL.Path == "/federation" && r.URL.Query().Get("q") == "bob*qubit.sh":
+			fmt.Fprint(w, `{"stellar_address": "bob*qubit.sh", "account_id": "`+bob+`", "memo_type": "id", "memo": "42"}`)
+		case r.URL.Path == "/federation":
+			http.NotFound(w, r)
+		case r.Method == "POST":
+			submissions++
+			fmt.Fprint(w, testTxSuccess)
+		default:
+			fmt.Fprint(w, `{"id": "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R", "sequence": "100"}`)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"}).WithHTTPClient(&http.Client{Transport: &redirectTransport{target}})
+
+	if err := ms.PayToFederated(source, "bob*qubit.sh", "1", NativeAsset); err != nil {
+		t.Fatalf("PayToFederated failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastEnvelopeXDR()
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("can't decode payload: %v", err)
+	}
+
+	if id, ok := txe.Tx.Memo.GetId(); !ok || id != 42 {
+		t.Errorf("want memo ID 42, got %+v", txe.Tx.Memo)
+	}
+
+	if dest := txe.Tx.Operations[0].Body.MustPaymentOp().Destination; dest.Address() != bob {
+		t.Errorf("want payment to %s, got %s", bob, dest.Address())
+	}
+
+	err = ms.PayToFederated(source, "bob*qubit.sh", "1", NativeAsset, Opts().WithMemoText("hi"))
+	if fedErr, ok := errors.Cause(err).(*FederationError); !ok || fedErr.Err != ErrMemoConflict {
+		t.Errorf("want ErrMemoConflict, got: %v", err)
+	}
+
+	_, ok := errors.Cause(ms.PayToFederated(source, "alice*qubit.sh", "1", NativeAsset)).(*FederationError)
+	if !ok {
+		t.Errorf("want FederationError for unknown address")
+	}
+
+	if err := ms.PayToFederated(source, bob, "1", NativeAsset); err == nil {
+		t.Errorf("PayToFederated should reject non-federated addresses")
+	}
+
+	if submissions != 1 {
+		t.Errorf("want 1 submission, got %d", submissions)
+	}
+}
@@ -0,0 +1,199 @@
+package microstellar
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+)
+
+// ErrInvalidChallenge is returned by VerifyChallengeTx if the challenge transaction is malformed,
+// expired, or not signed by the server. Use errors.Cause to check for it.
+var ErrInvalidChallenge = errors.New("invalid challenge transaction")
+
+// SEP-10 challenge parameters.
+const (
+	challengeNonceSize     = 48 // 64 bytes once base64-encoded
+	challengeClockSkew     = 5 * time.Minute
+	webAuthDomainDataName  = "web_auth_domain"
+	challengeDataKeySuffix = " auth"
+)
+
+// BuildChallengeTx returns a base64-encoded SEP-10 challenge transaction for the account at
+// clientAddress, signed by serverSeed. The challenge has a random 64-byte nonce in a manage_data
+// operation sourced from the client, is valid for timeout, and has a sequence number of 0 so it
+// can never be submitted. If webAuthDomain is set, it's added in a web_auth_domain operation
+// sourced from the server.
+//
+//   challenge, err := ms.BuildChallengeTx(serverSeed, clientAddress, "qubit.sh", "auth.qubit.sh", 5*time.Minute)
+//
+// The client signs the challenge (e.g., with SignTransaction) and returns it to the server,
+// which checks it with VerifyChallengeTx.
+func (ms *MicroStellar) BuildChallengeTx(serverSeed, clientAddress, homeDomain, webAuthDomain string, timeout time.Duration) (string, error) {
+	if err := ValidSeed(serverSeed); err != nil {
+		return "", ms.errorf("can't build challenge: invalid server seed")
+	}
+
+	if err := ValidAddress(clientAddress); err != nil {
+		return "", ms.errorf("can't build challenge: invalid client address: %s", clientAddress)
+	}
+
+	if homeDomain == "" {
+		return "", ms.errorf("can't build challenge: missing home domain")
+	}
+
+	if timeout <= 0 {
+		return "", ms.errorf("can't build challenge: timeout must be positive")
+	}
+
+	nonce := make([]byte, challengeNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", ms.wrapf(err, "can't build challenge: can't generate nonce")
+	}
+
+	now := time.Now()
+	muts := []build.TransactionMutator{
+		sourceAccount(serverSeed),
+		build.Sequence{Sequence: 0},
+		build.Network{Passphrase: ms.NetworkPassphrase()},
+		build.Timebounds{MinTime: uint64(now.Unix()), MaxTime: uint64(now.Add(timeout).Unix())},
+		build.SetData(homeDomain+challengeDataKeySuffix, []byte(base64.StdEncoding.EncodeToString(nonce)),
+			build.SourceAccount{AddressOrSeed: clientAddress}),
+	}
+
+	if webAuthDomain != "" {
+		muts = append(muts, build.SetData(webAuthDomainDataName, []byte(webAuthDomain),
+			build.SourceAccount{AddressOrSeed: serverSeed}))
+	}
+
+	builder, err := build.Transaction(muts...)
+	if err != nil {
+		return "", ms.wrapf(err, "can't build challenge")
+	}
+
+	txe, err := builder.Sign(serverSeed)
+	if err != nil {
+		return "", ms.wrapf(err, "can't sign challenge")
+	}
+
+	b64, err := txe.Base64()
+	if err != nil {
+		return "", ms.wrapf(err, "can't encode challenge")
+	}
+
+	return b64, ms.success()
+}
+
+// VerifyChallengeTx checks the SEP-10 challenge transaction b64Tx, as returned by the client, and
+// returns the client's address. It checks that the challenge was issued by serverAddress for
+// homeDomain, that it hasn't expired, that its operations are well-formed, and that it's signed
+// by the server. Errors have ErrInvalidChallenge as their cause.
+//
+//   clientAddress, err := ms.VerifyChallengeTx(signedChallenge, serverAddress, "qubit.sh")
+//   if errors.Cause(err) == microstellar.ErrInvalidChallenge {
+//       // reject the client
+//   }
+//
+// VerifyChallengeTx doesn't check the client's signatures, since they depend on the client
+// account's signers. Use VerifySignatures with the client's account for that.
+func (ms *MicroStellar) VerifyChallengeTx(b64Tx, serverAddress, homeDomain string) (string, error) {
+	if err := ValidAddress(serverAddress); err != nil {
+		return "", ms.errorf("can't verify challenge: invalid server address: %s", serverAddress)
+	}
+
+	invalid := func(format string, args ...interface{}) (string, error) {
+		return "", ms.wrapf(ErrInvalidChallenge, format, args...)
+	}
+
+	if err := checkEnvelopeType(b64Tx); err != nil {
+		return invalid("%v", err)
+	}
+
+	txe, err := DecodeTx(b64Tx)
+	if err != nil {
+		return invalid("can't decode transaction: %v", err)
+	}
+
+	tx := txe.Tx
+	if tx.SourceAccount.Address() != serverAddress {
+		return invalid("transaction source is not the server: %s", tx.SourceAccount.Address())
+	}
+
+	if tx.SeqNum != 0 {
+		return invalid("sequence number must be 0, got %d", tx.SeqNum)
+	}
+
+	if tx.TimeBounds == nil || tx.TimeBounds.MaxTime == 0 {
+		return invalid("missing time bounds")
+	}
+
+	now := time.Now()
+	minTime := time.Unix(int64(tx.TimeBounds.MinTime), 0)
+	maxTime := time.Unix(int64(tx.TimeBounds.MaxTime), 0)
+	if now.Add(challengeClockSkew).Before(minTime) || now.After(maxTime) {
+		return invalid("challenge expired or not yet valid (valid from %v to %v)", minTime, maxTime)
+	}
+
+	if len(tx.Operations) == 0 {
+		return invalid("no operations")
+	}
+
+	// The first operation carries the nonce, and is sourced from the client.
+	first := tx.Operations[0]
+	data, ok := first.Body.GetManageDataOp()
+	if !ok {
+		return invalid("first operation is not manage_data")
+	}
+
+	if first.SourceAccount == nil {
+		return invalid("first operation has no source account")
+	}
+
+	if string(data.DataName) != homeDomain+challengeDataKeySuffix {
+		return invalid("unexpected data key: %q", data.DataName)
+	}
+
+	if data.DataValue == nil || len(*data.DataValue) != base64.StdEncoding.EncodedLen(challengeNonceSize) {
+		return invalid("nonce must be %d bytes", base64.StdEncoding.EncodedLen(challengeNonceSize))
+	}
+
+	if nonce, err := base64.StdEncoding.DecodeString(string(*data.DataValue)); err != nil || len(nonce) != challengeNonceSize {
+		return invalid("nonce is not base64-encoded")
+	}
+
+	// Any further operations must be manage_data operations sourced from the server.
+	for i, op := range tx.Operations[1:] {
+		if _, ok := op.Body.GetManageDataOp(); !ok {
+			return invalid("operation %d is not manage_data", i+1)
+		}
+
+		if op.SourceAccount == nil || op.SourceAccount.Address() != serverAddress {
+			return invalid("operation %d is not sourced from the server", i+1)
+		}
+	}
+
+	passphrase := ms.NetworkPassphrase()
+	hash, err := network.HashTransaction(&tx, passphrase)
+	if err != nil {
+		return invalid("can't hash transaction: %v", err)
+	}
+
+	server, _ := keypair.Parse(serverAddress)
+	signed := false
+	for _, sig := range txe.Signatures {
+		if sig.Hint == server.Hint() && server.Verify(hash[:], sig.Signature) == nil {
+			signed = true
+			break
+		}
+	}
+
+	if !signed {
+		return invalid("not signed by the server")
+	}
+
+	return first.SourceAccount.Address(), ms.success()
+}
@@ -0,0 +1,100 @@
+package microstellar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+)
+
+func TestChallengeTx(t *testing.T) {
+	server, _ := keypair.Random()
+	client, _ := keypair.Random()
+
+	ms := New("test")
+	challenge, err := ms.BuildChallengeTx(server.Seed(), client.Address(), "qubit.sh", "auth.qubit.sh", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("BuildChallengeTx failed: %v", err)
+	}
+
+	txe, err := DecodeTx(challenge)
+	if err != nil {
+		t.Fatalf("bad challenge: %v", err)
+	}
+
+	if txe.Tx.SeqNum != 0 || len(txe.Tx.Operations) != 2 || len(txe.Signatures) != 1 {
+		t.Errorf("unexpected challenge: %+v", txe.Tx)
+	}
+
+	signed, err := ms.SignTransaction(challenge, client.Seed())
+	if err != nil {
+		t.Fatalf("SignTransaction failed: %v", err)
+	}
+
+	address, err := ms.VerifyChallengeTx(signed, server.Address(), "qubit.sh")
+	if err != nil || address != client.Address() {
+		t.Errorf("want %s, got %s (%v)", client.Address(), address, err)
+	}
+
+	// Wrong server or home domain.
+	imposter, _ := keypair.Random()
+	if _, err := ms.VerifyChallengeTx(signed, imposter.Address(), "qubit.sh"); errors.Cause(err) != ErrInvalidChallenge {
+		t.Errorf("want ErrInvalidChallenge for wrong server, got %v", err)
+	}
+
+	if _, err := ms.VerifyChallengeTx(signed, server.Address(), "example.com"); errors.Cause(err) != ErrInvalidChallenge {
+		t.Errorf("want ErrInvalidChallenge for wrong home domain, got %v", err)
+	}
+
+	// Challenges on other networks don't verify.
+	if _, err := New("public").VerifyChallengeTx(signed, server.Address(), "qubit.sh"); errors.Cause(err) != ErrInvalidChallenge {
+		t.Errorf("want ErrInvalidChallenge for wrong network, got %v", err)
+	}
+
+	// Hand-built challenges that are expired, unsigned, or have a bad nonce.
+	now := time.Now()
+	nonce := []byte("0123456789012345678901234567890123456789012345678901234567890123")
+	challenges := map[string]struct {
+		muts  []build.TransactionMutator
+		seeds []string
+	}{
+		"expired": {[]build.TransactionMutator{
+			build.Timebounds{MinTime: uint64(now.Add(-time.Hour).Unix()), MaxTime: uint64(now.Add(-time.Minute).Unix())},
+			build.SetData("qubit.sh auth", nonce, build.SourceAccount{AddressOrSeed: client.Address()}),
+		}, []string{server.Seed()}},
+		"unsigned": {[]build.TransactionMutator{
+			build.Timebounds{MinTime: uint64(now.Unix()), MaxTime: uint64(now.Add(time.Minute).Unix())},
+			build.SetData("qubit.sh auth", nonce, build.SourceAccount{AddressOrSeed: client.Address()}),
+		}, []string{client.Seed()}},
+		"short nonce": {[]build.TransactionMutator{
+			build.Timebounds{MinTime: uint64(now.Unix()), MaxTime: uint64(now.Add(time.Minute).Unix())},
+			build.SetData("qubit.sh auth", nonce[:32], build.SourceAccount{AddressOrSeed: client.Address()}),
+		}, []string{server.Seed()}},
+		"client-sourced extra op": {[]build.TransactionMutator{
+			build.Timebounds{MinTime: uint64(now.Unix()), MaxTime: uint64(now.Add(time.Minute).Unix())},
+			build.SetData("qubit.sh auth", nonce, build.SourceAccount{AddressOrSeed: client.Address()}),
+			build.SetData("web_auth_domain", []byte("auth.qubit.sh"), build.SourceAccount{AddressOrSeed: client.Address()}),
+		}, []string{server.Seed()}},
+	}
+
+	for name, c := range challenges {
+		muts := append([]build.TransactionMutator{
+			build.SourceAccount{AddressOrSeed: server.Address()},
+			build.Sequence{Sequence: 0},
+			build.TestNetwork,
+		}, c.muts...)
+
+		builder, err := build.Transaction(muts...)
+		if err != nil {
+			t.Fatalf("%s: can't build challenge: %v", name, err)
+		}
+
+		txe, _ := builder.Sign(c.seeds...)
+		b64, _ := txe.Base64()
+		if _, err := ms.VerifyChallengeTx(b64, server.Address(), "qubit.sh"); errors.Cause(err) != ErrInvalidChallenge {
+			t.Errorf("%s: want ErrInvalidChallenge, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package microstellar
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+)
+
+// XDR operation types for sponsorship.
+const (
+	opBeginSponsoringFutureReserves = 16
+	opEndSponsoringFutureReserves   = 17
+	opRevokeSponsorship             = 18
+)
+
+// SponsoredEntryType is the type of a sponsored ledger entry.
+type SponsoredEntryType int
+
+// Sponsored entry types (these match the XDR ledger entry types.)
+const (
+	SponsoredEntryAccount          = SponsoredEntryType(0)
+	SponsoredEntryTrustLine        = SponsoredEntryType(1)
+	SponsoredEntryOffer            = SponsoredEntryType(2)
+	SponsoredEntryData             = SponsoredEntryType(3)
+	SponsoredEntryClaimableBalance = SponsoredEntryType(4)
+	SponsoredEntrySigner           = SponsoredEntryType(-1) // signers aren't ledger entries
+)
+
+// SponsoredEntry identifies a sponsored ledger entry (or signer), for RevokeSponsorship. Use the
+// Sponsored*Entry functions to build them.
+type SponsoredEntry struct {
+	Type      SponsoredEntryType
+	Account   string // the account that owns the entry (the seller for offers)
+	Asset     *Asset // trust lines
+	OfferID   int64  // offers
+	DataName  string // data entries
+	BalanceID string // claimable balances
+	Signer    string // signers: the signer's address (G...), pre-auth hash (T...), or hash (X...)
+}
+
+// SponsoredAccountEntry returns the account entry for address.
+func SponsoredAccountEntry(address string) *SponsoredEntry {
+	return &SponsoredEntry{Type: SponsoredEntryAccount, Account: address}
+}
+
+// SponsoredTrustLineEntry returns the entry for address's trust line to asset.
+func SponsoredTrustLineEntry(address string, asset *Asset) *SponsoredEntry {
+	return &SponsoredEntry{Type: SponsoredEntryTrustLine, Account: address, Asset: asset}
+}
+
+// SponsoredOfferEntry returns the entry for seller's offer with ID offerID.
+func SponsoredOfferEntry(seller string, offerID int64) *SponsoredEntry {
+	return &SponsoredEntry{Type: SponsoredEntryOffer, Account: seller, OfferID: offerID}
+}
+
+// SponsoredDataEntry returns the entry for address's data entry with key name.
+func SponsoredDataEntry(address string, name string) *SponsoredEntry {
+	return &SponsoredEntry{Type: SponsoredEntryData, Account: address, DataName: name}
+}
+
+// SponsoredClaimableBalanceEntry returns the entry for the claimable balance with ID balanceID.
+func SponsoredClaimableBalanceEntry(balanceID string) *SponsoredEntry {
+	return &SponsoredEntry{Type: SponsoredEntryClaimableBalance, BalanceID: balanceID}
+}
+
+// SponsoredSignerEntry returns the signer signer on address's account.
+func SponsoredSignerEntry(address string, signer string) *SponsoredEntry {
+	return &SponsoredEntry{Type: SponsoredEntrySigner, Account: address, Signer: signer}
+}
+
+// revokeSponsorshipOp returns the raw XDR body of a REVOKE_SPONSORSHIP operation for entry.
+func revokeSponsorshipOp(entry *SponsoredEntry) ([]byte, error) {
+	w := &xdrWriter{}
+	w.uint32(opRevokeSponsorship)
+
+	if entry.Type == SponsoredEntrySigner {
+		var key xdr.SignerKey
+		if err := key.SetAddress(entry.Signer); err != nil {
+			return nil, errors.Wrapf(err, "invalid signer: %s", entry.Signer)
+		}
+
+		// REVOKE_SPONSORSHIP_SIGNER
+		w.uint32(1)
+		w.account(entry.Account)
+		w.marshal(key)
+		return w.bytes()
+	}
+
+	// REVOKE_SPONSORSHIP_LEDGER_ENTRY
+	w.uint32(0)
+	w.uint32(uint32(entry.Type))
+
+	switch entry.Type {
+	case SponsoredEntryAccount:
+		w.account(entry.Account)
+	case SponsoredEntryTrustLine:
+		if entry.Asset == nil || entry.Asset.IsNative() {
+			return nil, errors.Errorf("trust lines need a non-native asset")
+		}
+		if err := entry.Asset.Validate(); err != nil {
+			return nil, err
+		}
+		w.account(entry.Account)
+		w.asset(entry.Asset)
+	case SponsoredEntryOffer:
+		w.account(entry.Account)
+		w.int64(entry.OfferID)
+	case SponsoredEntryData:
+		if entry.DataName == "" || len(entry.DataName) > 64 {
+			return nil, errors.Errorf("invalid data key: %q", entry.DataName)
+		}
+		w.account(entry.Account)
+		w.marshal(xdr.String64(entry.DataName))
+	case SponsoredEntryClaimableBalance:
+		id, err := parseBalanceID(entry.BalanceID)
+		if err != nil {
+			return nil, err
+		}
+		w.raw(id)
+	default:
+		return nil, errors.Errorf("unknown sponsored entry type: %d", entry.Type)
+	}
+
+	return w.bytes()
+}
+
+// SponsorReserves pays the reserves for the ledger entries that the operations added by ops create
+// for the account at sponsoredAddress, so the account doesn't need to hold lumens for them. The
+// operations are submitted in a single transaction, between the begin_sponsoring_future_reserves
+// and end_sponsoring_future_reserves operations that sponsorship needs.
+//
+// Use the MicroStellar instance passed to ops to add the operations, as you would after Start().
+// Operations keep their source accounts, so trust lines and signers are added to the sponsored
+// account. ops must not call Start, Submit, or Payload.
+//
+//   // Create Bob's account, and a trustline to USD, without Bob paying any reserves.
+//   err := ms.SponsorReserves(sponsorSeed, bobSeed, func(ms *microstellar.MicroStellar) {
+//       ms.FundAccount(sponsorSeed, bobAddress, "0")
+//       ms.CreateTrustLine(bobSeed, USD, "")
+//   })
+//
+// The sponsored account must also sign the transaction. If sponsoredAddress is a seed, it's
+// used to sign. Otherwise, pass in its seed with Options.WithSponsoredSeed, or all the signers
+// with Options.WithSigner.
+func (ms *MicroStellar) SponsorReserves(sponsorSeed string, sponsoredAddress string, ops func(*MicroStellar), options ...*Options) error {
+	if !ValidAddressOrSeed(sponsorSeed) {
+		return ms.errorf("can't sponsor reserves: invalid sponsor address or seed: %s", sponsorSeed)
+	}
+
+	if !ValidAddressOrSeed(sponsoredAddress) {
+		return ms.errorf("can't sponsor reserves: invalid sponsored address or seed: %s", sponsoredAddress)
+	}
+
+	if ops == nil {
+		return ms.errorf("can't sponsor reserves: no operations")
+	}
+
+	sponsor, _ := keypair.Parse(sponsorSeed)
+	sponsored, _ := keypair.Parse(sponsoredAddress)
+	if sponsor.Address() == sponsored.Address() {
+		return ms.errorf("can't sponsor reserves: accounts can't sponsor themselves")
+	}
+
+	sponsoredSeed := mergeOptions(options).sponsoredSeed
+	if ValidSeed(sponsoredAddress) == nil {
+		sponsoredSeed = sponsoredAddress
+	}
+
+	if sponsoredSeed != "" {
+		if kp, err := keypair.Parse(sponsoredSeed); err != nil || ValidSeed(sponsoredSeed) != nil || kp.Address() != sponsored.Address() {
+			return ms.errorf("can't sponsor reserves: sponsored seed doesn't match %s", sponsored.Address())
+		}
+	}
+
+	ms.mu.Lock()
+	inProgress := ms.tx != nil
+	ms.mu.Unlock()
+	if inProgress {
+		return ms.errorf("can't sponsor reserves: a multi-op transaction is already in progress")
+	}
+
+	ms.Start(sponsorSeed, options...)
+	tx := ms.getTx()
+	tx.opSources = true
+
+	w := &xdrWriter{}
+	w.uint32(opBeginSponsoringFutureReserves)
+	w.account(sponsored.Address())
+	begin, err := w.bytes()
+	if err != nil {
+		ms.closeTx(tx)
+		return ms.wrapf(err, "can't sponsor reserves")
+	}
+
+	tx.Build(sourceAccount(sponsorSeed), tx.rawOp("", begin))
+	numOps := len(tx.ops)
+	ms.success()
+
+	ops(ms)
+
+	if ms.getTx() != tx {
+		ms.closeTx(tx)
+		return ms.errorf("can't sponsor reserves: ops must not start or close transactions")
+	}
+
+	if err := ms.Err(); err != nil {
+		ms.closeTx(tx)
+		return ms.wrapf(err, "can't sponsor reserves")
+	}
+
+	if len(tx.ops) == numOps {
+		ms.closeTx(tx)
+		return ms.errorf("can't sponsor reserves: no operations")
+	}
+
+	w = &xdrWriter{}
+	w.uint32(opEndSponsoringFutureReserves)
+	tx.Build(sourceAccount(sponsorSeed), tx.rawOp(sponsored.Address(), w.buf.Bytes()))
+
+	signers := []string{sponsorSeed}
+	if sponsoredSeed != "" {
+		signers = append(signers, sponsoredSeed)
+	}
+
+	tx.signAndSubmit(signers...)
+	ms.closeTx(tx)
+	return ms.err(tx.Err())
+}
+
+// CreateSponsoredAccount creates the account at newAddress with startingBalance lumens (which can be
+// "0"), and has sponsorSeed pay its reserves, so users without lumens can be onboarded. The account
+// creation is wrapped in begin_sponsoring_future_reserves and end_sponsoring_future_reserves
+// operations in a single transaction.
+//
+// The new account must co-sign the transaction: pass in its seed with Options.WithSponsoredSeed
+// (or as newAddress.)
+//
+//   err := ms.CreateSponsoredAccount(sponsorSeed, bob.Address, "0",
+//       microstellar.Opts().WithSponsoredSeed(bob.Seed))
+func (ms *MicroStellar) CreateSponsoredAccount(sponsorSeed string, newAddress string, startingBalance string, options ...*Options) error {
+	if !ValidAddressOrSeed(newAddress) {
+		return ms.errorf("can't create sponsored account: invalid address or seed: %s", newAddress)
+	}
+
+	if err := ValidAmount(startingBalance); err != nil {
+		return ms.wrapf(err, "can't create sponsored account")
+	}
+
+	if ValidSeed(newAddress) != nil && mergeOptions(options).sponsoredSeed == "" {
+		return ms.errorf("can't create sponsored account: missing seed for %s (see Options.WithSponsoredSeed)", newAddress)
+	}
+
+	newAccount, _ := keypair.Parse(newAddress)
+	return ms.SponsorReserves(sponsorSeed, newAddress, func(ms *MicroStellar) {
+		ms.FundAccount(sponsorSeed, newAccount.Address(), startingBalance)
+	}, options...)
+}
+
+// RevokeSponsorship revokes sourceSeed's sponsorship of entry, which transfers the entry's reserve
+// back to its owner (or, if the owner is itself sponsored, to its sponsor.)
+//
+//   err := ms.RevokeSponsorship(sponsorSeed, microstellar.SponsoredTrustLineEntry(bobAddress, USD))
+//
+// Revoking fails if the owner can't pay the reserve.
+func (ms *MicroStellar) RevokeSponsorship(sourceSeed string, entry *SponsoredEntry, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't revoke sponsorship: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if entry == nil {
+		return ms.errorf("can't revoke sponsorship: no entry")
+	}
+
+	if entry.Type != SponsoredEntryClaimableBalance {
+		if err := ValidAddress(entry.Account); err != nil {
+			return ms.errorf("can't revoke sponsorship: invalid address: %s", entry.Account)
+		}
+	}
+
+	body, err := revokeSponsorshipOp(entry)
+	if err != nil {
+		return ms.wrapf(err, "can't revoke sponsorship")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(mergeOptions(options))
+	}
+
+	tx.Build(sourceAccount(sourceSeed), tx.rawOp("", body))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// sponsorPageSize is the number of records requested per page when walking the
+// entries sponsored by an account.
+const sponsorPageSize = 200
+
+// sponsoredSigner is a signer record that carries its (optional) sponsor.
+type sponsoredSigner struct {
+	Key     string `json:"key"`
+	Sponsor string `json:"sponsor"`
+}
+
+// sponsoredBalance is a trustline record that carries its (optional) sponsor.
+type sponsoredBalance struct {
+	AssetType string `json:"asset_type"`
+	Sponsor   string `json:"sponsor"`
+}
+
+// sponsoredAccount is the subset of a Horizon account record needed to work out which
+// of its entries are sponsored.
+type sponsoredAccount struct {
+	ID       string             `json:"id"`
+	PT       string             `json:"paging_token"`
+	Sponsor  string             `json:"sponsor"`
+	Balances []sponsoredBalance `json:"balances"`
+	Signers  []sponsoredSigner  `json:"signers"`
+}
+
+// sponsoredClaimableBalance is the subset of a Horizon claimable balance record needed
+// to work out its reserve.
+type sponsoredClaimableBalance struct {
+	ID        string            `json:"id"`
+	PT        string            `json:"paging_token"`
+	Sponsor   string            `json:"sponsor"`
+	Claimants []json.RawMessage `json:"claimants"`
+}
+
+type sponsoredAccountsPage struct {
+	Embedded struct {
+		Records []sponsoredAccount `json:"records"`
+	} `json:"_embedded"`
+}
+
+type sponsoredClaimableBalancesPage struct {
+	Embedded struct {
+		Records []sponsoredClaimableBalance `json:"records"`
+	} `json:"_embedded"`
+}
+
+type latestLedgerPage struct {
+	Embedded struct {
+		Records []struct {
+			BaseReserve int32 `json:"base_reserve_in_stroops"`
+		} `json:"records"`
+	} `json:"_embedded"`
+}
+
+// loadBaseReserve returns the base reserve (in stroops) of the latest ledger.
+func loadBaseReserve(logger Logger, client *horizon.Client) (int64, error) {
+	var page latestLedgerPage
+	if err := getJSON(logger, client, "/ledgers?order=desc&limit=1", &page); err != nil {
+		return 0, errors.Wrap(err, "can't load latest ledger")
+	}
+
+	if len(page.Embedded.Records) == 0 {
+		return 0, errors.New("no ledgers found")
+	}
+
+	return int64(page.Embedded.Records[0].BaseReserve), nil
+}
+
+// sponsoredPath returns the path for a page of resource records sponsored by sponsor.
+func sponsoredPath(resource string, sponsor string, cursor string) string {
+	query := url.Values{}
+	query.Add("sponsor", sponsor)
+	query.Add("limit", fmt.Sprintf("%d", sponsorPageSize))
+	query.Add("order", "asc")
+	if cursor != "" {
+		query.Add("cursor", cursor)
+	}
+
+	return fmt.Sprintf("/%s?%s", resource, query.Encode())
+}
+
+// countSponsoredReserves returns the number of base reserves and the number of ledger
+// entries in account that are sponsored by sponsor. A sponsored account costs its
+// sponsor two base reserves, every other sponsored entry costs one.
+func countSponsoredReserves(account sponsoredAccount, sponsor string) (reserves int64, entries int) {
+	if account.Sponsor == sponsor {
+		reserves += 2
+		entries++
+	}
+
+	for _, balance := range account.Balances {
+		if balance.AssetType != string(NativeType) && balance.Sponsor == sponsor {
+			reserves++
+			entries++
+		}
+	}
+
+	for _, signer := range account.Signers {
+		if signer.Sponsor == sponsor {
+			reserves++
+			entries++
+		}
+	}
+
+	return reserves, entries
+}
+
+// SponsoredReservesTotal returns the total amount of lumens (as a string) that sponsorAddress
+// has locked up in reserves on behalf of other accounts, along with the number of sponsored
+// entries. It pages through every account and claimable balance that sponsorAddress sponsors.
+//
+// Accounts, trustlines, signers, and claimable balances are counted. Sponsored offers and
+// data entries are not reported by Horizon's account records, and are not included.
+func (ms *MicroStellar) SponsoredReservesTotal(sponsorAddress string, options ...*Options) (string, int, error) {
+	if err := ValidAddress(sponsorAddress); err != nil {
+		return "", 0, ms.errorf("invalid sponsor address: %s", sponsorAddress)
+	}
+
+	ms.debugf("SponsoredReservesTotal", "loading entries sponsored by %s", sponsorAddress)
+	if ms.fake {
+		return "0", 0, ms.success()
+	}
+
+	opt := mergeOptions(options)
+	client := clientWithContext(opt.ctx, ms.getTx().GetClient())
+
+	baseReserve, err := loadBaseReserve(ms.logger(), client)
+	if err != nil {
+		return "", 0, ms.wrapf(err, "SponsoredReservesTotal")
+	}
+
+	reserves := int64(0)
+	entries := 0
+
+	cursor := ""
+	for {
+		var page sponsoredAccountsPage
+		if err := getJSON(ms.logger(), client, sponsoredPath("accounts", sponsorAddress, cursor), &page); err != nil {
+			return "", 0, ms.wrapf(err, "SponsoredReservesTotal: can't load sponsored accounts")
+		}
+
+		for _, account := range page.Embedded.Records {
+			r, e := countSponsoredReserves(account, sponsorAddress)
+			reserves += r
+			entries += e
+		}
+
+		if len(page.Embedded.Records) < sponsorPageSize {
+			break
+		}
+		cursor = page.Embedded.Records[len(page.Embedded.Records)-1].PT
+	}
+
+	cursor = ""
+	for {
+		var page sponsoredClaimableBalancesPage
+		if err := getJSON(ms.logger(), client, sponsoredPath("claimable_balances", sponsorAddress, cursor), &page); err != nil {
+			return "", 0, ms.wrapf(err, "SponsoredReservesTotal: can't load sponsored claimable balances")
+		}
+
+		for _, balance := range page.Embedded.Records {
+			// A claimable balance reserves one base reserve per claimant.
+			reserves += int64(len(balance.Claimants))
+			entries++
+		}
+
+		if len(page.Embedded.Records) < sponsorPageSize {
+			break
+		}
+		cursor = page.Embedded.Records[len(page.Embedded.Records)-1].PT
+	}
+
+	return ToAmountString(reserves * baseReserve), entries, ms.success()
+}
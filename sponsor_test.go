@@ -0,0 +1,290 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+)
+
+func TestSponsorReserves(t *testing.T) {
+	sponsorSeed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob, _ := keypair.Random()
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	var submitted string
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		submitted = r.FormValue("tx")
+		fmt.Fprint(w, testTxSuccess)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	err := ms.SponsorReserves(sponsorSeed, bob.Seed(), func(ms *MicroStellar) {
+		ms.FundAccount(sponsorSeed, bob.Address(), "0")
+		ms.CreateTrustLine(bob.Seed(), USD, "")
+	})
+	if err != nil {
+		t.Fatalf("SponsorReserves failed: %v", ErrorString(err))
+	}
+
+	envelope, _ := base64.StdEncoding.DecodeString(submitted)
+	if len(envelope) < 4+2*72 {
+		t.Fatalf("bad envelope: %q", submitted)
+	}
+
+	// The envelope ends with two decorated signatures: the sponsor's and Bob's.
+	txBytes := envelope[:len(envelope)-4-2*72]
+	hash := rawHash(txBytes, "test")
+	sponsor, _ := keypair.Parse(sponsorSeed)
+	for i, kp := range []keypair.KP{sponsor, bob} {
+		sig := envelope[len(envelope)-2*72+i*72+8 : len(envelope)-2*72+(i+1)*72]
+		if err := kp.Verify(hash[:], sig); err != nil {
+			t.Errorf("bad signature %d: %v", i, err)
+		}
+	}
+
+	w := &xdrWriter{}
+	w.account(bob.Address())
+	bobBytes, _ := w.bytes()
+
+	numOps, _ := hex.DecodeString("00000004")
+	begin, _ := hex.DecodeString("00000000" + "00000010" + hex.EncodeToString(bobBytes))
+	end, _ := hex.DecodeString("00000001" + hex.EncodeToString(bobBytes) + "00000011")
+
+	opsStart := bytes.Index(txBytes, numOps)
+	if opsStart < 0 || !bytes.HasPrefix(txBytes[opsStart+4:], begin) {
+		t.Errorf("want begin_sponsoring_future_reserves first: %x", txBytes)
+	}
+
+	// The last op is followed by the (empty) transaction extension.
+	if !bytes.HasSuffix(txBytes, append(end, 0, 0, 0, 0)) {
+		t.Errorf("want end_sponsoring_future_reserves last: %x", txBytes)
+	}
+
+	// Sandwiches need operations, and ops must not close the transaction.
+	if err := ms.SponsorReserves(sponsorSeed, bob.Address(), func(ms *MicroStellar) {}); err == nil {
+		t.Errorf("want error for empty sponsorship")
+	}
+
+	if err := ms.SponsorReserves(sponsorSeed, bob.Address(), func(ms *MicroStellar) {
+		ms.FundAccount(sponsorSeed, bob.Address(), "0")
+		ms.Submit()
+	}); err == nil {
+		t.Errorf("want error for ops that submit")
+	}
+
+	if err := ms.SponsorReserves(sponsorSeed, bob.Address(), func(ms *MicroStellar) {
+		ms.FundAccount(sponsorSeed, "BAD", "0")
+	}); err == nil {
+		t.Errorf("want error for failed ops")
+	}
+
+	if err := ms.SponsorReserves(sponsorSeed, sponsor.Address(), func(ms *MicroStellar) {}); err == nil {
+		t.Errorf("want error for self-sponsorship")
+	}
+
+	ms.Start(sponsorSeed)
+	if err := ms.SponsorReserves(sponsorSeed, bob.Address(), func(ms *MicroStellar) {}); err == nil {
+		t.Errorf("want error for nested multi-op transactions")
+	}
+}
+
+func TestCreateSponsoredAccount(t *testing.T) {
+	sponsorSeed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob, _ := keypair.Random()
+	other, _ := keypair.Random()
+
+	var submitted string
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		submitted = r.FormValue("tx")
+		fmt.Fprint(w, testTxSuccess)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.CreateSponsoredAccount(sponsorSeed, bob.Address(), "0", Opts().WithSponsoredSeed(bob.Seed())); err != nil {
+		t.Fatalf("CreateSponsoredAccount failed: %v", ErrorString(err))
+	}
+
+	envelope, _ := base64.StdEncoding.DecodeString(submitted)
+	if len(envelope) < 4+2*72 {
+		t.Fatalf("bad envelope: %q", submitted)
+	}
+
+	// begin_sponsoring_future_reserves, create_account, end_sponsoring_future_reserves
+	txBytes := envelope[:len(envelope)-4-2*72]
+	numOps, _ := hex.DecodeString("00000003" + "00000000" + "00000010")
+	if !bytes.Contains(txBytes, numOps) {
+		t.Errorf("want 3 operations, starting with begin_sponsoring_future_reserves: %x", txBytes)
+	}
+
+	// Signed by the sponsor and Bob.
+	hash := rawHash(txBytes, "test")
+	sponsor, _ := keypair.Parse(sponsorSeed)
+	for i, kp := range []keypair.KP{sponsor, bob} {
+		sig := envelope[len(envelope)-2*72+i*72+8 : len(envelope)-2*72+(i+1)*72]
+		if err := kp.Verify(hash[:], sig); err != nil {
+			t.Errorf("bad signature %d: %v", i, err)
+		}
+	}
+
+	if err := ms.CreateSponsoredAccount(sponsorSeed, bob.Address(), "0"); err == nil {
+		t.Errorf("want error without the new account's seed")
+	}
+
+	if err := ms.CreateSponsoredAccount(sponsorSeed, bob.Address(), "0", Opts().WithSponsoredSeed(other.Seed())); err == nil {
+		t.Errorf("want error for mismatched seed")
+	}
+
+	if err := ms.CreateSponsoredAccount(sponsorSeed, bob.Address(), "-1", Opts().WithSponsoredSeed(bob.Seed())); err == nil {
+		t.Errorf("want error for negative starting balance")
+	}
+}
+
+func TestOpSources(t *testing.T) {
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	other := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	ms.Start(source)
+	tx := ms.getTx()
+	tx.opSources = true
+	ms.SetHomeDomain(source, "qubit.sh")
+	ms.SetHomeDomain(other, "qubit.sh")
+
+	payload, err := ms.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	txe, err := DecodeTx(payload)
+	if err != nil || len(txe.Tx.Operations) != 2 {
+		t.Fatalf("bad payload: %v", err)
+	}
+
+	if txe.Tx.Operations[0].SourceAccount != nil {
+		t.Errorf("operations sourced from the transaction source should not have a source")
+	}
+
+	if s := txe.Tx.Operations[1].SourceAccount; s == nil || s.Address() != other {
+		t.Errorf("want source %s, got %v", other, s)
+	}
+}
+
+func TestRevokeSponsorship(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	balanceID := "00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be"
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	w := &xdrWriter{}
+	w.account(address)
+	accountBytes, _ := w.bytes()
+	account := hex.EncodeToString(accountBytes)
+
+	tests := []struct {
+		entry *SponsoredEntry
+		want  string
+	}{
+		{SponsoredAccountEntry(address), "00000012" + "00000000" + "00000000" + account},
+		{SponsoredOfferEntry(address, 42), "00000012" + "00000000" + "00000002" + account + "000000000000002a"},
+		{SponsoredDataEntry(address, "foo"), "00000012" + "00000000" + "00000003" + account + "00000003" + "666f6f00"},
+		{SponsoredClaimableBalanceEntry(balanceID), "00000012" + "00000000" + "00000004" + balanceID},
+		{SponsoredSignerEntry(address, address), "00000012" + "00000001" + account + account},
+	}
+
+	for i, test := range tests {
+		body, err := revokeSponsorshipOp(test.entry)
+		if err != nil || hex.EncodeToString(body) != test.want {
+			t.Errorf("%d: want %s, got %x (%v)", i, test.want, body, err)
+		}
+	}
+
+	ms := New("fake")
+	if err := ms.RevokeSponsorship("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK", SponsoredTrustLineEntry(address, USD)); err != nil {
+		t.Errorf("RevokeSponsorship failed: %v", err)
+	}
+
+	for _, entry := range []*SponsoredEntry{nil, SponsoredTrustLineEntry(address, NativeAsset), SponsoredDataEntry(address, ""), SponsoredAccountEntry("BAD")} {
+		if err := ms.RevokeSponsorship("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK", entry); err == nil {
+			t.Errorf("want error for bad entry: %+v", entry)
+		}
+	}
+
+	// Every option applies, not just the first.
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms = New("custom", Params{"url": server.URL, "passphrase": "test"})
+	err := ms.RevokeSponsorship("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK", SponsoredAccountEntry(address),
+		Opts().WithMemoText("revoke"), Opts().WithDryRun())
+	if err != nil {
+		t.Fatalf("RevokeSponsorship failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	envelope, _ := base64.StdEncoding.DecodeString(payload)
+	if ms.Response() != nil || !bytes.Contains(envelope, []byte("revoke")) {
+		t.Errorf("want dry run with memo, got response %+v and envelope %x", ms.Response(), envelope)
+	}
+}
+
+func TestSponsoredReservesTotal(t *testing.T) {
+	sponsor := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	other := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ledgers" && r.URL.Query().Get("sponsor") != sponsor {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/ledgers":
+			fmt.Fprint(w, `{"_embedded": {"records": [{"base_reserve_in_stroops": 5000000}]}}`)
+		case "/accounts":
+			// One sponsored account with a sponsored trustline, and one unsponsored account
+			// with a sponsored signer.
+			fmt.Fprintf(w, `{"_embedded": {"records": [
+				{"id": "A", "paging_token": "A", "sponsor": "%s",
+				 "balances": [{"asset_type": "credit_alphanum4", "sponsor": "%s"}, {"asset_type": "native"}],
+				 "signers": [{"key": "A"}]},
+				{"id": "B", "paging_token": "B",
+				 "balances": [{"asset_type": "credit_alphanum4", "sponsor": "%s"}],
+				 "signers": [{"key": "S", "sponsor": "%s"}, {"key": "B"}]}
+			]}}`, sponsor, sponsor, other, sponsor)
+		case "/claimable_balances":
+			fmt.Fprintf(w, `{"_embedded": {"records": [
+				{"id": "C", "paging_token": "C", "sponsor": "%s", "claimants": [{}, {}]}
+			]}}`, sponsor)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	// Account (2) + trustline (1) + signer (1) + claimable balance with two claimants (2).
+	total, count, err := ms.SponsoredReservesTotal(sponsor)
+	if err != nil {
+		t.Fatalf("SponsoredReservesTotal failed: %v", ErrorString(err))
+	}
+
+	if total != "3.0000000" || count != 4 {
+		t.Errorf("wrong sponsored reserves: want 3.0000000/4, got %s/%d", total, count)
+	}
+
+	if _, _, err := ms.SponsoredReservesTotal("bad address"); err == nil {
+		t.Errorf("SponsoredReservesTotal should reject invalid addresses")
+	}
+}
@@ -0,0 +1,161 @@
+package microstellar
+
+import (
+	"github.com/stellar/go/keypair"
+)
+
+// XDR operation type for strict-send path payments.
+const opPathPaymentStrictSend = 13
+
+// maxPathHops is the maximum number of intermediate assets in a payment path.
+const maxPathHops = 5
+
+// PayStrictSend sends exactly sendAmount of sendAsset from sourceSeed to targetAddress, converted
+// along a payment path to destAsset. The payment fails unless at least destMin of destAsset arrives.
+//
+//   // Spend exactly 10 USD, and make sure Bob gets at least 8 EUR.
+//   err := ms.PayStrictSend(sourceSeed, bobAddress, USD, "10", EUR, "8")
+//
+// Use Options.Through to set the path. Otherwise, PayStrictSend uses FindSendPaths to pick the path
+// that delivers the most destAsset. To convert directly, without path finding, use
+// PayThroughStrictSend.
+func (ms *MicroStellar) PayStrictSend(sourceSeed string, targetAddress string, sendAsset *Asset, sendAmount string, destAsset *Asset, destMin string, options ...*Options) error {
+	opts := mergeOptions(options)
+	if len(opts.path) > 0 {
+		return ms.PayThroughStrictSend(sourceSeed, targetAddress, sendAsset, sendAmount, destAsset, destMin, opts)
+	}
+
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't pay: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if err := destAsset.Validate(); err != nil {
+		return ms.wrapf(err, "can't pay: bad destination asset")
+	}
+
+	source, _ := keypair.Parse(sourceSeed)
+	paths, err := ms.FindSendPaths(source.Address(), targetAddress, sendAsset, sendAmount, Opts().WithAsset(destAsset, destMin))
+	if err != nil {
+		return ms.wrapf(err, "path finding error")
+	}
+
+	if len(paths) < 1 {
+		return ms.errorf("no paths found from %s to %s", sendAsset.Code, destAsset.Code)
+	}
+
+	best, bestAmount := paths[0], int64(0)
+	for _, p := range paths {
+		if amount, err := ParseAmount(p.DestAmount); err == nil && amount > bestAmount {
+			best, bestAmount = p, amount
+		}
+	}
+
+	ms.debugf("PayStrictSend", "path payment: %s arrives through %d hops", best.DestAmount, len(best.Hops))
+
+	// Copy the options so we don't modify the caller's.
+	pathOpts := *opts
+	pathOpts.path = best.Hops
+	return ms.PayThroughStrictSend(sourceSeed, targetAddress, sendAsset, sendAmount, destAsset, destMin, &pathOpts)
+}
+
+// MaxSendableViaPath returns the most sendAsset that the account at sourceAddress can send in a
+// path payment (e.g., as the sendAmount of PayStrictSend) without failing for an underfunded
+// source. For lumens, this is the balance less the minimum balance and the lumens reserved by
+// open offers (see Account.SpendableBalance), and for credit assets, the balance less the amount
+// reserved by open offers.
+//
+//   max, err := ms.MaxSendableViaPath("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", USD)
+//   err = ms.PayStrictSend(sourceSeed, bobAddress, USD, max, EUR, "1")
+//
+// The transaction fee is also paid in lumens, so leave room for it when sending lumens.
+func (ms *MicroStellar) MaxSendableViaPath(sourceAddress string, sendAsset *Asset) (string, error) {
+	if !ValidAddressOrSeed(sourceAddress) {
+		return "", ms.errorf("can't compute sendable amount: invalid source address or seed: %s", sourceAddress)
+	}
+
+	if err := sendAsset.Validate(); err != nil {
+		return "", ms.wrapf(err, "can't compute sendable amount: bad send asset")
+	}
+
+	account, err := ms.LoadAccount(sourceAddress)
+	if err != nil {
+		return "", ms.wrapf(err, "can't compute sendable amount")
+	}
+
+	sendable, err := account.spendableBalanceOf(sendAsset)
+	if err != nil {
+		return "", ms.wrapf(err, "can't compute sendable amount")
+	}
+
+	return sendable, ms.success()
+}
+
+// PayThroughStrictSend sends exactly sendAmount of sendAsset from sourceSeed to destAddress in a
+// path_payment_strict_send operation, converted to destAsset through the intermediate assets set
+// with Options.Through (or directly, if there are none.) The payment fails unless at least destMin
+// of destAsset arrives.
+//
+//   // Convert 10 USD to EUR through XLM.
+//   err := ms.PayThroughStrictSend(sourceSeed, bobAddress, USD, "10", EUR, "8",
+//       microstellar.Opts().Through(microstellar.NativeAsset))
+func (ms *MicroStellar) PayThroughStrictSend(sourceSeed, destAddress string, sendAsset *Asset, sendAmount string, destAsset *Asset, destMin string, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't pay: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if err := ValidAddress(destAddress); err != nil {
+		return ms.errorf("can't pay: invalid address: %v", destAddress)
+	}
+
+	if err := sendAsset.Validate(); err != nil {
+		return ms.wrapf(err, "can't pay: bad send asset")
+	}
+
+	if err := destAsset.Validate(); err != nil {
+		return ms.wrapf(err, "can't pay: bad destination asset")
+	}
+
+	sendAmountInt, err := ParseAmount(sendAmount)
+	if err != nil || sendAmountInt <= 0 {
+		return ms.errorf("can't pay: send amount must be positive: %s", sendAmount)
+	}
+
+	destMinInt, err := ParseAmount(destMin)
+	if err != nil || destMinInt <= 0 {
+		return ms.errorf("can't pay: minimum destination amount must be positive: %s", destMin)
+	}
+
+	path := mergeOptions(options).path
+	if len(path) > maxPathHops {
+		return ms.errorf("can't pay: path too long: %d hops (max %d)", len(path), maxPathHops)
+	}
+
+	w := &xdrWriter{}
+	w.uint32(opPathPaymentStrictSend)
+	w.asset(sendAsset)
+	w.int64(sendAmountInt)
+	w.account(destAddress) // an ed25519 MuxedAccount encodes like an AccountId
+	w.asset(destAsset)
+	w.int64(destMinInt)
+	w.uint32(uint32(len(path)))
+	for _, hop := range path {
+		if err := hop.Validate(); err != nil {
+			return ms.wrapf(err, "can't pay: bad path")
+		}
+		w.asset(hop)
+	}
+
+	body, err := w.bytes()
+	if err != nil {
+		return ms.wrapf(err, "can't pay")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), tx.rawOp("", body))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
@@ -0,0 +1,218 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPathServer(t *testing.T, issuer string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/accounts/") {
+			fmt.Fprint(w, `{"id": "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "sequence": "100"}`)
+			return
+		}
+
+		if r.URL.Path != "/paths/strict-send" {
+			t.Errorf("unexpected request: %s", r.URL)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("source_asset_type") != "credit_alphanum4" || query.Get("source_asset_code") != "USD" ||
+			query.Get("source_amount") != "10" || query.Get("destination_account") == "" {
+			t.Errorf("wrong query: %s", r.URL.RawQuery)
+		}
+
+		fmt.Fprintf(w, `{"_embedded": {"records": [
+			{"source_asset_type": "credit_alphanum4", "source_asset_code": "USD", "source_asset_issuer": "%[1]s",
+			 "source_amount": "10.0000000", "destination_asset_type": "credit_alphanum4", "destination_asset_code": "EUR",
+			 "destination_asset_issuer": "%[1]s", "destination_amount": "8.5000000", "path": []},
+			{"source_asset_type": "credit_alphanum4", "source_asset_code": "USD", "source_asset_issuer": "%[1]s",
+			 "source_amount": "10.0000000", "destination_asset_type": "credit_alphanum4", "destination_asset_code": "EUR",
+			 "destination_asset_issuer": "%[1]s", "destination_amount": "9.0000000",
+			 "path": [{"asset_type": "native"}]},
+			{"source_asset_type": "credit_alphanum4", "source_asset_code": "USD", "source_asset_issuer": "%[1]s",
+			 "source_amount": "10.0000000", "destination_asset_type": "native", "destination_amount": "40.0000000", "path": []}
+		]}}`, issuer)
+	}))
+}
+
+func TestFindSendPaths(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	USD := NewAsset("USD", issuer, Credit4Type)
+	EUR := NewAsset("EUR", issuer, Credit4Type)
+
+	server := newPathServer(t, issuer)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	paths, err := ms.FindSendPaths(source, bob, USD, "10")
+	if err != nil {
+		t.Fatalf("FindSendPaths failed: %v", err)
+	}
+
+	if len(paths) != 3 || !paths[0].SourceAsset.Equals(*USD) || paths[0].SourceAmount != "10.0000000" ||
+		!paths[1].DestAsset.Equals(*EUR) || paths[1].DestAmount != "9.0000000" ||
+		len(paths[1].Hops) != 1 || !paths[1].Hops[0].IsNative() {
+		t.Errorf("wrong paths: %+v", paths)
+	}
+
+	paths, err = ms.FindSendPaths(source, bob, USD, "10", Opts().WithAsset(EUR, "8.6"))
+	if err != nil || len(paths) != 1 || paths[0].DestAmount != "9.0000000" {
+		t.Errorf("wrong filtered paths: %+v: %v", paths, err)
+	}
+
+	if _, err := ms.FindSendPaths(source, bob, USD, "-1"); err == nil {
+		t.Errorf("want error for bad amount")
+	}
+}
+
+func TestPayStrictSend(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	USD := NewAsset("USD", issuer, Credit4Type)
+	EUR := NewAsset("EUR", issuer, Credit4Type)
+
+	server := newPathServer(t, issuer)
+	defer server.Close()
+
+	want := func(hops ...*Asset) []byte {
+		w := &xdrWriter{}
+		w.uint32(opPathPaymentStrictSend)
+		w.asset(USD)
+		w.int64(100000000)
+		w.account(bob)
+		w.asset(EUR)
+		w.int64(80000000)
+		w.uint32(uint32(len(hops)))
+		for _, hop := range hops {
+			w.asset(hop)
+		}
+		b, _ := w.bytes()
+		return b
+	}
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	tests := []struct {
+		opts *Options
+		want []byte
+	}{
+		{Opts().WithDryRun(), want(NativeAsset)}, // picks the path delivering the most EUR
+		{Opts().WithDryRun().Through(NativeAsset, USD), want(NativeAsset, USD)},
+	}
+
+	for i, test := range tests {
+		if err := ms.PayStrictSend(seed, bob, USD, "10", EUR, "8", test.opts); err != nil {
+			t.Fatalf("%d: PayStrictSend failed: %v", i, ErrorString(err))
+		}
+
+		payload, _ := ms.LastPayload()
+		envelope, _ := base64.StdEncoding.DecodeString(payload)
+		if !bytes.Contains(envelope, test.want) {
+			t.Errorf("%d: operation %x not found in %x", i, test.want, envelope)
+		}
+	}
+
+	if err := ms.PayStrictSend(seed, bob, USD, "10", EUR, "9.5"); err == nil {
+		t.Errorf("want error when no path delivers the minimum")
+	}
+
+	if err := ms.PayStrictSend(seed, bob, USD, "10", EUR, "0"); err == nil {
+		t.Errorf("want error for zero minimum")
+	}
+
+	if err := ms.PayStrictSend(seed, bob, USD, "10", EUR, "8", Opts().Through(USD, USD, USD, USD, USD, USD)); err == nil {
+		t.Errorf("want error for long paths")
+	}
+}
+
+func TestPayThroughStrictSend(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	seed := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	USD := NewAsset("USD", issuer, Credit4Type)
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	// No path: convert directly, without path finding.
+	w := &xdrWriter{}
+	w.uint32(opPathPaymentStrictSend)
+	w.asset(USD)
+	w.int64(100000000)
+	w.account(bob)
+	w.asset(NativeAsset)
+	w.int64(12345678)
+	w.uint32(0)
+	want, _ := w.bytes()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.PayThroughStrictSend(seed, bob, USD, "10", NativeAsset, "1.2345678"); err != nil {
+		t.Fatalf("PayThroughStrictSend failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	envelope, _ := base64.StdEncoding.DecodeString(payload)
+	if !bytes.Contains(envelope, want) || submissions != 1 {
+		t.Errorf("operation %x not found in %x (submissions: %d)", want, envelope, submissions)
+	}
+
+	if err := ms.PayThroughStrictSend(seed, bob, USD, "0", NativeAsset, "1"); err == nil {
+		t.Errorf("want error for zero send amount")
+	}
+
+	if err := ms.PayThroughStrictSend(seed, "bob*qubit.sh", USD, "10", NativeAsset, "1"); err == nil {
+		t.Errorf("want error for bad destination")
+	}
+}
+
+func TestMaxSendableViaPath(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100", "subentry_count": 2, "balances": [
+			{"balance": "25.0000000", "limit": "1000.0000000", "selling_liabilities": "5.0000000",
+			 "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "%s"},
+			{"balance": "3.0000000", "limit": "1000.0000000", "selling_liabilities": "4.0000000",
+			 "asset_type": "credit_alphanum4", "asset_code": "EUR", "asset_issuer": "%s"},
+			{"balance": "10.0000000", "selling_liabilities": "1.0000000", "asset_type": "native"}]}`,
+			address, address, issuer, issuer)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	tests := []struct {
+		asset *Asset
+		want  string
+	}{
+		{NativeAsset, "7.0000000"}, // 10 - (2 + 2 subentries) * 0.5 - 1
+		{NewAsset("USD", issuer, Credit4Type), "20.0000000"},
+		{NewAsset("EUR", issuer, Credit4Type), "0.0000000"},
+	}
+
+	for _, test := range tests {
+		got, err := ms.MaxSendableViaPath(address, test.asset)
+		if err != nil || got != test.want {
+			t.Errorf("%s: want %s, got %s (%v)", test.asset.Code, test.want, got, ErrorString(err))
+		}
+	}
+
+	if _, err := ms.MaxSendableViaPath(address, NewAsset("INR", issuer, Credit4Type)); err == nil {
+		t.Errorf("want error for untrusted asset")
+	}
+
+	if _, err := ms.MaxSendableViaPath("BAD", NativeAsset); err == nil {
+		t.Errorf("want error for bad address")
+	}
+}
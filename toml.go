@@ -0,0 +1,198 @@
+package microstellar
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// stellarTOMLMaxSize is the maximum size of a stellar.toml file (SEP-1.)
+const stellarTOMLMaxSize = 100 * 1024
+
+// ErrStellarTOMLNotFound is returned by LoadStellarTOML if the domain doesn't serve a
+// stellar.toml file. Use errors.Cause to check for it.
+var ErrStellarTOMLNotFound = errors.New("stellar.toml not found")
+
+// ErrStellarTOMLRedirect is returned by LoadStellarTOML if the domain redirects requests for
+// its stellar.toml file, which SEP-1 doesn't allow. Use errors.Cause to check for it.
+var ErrStellarTOMLRedirect = errors.New("stellar.toml request redirected")
+
+// StellarTOML is a domain's stellar.toml file (SEP-1), which describes the domain's Stellar
+// integration: its servers, accounts, and currencies.
+type StellarTOML struct {
+	Version              string         `toml:"VERSION"`
+	NetworkPassphrase    string         `toml:"NETWORK_PASSPHRASE"`
+	FederationServer     string         `toml:"FEDERATION_SERVER"`
+	AuthServer           string         `toml:"AUTH_SERVER"`
+	WebAuthEndpoint      string         `toml:"WEB_AUTH_ENDPOINT"`
+	TransferServer       string         `toml:"TRANSFER_SERVER"`
+	TransferServerSEP24  string         `toml:"TRANSFER_SERVER_SEP0024"`
+	KYCServer            string         `toml:"KYC_SERVER"`
+	HorizonURL           string         `toml:"HORIZON_URL"`
+	SigningKey           string         `toml:"SIGNING_KEY"`
+	URIRequestSigningKey string         `toml:"URI_REQUEST_SIGNING_KEY"`
+	Accounts             []string       `toml:"ACCOUNTS"`
+	Currencies           []TOMLCurrency `toml:"CURRENCIES"`
+	DirectPaymentServer  string         `toml:"DIRECT_PAYMENT_SERVER"`
+	AnchorQuoteServer    string         `toml:"ANCHOR_QUOTE_SERVER"`
+}
+
+// TOMLCurrency is a currency declared in a stellar.toml file.
+type TOMLCurrency struct {
+	Code                   string `toml:"code"`
+	Issuer                 string `toml:"issuer"`
+	Status                 string `toml:"status"`
+	DisplayDecimals        int    `toml:"display_decimals"`
+	Name                   string `toml:"name"`
+	Desc                   string `toml:"desc"`
+	Conditions             string `toml:"conditions"`
+	Image                  string `toml:"image"`
+	IsAssetAnchored        bool   `toml:"is_asset_anchored"`
+	AnchorAssetType        string `toml:"anchor_asset_type"`
+	AnchorAsset            string `toml:"anchor_asset"`
+	RedemptionInstructions string `toml:"redemption_instructions"`
+}
+
+// Asset returns the currency as an Asset, or nil if the currency is not a Stellar asset
+// (e.g., it has no issuer.)
+func (c TOMLCurrency) Asset() *Asset {
+	if ValidAddress(c.Issuer) != nil {
+		return nil
+	}
+
+	asset, err := NewAssetAuto(c.Code, c.Issuer)
+	if err != nil {
+		return nil
+	}
+
+	return asset
+}
+
+// LoadStellarTOML fetches and parses the stellar.toml file at
+// https://domain/.well-known/stellar.toml.
+//
+//   stoml, err := ms.LoadStellarTOML("qubit.sh")
+//   for _, c := range stoml.Currencies {
+//       log.Printf("%s: %s", c.Code, c.Name)
+//   }
+//
+// Returns an error with cause ErrStellarTOMLNotFound if the domain doesn't have a stellar.toml,
+// and ErrStellarTOMLRedirect if the request is redirected. (The vendored stellartoml client only
+// decodes a few fields, and doesn't report status codes, so this fetches the file directly.)
+func (ms *MicroStellar) LoadStellarTOML(domain string) (*StellarTOML, error) {
+	if domain == "" {
+		return nil, ms.errorf("can't load stellar.toml: missing domain")
+	}
+
+	client := http.DefaultClient
+	if httpClient := httpClientFromParams(ms.params); httpClient != nil {
+		client = httpClient
+	}
+
+	// Don't follow redirects, so they can be reported.
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	url := "https://" + domain + "/.well-known/stellar.toml"
+	ms.debugf("LoadStellarTOML", "loading %s", url)
+
+	resp, err := noRedirects.Get(url)
+	if err != nil {
+		return nil, ms.wrapf(err, "can't load stellar.toml for %s", domain)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ms.wrapf(ErrStellarTOMLNotFound, "can't load stellar.toml for %s", domain)
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return nil, ms.wrapf(ErrStellarTOMLRedirect, "can't load stellar.toml for %s (redirected to %s)", domain, resp.Header.Get("Location"))
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, ms.errorf("can't load stellar.toml for %s: status %d", domain, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, stellarTOMLMaxSize+1))
+	if err != nil {
+		return nil, ms.wrapf(err, "can't load stellar.toml for %s", domain)
+	}
+
+	if len(body) > stellarTOMLMaxSize {
+		return nil, ms.errorf("can't load stellar.toml for %s: larger than %d bytes", domain, stellarTOMLMaxSize)
+	}
+
+	var stoml StellarTOML
+	if _, err := toml.Decode(string(body), &stoml); err != nil {
+		return nil, ms.wrapf(err, "can't parse stellar.toml for %s", domain)
+	}
+
+	return &stoml, ms.success()
+}
+
+// AssetInfo describes an asset's issuer: its home domain, its auth flags, and the currency entry
+// for the asset in the home domain's stellar.toml.
+type AssetInfo struct {
+	Asset      *Asset
+	HomeDomain string
+
+	// Flags are the issuer's auth flags. If AuthRequired is set, holders need the issuer's
+	// approval (see AllowTrust) before they can receive the asset, and if AuthRevocable is set,
+	// the issuer can freeze their balances.
+	Flags Flags
+
+	// Currency is the asset's entry in the issuer's stellar.toml, or nil if the issuer has no
+	// home domain, the domain has no stellar.toml, or the asset isn't listed in it.
+	Currency *TOMLCurrency
+}
+
+// AssetInfo loads the issuer of asset, and returns its home domain, its auth flags, and the
+// asset's currency entry (matched by code and issuer) from the home domain's stellar.toml.
+//
+//   info, err := ms.AssetInfo(USD)
+//   if info.Flags.AuthRequired || info.Flags.AuthRevocable {
+//       log.Printf("warning: %s is a restricted asset", info.Asset.Code)
+//   }
+//
+// Only the issuer account and the stellar.toml file are trusted: nothing else verifies that the
+// home domain belongs to the issuer.
+func (ms *MicroStellar) AssetInfo(asset *Asset) (*AssetInfo, error) {
+	if err := asset.Validate(); err != nil {
+		return nil, ms.wrapf(err, "can't load asset info")
+	}
+
+	if asset.IsNative() {
+		return nil, ms.errorf("can't load asset info: native assets have no issuer")
+	}
+
+	issuer, err := ms.LoadAccount(asset.Issuer)
+	if err != nil {
+		return nil, ms.wrapf(err, "can't load asset info: can't load issuer")
+	}
+
+	info := &AssetInfo{Asset: asset, HomeDomain: issuer.HomeDomain, Flags: issuer.Flags}
+	if info.HomeDomain == "" {
+		return info, ms.success()
+	}
+
+	stoml, err := ms.LoadStellarTOML(info.HomeDomain)
+	if errors.Cause(err) == ErrStellarTOMLNotFound {
+		return info, ms.success()
+	}
+
+	if err != nil {
+		return nil, ms.wrapf(err, "can't load asset info")
+	}
+
+	for i, c := range stoml.Currencies {
+		if c.Code == asset.Code && c.Issuer == asset.Issuer {
+			info.Currency = &stoml.Currencies[i]
+			break
+		}
+	}
+
+	return info, ms.success()
+}
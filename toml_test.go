@@ -0,0 +1,151 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestLoadStellarTOML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/stellar.toml" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Host {
+		case "qubit.sh":
+			fmt.Fprint(w, `
+VERSION="2.0.0"
+FEDERATION_SERVER="https://qubit.sh/federation"
+WEB_AUTH_ENDPOINT="https://qubit.sh/auth"
+TRANSFER_SERVER="https://qubit.sh/transfer"
+SIGNING_KEY="GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+ACCOUNTS=["GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"]
+
+[DOCUMENTATION]
+ORG_NAME="Qubit"
+
+[[CURRENCIES]]
+code="USD"
+issuer="GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+display_decimals=2
+name="US Dollar"
+is_asset_anchored=true
+anchor_asset_type="fiat"
+
+[[CURRENCIES]]
+code="BTC"
+`)
+		case "redirect.com":
+			http.Redirect(w, r, "https://www.redirect.com/.well-known/stellar.toml", http.StatusMovedPermanently)
+		case "bad.com":
+			fmt.Fprint(w, `VERSION=`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	ms := New("test").WithHTTPClient(&http.Client{Transport: &redirectTransport{target}})
+
+	stoml, err := ms.LoadStellarTOML("qubit.sh")
+	if err != nil {
+		t.Fatalf("LoadStellarTOML failed: %v", err)
+	}
+
+	if stoml.FederationServer != "https://qubit.sh/federation" || stoml.TransferServer != "https://qubit.sh/transfer" ||
+		stoml.WebAuthEndpoint != "https://qubit.sh/auth" || len(stoml.Accounts) != 1 {
+		t.Errorf("unexpected stellar.toml: %+v", stoml)
+	}
+
+	if len(stoml.Currencies) != 2 || stoml.Currencies[0].DisplayDecimals != 2 || !stoml.Currencies[0].IsAssetAnchored {
+		t.Fatalf("unexpected currencies: %+v", stoml.Currencies)
+	}
+
+	if asset := stoml.Currencies[0].Asset(); asset == nil || asset.Code != "USD" || asset.Type != Credit4Type {
+		t.Errorf("want USD asset, got %+v", asset)
+	}
+
+	if asset := stoml.Currencies[1].Asset(); asset != nil {
+		t.Errorf("currencies without issuers have no asset, got %+v", asset)
+	}
+
+	if _, err := ms.LoadStellarTOML("redirect.com"); errors.Cause(err) != ErrStellarTOMLRedirect {
+		t.Errorf("want ErrStellarTOMLRedirect, got %v", err)
+	}
+
+	if _, err := ms.LoadStellarTOML("example.com"); errors.Cause(err) != ErrStellarTOMLNotFound {
+		t.Errorf("want ErrStellarTOMLNotFound, got %v", err)
+	}
+
+	if _, err := ms.LoadStellarTOML("bad.com"); err == nil {
+		t.Errorf("want error for bad toml")
+	}
+}
+
+func TestAssetInfo(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	other := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/accounts/"+issuer:
+			fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100", "home_domain": "qubit.sh",
+				"flags": {"auth_required": true, "auth_revocable": true}}`, issuer, issuer)
+		case r.URL.Path == "/accounts/"+other:
+			fmt.Fprintf(w, `{"id": "%s", "account_id": "%s", "sequence": "100"}`, other, other)
+		case r.URL.Path == "/.well-known/stellar.toml" && r.Host == "qubit.sh":
+			fmt.Fprintf(w, `
+[[CURRENCIES]]
+code="EUR"
+issuer="%s"
+
+[[CURRENCIES]]
+code="USD"
+issuer="%s"
+name="US Dollar"
+`, other, issuer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"}).WithHTTPClient(&http.Client{Transport: &redirectTransport{target}})
+
+	info, err := ms.AssetInfo(NewAsset("USD", issuer, Credit4Type))
+	if err != nil {
+		t.Fatalf("AssetInfo failed: %v", ErrorString(err))
+	}
+
+	if info.HomeDomain != "qubit.sh" || !info.Flags.AuthRequired || !info.Flags.AuthRevocable || info.Flags.AuthImmutable {
+		t.Errorf("unexpected issuer info: %+v", info)
+	}
+
+	if info.Currency == nil || info.Currency.Name != "US Dollar" {
+		t.Errorf("want USD currency, got %+v", info.Currency)
+	}
+
+	// EUR is listed with a different issuer.
+	info, err = ms.AssetInfo(NewAsset("EUR", issuer, Credit4Type))
+	if err != nil || info.Currency != nil {
+		t.Errorf("want no currency for unlisted asset, got %+v, %v", info, err)
+	}
+
+	// Issuers without home domains have no stellar.toml.
+	info, err = ms.AssetInfo(NewAsset("EUR", other, Credit4Type))
+	if err != nil || info.HomeDomain != "" || info.Flags.AuthRequired || info.Currency != nil {
+		t.Errorf("unexpected asset info: %+v, %v", info, err)
+	}
+
+	if _, err := ms.AssetInfo(NativeAsset); err == nil {
+		t.Errorf("AssetInfo should fail for native assets")
+	}
+}
@@ -0,0 +1,274 @@
+package microstellar
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Trade is an executed trade between two offers (or an offer and a path payment.)
+type Trade struct {
+	ID              string    `json:"id"`
+	PT              string    `json:"paging_token"` // use with WithCursor to fetch the next page
+	LedgerCloseTime time.Time `json:"ledger_close_time"`
+	OfferID         string    `json:"offer_id"`
+
+	BaseAccount string `json:"base_account"`
+	BaseAmount  string `json:"base_amount"`
+	BaseAsset   *Asset `json:"base_asset"`
+
+	CounterAccount string `json:"counter_account"`
+	CounterAmount  string `json:"counter_amount"`
+	CounterAsset   *Asset `json:"counter_asset"`
+
+	// BaseIsSeller is true if the base account sold BaseAsset.
+	BaseIsSeller bool `json:"base_is_seller"`
+
+	// Price is the price of BaseAsset in units of CounterAsset.
+	Price string `json:"price"`
+}
+
+// horizonTrade is a Horizon trade record. The vendored Horizon client doesn't decode account
+// trades.
+type horizonTrade struct {
+	ID                 string      `json:"id"`
+	PT                 string      `json:"paging_token"`
+	LedgerCloseTime    time.Time   `json:"ledger_close_time"`
+	OfferID            interface{} `json:"offer_id"` // number in older Horizons, string in newer ones
+	BaseAccount        string      `json:"base_account"`
+	BaseAmount         string      `json:"base_amount"`
+	BaseAssetType      string      `json:"base_asset_type"`
+	BaseAssetCode      string      `json:"base_asset_code"`
+	BaseAssetIssuer    string      `json:"base_asset_issuer"`
+	CounterAccount     string      `json:"counter_account"`
+	CounterAmount      string      `json:"counter_amount"`
+	CounterAssetType   string      `json:"counter_asset_type"`
+	CounterAssetCode   string      `json:"counter_asset_code"`
+	CounterAssetIssuer string      `json:"counter_asset_issuer"`
+	BaseIsSeller       bool        `json:"base_is_seller"`
+	Price              struct {
+		N interface{} `json:"n"` // number in older Horizons, string in newer ones
+		D interface{} `json:"d"`
+	} `json:"price"`
+}
+
+type horizonTradesPage struct {
+	Embedded struct {
+		Records []horizonTrade `json:"records"`
+	} `json:"_embedded"`
+}
+
+// newTradeFromHorizon creates a new trade from a Horizon trade record.
+func newTradeFromHorizon(ht horizonTrade) Trade {
+	trade := Trade{
+		ID:              ht.ID,
+		PT:              ht.PT,
+		LedgerCloseTime: ht.LedgerCloseTime,
+		OfferID:         horizonID(ht.OfferID),
+		BaseAccount:     ht.BaseAccount,
+		BaseAmount:      ht.BaseAmount,
+		BaseAsset:       effectAsset(ht.BaseAssetType, ht.BaseAssetCode, ht.BaseAssetIssuer),
+		CounterAccount:  ht.CounterAccount,
+		CounterAmount:   ht.CounterAmount,
+		CounterAsset:    effectAsset(ht.CounterAssetType, ht.CounterAssetCode, ht.CounterAssetIssuer),
+		BaseIsSeller:    ht.BaseIsSeller,
+	}
+
+	if price, ok := new(big.Rat).SetString(horizonID(ht.Price.N) + "/" + horizonID(ht.Price.D)); ok {
+		trade.Price = price.FloatString(7)
+	}
+
+	return trade
+}
+
+// isBetween returns true if the trade is between base and counter, in either direction.
+func (trade Trade) isBetween(base *Asset, counter *Asset) bool {
+	if trade.BaseAsset == nil || trade.CounterAsset == nil {
+		return false
+	}
+
+	return (trade.BaseAsset.Equals(*base) && trade.CounterAsset.Equals(*counter)) ||
+		(trade.BaseAsset.Equals(*counter) && trade.CounterAsset.Equals(*base))
+}
+
+// LoadTrades returns the trades executed by the account at address, with the assets and amounts
+// exchanged, the price, and the ledger close time. Use WithLimit, WithCursor and WithSortOrder to
+// page through the results; the PT field of the last trade is the cursor for the next page.
+// Returns an empty slice if the account has no trades.
+//
+//   trades, err := ms.LoadTrades("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+//     microstellar.Opts().WithLimit(50).WithAssetPair(microstellar.NativeAsset, USD))
+//
+// Horizon can't filter an account's trades by asset, so WithAssetPair filters each page after
+// it's loaded. Filtered pages may have fewer than limit trades (or none) before the last page, so
+// page with the cursor of the last trade Horizon returned (see LoadTradesPage.)
+func (ms *MicroStellar) LoadTrades(address string, options ...*Options) ([]Trade, error) {
+	trades, _, err := ms.LoadTradesPage(address, options...)
+	return trades, err
+}
+
+// LoadTradesPage is like LoadTrades, but also returns the cursor for the next page, which is
+// set even if every trade on the page was filtered out.
+func (ms *MicroStellar) LoadTradesPage(address string, options ...*Options) ([]Trade, string, error) {
+	if err := ValidAddress(address); err != nil {
+		return nil, "", ms.errorf("invalid address: %s", address)
+	}
+
+	opt := mergeOptions(options)
+	if (opt.baseAsset == nil) != (opt.counterAsset == nil) {
+		return nil, "", ms.errorf("can't load trades: asset pair needs a base and counter asset")
+	}
+
+	query := pageQuery(opt)
+
+	ms.debugf("LoadTrades", "loading trades for %s, with params %+v", address, query)
+	if ms.fake {
+		return []Trade{}, "", ms.success()
+	}
+
+	var page horizonTradesPage
+	path := fmt.Sprintf("/accounts/%s/trades?%s", address, query.Encode())
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), path, &page); err != nil {
+		return nil, "", ms.wrapf(err, "can't load trades")
+	}
+
+	cursor := ""
+	trades := []Trade{}
+	for _, ht := range page.Embedded.Records {
+		cursor = ht.PT
+
+		trade := newTradeFromHorizon(ht)
+		if opt.baseAsset != nil && !trade.isBetween(opt.baseAsset, opt.counterAsset) {
+			continue
+		}
+
+		trades = append(trades, trade)
+	}
+
+	return trades, cursor, ms.success()
+}
+
+// Resolutions of trade aggregations supported by Horizon.
+const (
+	Resolution1m  = time.Minute
+	Resolution5m  = 5 * time.Minute
+	Resolution15m = 15 * time.Minute
+	Resolution1h  = time.Hour
+	Resolution1d  = 24 * time.Hour
+	Resolution1w  = 7 * 24 * time.Hour
+)
+
+// TradeAggregation is a price candle: the trades between two assets in a time bucket. Prices are
+// in units of the counter asset per unit of the base asset.
+type TradeAggregation struct {
+	Timestamp     time.Time `json:"timestamp"` // start of the bucket
+	TradeCount    int64     `json:"trade_count"`
+	BaseVolume    string    `json:"base_volume"`
+	CounterVolume string    `json:"counter_volume"`
+	Avg           string    `json:"avg"`
+	Open          string    `json:"open"`
+	High          string    `json:"high"`
+	Low           string    `json:"low"`
+	Close         string    `json:"close"`
+}
+
+// horizonTradeAggregation is a Horizon trade aggregation record. Newer Horizons encode the
+// timestamp and trade count as strings.
+type horizonTradeAggregation struct {
+	Timestamp     interface{} `json:"timestamp"`
+	TradeCount    interface{} `json:"trade_count"`
+	BaseVolume    string      `json:"base_volume"`
+	CounterVolume string      `json:"counter_volume"`
+	Avg           string      `json:"avg"`
+	Open          string      `json:"open"`
+	High          string      `json:"high"`
+	Low           string      `json:"low"`
+	Close         string      `json:"close"`
+}
+
+type horizonTradeAggregationsPage struct {
+	Embedded struct {
+		Records []horizonTradeAggregation `json:"records"`
+	} `json:"_embedded"`
+}
+
+// addAssetQuery adds the Horizon query parameters for asset, with the given prefix (e.g., "base").
+func addAssetQuery(query url.Values, prefix string, asset *Asset) {
+	query.Add(prefix+"_asset_type", string(asset.Type))
+	if !asset.IsNative() {
+		query.Add(prefix+"_asset_code", asset.Code)
+		query.Add(prefix+"_asset_issuer", asset.Issuer)
+	}
+}
+
+// LoadTradeAggregations returns the price candles (open, high, low, close, and volume) for trades
+// between base and counter from start to end, in buckets of resolution. resolution must be one of
+// Resolution1m, Resolution5m, Resolution15m, Resolution1h, Resolution1d, or Resolution1w. Use
+// WithLimit and WithSortOrder to control the number and order of buckets.
+//
+//   candles, err := ms.LoadTradeAggregations(microstellar.NativeAsset, USD, microstellar.Resolution1h,
+//     time.Now().Add(-24*time.Hour), time.Now())
+//
+// Buckets without trades are skipped. To load more buckets, move start past the timestamp of the
+// last one.
+func (ms *MicroStellar) LoadTradeAggregations(base, counter *Asset, resolution time.Duration, start, end time.Time, options ...*Options) ([]TradeAggregation, error) {
+	if err := base.Validate(); err != nil {
+		return nil, ms.wrapf(err, "can't load trade aggregations: invalid base asset")
+	}
+
+	if err := counter.Validate(); err != nil {
+		return nil, ms.wrapf(err, "can't load trade aggregations: invalid counter asset")
+	}
+
+	switch resolution {
+	case Resolution1m, Resolution5m, Resolution15m, Resolution1h, Resolution1d, Resolution1w:
+	default:
+		return nil, ms.errorf("can't load trade aggregations: unsupported resolution: %v", resolution)
+	}
+
+	if !end.After(start) {
+		return nil, ms.errorf("can't load trade aggregations: end time must be after start time")
+	}
+
+	opt := mergeOptions(options)
+	query := pageQuery(opt)
+	query.Del("cursor") // trade aggregations are paged by time
+	addAssetQuery(query, "base", base)
+	addAssetQuery(query, "counter", counter)
+	query.Add("resolution", strconv.FormatInt(int64(resolution/time.Millisecond), 10))
+	query.Add("start_time", strconv.FormatInt(start.UnixNano()/int64(time.Millisecond), 10))
+	query.Add("end_time", strconv.FormatInt(end.UnixNano()/int64(time.Millisecond), 10))
+
+	ms.debugf("LoadTradeAggregations", "loading trade aggregations, with params %+v", query)
+	if ms.fake {
+		return []TradeAggregation{}, ms.success()
+	}
+
+	var page horizonTradeAggregationsPage
+	path := "/trade_aggregations?" + query.Encode()
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), path, &page); err != nil {
+		return nil, ms.wrapf(err, "can't load trade aggregations")
+	}
+
+	candles := make([]TradeAggregation, len(page.Embedded.Records))
+	for i, ha := range page.Embedded.Records {
+		timestamp, _ := strconv.ParseInt(horizonID(ha.Timestamp), 10, 64)
+		count, _ := strconv.ParseInt(horizonID(ha.TradeCount), 10, 64)
+
+		candles[i] = TradeAggregation{
+			Timestamp:     time.Unix(0, timestamp*int64(time.Millisecond)).UTC(),
+			TradeCount:    count,
+			BaseVolume:    ha.BaseVolume,
+			CounterVolume: ha.CounterVolume,
+			Avg:           ha.Avg,
+			Open:          ha.Open,
+			High:          ha.High,
+			Low:           ha.Low,
+			Close:         ha.Close,
+		}
+	}
+
+	return candles, ms.success()
+}
@@ -0,0 +1,149 @@
+package microstellar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLoadTrades(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+
+	query := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/"+address+"/trades" {
+			fmt.Fprint(w, `{"_embedded": {"records": []}}`)
+			return
+		}
+
+		query = r.URL.RawQuery
+		fmt.Fprintf(w, `{"_embedded": {"records": [
+			{"id": "1-0", "paging_token": "1-0", "ledger_close_time": "2019-03-01T12:00:00Z", "offer_id": 42,
+			 "base_account": "%s", "base_amount": "10.0000000", "base_asset_type": "native",
+			 "counter_account": "%s", "counter_amount": "2.5000000", "counter_asset_type": "credit_alphanum4",
+			 "counter_asset_code": "USD", "counter_asset_issuer": "%s", "base_is_seller": true,
+			 "price": {"n": 1, "d": 4}},
+			{"id": "2-0", "paging_token": "2-0", "ledger_close_time": "2019-03-02T12:00:00Z", "offer_id": "43",
+			 "base_account": "%s", "base_amount": "1.0000000", "base_asset_type": "credit_alphanum4",
+			 "base_asset_code": "EUR", "base_asset_issuer": "%s",
+			 "counter_account": "%s", "counter_amount": "3.0000000", "counter_asset_type": "native",
+			 "base_is_seller": false, "price": {"n": "3", "d": "1"}}]}}`,
+			address, issuer, issuer, issuer, issuer, address)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	trades, err := ms.LoadTrades(address, Opts().WithLimit(2).WithCursor("0-0"))
+	if err != nil {
+		t.Fatalf("LoadTrades failed: %v", ErrorString(err))
+	}
+
+	if query != "cursor=0-0&limit=2&order=asc" {
+		t.Errorf("wrong query: %s", query)
+	}
+
+	if len(trades) != 2 {
+		t.Fatalf("want 2 trades, got %d", len(trades))
+	}
+
+	trade := trades[0]
+	if trade.OfferID != "42" || !trade.BaseAsset.IsNative() || trade.BaseAmount != "10.0000000" ||
+		trade.CounterAsset.Code != "USD" || trade.CounterAmount != "2.5000000" || !trade.BaseIsSeller ||
+		trade.Price != "0.2500000" || trade.LedgerCloseTime.Day() != 1 {
+		t.Errorf("unexpected trade: %+v", trade)
+	}
+
+	if trades[1].OfferID != "43" || trades[1].Price != "3.0000000" {
+		t.Errorf("unexpected trade: %+v", trades[1])
+	}
+
+	// Filter by asset pair, in either direction.
+	USD := NewAsset("USD", issuer, Credit4Type)
+	trades, cursor, err := ms.LoadTradesPage(address, Opts().WithAssetPair(USD, NativeAsset))
+	if err != nil {
+		t.Fatalf("LoadTradesPage failed: %v", ErrorString(err))
+	}
+
+	if len(trades) != 1 || trades[0].ID != "1-0" {
+		t.Errorf("want trade 1-0, got %+v", trades)
+	}
+
+	if cursor != "2-0" {
+		t.Errorf("want cursor 2-0, got %s", cursor)
+	}
+
+	INR := NewAsset("INR", issuer, Credit4Type)
+	if trades, err := ms.LoadTrades(address, Opts().WithAssetPair(INR, NativeAsset)); err != nil || len(trades) != 0 {
+		t.Errorf("want no trades, got %+v: %v", trades, err)
+	}
+
+	if _, err := ms.LoadTrades("bad address"); err == nil {
+		t.Errorf("want error for invalid address")
+	}
+}
+
+func TestLoadTradeAggregations(t *testing.T) {
+	issuer := "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"
+	USD := NewAsset("USD", issuer, Credit4Type)
+
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprint(w, `{"_embedded": {"records": [
+			{"timestamp": 1551441600000, "trade_count": 3, "base_volume": "30.0000000", "counter_volume": "7.5000000",
+			 "avg": "0.2500000", "open": "0.2000000", "high": "0.3000000", "low": "0.2000000", "close": "0.2500000"},
+			{"timestamp": "1551445200000", "trade_count": "1", "base_volume": "1.0000000", "counter_volume": "0.2600000",
+			 "avg": "0.2600000", "open": "0.2600000", "high": "0.2600000", "low": "0.2600000", "close": "0.2600000"}]}}`)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	start := time.Unix(1551441600, 0)
+	end := start.Add(2 * time.Hour)
+
+	candles, err := ms.LoadTradeAggregations(NativeAsset, USD, Resolution1h, start, end, Opts().WithLimit(10))
+	if err != nil {
+		t.Fatalf("LoadTradeAggregations failed: %v", ErrorString(err))
+	}
+
+	want := url.Values{
+		"base_asset_type":      {"native"},
+		"counter_asset_type":   {"credit_alphanum4"},
+		"counter_asset_code":   {"USD"},
+		"counter_asset_issuer": {issuer},
+		"resolution":           {"3600000"},
+		"start_time":           {"1551441600000"},
+		"end_time":             {"1551448800000"},
+		"limit":                {"10"},
+		"order":                {"asc"},
+	}
+
+	if query.Encode() != want.Encode() {
+		t.Errorf("wrong query: want %s, got %s", want.Encode(), query.Encode())
+	}
+
+	if len(candles) != 2 {
+		t.Fatalf("want 2 candles, got %d", len(candles))
+	}
+
+	if c := candles[0]; !c.Timestamp.Equal(start) || c.TradeCount != 3 || c.Open != "0.2000000" ||
+		c.High != "0.3000000" || c.Low != "0.2000000" || c.Close != "0.2500000" || c.BaseVolume != "30.0000000" {
+		t.Errorf("unexpected candle: %+v", c)
+	}
+
+	if c := candles[1]; !c.Timestamp.Equal(start.Add(time.Hour)) || c.TradeCount != 1 {
+		t.Errorf("unexpected candle: %+v", c)
+	}
+
+	if _, err := ms.LoadTradeAggregations(NativeAsset, USD, 2*time.Hour, start, end); err == nil {
+		t.Errorf("want error for unsupported resolution")
+	}
+
+	if _, err := ms.LoadTradeAggregations(NativeAsset, USD, Resolution1h, end, start); err == nil {
+		t.Errorf("want error for end before start")
+	}
+}
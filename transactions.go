@@ -0,0 +1,326 @@
+package microstellar
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// ErrTransactionNotFound is returned by GetTransaction if Horizon doesn't know about the
+// transaction. Use errors.Cause to check for it.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// TxResultCodes are the Horizon result codes of a transaction, e.g., "tx_failed", and one
+// code per operation, e.g., "op_underfunded".
+type TxResultCodes horizon.TransactionResultCodes
+
+// horizonTransaction is a Horizon transaction record. Newer Horizons report the fee in
+// fee_charged (as a string) instead of fee_paid.
+type horizonTransaction struct {
+	horizon.Transaction
+	FeeCharged interface{} `json:"fee_charged"`
+	Successful *bool       `json:"successful"`
+}
+
+type horizonTransactionsPage struct {
+	Embedded struct {
+		Records []horizonTransaction `json:"records"`
+	} `json:"_embedded"`
+}
+
+// newTransactionFromHorizon creates a new transaction from a Horizon transaction record.
+func newTransactionFromHorizon(ht horizonTransaction) Transaction {
+	tx := Transaction(ht.Transaction)
+
+	if tx.FeePaid == 0 {
+		if fee, err := strconv.ParseInt(horizonID(ht.FeeCharged), 10, 32); err == nil {
+			tx.FeePaid = int32(fee)
+		}
+	}
+
+	return tx
+}
+
+// LoadTransactions returns the transactions in the history of the account at address. Each
+// transaction includes its hash, ledger, creation time (LedgerCloseTime), fee paid, memo, and
+// operation count. Use WithLimit, WithCursor and WithSortOrder to page through the results; e.g.,
+// to page backwards from the most recent transaction:
+//
+//   txs, err := ms.LoadTransactions("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+//     microstellar.Opts().WithLimit(20).WithSortOrder(microstellar.SortDescending))
+//
+//   // Next (older) page
+//   txs, err = ms.LoadTransactions("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E",
+//     microstellar.Opts().WithLimit(20).WithSortOrder(microstellar.SortDescending).WithCursor(txs[len(txs)-1].PT))
+//
+// Returns an empty slice if the account has no transactions.
+func (ms *MicroStellar) LoadTransactions(address string, options ...*Options) ([]Transaction, error) {
+	if err := ValidAddress(address); err != nil {
+		return nil, ms.errorf("invalid address: %s", address)
+	}
+
+	opt := mergeOptions(options)
+	query := pageQuery(opt)
+
+	ms.debugf("LoadTransactions", "loading transactions for %s, with params %+v", address, query)
+	if ms.fake {
+		return []Transaction{}, ms.success()
+	}
+
+	var page horizonTransactionsPage
+	path := fmt.Sprintf("/accounts/%s/transactions?%s", address, query.Encode())
+	if err := getJSON(ms.logger(), clientWithContext(opt.ctx, ms.getTx().GetClient()), path, &page); err != nil {
+		return nil, ms.wrapf(err, "can't load transactions")
+	}
+
+	txs := make([]Transaction, len(page.Embedded.Records))
+	for i, ht := range page.Embedded.Records {
+		txs[i] = newTransactionFromHorizon(ht)
+	}
+
+	return txs, ms.success()
+}
+
+// horizonResultCodes maps the XDR result codes whose Horizon names don't follow from the
+// XDR names.
+var horizonResultCodes = map[string]string{
+	"OperationResultCodeOpNoAccount":                   "op_no_source_account",
+	"CreateAccountResultCodeCreateAccountAlreadyExist": "op_already_exists",
+	"PathPaymentResultCodePathPaymentOverSendmax":      "op_over_source_max",
+	"ManageOfferResultCodeManageOfferNotFound":         "op_offer_not_found",
+	"AllowTrustResultCodeAllowTrustNoTrustLine":        "op_no_trustline",
+	"AllowTrustResultCodeAllowTrustTrustNotRequired":   "op_not_required",
+	"ManageDataResultCodeManageDataNameNotFound":       "op_data_name_not_found",
+	"ManageDataResultCodeManageDataInvalidName":        "op_data_invalid_name",
+}
+
+// horizonResultCode converts the name of an XDR result code (e.g., "PaymentResultCodePaymentUnderfunded")
+// to the code Horizon uses for it (e.g., "op_underfunded".)
+func horizonResultCode(name string) string {
+	if code, ok := horizonResultCodes[name]; ok {
+		return code
+	}
+
+	i := strings.Index(name, "ResultCode")
+	if i < 0 {
+		return name
+	}
+
+	kind := name[:i]
+	code := strings.TrimPrefix(name[i+len("ResultCode"):], kind)
+
+	var snake []rune
+	if kind != "Transaction" && kind != "Operation" {
+		snake = []rune("op_")
+	}
+
+	for j, r := range code {
+		if unicode.IsUpper(r) {
+			if j > 0 {
+				snake = append(snake, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		snake = append(snake, r)
+	}
+
+	return string(snake)
+}
+
+// operationResultCode returns the Horizon code for the result of a single operation.
+func operationResultCode(result xdr.OperationResult) string {
+	if result.Code != xdr.OperationResultCodeOpInner || result.Tr == nil {
+		return horizonResultCode(result.Code.String())
+	}
+
+	var code fmt.Stringer
+	tr := result.Tr
+	switch tr.Type {
+	case xdr.OperationTypeCreateAccount:
+		code = tr.MustCreateAccountResult().Code
+	case xdr.OperationTypePayment:
+		code = tr.MustPaymentResult().Code
+	case xdr.OperationTypePathPayment:
+		code = tr.MustPathPaymentResult().Code
+	case xdr.OperationTypeManageOffer:
+		code = tr.MustManageOfferResult().Code
+	case xdr.OperationTypeCreatePassiveOffer:
+		code = tr.MustCreatePassiveOfferResult().Code
+	case xdr.OperationTypeSetOptions:
+		code = tr.MustSetOptionsResult().Code
+	case xdr.OperationTypeChangeTrust:
+		code = tr.MustChangeTrustResult().Code
+	case xdr.OperationTypeAllowTrust:
+		code = tr.MustAllowTrustResult().Code
+	case xdr.OperationTypeAccountMerge:
+		code = tr.MustAccountMergeResult().Code
+	case xdr.OperationTypeInflation:
+		code = tr.MustInflationResult().Code
+	case xdr.OperationTypeManageData:
+		code = tr.MustManageDataResult().Code
+	case xdr.OperationTypeBumpSequence:
+		code = tr.MustBumpSeqResult().Code
+	default:
+		return "op_unknown"
+	}
+
+	return horizonResultCode(code.String())
+}
+
+// ResultCodes decodes the transaction's result XDR and returns its result codes.
+func (tx *Transaction) ResultCodes() (*TxResultCodes, error) {
+	var result xdr.TransactionResult
+	if err := xdr.SafeUnmarshalBase64(tx.ResultXdr, &result); err != nil {
+		return nil, errors.Wrapf(err, "can't decode transaction result")
+	}
+
+	codes := &TxResultCodes{
+		TransactionCode: horizonResultCode(result.Result.Code.String()),
+	}
+
+	if results, ok := result.Result.GetResults(); ok {
+		for _, r := range results {
+			codes.OperationCodes = append(codes.OperationCodes, operationResultCode(r))
+		}
+	}
+
+	return codes, nil
+}
+
+// OperationResult is the result of a single operation in a submitted transaction.
+type OperationResult struct {
+	Index int    // the operation's position in the transaction
+	Code  string // the Horizon result code, e.g., "op_success" or "op_no_trust"
+}
+
+// LastOperationResults returns the result of each operation in the last failed submission, as
+// decoded from the result XDR in Horizon's response. Use this to find out which operations made a
+// multi-op transaction fail.
+//
+//   if err := ms.Submit(); err != nil {
+//       results, _ := ms.LastOperationResults()
+//       for _, r := range results {
+//           if r.Code != "op_success" {
+//               log.Printf("operation %d failed: %s", r.Index, r.Code)
+//           }
+//       }
+//   }
+//
+// Unlike most methods, LastOperationResults doesn't change Err(), so the submission error is
+// still available. It fails if the last error is not a Horizon transaction failure.
+func (ms *MicroStellar) LastOperationResults() ([]OperationResult, error) {
+	lastErr := ms.Err()
+	if lastErr == nil {
+		return nil, errors.Errorf("no failed submission")
+	}
+
+	herr, ok := errors.Cause(lastErr).(*horizon.Error)
+	if !ok {
+		return nil, errors.Wrapf(lastErr, "last error is not a horizon error")
+	}
+
+	b64, err := herr.ResultString()
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get transaction result")
+	}
+
+	var result xdr.TransactionResult
+	if err := xdr.SafeUnmarshalBase64(b64, &result); err != nil {
+		// The vendored XDR package can't decode the results of newer operations (see rawop.go),
+		// so fall back to the codes Horizon reports.
+		codes, codesErr := herr.ResultCodes()
+		if codesErr != nil {
+			return nil, errors.Wrapf(err, "can't decode transaction result")
+		}
+
+		results := make([]OperationResult, len(codes.OperationCodes))
+		for i, code := range codes.OperationCodes {
+			results[i] = OperationResult{Index: i, Code: code}
+		}
+
+		return results, nil
+	}
+
+	xdrResults, _ := result.Result.GetResults()
+	results := make([]OperationResult, len(xdrResults))
+	for i, r := range xdrResults {
+		results[i] = OperationResult{Index: i, Code: operationResultCode(r)}
+	}
+
+	return results, nil
+}
+
+// Envelope decodes the transaction's envelope XDR. See InspectTransaction for details.
+func (tx *Transaction) Envelope() (*TransactionInfo, error) {
+	if tx.EnvelopeXdr == "" {
+		return nil, errors.Errorf("transaction has no envelope")
+	}
+
+	return inspectEnvelope(tx.EnvelopeXdr)
+}
+
+// GetTransaction loads the transaction with the given hash (hex or base64 encoded) from Horizon.
+// Use Transaction.ResultCodes and Transaction.Envelope to decode its result and envelope. If
+// there's no such transaction, returns an error whose cause is ErrTransactionNotFound.
+//
+//   tx, err := ms.GetTransaction(ms.LastTxHash())
+//   if errors.Cause(err) == microstellar.ErrTransactionNotFound {
+//       log.Print("transaction not in ledger yet")
+//   }
+func (ms *MicroStellar) GetTransaction(hash string) (*Transaction, error) {
+	txHash, err := ParseTxHash(hash)
+	if err != nil {
+		return nil, ms.wrapf(err, "can't get transaction")
+	}
+
+	hexHash := hex.EncodeToString(txHash[:])
+	ms.debugf("GetTransaction", "loading transaction: %s", hexHash)
+
+	if ms.fake {
+		tx := newFakeTransaction("", 1)
+		tx.ID = hexHash
+		tx.Hash = hexHash
+		return tx, ms.success()
+	}
+
+	ht, err := getHorizonTransaction(ms.getTx().GetClient(), hexHash)
+	if err != nil {
+		return nil, ms.err(err)
+	}
+
+	tx := newTransactionFromHorizon(*ht)
+	return &tx, ms.success()
+}
+
+// getHorizonTransaction loads the Horizon record of the transaction with the hex-encoded hash
+// hexHash.
+func getHorizonTransaction(client *horizon.Client, hexHash string) (*horizonTransaction, error) {
+	resp, err := client.HTTP.Get(strings.TrimRight(client.URL, "/") + "/transactions/" + hexHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get transaction %s", hexHash)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(ErrTransactionNotFound, "can't get transaction %s", hexHash)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("can't get transaction %s: %s", hexHash, resp.Status)
+	}
+
+	var ht horizonTransaction
+	if err := json.NewDecoder(resp.Body).Decode(&ht); err != nil {
+		return nil, errors.Wrapf(err, "can't get transaction %s: error unmarshalling response", hexHash)
+	}
+
+	return &ht, nil
+}
@@ -0,0 +1,284 @@
+package microstellar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/xdr"
+)
+
+func TestLoadTransactions(t *testing.T) {
+	address := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/"+address+"/transactions" {
+			fmt.Fprint(w, `{"_embedded": {"records": []}}`)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("order") != "desc" || query.Get("limit") != "2" || query.Get("cursor") != "999" {
+			t.Errorf("wrong query: %s", r.URL.RawQuery)
+		}
+
+		fmt.Fprintf(w, `{"_embedded": {"records": [
+			{"id": "aa", "paging_token": "998", "hash": "aa", "ledger": 20, "created_at": "2018-05-02T10:00:00Z",
+			 "source_account": "%[1]s", "fee_paid": 200, "operation_count": 2, "memo_type": "text", "memo": "hello"},
+			{"id": "bb", "paging_token": "997", "hash": "bb", "ledger": 19, "created_at": "2018-05-01T10:00:00Z",
+			 "source_account": "%[1]s", "fee_charged": "100", "operation_count": 1, "memo_type": "none"}
+		]}}`, address)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	txs, err := ms.LoadTransactions(address, Opts().WithLimit(2).WithCursor("999").WithSortOrder(SortDescending))
+	if err != nil {
+		t.Fatalf("LoadTransactions failed: %v", err)
+	}
+
+	if len(txs) != 2 {
+		t.Fatalf("want 2 transactions, got %d", len(txs))
+	}
+
+	if txs[0].Hash != "aa" || txs[0].Ledger != 20 || txs[0].FeePaid != 200 || txs[0].OperationCount != 2 ||
+		txs[0].Memo != "hello" || txs[0].LedgerCloseTime.Day() != 2 || txs[0].PT != "998" {
+		t.Errorf("wrong transaction: %+v", txs[0])
+	}
+
+	if txs[1].Hash != "bb" || txs[1].FeePaid != 100 || txs[1].MemoType != "none" {
+		t.Errorf("wrong transaction: %+v", txs[1])
+	}
+
+	txs, err = ms.LoadTransactions("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A")
+	if err != nil || txs == nil || len(txs) != 0 {
+		t.Errorf("want empty transactions, got %v: %v", txs, err)
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	found := strings.Repeat("ab", 32)
+	missing := strings.Repeat("cd", 32)
+
+	tx, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: source},
+		build.Sequence{Sequence: 101},
+		build.TestNetwork,
+		build.Payment(build.Destination{AddressOrSeed: "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"}, build.NativeAmount{Amount: "10"}),
+		build.CreateAccount(build.Destination{AddressOrSeed: "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ"}, build.NativeAmount{Amount: "10"}),
+	)
+	if err != nil {
+		t.Fatalf("can't build transaction: %v", err)
+	}
+
+	txe, _ := tx.Sign()
+	envelope, _ := txe.Base64()
+
+	paymentResult, _ := xdr.NewOperationResultTr(xdr.OperationTypePayment,
+		xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentUnderfunded})
+	createResult, _ := xdr.NewOperationResultTr(xdr.OperationTypeCreateAccount,
+		xdr.CreateAccountResult{Code: xdr.CreateAccountResultCodeCreateAccountAlreadyExist})
+	results := []xdr.OperationResult{
+		{Code: xdr.OperationResultCodeOpInner, Tr: &paymentResult},
+		{Code: xdr.OperationResultCodeOpInner, Tr: &createResult},
+	}
+	result, _ := xdr.MarshalBase64(xdr.TransactionResult{
+		FeeCharged: 200,
+		Result:     xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxFailed, Results: &results},
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transactions/"+found {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"status": 404, "title": "Resource Missing"}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"id": "%[1]s", "hash": "%[1]s", "ledger": 7, "source_account": "%[2]s", "fee_charged": "200",
+			"operation_count": 2, "envelope_xdr": "%[3]s", "result_xdr": "%[4]s"}`, found, source, envelope, result)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	got, err := ms.GetTransaction(found)
+	if err != nil {
+		t.Fatalf("GetTransaction failed: %v", err)
+	}
+
+	if got.Hash != found || got.Ledger != 7 || got.FeePaid != 200 || got.OperationCount != 2 {
+		t.Errorf("wrong transaction: %+v", got)
+	}
+
+	codes, err := got.ResultCodes()
+	if err != nil {
+		t.Fatalf("ResultCodes failed: %v", err)
+	}
+
+	if codes.TransactionCode != "tx_failed" || len(codes.OperationCodes) != 2 ||
+		codes.OperationCodes[0] != "op_underfunded" || codes.OperationCodes[1] != "op_already_exists" {
+		t.Errorf("wrong result codes: %+v", codes)
+	}
+
+	info, err := got.Envelope()
+	if err != nil {
+		t.Fatalf("Envelope failed: %v", err)
+	}
+
+	if info.Source != source || info.Sequence != "101" || len(info.Operations) != 2 {
+		t.Errorf("wrong envelope: %+v", info)
+	}
+
+	if _, err := ms.GetTransaction(missing); errors.Cause(err) != ErrTransactionNotFound {
+		t.Errorf("want ErrTransactionNotFound, got: %v", err)
+	}
+
+	if _, err := ms.GetTransaction("not a hash"); err == nil || errors.Cause(err) == ErrTransactionNotFound {
+		t.Errorf("want invalid hash error, got: %v", err)
+	}
+}
+
+func TestHorizonResultCode(t *testing.T) {
+	tests := map[string]string{
+		"TransactionResultCodeTxBadSeq":               "tx_bad_seq",
+		"TransactionResultCodeTxBadAuthExtra":         "tx_bad_auth_extra",
+		"OperationResultCodeOpNoAccount":              "op_no_source_account",
+		"OperationResultCodeOpBadAuth":                "op_bad_auth",
+		"PaymentResultCodePaymentSrcNoTrust":          "op_src_no_trust",
+		"ManageOfferResultCodeManageOfferSellNoTrust": "op_sell_no_trust",
+		"AccountMergeResultCodeAccountMergeNoAccount": "op_no_account",
+		"BumpSequenceResultCodeBumpSequenceSuccess":   "op_success",
+	}
+
+	for name, want := range tests {
+		if got := horizonResultCode(name); got != want {
+			t.Errorf("horizonResultCode(%s): want %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestLastOperationResults(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	successResult, _ := xdr.NewOperationResultTr(xdr.OperationTypePayment,
+		xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentSuccess})
+	noTrustResult, _ := xdr.NewOperationResultTr(xdr.OperationTypePayment,
+		xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentNoTrust})
+	results := []xdr.OperationResult{
+		{Code: xdr.OperationResultCodeOpInner, Tr: &successResult},
+		{Code: xdr.OperationResultCodeOpInner, Tr: &noTrustResult},
+	}
+	result, _ := xdr.MarshalBase64(xdr.TransactionResult{
+		FeeCharged: 200,
+		Result:     xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxFailed, Results: &results},
+	})
+
+	resultXDR := result
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"type": "transaction_failed", "title": "Transaction Failed", "status": 400,
+			"extras": {"result_xdr": "%s", "result_codes": {"transaction": "tx_failed",
+			"operations": ["op_success", "op_no_trust"]}}}`, resultXDR)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if _, err := ms.LastOperationResults(); err == nil {
+		t.Errorf("want error with no failed submission")
+	}
+
+	// "AAAA" can't be decoded, so the results come from the result codes.
+	for _, resultXDR = range []string{result, "AAAA"} {
+		ms.Start(source)
+		ms.Pay(source, bob, "1", NativeAsset)
+		ms.Pay(source, bob, "1", NativeAsset)
+		if err := ms.Submit(); err == nil {
+			t.Fatalf("want submission error")
+		}
+
+		got, err := ms.LastOperationResults()
+		if err != nil {
+			t.Fatalf("LastOperationResults failed: %v", err)
+		}
+
+		want := []OperationResult{{0, "op_success"}, {1, "op_no_trust"}}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("wrong results for %s: want %v, got %v", resultXDR, want, got)
+		}
+
+		if ms.Err() == nil {
+			t.Errorf("LastOperationResults cleared the submission error")
+		}
+	}
+
+	ms.errorf("not a horizon error")
+	if _, err := ms.LastOperationResults(); err == nil {
+		t.Errorf("want error for non-horizon errors")
+	}
+}
+
+func TestSubmitTransactionAndConfirm(t *testing.T) {
+	hash := "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889"
+
+	defer func(interval time.Duration) { confirmPollInterval = interval }(confirmPollInterval)
+	confirmPollInterval = 10 * time.Millisecond
+
+	polls := 0
+	pending := 2
+	successful := "true"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			// Accepted, but not yet in a ledger.
+			fmt.Fprintf(w, `{"hash": "%s", "ledger": 0}`, hash)
+			return
+		}
+
+		polls++
+		if r.URL.Path != "/transactions/"+hash || polls <= pending {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, `{"hash": "%s", "ledger": 1234, "successful": %s, "result_xdr": "AAAAAAAAAGQAAAAAAAAAAQAAAAAAAAABAAAAAAAAAAA="}`, hash, successful)
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	resp, err := ms.SubmitTransactionAndConfirm(context.Background(), "PAYLOAD")
+	if err != nil {
+		t.Fatalf("SubmitTransactionAndConfirm failed: %v", ErrorString(err))
+	}
+
+	if resp.Hash != hash || resp.Ledger != 1234 || resp.Result == "" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	if polls != pending+1 {
+		t.Errorf("want %d polls, got %d", pending+1, polls)
+	}
+
+	// Failed transactions are returned with an error.
+	polls, successful = 0, "false"
+	resp, err = ms.SubmitTransactionAndConfirm(context.Background(), "PAYLOAD")
+	if err == nil || resp == nil || resp.Ledger != 1234 {
+		t.Errorf("want failed transaction with error, got %+v: %v", resp, err)
+	}
+
+	// Give up when the context expires.
+	polls, pending = 0, 1000
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := ms.SubmitTransactionAndConfirm(ctx, "PAYLOAD"); err == nil {
+		t.Errorf("want error when context expires")
+	}
+}
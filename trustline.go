@@ -0,0 +1,145 @@
+package microstellar
+
+import (
+	"github.com/pkg/errors"
+)
+
+// TrustLineFlags are the flags on a trust line, which are controlled by the asset's issuer.
+type TrustLineFlags uint32
+
+const (
+	// TrustLineAuthorized means the account can hold and transact in the asset.
+	TrustLineAuthorized = TrustLineFlags(1)
+
+	// TrustLineAuthorizedToMaintainLiabilities means the account can keep its existing
+	// offers open, but can't otherwise transact in the asset.
+	TrustLineAuthorizedToMaintainLiabilities = TrustLineFlags(2)
+
+	// TrustLineClawbackEnabled means the issuer can claw back the asset from the account. It
+	// can only be cleared.
+	TrustLineClawbackEnabled = TrustLineFlags(4)
+)
+
+// XDR operation types for trust line authorization.
+const (
+	opAllowTrust        = 7
+	opSetTrustLineFlags = 21
+)
+
+// assetCode encodes the XDR AssetCode union for code.
+func (w *xdrWriter) assetCode(code string) {
+	if len(code) == 0 || len(code) > 12 {
+		w.err = errors.Errorf("invalid asset code: %s", code)
+		return
+	}
+
+	size := 4
+	if len(code) > 4 {
+		size = 12
+		w.uint32(uint32(2))
+	} else {
+		w.uint32(uint32(1))
+	}
+
+	padded := make([]byte, size)
+	copy(padded, code)
+	w.raw(padded)
+}
+
+// AllowTrustWithFlags sets the authorization of the trust line from the account at address to
+// assetCode (which must be issued by sourceSeed). Unlike AllowTrust, this supports the intermediate
+// TrustLineAuthorizedToMaintainLiabilities state, which freezes the account's balance but keeps
+// its open offers. flags must be 0 (to revoke authorization), TrustLineAuthorized, or
+// TrustLineAuthorizedToMaintainLiabilities.
+//
+//   err := ms.AllowTrustWithFlags(issuerSeed, bobAddress, "USD", microstellar.TrustLineAuthorizedToMaintainLiabilities)
+func (ms *MicroStellar) AllowTrustWithFlags(sourceSeed string, address string, assetCode string, flags TrustLineFlags, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't authorize trust line: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if err := ValidAddress(address); err != nil {
+		return ms.errorf("can't authorize trust line: invalid account address: %s", address)
+	}
+
+	switch flags {
+	case 0, TrustLineAuthorized, TrustLineAuthorizedToMaintainLiabilities:
+	default:
+		return ms.errorf("can't authorize trust line: invalid flags: %d", flags)
+	}
+
+	w := &xdrWriter{}
+	w.uint32(opAllowTrust)
+	w.account(address)
+	w.assetCode(assetCode)
+	w.uint32(uint32(flags))
+
+	body, err := w.bytes()
+	if err != nil {
+		return ms.wrapf(err, "can't authorize trust line")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), tx.rawOp("", body))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
+
+// SetTrustLineFlags sets and clears flags on the trust line from the account at address to asset,
+// which must be issued by sourceSeed. Use this to migrate existing trust lines, e.g., to clear
+// TrustLineClawbackEnabled, or to move between authorization states. It's an error to set and
+// clear the same flag, or to set TrustLineClawbackEnabled.
+//
+//   // Stop clawbacks on Bob's trust line.
+//   err := ms.SetTrustLineFlags(issuerSeed, bobAddress, USD, 0, microstellar.TrustLineClawbackEnabled)
+func (ms *MicroStellar) SetTrustLineFlags(sourceSeed string, address string, asset *Asset, setFlags TrustLineFlags, clearFlags TrustLineFlags, options ...*Options) error {
+	if !ValidAddressOrSeed(sourceSeed) {
+		return ms.errorf("can't set trust line flags: invalid source address or seed: %s", sourceSeed)
+	}
+
+	if err := ValidAddress(address); err != nil {
+		return ms.errorf("can't set trust line flags: invalid account address: %s", address)
+	}
+
+	if err := asset.Validate(); err != nil {
+		return ms.wrapf(err, "can't set trust line flags")
+	}
+
+	if asset.IsNative() {
+		return ms.errorf("can't set trust line flags: native assets have no trust lines")
+	}
+
+	allFlags := TrustLineAuthorized | TrustLineAuthorizedToMaintainLiabilities | TrustLineClawbackEnabled
+	if setFlags&^allFlags != 0 || clearFlags&^allFlags != 0 || setFlags&clearFlags != 0 {
+		return ms.errorf("can't set trust line flags: invalid flags: set %d, clear %d", setFlags, clearFlags)
+	}
+
+	if setFlags&TrustLineClawbackEnabled != 0 {
+		return ms.errorf("can't set trust line flags: TrustLineClawbackEnabled can only be cleared")
+	}
+
+	w := &xdrWriter{}
+	w.uint32(opSetTrustLineFlags)
+	w.account(address)
+	w.asset(asset)
+	w.uint32(uint32(clearFlags))
+	w.uint32(uint32(setFlags))
+
+	body, err := w.bytes()
+	if err != nil {
+		return ms.wrapf(err, "can't set trust line flags")
+	}
+
+	tx := ms.getTx()
+
+	if len(options) > 0 {
+		tx.SetOptions(options[0])
+	}
+
+	tx.Build(sourceAccount(sourceSeed), tx.rawOp("", body))
+	return ms.signAndSubmit(tx, sourceSeed)
+}
@@ -0,0 +1,150 @@
+package microstellar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTrustLineFlags(t *testing.T) {
+	issuer := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	w := &xdrWriter{}
+	w.account(bob)
+	bobBytes, _ := w.bytes()
+	account := hex.EncodeToString(bobBytes)
+
+	w = &xdrWriter{}
+	w.asset(USD)
+	assetBytes, _ := w.bytes()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	tests := []struct {
+		submit func() error
+		want   string
+	}{
+		{
+			func() error {
+				return ms.AllowTrustWithFlags(issuer, bob, "USD", TrustLineAuthorizedToMaintainLiabilities, Opts().WithDryRun())
+			},
+			"00000007" + account + "00000001" + hex.EncodeToString([]byte("USD\x00")) + "00000002",
+		},
+		{
+			func() error {
+				return ms.AllowTrustWithFlags(issuer, bob, "LONGCODE", 0, Opts().WithDryRun())
+			},
+			"00000007" + account + "00000002" + hex.EncodeToString([]byte("LONGCODE\x00\x00\x00\x00")) + "00000000",
+		},
+		{
+			func() error {
+				return ms.SetTrustLineFlags(issuer, bob, USD, TrustLineAuthorized, TrustLineClawbackEnabled, Opts().WithDryRun())
+			},
+			"00000015" + account + hex.EncodeToString(assetBytes) + "00000004" + "00000001",
+		},
+	}
+
+	for i, test := range tests {
+		if err := test.submit(); err != nil {
+			t.Fatalf("%d: failed: %v", i, ErrorString(err))
+		}
+
+		payload, _ := ms.LastPayload()
+		envelope, _ := base64.StdEncoding.DecodeString(payload)
+		want, _ := hex.DecodeString(test.want)
+		if !bytes.Contains(envelope, want) {
+			t.Errorf("%d: operation %s not found in %x", i, test.want, envelope)
+		}
+	}
+
+	if err := ms.AllowTrustWithFlags(issuer, bob, "USD", TrustLineAuthorized|TrustLineAuthorizedToMaintainLiabilities); err == nil {
+		t.Errorf("want error for conflicting authorization flags")
+	}
+
+	if err := ms.AllowTrustWithFlags(issuer, bob, "THIRTEENCHARS", TrustLineAuthorized); err == nil {
+		t.Errorf("want error for bad asset code")
+	}
+
+	if err := ms.SetTrustLineFlags(issuer, bob, USD, TrustLineClawbackEnabled, 0); err == nil {
+		t.Errorf("want error for setting clawback")
+	}
+
+	if err := ms.SetTrustLineFlags(issuer, bob, USD, TrustLineAuthorized, TrustLineAuthorized); err == nil {
+		t.Errorf("want error for setting and clearing the same flag")
+	}
+
+	if err := ms.SetTrustLineFlags(issuer, bob, NativeAsset, TrustLineAuthorized, 0); err == nil {
+		t.Errorf("want error for native asset")
+	}
+
+	if submissions != 0 {
+		t.Errorf("want no submissions, got %d", submissions)
+	}
+}
+
+func TestAllowTrustForAsset(t *testing.T) {
+	issuer := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	issuerAddress := "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R"
+	bob := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	check := func(code string, authorized bool) {
+		payload, _ := ms.LastPayload()
+		txe, err := DecodeTx(payload)
+		if err != nil {
+			t.Fatalf("can't decode payload: %v", err)
+		}
+
+		op := txe.Tx.Operations[0].Body.MustAllowTrustOp()
+		if op.Trustor.Address() != bob || op.Authorize != authorized {
+			t.Errorf("want %s authorized=%v, got %s authorized=%v", bob, authorized, op.Trustor.Address(), op.Authorize)
+		}
+
+		var got string
+		if op.Asset.AssetCode4 != nil {
+			got = string(bytes.TrimRight(op.Asset.AssetCode4[:], "\x00"))
+		} else {
+			got = string(bytes.TrimRight(op.Asset.AssetCode12[:], "\x00"))
+		}
+
+		if got != code {
+			t.Errorf("want asset code %s, got %s", code, got)
+		}
+	}
+
+	USD := NewAsset("USD", issuerAddress, Credit4Type)
+	if err := ms.AllowTrustForAsset(issuer, bob, USD, true, Opts().WithDryRun()); err != nil {
+		t.Fatalf("AllowTrustForAsset failed: %v", ErrorString(err))
+	}
+	check("USD", true)
+
+	// AllowTrust delegates to AllowTrustForAsset.
+	if err := ms.AllowTrust(issuer, bob, "DOLLARS", false, Opts().WithDryRun()); err != nil {
+		t.Fatalf("AllowTrust failed: %v", ErrorString(err))
+	}
+	check("DOLLARS", false)
+
+	otherUSD := NewAsset("USD", bob, Credit4Type)
+	if err := ms.AllowTrustForAsset(issuer, bob, otherUSD, true, Opts().WithDryRun()); err == nil {
+		t.Errorf("want error for asset issued by another account")
+	}
+
+	if err := ms.AllowTrustForAsset(issuer, bob, NativeAsset, true, Opts().WithDryRun()); err == nil {
+		t.Errorf("want error for native asset")
+	}
+
+	if err := ms.AllowTrust(issuer, bob, "", true, Opts().WithDryRun()); err == nil {
+		t.Errorf("want error for empty asset code")
+	}
+}
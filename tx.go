@@ -1,12 +1,17 @@
 package microstellar
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"github.com/stellar/go/build"
 	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/xdr"
 )
 
@@ -27,27 +32,40 @@ type Tx struct {
 	builder       *build.TransactionBuilder
 	payload       string
 	submitted     bool
-	response      *horizon.TransactionSuccess
+	sentPayload   string // envelope most recently sent to Horizon
+	response      *TxResponse
 	isMultiOp     bool                       // is this a multi-op transaction
 	ops           []build.TransactionMutator // all ops for multi-op
+	opCount       int                        // operations appended to a multi-op transaction
+	rawOps        map[int][]byte             // hand-encoded ops, by index (see rawop.go)
+	opSources     bool                       // set per-op source accounts for multi-op
+	opSigners     []string                   // seeds of operation source accounts (see Options.WithSourceAccount)
 	sourceAccount string
+	ledger        *FakeLedger // fake network ledger, or nil (see MicroStellar.FakeLedger)
+	fakeOps       []fakeTxOp  // changes to the fake ledger
+	fakeSource    string      // source account of single-op transactions on the fake ledger
+	logger        Logger      // custom logger, or nil for logrus
 	err           error
 }
 
 // NewTx returns a new Tx that operates on the network specified by
 // networkName. The supported networks are:
 //
-//    public: the public horizon network
-//    test: the public horizon testnet
-//    fake: a fake network used for tests
-//    custom: a custom network specified by the parameters
+//   public: the public horizon network
+//   test: the public horizon testnet
+//   fake: a fake network used for tests
+//   custom: a custom network specified by the parameters
 //
 // If you're using "custom", provide the URL and Passphrase to your
 // horizon network server in the parameters.
 //
-//    NewTx("custom", Params{
-//        "url": "https://my-horizon-server.com",
-//        "passphrase": "foobar"})
+//   NewTx("custom", Params{
+//       "url": "https://my-horizon-server.com",
+//       "passphrase": "foobar"})
+//
+// To use a custom HTTP client for all requests, set "http_client" to an *http.Client. Setting
+// "passphrase" overrides the network passphrase of the named networks too. To retry requests that
+// Horizon rate limits (429 Too Many Requests), set "maxRetries" to the number of retries.
 func NewTx(networkName string, params ...Params) *Tx {
 	var network build.Network
 	var client *horizon.Client
@@ -67,7 +85,7 @@ func NewTx(networkName string, params ...Params) *Tx {
 		fake = true
 	case "custom":
 		if len(params) < 1 {
-			logrus.Errorf("missing parameters for custom network, connecting to testnet")
+			errorf(nil, "NewTx", "missing parameters for custom network, connecting to testnet")
 			return NewTx("test")
 		}
 
@@ -75,7 +93,7 @@ func NewTx(networkName string, params ...Params) *Tx {
 		passphrase, ok2 := params[0]["passphrase"]
 
 		if !(ok1 && ok2) {
-			logrus.Errorf("missing url or passphrase, connecting to testnet")
+			errorf(loggerFromParams(params...), "NewTx", "missing url or passphrase, connecting to testnet")
 			return NewTx("test")
 		}
 
@@ -90,6 +108,27 @@ func NewTx(networkName string, params ...Params) *Tx {
 		client = horizon.DefaultTestNetClient
 	}
 
+	if networkName != "custom" && len(params) > 0 {
+		// Override the passphrase of named networks, e.g., for private networks that shadow them.
+		if passphrase, ok := params[0]["passphrase"].(string); ok && passphrase != "" {
+			network = build.Network{Passphrase: passphrase}
+		}
+	}
+
+	if httpClient := httpClientFromParams(params...); httpClient != nil {
+		client = &horizon.Client{
+			URL:  client.URL,
+			HTTP: httpClient,
+		}
+	}
+
+	if maxRetries := maxRetriesFromParams(params...); maxRetries > 0 {
+		client = &horizon.Client{
+			URL:  client.URL,
+			HTTP: &retryHTTP{client: client.HTTP, maxRetries: maxRetries, logger: loggerFromParams(params...)},
+		}
+	}
+
 	return &Tx{
 		networkName: networkName,
 		client:      client,
@@ -102,6 +141,7 @@ func NewTx(networkName string, params ...Params) *Tx {
 		response:    nil,
 		isMultiOp:   false,
 		ops:         []build.TransactionMutator{},
+		logger:      loggerFromParams(params...),
 		err:         nil,
 	}
 }
@@ -120,8 +160,13 @@ func (tx *Tx) WithOptions(options *Options) *Tx {
 	return tx
 }
 
-// GetClient returns the underlying horizon client handle.
+// GetClient returns the underlying horizon client handle. If a context was set with
+// Options.WithContext, requests made with the returned client are bound to it.
 func (tx *Tx) GetClient() *horizon.Client {
+	if tx.options != nil && tx.options.ctx != nil {
+		return clientWithContext(tx.options.ctx, tx.client)
+	}
+
 	return tx.client
 }
 
@@ -130,15 +175,66 @@ func (tx *Tx) Err() error {
 	return tx.err
 }
 
-// TxResponse is returned by the horizon server for a successful transaction.
-type TxResponse horizon.TransactionSuccess
+// TxResponse is returned by the horizon server for a successful transaction. The hex-encoded
+// transaction hash is in the Hash field.
+//
+// TxResponse embeds horizon.TransactionSuccess. It used to be a horizon.TransactionSuccess, so
+// code that converts between the two, or builds TxResponse literals with its fields, must use
+// the TransactionSuccess field instead.
+type TxResponse struct {
+	horizon.TransactionSuccess
+
+	// Ledger is the sequence number of the ledger the transaction was included in, or 0 if
+	// Horizon responded before applying it. It shadows (and matches) TransactionSuccess.Ledger.
+	Ledger int64 `json:"ledger"`
+
+	// CreatedAt is the close time of that ledger. It's the zero time if Horizon didn't report it
+	// (older Horizons don't, in submission responses.)
+	CreatedAt time.Time `json:"created_at"`
+}
 
-// Response returns the horison response for the submitted operation.
+// Response returns the horison response for the submitted operation, or nil if nothing was
+// submitted.
 func (tx *Tx) Response() *TxResponse {
-	response := TxResponse(*tx.response)
+	if tx == nil || tx.response == nil {
+		// Nothing was submitted, e.g., for dry runs.
+		return nil
+	}
+
+	response := *tx.response
 	return &response
 }
 
+// Hash returns the hex-encoded hash of the transaction. For submitted transactions, this is the
+// hash returned by Horizon, otherwise it's computed from the built transaction.
+func (tx *Tx) Hash() (string, error) {
+	if tx.response != nil && tx.response.Hash != "" {
+		return tx.response.Hash, nil
+	}
+
+	if tx.fake {
+		return "", errors.Errorf("transactions on the fake network have no hash")
+	}
+
+	if tx.builder == nil {
+		return "", errors.Errorf("transaction not built")
+	}
+
+	var hash string
+	var err error
+	if tx.hasRawOps() {
+		hash, err = tx.rawHashHex()
+	} else {
+		hash, err = tx.builder.HashHex()
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, "could not hash transaction")
+	}
+
+	return hash, nil
+}
+
 // Payload returns the built (and possibly signed) payload for this transaction as a
 // base64 string.
 func (tx *Tx) Payload() (string, error) {
@@ -160,6 +256,11 @@ func (tx *Tx) Payload() (string, error) {
 
 	if tx.payload == "" {
 		// If there's no payload, build it.
+		if tx.hasRawOps() {
+			b64, err := tx.rawEnvelope(nil)
+			return b64, errors.Wrap(err, "error generating payload")
+		}
+
 		var txe build.TransactionEnvelopeBuilder
 		txe.Mutate(tx.builder)
 		b64, err := txe.Base64()
@@ -180,6 +281,7 @@ func (tx *Tx) Reset() {
 	tx.submitted = false
 	tx.response = nil
 	tx.isMultiOp = false
+	tx.rawOps = nil
 	tx.err = nil
 }
 
@@ -187,20 +289,137 @@ func sourceAccount(addressOrSeed string) build.SourceAccount {
 	return build.SourceAccount{AddressOrSeed: addressOrSeed}
 }
 
-// Start begins a new multi-op transaction with fees billed to account
+// opSource is a transaction mutator that applies mut, and sets the source account of any
+// operations it adds to source, unless source is the transaction's source account.
+type opSource struct {
+	source build.TransactionMutator
+	mut    build.TransactionMutator
+}
+
+func (m opSource) MutateTransaction(b *build.TransactionBuilder) error {
+	n := len(b.TX.Operations)
+	if err := m.mut.MutateTransaction(b); err != nil {
+		return err
+	}
+
+	source, ok := m.source.(build.SourceAccount)
+	if !ok {
+		return nil
+	}
+
+	kp, err := keypair.Parse(source.AddressOrSeed)
+	if err != nil {
+		return errors.Wrap(err, "invalid operation source")
+	}
+
+	if kp.Address() == b.TX.SourceAccount.Address() {
+		return nil
+	}
+
+	for i := n; i < len(b.TX.Operations); i++ {
+		if b.TX.Operations[i].SourceAccount == nil {
+			var aid xdr.AccountId
+			aid.SetAddress(kp.Address())
+			b.TX.Operations[i].SourceAccount = &aid
+		}
+	}
+
+	return nil
+}
+
+// withOpSource returns a copy of muts with each mutator wrapped in an opSource for source. The
+// caller's slice is left alone.
+func withOpSource(source build.TransactionMutator, muts []build.TransactionMutator) []build.TransactionMutator {
+	wrapped := make([]build.TransactionMutator, len(muts))
+	for i, mut := range muts {
+		wrapped[i] = opSource{source, mut}
+	}
+	return wrapped
+}
+
+// withSigners returns keys with the seeds in signers that aren't in keys (or for the same account)
+// appended. It doesn't modify keys.
+func withSigners(keys []string, signers []string) []string {
+	result := append([]string{}, keys...)
+
+	signed := map[string]bool{}
+	for _, key := range keys {
+		if kp, err := keypair.Parse(key); err == nil {
+			signed[kp.Address()] = true
+		}
+	}
+
+	for _, signer := range signers {
+		kp, err := keypair.Parse(signer)
+		if err != nil || signed[kp.Address()] {
+			continue
+		}
+
+		signed[kp.Address()] = true
+		result = append(result, signer)
+	}
+
+	return result
+}
+
+// sequence returns the mutator that sets the transaction's sequence number: the one set with
+// Options.WithSequence, or the next one for the source account.
+func (tx *Tx) sequence() build.TransactionMutator {
+	if tx.options != nil && tx.options.hasSequence {
+		return build.Sequence{Sequence: uint64(tx.options.sequence)}
+	}
+
+	return build.AutoSequence{SequenceProvider: tx.GetClient()}
+}
+
+// Start begins a new multi-op transaction with fees billed to account (or to the channel account
+// set with Options.WithChannelAccount.)
 func (tx *Tx) Start(account string) *Tx {
 	tx.sourceAccount = account
 	sourceAccount := sourceAccount(account)
+	if tx.options != nil && tx.options.channelSeed != "" {
+		sourceAccount = build.SourceAccount{AddressOrSeed: tx.options.channelSeed}
+		tx.opSources = true
+	}
 	tx.ops = []build.TransactionMutator{
 		build.TransactionMutator(sourceAccount),
 		tx.network,
-		build.AutoSequence{SequenceProvider: tx.client},
+		tx.sequence(),
 	}
+	tx.opCount = 0
 	tx.isMultiOp = true
+	tx.fakeOps = nil
 
 	return tx
 }
 
+// timeBound converts t to a transaction time bound. Zero times are unbounded.
+func timeBound(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.Unix())
+}
+
+// validTimeBounds returns an error if the time bounds can never be satisfied, so callers
+// get a clear error instead of a tx_too_late rejection from Horizon.
+func validTimeBounds(min time.Time, max time.Time) error {
+	if max.IsZero() {
+		return nil
+	}
+
+	if max.Before(time.Now()) {
+		return errors.Errorf("max time bound is in the past: %v", max)
+	}
+
+	if !min.IsZero() && min.After(max) {
+		return errors.Errorf("min time bound (%v) is after max time bound (%v)", min, max)
+	}
+
+	return nil
+}
+
 // Build creates a new operation out of the provided mutators.
 func (tx *Tx) Build(sourceAccount build.TransactionMutator, muts ...build.TransactionMutator) error {
 	if tx.err != nil {
@@ -212,7 +431,31 @@ func (tx *Tx) Build(sourceAccount build.TransactionMutator, muts ...build.Transa
 		return tx.err
 	}
 
+	if tx.options != nil && tx.options.hasTimeBounds {
+		if err := validTimeBounds(tx.options.minTimeBound, tx.options.maxTimeBound); err != nil {
+			tx.err = err
+			return tx.err
+		}
+	}
+
+	if tx.options != nil && tx.options.channelSeed != "" {
+		if err := ValidSeed(tx.options.channelSeed); err != nil {
+			tx.err = errors.Wrap(err, "invalid channel account")
+			return tx.err
+		}
+	}
+
+	if tx.options != nil && tx.options.opSourceAccount != "" {
+		if !ValidAddressOrSeed(tx.options.opSourceAccount) {
+			tx.err = errors.Errorf("invalid operation source account: %s", tx.options.opSourceAccount)
+			return tx.err
+		}
+	}
+
 	if tx.fake && !tx.isMultiOp {
+		if source, ok := sourceAccount.(build.SourceAccount); ok {
+			tx.fakeSource = source.AddressOrSeed
+		}
 		tx.builder = &build.TransactionBuilder{}
 		return nil
 	}
@@ -232,18 +475,49 @@ func (tx *Tx) Build(sourceAccount build.TransactionMutator, muts ...build.Transa
 			muts = append(muts, build.MemoReturn{Value: xdr.Hash(tx.options.memoHash)})
 		}
 
+		if tx.options.hasFee {
+			muts = append(muts, build.BaseFee{Amount: uint64(tx.options.fee)})
+		}
+
 		if tx.options.hasTimeBounds {
-			muts = append(muts, build.Timebounds{MinTime: uint64(tx.options.minTimeBound.Unix()), MaxTime: uint64(tx.options.maxTimeBound.Unix())})
+			muts = append(muts, build.Timebounds{MinTime: timeBound(tx.options.minTimeBound), MaxTime: timeBound(tx.options.maxTimeBound)})
+		}
+	}
+
+	if tx.options != nil && tx.options.opSourceAccount != "" {
+		opSourceAccount := tx.options.opSourceAccount
+		muts = withOpSource(build.SourceAccount{AddressOrSeed: opSourceAccount}, muts)
+
+		if ValidSeed(opSourceAccount) == nil {
+			tx.opSigners = append(tx.opSigners, opSourceAccount)
+		}
+
+		if tx.isMultiOp {
+			// The source account only applies to this operation, not the ones after it.
+			opts := *tx.options
+			opts.opSourceAccount = ""
+			tx.options = &opts
 		}
 	}
 
 	if tx.isMultiOp {
+		if tx.opSources {
+			muts = withOpSource(sourceAccount, muts)
+		}
 		tx.ops = append(tx.ops, muts...)
+		tx.opCount += len(muts)
 	} else {
+		txSource := sourceAccount
+		if tx.options != nil && tx.options.channelSeed != "" {
+			// The channel account is the transaction source, the operations keep theirs.
+			txSource = build.SourceAccount{AddressOrSeed: tx.options.channelSeed}
+			muts = withOpSource(sourceAccount, muts)
+		}
+
 		muts = append([]build.TransactionMutator{
-			sourceAccount,
+			txSource,
 			tx.network,
-			build.AutoSequence{SequenceProvider: tx.client},
+			tx.sequence(),
 		}, muts...)
 
 		builder, err := build.Transaction(muts...)
@@ -280,6 +554,7 @@ func (tx *Tx) Sign(keys ...string) error {
 	}
 
 	var txe build.TransactionEnvelopeBuilder
+	var sigs []xdr.DecoratedSignature
 	var err error
 
 	if tx.isMultiOp {
@@ -287,17 +562,40 @@ func (tx *Tx) Sign(keys ...string) error {
 	}
 
 	if tx.options != nil && tx.options.skipSignatures {
-		debugf("Tx.Sign", "skipping signatures")
+		tx.debugf("Tx.Sign", "skipping signatures")
+		keys = nil
 		txe.Mutate(tx.builder)
 	} else {
-		debugf("Tx.Sign", "signing transaction, seq: %v", tx.builder.TX.SeqNum)
+		tx.debugf("Tx.Sign", "signing transaction, seq: %v", tx.builder.TX.SeqNum)
 		if tx.options != nil && len(tx.options.signerSeeds) > 0 {
-			txe, err = tx.builder.Sign(tx.options.signerSeeds...)
-		} else {
-			if len(keys) == 0 {
-				keys = []string{tx.sourceAccount}
+			keys = tx.options.signerSeeds
+		} else if len(keys) == 0 {
+			keys = []string{tx.sourceAccount}
+		}
+
+		if tx.options != nil && tx.options.channelSeed != "" {
+			keys = append(append([]string{}, keys...), tx.options.channelSeed)
+		}
+
+		keys = withSigners(keys, tx.opSigners)
+
+		if tx.options != nil && tx.options.signerFunc != nil {
+			// The signer function signs for the addresses.
+			keys = onlySeeds(keys)
+
+			var sig xdr.DecoratedSignature
+			if sig, err = tx.externalSignature(); err != nil {
+				tx.err = errors.Wrap(err, "signing error")
+				return tx.err
 			}
+			sigs = append(sigs, sig)
+		}
+
+		if !tx.hasRawOps() {
 			txe, err = tx.builder.Sign(keys...)
+			if err == nil {
+				txe.E.Signatures = append(txe.E.Signatures, sigs...)
+			}
 		}
 
 		if err != nil {
@@ -306,17 +604,178 @@ func (tx *Tx) Sign(keys ...string) error {
 		}
 	}
 
-	tx.payload, err = txe.Base64()
-	debugf("Tx.Sign", "signed transaction, payload: %s", tx.payload)
+	if tx.hasRawOps() {
+		tx.payload, err = tx.rawEnvelope(sigs, keys...)
+	} else {
+		tx.payload, err = txe.Base64()
+	}
+	tx.debugf("Tx.Sign", "signed transaction, payload: %s", tx.payload)
 
 	if err != nil {
 		tx.err = errors.Wrap(err, "base64 conversion error")
 		return tx.err
 	}
 
+	// Hand-encoded operations can't be decoded by the vendored XDR package.
+	if debugEnabled(tx.logger) && !tx.hasRawOps() {
+		if envelope, err := DecodeTxToJSON(tx.payload, false); err == nil {
+			tx.debugf("Tx.Sign", "signed transaction, envelope: %s", envelope)
+		}
+	}
+
 	return nil
 }
 
+// onlySeeds returns the keys that are seeds, dropping addresses.
+func onlySeeds(keys []string) []string {
+	seeds := []string{}
+	for _, key := range keys {
+		if ValidSeed(key) == nil {
+			seeds = append(seeds, key)
+		}
+	}
+
+	return seeds
+}
+
+// externalSignature signs the transaction hash with the signer function set with
+// Options.WithSignerFunc.
+func (tx *Tx) externalSignature() (xdr.DecoratedSignature, error) {
+	var hash [32]byte
+	var err error
+
+	if tx.hasRawOps() {
+		var txBytes []byte
+		if txBytes, err = encodeTransaction(tx.builder.TX, tx.rawOps); err == nil {
+			hash = rawHash(txBytes, tx.builder.NetworkPassphrase)
+		}
+	} else {
+		hash, err = tx.builder.Hash()
+	}
+
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "could not hash transaction")
+	}
+
+	sig, err := tx.options.signerFunc(hash)
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "signer function failed")
+	}
+
+	return sig, nil
+}
+
+// resequence reloads the source account's sequence number and discards the signed payload, so
+// the transaction can be signed and submitted again.
+func (tx *Tx) resequence() error {
+	if tx.isMultiOp {
+		// Sign() rebuilds multi-op transactions, which reloads the sequence number.
+		tx.builder = nil
+	} else {
+		address := tx.builder.TX.SourceAccount.Address()
+		seq, err := tx.GetClient().SequenceForAccount(address)
+		if err != nil {
+			return errors.Wrapf(err, "could not reload sequence for %s", address)
+		}
+
+		tx.builder.TX.SeqNum = seq + 1
+	}
+
+	tx.payload = ""
+	tx.err = nil
+	return nil
+}
+
+// signAndSubmit signs the transaction with keys and submits it. If the options allow it,
+// tx_bad_seq failures are retried with a fresh sequence number.
+func (tx *Tx) signAndSubmit(keys ...string) error {
+	if tx.options != nil && tx.options.dryRun {
+		if tx.err == nil && tx.isMultiOp {
+			// Payload() builds multi-op transactions, check that it works.
+			_, tx.err = tx.Payload()
+		}
+
+		tx.debugf("Tx.signAndSubmit", "dry run, not signing or submitting")
+		return tx.err
+	}
+
+	tx.Sign(keys...)
+	tx.Submit()
+
+	retries := 0
+	if tx.options != nil && !tx.options.hasSequence {
+		retries = tx.options.seqRetries
+	}
+
+	for i := 0; i < retries && IsBadSequence(tx.err); i++ {
+		tx.debugf("Tx.signAndSubmit", "bad sequence, retrying (%d of %d)", i+1, retries)
+		if err := tx.resequence(); err != nil {
+			tx.err = err
+			return tx.err
+		}
+
+		tx.Sign(keys...)
+		tx.Submit()
+	}
+
+	return tx.err
+}
+
+// maxTxEnvelopeSize is the largest transaction envelope (in bytes of XDR) that Stellar Core
+// accepts.
+const maxTxEnvelopeSize = 100 * 1024
+
+// validEnvelopeSize returns an error if the base64-encoded envelope payload, with ops operations,
+// is too large to be accepted by the network. Set ops to -1 if it's not known.
+func validEnvelopeSize(payload string, ops int) error {
+	if ops > maxOpsPerTx {
+		return errors.Errorf("transaction has too many operations: %d (max %d)", ops, maxOpsPerTx)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		// Leave malformed payloads to Horizon.
+		return nil
+	}
+
+	if size := len(raw); size > maxTxEnvelopeSize {
+		if ops < 0 {
+			return errors.Errorf("transaction too large: %d bytes (max %d)", size, maxTxEnvelopeSize)
+		}
+		return errors.Errorf("transaction too large: %d bytes with %d operations (max %d bytes)", size, ops, maxTxEnvelopeSize)
+	}
+
+	return nil
+}
+
+// submitTransaction submits the base64-encoded envelope b64Tx to Horizon. It's like the client's
+// SubmitTransaction, but also decodes the created_at time, which the vendored client drops.
+func submitTransaction(client *horizon.Client, b64Tx string) (TxResponse, error) {
+	var response TxResponse
+
+	resp, err := client.HTTP.PostForm(strings.TrimRight(client.URL, "/")+"/transactions", url.Values{"tx": {b64Tx}})
+	if err != nil {
+		return response, errors.Wrap(err, "http post failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		horizonError := &horizon.Error{Response: resp}
+		if err := json.NewDecoder(resp.Body).Decode(&horizonError.Problem); err != nil {
+			return response, errors.Wrap(err, "error decoding horizon.Problem")
+		}
+		return response, horizonError
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, errors.Wrap(err, "error decoding submission response")
+	}
+
+	// The outer Ledger field shadows the embedded one when decoding.
+	response.TransactionSuccess.Ledger = int32(response.Ledger)
+	return response, nil
+}
+
 // Submit sends the transaction to the stellar network.
 func (tx *Tx) Submit() error {
 	if tx.err != nil {
@@ -333,11 +792,19 @@ func (tx *Tx) Submit() error {
 		return tx.err
 	}
 
+	if !tx.fake {
+		// Fail before the round trip to Horizon.
+		if err := validEnvelopeSize(tx.payload, len(tx.builder.TX.Operations)); err != nil {
+			tx.err = errors.Wrap(err, "could not submit transaction")
+			return tx.err
+		}
+	}
+
 	if tx.options != nil {
 		// Call the presubmit handler, if set.
 		handler, ok := tx.options.handlers[EvBeforeSubmit]
 		if ok {
-			debugf("Tx.Submit", "calling presubmit handler")
+			tx.debugf("Tx.Submit", "calling presubmit handler")
 			f := (func(...interface{}) (bool, error))(*handler)
 			cont, err := f(tx.payload)
 			if tx.err != nil {
@@ -352,20 +819,34 @@ func (tx *Tx) Submit() error {
 	}
 
 	if tx.fake {
-		tx.response = &horizon.TransactionSuccess{Result: "fake_ok"}
+		if err := tx.applyFake(); err != nil {
+			tx.err = errors.Wrap(err, "could not submit transaction")
+			return tx.err
+		}
+
+		tx.sentPayload = tx.payload
+		tx.response = &TxResponse{TransactionSuccess: horizon.TransactionSuccess{Result: "fake_ok"}}
 		return nil
 	}
 
-	debugf("Tx.Submit", "submitting transaction to network %s", tx.networkName)
-	resp, err := tx.client.SubmitTransaction(tx.payload)
+	if tx.options != nil && tx.options.hasHealthGate {
+		if err := checkHealthGate(tx.logger, tx.GetClient(), tx.options.maxLag); err != nil {
+			tx.err = errors.Wrap(err, "could not submit transaction")
+			return tx.err
+		}
+	}
+
+	tx.debugf("Tx.Submit", "submitting transaction to network %s", tx.networkName)
+	tx.sentPayload = tx.payload
+	resp, err := submitTransaction(tx.GetClient(), tx.payload)
 
 	if err != nil {
-		debugf("Tx.Submit", "submit failed: %s", ErrorString(err))
+		tx.debugf("Tx.Submit", "submit failed: %s", ErrorString(err))
 		tx.err = errors.Wrap(err, "could not submit transaction")
 		return tx.err
 	}
 
-	debugf("Tx.Submit", "transaction submitted to ledger %d with hash %s", int32(resp.Ledger), resp.Hash)
+	tx.infof("Tx.Submit", "transaction submitted to ledger %d with hash %s", resp.Ledger, resp.Hash)
 	tx.response = &resp
 	tx.submitted = true
 
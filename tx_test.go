@@ -1,9 +1,19 @@
 package microstellar
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
 )
 
 // Payments with memotext and memoid
@@ -124,3 +134,678 @@ func TestTx(t *testing.T) {
 	tx.Sign()
 	tx.Submit()
 }
+
+func TestTxTrackingID(t *testing.T) {
+	ms := New("test")
+	unsigned := "AAAAAJb3jlBt5y04F3kXk47T9MO/Se7NcfhnIxXvWjOCzZ14AAAAZAB50HAAAAABAAAAAAAAAAAAAAABAAAAAAAAAAEAAAAAuIMOnlpDFWhoO8o6VVzH4MZdIpgqr21GMRGG2riMxNoAAAAAAAAAAACYloAAAAAAAAAAAA"
+
+	id, err := ms.TxTrackingID(unsigned)
+	if err != nil {
+		t.Fatalf("TxTrackingID: %v", err)
+	}
+
+	if len(id) != 64 {
+		t.Errorf("wrong ID length: want %v, got %v (%s)", 64, len(id), id)
+	}
+
+	signed, err := ms.SignTransaction(unsigned, "SA6UC3LRJVNZ6DO3ZIBWUXHG6O7LKWWFTTAG2HK6QHSXZROMCVDU73RH")
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+
+	signedID, err := ms.TxTrackingID(signed)
+	if err != nil {
+		t.Fatalf("TxTrackingID: %v", err)
+	}
+
+	if signedID != id {
+		t.Errorf("signing changed the tracking ID: want %v, got %v", id, signedID)
+	}
+
+	if publicID, _ := New("public").TxTrackingID(unsigned); publicID == id {
+		t.Errorf("tracking ID should depend on the network: got %v for both", id)
+	}
+}
+
+func TestSignTransactionForNetwork(t *testing.T) {
+	unsigned := "AAAAAJb3jlBt5y04F3kXk47T9MO/Se7NcfhnIxXvWjOCzZ14AAAAZAB50HAAAAABAAAAAAAAAAAAAAABAAAAAAAAAAEAAAAAuIMOnlpDFWhoO8o6VVzH4MZdIpgqr21GMRGG2riMxNoAAAAAAAAAAACYloAAAAAAAAAAAA"
+	seed := "SA6UC3LRJVNZ6DO3ZIBWUXHG6O7LKWWFTTAG2HK6QHSXZROMCVDU73RH"
+
+	want, err := New("test").SignTransaction(unsigned, seed)
+	if err != nil {
+		t.Fatalf("SignTransaction: %v", err)
+	}
+
+	// A public network client signing for the test network.
+	ms := New("public")
+	got, err := ms.SignTransactionForNetwork(unsigned, network.TestNetworkPassphrase, seed)
+	if err != nil {
+		t.Fatalf("SignTransactionForNetwork: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("want testnet signature, got %s", got)
+	}
+
+	if public, _ := ms.SignTransaction(unsigned, seed); public == want {
+		t.Errorf("signatures should depend on the network")
+	}
+
+	if _, err := ms.SignTransactionForNetwork(unsigned, "", seed); err == nil {
+		t.Errorf("want error for missing passphrase")
+	}
+}
+
+func TestTimeBounds(t *testing.T) {
+	ms := New("fake")
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	if err := ms.PayNative(source, target, "1", Opts().WithTimeout(time.Minute)); err != nil {
+		t.Errorf("payment with timeout failed: %v", err)
+	}
+
+	if err := ms.PayNative(source, target, "1", Opts().WithTimeout(-time.Minute)); err == nil {
+		t.Errorf("payment with expired timeout should fail")
+	}
+
+	if err := ms.PayNative(source, target, "1", Opts().WithTimeBounds(time.Now().Add(2*time.Hour), time.Now().Add(time.Hour))); err == nil {
+		t.Errorf("payment with min time after max time should fail")
+	}
+
+	if err := ms.PayNative(source, target, "1", Opts().WithTimeBounds(time.Now().Add(time.Hour), time.Time{})); err != nil {
+		t.Errorf("payment with no max time failed: %v", err)
+	}
+}
+
+func TestLastEnvelopeXDR(t *testing.T) {
+	var sent string
+	fail := false
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		sent = r.PostFormValue("tx")
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status": 400, "title": "Transaction Failed"}`)
+			return
+		}
+		fmt.Fprint(w, testTxSuccess)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	if _, err := ms.LastEnvelopeXDR(); err == nil {
+		t.Errorf("LastEnvelopeXDR should fail without a transaction")
+	}
+
+	if err := ms.PayNative(source, target, "1"); err != nil {
+		t.Fatalf("PayNative failed: %v", ErrorString(err))
+	}
+
+	if got, err := ms.LastEnvelopeXDR(); err != nil || got == "" || got != sent {
+		t.Errorf("wrong envelope: want %s, got %s (%v)", sent, got, err)
+	}
+
+	// Failed submissions are still recorded.
+	fail = true
+	ms.Start(source)
+	ms.PayNative(source, target, "2")
+	if err := ms.Submit(); err == nil {
+		t.Fatalf("Submit should fail")
+	}
+
+	if got, err := ms.LastEnvelopeXDR(); err != nil || got != sent {
+		t.Errorf("wrong envelope for failed submission: want %s, got %s (%v)", sent, got, err)
+	}
+
+	ms.PayNative(source, target, "3", Opts().WithDryRun())
+	if _, err := ms.LastEnvelopeXDR(); err == nil {
+		t.Errorf("LastEnvelopeXDR should fail for dry runs")
+	}
+}
+
+func TestLastTxHash(t *testing.T) {
+	hash := "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889"
+	server := newAccountServer(nil)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	if _, err := ms.LastTxHash(); err == nil {
+		t.Errorf("LastTxHash should fail without a transaction")
+	}
+
+	if err := ms.PayNative(source, target, "1"); err != nil {
+		t.Fatalf("PayNative failed: %v", ErrorString(err))
+	}
+
+	if got, err := ms.LastTxHash(); err != nil || got != hash || ms.Response().Hash != hash {
+		t.Errorf("wrong hash: want %s, got %s (%v)", hash, got, err)
+	}
+
+	ms.Start(source)
+	ms.PayNative(source, target, "1")
+	payload, err := ms.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	want, _ := ms.TxTrackingID(payload)
+	if got, err := ms.LastTxHash(); err != nil || got != want {
+		t.Errorf("wrong payload hash: want %s, got %s (%v)", want, got, err)
+	}
+}
+
+// testTxSuccess is the body that test servers send back for accepted submissions.
+const testTxSuccess = `{"hash": "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889", "ledger": 10}`
+
+// newAccountServer returns a test server that loads the test account with sequence number 100,
+// and hands submissions to submit. A nil submit accepts every transaction.
+func newAccountServer(submit http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			fmt.Fprint(w, `{"id": "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "sequence": "100"}`)
+			return
+		}
+
+		if submit == nil {
+			fmt.Fprint(w, testTxSuccess)
+			return
+		}
+
+		submit(w, r)
+	}))
+}
+
+// newRetryServer returns a test server that rejects the first failures submissions with
+// resultCode, and accepts the rest. The account sequence number goes up on every load.
+func newRetryServer(failures int, resultCode string, submissions *int) *httptest.Server {
+	sequence := 100
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			sequence++
+			fmt.Fprintf(w, `{"id": "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "sequence": "%d"}`, sequence)
+			return
+		}
+
+		*submissions++
+		if *submissions <= failures {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"type": "transaction_failed", "title": "Transaction Failed", "status": 400,
+				"extras": {"result_codes": {"transaction": "%s"}}}`, resultCode)
+			return
+		}
+
+		fmt.Fprint(w, testTxSuccess)
+	}))
+}
+
+func TestTxResponse(t *testing.T) {
+	server := newAccountServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"hash": "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889", "ledger": 12345,
+			"created_at": "2020-01-02T03:04:05Z", "result_meta_xdr": "AAAAAA=="}`)
+	})
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.PayNative("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK", "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "1"); err != nil {
+		t.Fatalf("payment failed: %v", ErrorString(err))
+	}
+
+	resp := ms.Response()
+	if resp.Ledger != 12345 || resp.TransactionSuccess.Ledger != 12345 {
+		t.Errorf("wrong ledger: want 12345, got %d (%d)", resp.Ledger, resp.TransactionSuccess.Ledger)
+	}
+
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC); !resp.CreatedAt.Equal(want) {
+		t.Errorf("wrong created at: want %v, got %v", want, resp.CreatedAt)
+	}
+
+	if resp.Hash != "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889" || resp.Meta != "AAAAAA==" {
+		t.Errorf("wrong hash or meta: %+v", resp)
+	}
+}
+
+func TestAutoSequenceRetry(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	submissions := 0
+	server := newRetryServer(2, "tx_bad_seq", &submissions)
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	if err := ms.PayNative(source, target, "1", Opts().WithMemoText("rent").WithAutoSequenceRetry(2)); err != nil {
+		t.Errorf("PayNative should succeed after retries: %v", ErrorString(err))
+	}
+
+	if submissions != 3 {
+		t.Errorf("want 3 submissions, got %d", submissions)
+	}
+	server.Close()
+
+	submissions = 0
+	server = newRetryServer(2, "tx_bad_seq", &submissions)
+	ms = New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	ms.Start(source, Opts().WithAutoSequenceRetry(1))
+	ms.PayNative(source, target, "1")
+	if err := ms.Submit(); err == nil {
+		t.Errorf("Submit should fail when retries run out")
+	}
+
+	if submissions != 2 {
+		t.Errorf("want 2 submissions, got %d", submissions)
+	}
+	server.Close()
+
+	submissions = 0
+	server = newRetryServer(1, "tx_insufficient_balance", &submissions)
+	defer server.Close()
+	ms = New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	if err := ms.PayNative(source, target, "1", Opts().WithAutoSequenceRetry(2)); err == nil {
+		t.Errorf("PayNative should fail without retrying")
+	}
+
+	if submissions != 1 {
+		t.Errorf("want 1 submission, got %d", submissions)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	source := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	target := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	if err := ms.PayNative(source, target, "10", Opts().WithDryRun()); err != nil {
+		t.Fatalf("PayNative failed: %v", err)
+	}
+
+	payload, err := ms.LastPayload()
+	if err != nil {
+		t.Fatalf("LastPayload failed: %v", err)
+	}
+
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("bad payload: %v", err)
+	}
+
+	if len(txe.Signatures) != 0 || len(txe.Tx.Operations) != 1 || txe.Tx.Operations[0].Body.Type != xdr.OperationTypePayment {
+		t.Errorf("want unsigned payment, got %d signatures, %d operations", len(txe.Signatures), len(txe.Tx.Operations))
+	}
+
+	if err := ms.SetHomeDomain(source, "qubit.sh", Opts().WithDryRun()); err != nil {
+		t.Fatalf("SetHomeDomain failed: %v", err)
+	}
+
+	payload, _ = ms.LastPayload()
+	if txe, err := DecodeTx(payload); err != nil || txe.Tx.Operations[0].Body.Type != xdr.OperationTypeSetOptions {
+		t.Errorf("want set_options payload, got %v", err)
+	}
+
+	ms.Start(source, Opts().WithDryRun())
+	ms.PayNative(source, target, "1")
+	ms.PayNative(source, target, "2")
+	if err := ms.Submit(); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	payload, _ = ms.LastPayload()
+	if txe, err := DecodeTx(payload); err != nil || len(txe.Tx.Operations) != 2 || len(txe.Signatures) != 0 {
+		t.Errorf("want unsigned multi-op payload, got %v", err)
+	}
+
+	if submissions != 0 {
+		t.Errorf("dry runs should not submit, got %d submissions", submissions)
+	}
+}
+
+func TestWithSequence(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	seq, err := ms.GetSequenceNumber(target)
+	if err != nil || seq != 101 {
+		t.Fatalf("want sequence 101, got %d: %v", seq, err)
+	}
+
+	seqNum := func(payload string) int64 {
+		txe, err := DecodeTx(payload)
+		if err != nil {
+			t.Fatalf("can't decode payload: %v", err)
+		}
+		return int64(txe.Tx.SeqNum)
+	}
+
+	// Nothing is loaded from Horizon when the sequence number is fixed.
+	offline := New("custom", Params{"url": "http://localhost:0", "passphrase": "test"})
+	if err := offline.Pay(source, target, "1", NativeAsset, Opts().WithSequence(seq+1).WithDryRun()); err != nil {
+		t.Fatalf("offline payment failed: %v", ErrorString(err))
+	}
+
+	if payload, _ := offline.LastPayload(); seqNum(payload) != 102 {
+		t.Errorf("want sequence 102, got %d", seqNum(payload))
+	}
+
+	offline.Start(source, Opts().WithSequence(200))
+	offline.Pay(source, target, "1", NativeAsset)
+	offline.Pay(source, target, "2", NativeAsset)
+	payload, err := offline.Payload()
+	if err != nil {
+		t.Fatalf("offline multi-op transaction failed: %v", ErrorString(err))
+	}
+
+	if seqNum(payload) != 200 {
+		t.Errorf("want sequence 200, got %d", seqNum(payload))
+	}
+
+	if _, err := offline.GetSequenceNumber(target); err == nil {
+		t.Errorf("want error loading sequence number offline")
+	}
+}
+
+func TestWithChannelAccount(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	channelKP, _ := keypair.Random()
+	channel := channelKP.Seed()
+	sourceKP, _ := keypair.Parse(source)
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	check := func(payload string, ops int) {
+		txe, err := DecodeTx(payload)
+		if err != nil {
+			t.Fatalf("can't decode payload: %v", err)
+		}
+
+		if got := txe.Tx.SourceAccount.Address(); got != channelKP.Address() {
+			t.Errorf("want transaction source %s, got %s", channelKP.Address(), got)
+		}
+
+		if len(txe.Tx.Operations) != ops {
+			t.Fatalf("want %d operations, got %d", ops, len(txe.Tx.Operations))
+		}
+
+		for i, op := range txe.Tx.Operations {
+			if op.SourceAccount == nil || op.SourceAccount.Address() != sourceKP.Address() {
+				t.Errorf("operation %d: want source %s, got %v", i, sourceKP.Address(), op.SourceAccount)
+			}
+		}
+
+		if len(txe.Signatures) != 2 {
+			t.Errorf("want 2 signatures, got %d", len(txe.Signatures))
+		}
+	}
+
+	if err := ms.Pay(source, target, "1", NativeAsset, Opts().WithChannelAccount(channel)); err != nil {
+		t.Fatalf("payment failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	check(payload, 1)
+
+	ms.Start(source, Opts().WithChannelAccount(channel))
+	ms.Pay(source, target, "1", NativeAsset)
+	ms.Pay(source, target, "2", NativeAsset)
+	if err := ms.Submit(); err != nil {
+		t.Fatalf("multi-op payment failed: %v", ErrorString(err))
+	}
+
+	payload, _ = ms.LastPayload()
+	check(payload, 2)
+
+	if err := ms.Pay(source, target, "1", NativeAsset, Opts().WithChannelAccount("bad seed")); err == nil {
+		t.Errorf("want error with invalid channel account")
+	}
+
+	// The caller's operations aren't wrapped in place.
+	tx := NewTx("fake")
+	tx.SetOptions(Opts().WithChannelAccount(channel))
+	ops := []build.TransactionMutator{build.SetOptions(build.HomeDomain("qubit.sh"))}
+	if err := tx.Build(sourceAccount(source), ops...); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if _, ok := ops[0].(opSource); ok {
+		t.Errorf("Build modified the caller's operations")
+	}
+}
+
+func TestWithSourceAccount(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	other, _ := keypair.Random()
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	decode := func() *xdr.TransactionEnvelope {
+		payload, _ := ms.LastPayload()
+		txe, err := DecodeTx(payload)
+		if err != nil {
+			t.Fatalf("can't decode payload: %v", err)
+		}
+		return txe
+	}
+
+	ms.Start(source)
+	ms.Pay(source, target, "1", NativeAsset)
+	ms.SetData(source, "foo", []byte("bar"), Opts().WithSourceAccount(other.Seed()))
+	ms.Pay(source, target, "2", NativeAsset)
+	if err := ms.Submit(); err != nil {
+		t.Fatalf("multi-op transaction failed: %v", ErrorString(err))
+	}
+
+	txe := decode()
+	if len(txe.Tx.Operations) != 3 {
+		t.Fatalf("want 3 operations, got %d", len(txe.Tx.Operations))
+	}
+
+	for i, op := range txe.Tx.Operations {
+		if i == 1 {
+			if op.SourceAccount == nil || op.SourceAccount.Address() != other.Address() {
+				t.Errorf("operation 1: want source %s, got %v", other.Address(), op.SourceAccount)
+			}
+		} else if op.SourceAccount != nil {
+			t.Errorf("operation %d: want transaction source, got %s", i, op.SourceAccount.Address())
+		}
+	}
+
+	if len(txe.Signatures) != 2 {
+		t.Errorf("want 2 signatures, got %d", len(txe.Signatures))
+	}
+
+	// Addresses aren't signed for.
+	if err := ms.Pay(source, target, "1", NativeAsset, Opts().WithSourceAccount(other.Address())); err != nil {
+		t.Fatalf("payment failed: %v", ErrorString(err))
+	}
+
+	txe = decode()
+	if op := txe.Tx.Operations[0]; op.SourceAccount == nil || op.SourceAccount.Address() != other.Address() {
+		t.Errorf("want source %s, got %v", other.Address(), op.SourceAccount)
+	}
+
+	if len(txe.Signatures) != 1 {
+		t.Errorf("want 1 signature, got %d", len(txe.Signatures))
+	}
+
+	if err := ms.Pay(source, target, "1", NativeAsset, Opts().WithSourceAccount("bad address")); err == nil {
+		t.Errorf("want error with invalid source account")
+	}
+
+	// The caller's operations aren't wrapped in place.
+	tx := NewTx("fake")
+	tx.SetOptions(Opts().WithSourceAccount(other.Address()))
+	ops := []build.TransactionMutator{build.SetOptions(build.HomeDomain("qubit.sh"))}
+	if err := tx.Build(sourceAccount(source), ops...); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if _, ok := ops[0].(opSource); ok {
+		t.Errorf("Build modified the caller's operations")
+	}
+}
+
+func TestWithSignerFunc(t *testing.T) {
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	signer, _ := keypair.Random()
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	var signed [32]byte
+	signerFunc := func(hash [32]byte) (xdr.DecoratedSignature, error) {
+		signed = hash
+		return signer.SignDecorated(hash[:])
+	}
+
+	// Pay from the address: the seed stays with the signer function.
+	if err := ms.PayNative(signer.Address(), target, "1", Opts().WithSignerFunc(signerFunc)); err != nil {
+		t.Fatalf("payment failed: %v", ErrorString(err))
+	}
+
+	payload, _ := ms.LastPayload()
+	txe, err := DecodeTx(payload)
+	if err != nil {
+		t.Fatalf("can't decode payload: %v", err)
+	}
+
+	if len(txe.Signatures) != 1 {
+		t.Fatalf("want 1 signature, got %d", len(txe.Signatures))
+	}
+
+	hash, _ := network.HashTransaction(&txe.Tx, "test")
+	if hash != signed {
+		t.Errorf("signer function called with wrong hash")
+	}
+
+	if err := signer.Verify(hash[:], txe.Signatures[0].Signature); err != nil {
+		t.Errorf("bad signature: %v", err)
+	}
+
+	// Seeds set with WithSigner still sign.
+	other, _ := keypair.Random()
+	if err := ms.PayNative(signer.Address(), target, "1", Opts().WithSignerFunc(signerFunc).WithSigner(other.Seed())); err != nil {
+		t.Fatalf("payment failed: %v", ErrorString(err))
+	}
+
+	payload, _ = ms.LastPayload()
+	if txe, _ = DecodeTx(payload); len(txe.Signatures) != 2 {
+		t.Errorf("want 2 signatures, got %d", len(txe.Signatures))
+	}
+
+	failing := func(hash [32]byte) (xdr.DecoratedSignature, error) {
+		return xdr.DecoratedSignature{}, fmt.Errorf("hsm offline")
+	}
+
+	if err := ms.PayNative(signer.Address(), target, "1", Opts().WithSignerFunc(failing)); err == nil || !strings.Contains(err.Error(), "hsm offline") {
+		t.Errorf("want signer function error, got %v", err)
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	signer := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	base := Opts().WithMemoText("payroll").WithSigner(signer).WithBaseFee(200).SkipSignatures()
+	override := Opts().WithMemoID(42).WithCursor("now")
+
+	merged := MergeOptions(base, nil, override)
+	if merged.memoType != MemoID || merged.memoID != 42 {
+		t.Errorf("later memo should win: got type %v, id %v", merged.memoType, merged.memoID)
+	}
+
+	if len(merged.signerSeeds) != 1 || merged.signerSeeds[0] != signer {
+		t.Errorf("want base signer, got %v", merged.signerSeeds)
+	}
+
+	if !merged.hasFee || merged.fee != 200 || !merged.skipSignatures {
+		t.Errorf("want base fee and flags, got fee %v, skipSignatures %v", merged.fee, merged.skipSignatures)
+	}
+
+	if !merged.hasCursor || merged.cursor != "now" {
+		t.Errorf("want override cursor, got %q", merged.cursor)
+	}
+
+	// Later sort orders win, even when they're ascending.
+	ascending := MergeOptions(Opts().WithSortOrder(SortDescending), Opts().WithSortOrder(SortAscending))
+	if ascending.sortDescending {
+		t.Errorf("later ascending sort order should win")
+	}
+
+	if descending := MergeOptions(Opts().WithSortOrder(SortDescending), override); !descending.sortDescending {
+		t.Errorf("want base sort order when the override has none")
+	}
+
+	// The inputs aren't modified.
+	merged.WithSigner(signer)
+	if len(base.signerSeeds) != 1 || override.hasFee {
+		t.Errorf("MergeOptions modified its arguments")
+	}
+
+	if merged := MergeOptions(); merged == nil || merged.memoType != MemoNone {
+		t.Errorf("want empty options, got %+v", merged)
+	}
+}
+
+func TestEnvelopeSize(t *testing.T) {
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+
+	submissions := 0
+	server := newRetryServer(0, "", &submissions)
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	ms.Start(source)
+	for i := 0; i < maxOpsPerTx+1; i++ {
+		ms.Pay(source, target, "1", NativeAsset)
+	}
+
+	err := ms.Submit()
+	if err == nil || !strings.Contains(err.Error(), "too many operations: 101") {
+		t.Errorf("want too many operations error, got %v", err)
+	}
+
+	large := base64.StdEncoding.EncodeToString(make([]byte, maxTxEnvelopeSize+1))
+	_, err = ms.SubmitTransaction(large)
+	if err == nil || !strings.Contains(err.Error(), "transaction too large") {
+		t.Errorf("want transaction too large error, got %v", err)
+	}
+
+	if submissions != 0 {
+		t.Errorf("want no submissions, got %d", submissions)
+	}
+
+	if err := ms.Pay(source, target, "1", NativeAsset); err != nil || submissions != 1 {
+		t.Errorf("want small transaction submitted: %v", err)
+	}
+}
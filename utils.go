@@ -1,36 +1,70 @@
 package microstellar
 
 import (
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
-	"github.com/sirupsen/logrus"
-
 	"github.com/pkg/errors"
 	"github.com/stellar/go/amount"
 	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/strkey"
 	"github.com/stellar/go/xdr"
 )
 
-func debugf(method string, msg string, args ...interface{}) {
-	logrus.WithFields(logrus.Fields{"lib": "microstellar", "method": method}).Debugf(msg, args...)
-}
-
-// ParseAmount converts a currency amount string to an int64
+// ParseAmount converts a currency amount string to an int64. Returns an error if v is malformed,
+// has more than 7 decimal places, or is out of range of an int64 once scaled.
 func ParseAmount(v string) (int64, error) {
 	return amount.ParseInt64(v)
 }
 
+// ValidAmount returns an error if v is not a valid amount: i.e., a non-negative decimal number
+// with no more than 7 decimal places that fits in an int64 once scaled.
+func ValidAmount(v string) error {
+	if strings.HasPrefix(v, "-") {
+		return errors.Errorf("invalid amount: must not be negative: %s", v)
+	}
+
+	if _, err := ParseAmount(v); err != nil {
+		return errors.Wrapf(err, "invalid amount: %s", v)
+	}
+
+	return nil
+}
+
 // ToAmountString converts an int64 amount to a string
 func ToAmountString(v int64) string {
 	return amount.StringFromInt64(v)
 }
 
+// StroopsFromLumens converts a decimal amount of lumens (or units of any asset) to stroops. It's
+// ParseAmount, with errors that say the amount is invalid: lumens is malformed, has more than 7
+// decimal places, or doesn't fit in an int64 once converted.
+//
+//   stroops, err := microstellar.StroopsFromLumens("2.5") // 25000000
+func StroopsFromLumens(lumens string) (int64, error) {
+	stroops, err := ParseAmount(lumens)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid amount: %s", lumens)
+	}
+
+	return stroops, nil
+}
+
+// LumensFromStroops converts an amount in stroops to a decimal amount of lumens (or units of any
+// asset), with 7 decimal places. It's the same as ToAmountString.
+//
+//   microstellar.LumensFromStroops(25000000) // "2.5000000"
+func LumensFromStroops(stroops int64) string {
+	return ToAmountString(stroops)
+}
+
 // ValidAddress returns error if address is an invalid stellar address
 func ValidAddress(address string) error {
 	_, err := strkey.Decode(strkey.VersionByteAccountID, address)
@@ -43,6 +77,63 @@ func ValidSeed(seed string) error {
 	return errors.Wrap(err, "invalid seed")
 }
 
+// MalformedKeyError is returned when a seed or address can't be decoded. Kind is either "seed"
+// or "address". The offending key is deliberately left out of the error message.
+type MalformedKeyError struct {
+	Kind string
+	Err  error
+}
+
+func (e *MalformedKeyError) Error() string {
+	return fmt.Sprintf("malformed %s: %v", e.Kind, e.Err)
+}
+
+// AddressFromSeed returns the address (public key) for seed. Returns a *MalformedKeyError if the
+// seed is invalid.
+//
+//   address, err := microstellar.AddressFromSeed("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK")
+func AddressFromSeed(seed string) (string, error) {
+	if err := ValidSeed(seed); err != nil {
+		return "", &MalformedKeyError{Kind: "seed", Err: errors.Cause(err)}
+	}
+
+	kp, err := keypair.Parse(seed)
+	if err != nil {
+		return "", &MalformedKeyError{Kind: "seed", Err: err}
+	}
+
+	return kp.Address(), nil
+}
+
+// addressOf returns the address of addressOrSeed.
+func addressOf(addressOrSeed string) (string, error) {
+	kp, err := keypair.Parse(addressOrSeed)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid address or seed")
+	}
+
+	return kp.Address(), nil
+}
+
+// SeedMatchesAddress returns true if seed is the private key for address. The derived address is
+// compared in constant time. Returns a *MalformedKeyError if either key is invalid.
+func SeedMatchesAddress(seed string, address string) (bool, error) {
+	if err := ValidSeed(seed); err != nil {
+		return false, &MalformedKeyError{Kind: "seed", Err: errors.Cause(err)}
+	}
+
+	if err := ValidAddress(address); err != nil {
+		return false, &MalformedKeyError{Kind: "address", Err: errors.Cause(err)}
+	}
+
+	derived, err := AddressFromSeed(seed)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(derived), []byte(address)) == 1, nil
+}
+
 // ValidAddressOrSeed returns true if the string is a valid address or seed
 func ValidAddressOrSeed(addressOrSeed string) bool {
 	err := ValidAddress(addressOrSeed)
@@ -54,6 +145,48 @@ func ValidAddressOrSeed(addressOrSeed string) bool {
 	return err == nil
 }
 
+// ParseMemoHash decodes a hex-encoded 32-byte hash for use with Options.WithMemoHash and
+// Options.WithMemoReturn.
+//
+//   hash, err := microstellar.ParseMemoHash("2a2d...")
+//   ms.PayNative("source_seed", "target_address", "3", microstellar.Opts().WithMemoHash(hash))
+func ParseMemoHash(hexHash string) ([32]byte, error) {
+	var hash [32]byte
+
+	decoded, err := hex.DecodeString(hexHash)
+	if err != nil {
+		return hash, errors.Wrap(err, "invalid memo hash")
+	}
+
+	if len(decoded) != len(hash) {
+		return hash, errors.Errorf("invalid memo hash: want %d bytes, got %d", len(hash), len(decoded))
+	}
+
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+// ParseTxHash decodes a 32-byte transaction hash, encoded as either hex (as returned by Horizon
+// and TxTrackingID) or base64, for use with AddPreAuthTxSigner.
+func ParseTxHash(encodedHash string) ([32]byte, error) {
+	if hash, err := ParseMemoHash(encodedHash); err == nil {
+		return hash, nil
+	}
+
+	var hash [32]byte
+	decoded, err := base64.StdEncoding.DecodeString(encodedHash)
+	if err != nil {
+		return hash, errors.Errorf("invalid transaction hash: not hex or base64: %s", encodedHash)
+	}
+
+	if len(decoded) != len(hash) {
+		return hash, errors.Errorf("invalid transaction hash: want %d bytes, got %d", len(hash), len(decoded))
+	}
+
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
 // ErrorString parses the horizon error out of err.
 func ErrorString(err error, showStackTrace ...bool) string {
 	var errorString string
@@ -80,10 +213,134 @@ func ErrorString(err error, showStackTrace ...bool) string {
 	return errorString
 }
 
+// HorizonErrorDetail is the structured detail of an error response from Horizon (a problem
+// document), including the extras Horizon adds to failed transaction submissions.
+type HorizonErrorDetail struct {
+	Status int    `json:"status"`
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+
+	// For failed submissions: the hash, base64-encoded envelope and result XDR, and result codes
+	// of the transaction. Use DecodeTx and DecodeTxToJSON to decode the envelope.
+	Hash        string         `json:"hash,omitempty"`
+	EnvelopeXDR string         `json:"envelope_xdr,omitempty"`
+	ResultXDR   string         `json:"result_xdr,omitempty"`
+	ResultCodes *TxResultCodes `json:"result_codes,omitempty"`
+
+	// All the extras, undecoded.
+	Extras map[string]json.RawMessage `json:"extras,omitempty"`
+}
+
+// GetHorizonError returns the detail of err, if it's (or wraps) an error response from Horizon.
+// Use it to log exactly what failed:
+//
+//   if err := ms.Pay(sourceSeed, targetAddress, "10", USD); err != nil {
+//       if detail, ok := microstellar.GetHorizonError(err); ok {
+//           log.Printf("%s: %v\nenvelope: %s\nresult: %s", detail.Title, detail.ResultCodes,
+//               detail.EnvelopeXDR, detail.ResultXDR)
+//       }
+//   }
+func GetHorizonError(err error) (*HorizonErrorDetail, bool) {
+	herr, ok := errors.Cause(err).(*horizon.Error)
+	if !ok {
+		return nil, false
+	}
+
+	detail := &HorizonErrorDetail{
+		Status: herr.Problem.Status,
+		Type:   herr.Problem.Type,
+		Title:  herr.Problem.Title,
+		Detail: herr.Problem.Detail,
+		Extras: herr.Problem.Extras,
+	}
+
+	// The extras are optional, and only set for some errors.
+	extraString := func(key string) string {
+		var s string
+		if raw, ok := herr.Problem.Extras[key]; ok {
+			json.Unmarshal(raw, &s)
+		}
+		return s
+	}
+
+	detail.Hash = extraString("hash")
+	detail.EnvelopeXDR = extraString("envelope_xdr")
+	detail.ResultXDR = extraString("result_xdr")
+
+	if codes, err := herr.ResultCodes(); err == nil {
+		resultCodes := TxResultCodes(*codes)
+		detail.ResultCodes = &resultCodes
+	}
+
+	return detail, true
+}
+
+// hasResultCode returns true if err is a Horizon transaction failure with one of codes as its
+// transaction result code, or as the result code of any of its operations.
+func hasResultCode(err error, codes ...string) bool {
+	herr, ok := errors.Cause(err).(*horizon.Error)
+	if !ok {
+		return false
+	}
+
+	resultCodes, rerr := herr.ResultCodes()
+	if rerr != nil {
+		return false
+	}
+
+	for _, code := range codes {
+		if resultCodes.TransactionCode == code {
+			return true
+		}
+
+		for _, opCode := range resultCodes.OperationCodes {
+			if opCode == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsInsufficientBalance returns true if err means the source account can't pay for the
+// transaction, i.e., the transaction failed with tx_insufficient_balance, or an operation
+// failed with op_underfunded.
+//
+//   if microstellar.IsInsufficientBalance(err) {
+//       // ask the user to top up
+//   }
+func IsInsufficientBalance(err error) bool {
+	return hasResultCode(err, "tx_insufficient_balance", "op_underfunded")
+}
+
+// IsNoTrust returns true if err means an operation failed because an account doesn't trust the
+// asset, i.e., with op_no_trust, op_src_no_trust, or op_no_trustline.
+func IsNoTrust(err error) bool {
+	return hasResultCode(err, "op_no_trust", "op_src_no_trust", "op_no_trustline")
+}
+
+// IsBadSequence returns true if err is a tx_bad_seq rejection from Horizon, which usually means
+// another transaction from the same source was submitted concurrently. These are safe to retry
+// after reloading the sequence number (see Options.WithAutoSequenceRetry.)
+func IsBadSequence(err error) bool {
+	return hasResultCode(err, "tx_bad_seq")
+}
+
+// IsTxTooLate returns true if err is a tx_too_late rejection from Horizon, i.e., the
+// transaction's time bounds expired before it was included in a ledger.
+func IsTxTooLate(err error) bool {
+	return hasResultCode(err, "tx_too_late")
+}
+
+// friendbotURL is the friendbot endpoint on the test network.
+var friendbotURL = "https://friendbot.stellar.org/"
+
 // FundWithFriendBot funds address on the test network with some initial funds.
 func FundWithFriendBot(address string) (string, error) {
-	debugf("FundWithFriendBot", "funding address: %s", address)
-	resp, err := http.Get("https://friendbot.stellar.org/?addr=" + address)
+	debugf(nil, "FundWithFriendBot", "funding address: %s", address)
+	resp, err := http.Get(friendbotURL + "?addr=" + address)
 	if err != nil {
 		return "", err
 	}
@@ -1,8 +1,13 @@
 package microstellar
 
 import (
+	"encoding/json"
 	"log"
 	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
 )
 
 func TestValidAddress(t *testing.T) {
@@ -33,6 +38,87 @@ func TestValidSeed(t *testing.T) {
 	}
 }
 
+func TestSeedMatchesAddress(t *testing.T) {
+	kp, _ := keypair.Random()
+	other, _ := keypair.Random()
+
+	if ok, err := SeedMatchesAddress(kp.Seed(), kp.Address()); !ok || err != nil {
+		t.Errorf("seed should match address: %v", err)
+	}
+
+	if ok, err := SeedMatchesAddress(kp.Seed(), other.Address()); ok || err != nil {
+		t.Errorf("seed should not match address: %v", err)
+	}
+
+	if _, err := SeedMatchesAddress(kp.Address(), kp.Address()); err == nil {
+		t.Error("address is not a valid seed")
+	} else if kerr, ok := err.(*MalformedKeyError); !ok || kerr.Kind != "seed" {
+		t.Errorf("want seed MalformedKeyError, got: %v", err)
+	}
+
+	if _, err := SeedMatchesAddress(kp.Seed(), "GA6FX3WVKZZRUE64H77BRWLDIOIOR4MU27L3ATNVUYKXPX5GF22TOZO"); err == nil {
+		t.Error("address should not be valid")
+	} else if kerr, ok := err.(*MalformedKeyError); !ok || kerr.Kind != "address" {
+		t.Errorf("want address MalformedKeyError, got: %v", err)
+	}
+}
+
+func TestAddressFromSeed(t *testing.T) {
+	address, err := AddressFromSeed("SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK")
+	if err != nil {
+		t.Fatalf("AddressFromSeed: %v", err)
+	}
+
+	if address != "GBXIQCGWEPDJHD57NXBE6NDJCPBGS476JCU2KC626CMEEEYKOOTEKG6R" {
+		t.Errorf("wrong address: %s", address)
+	}
+
+	if _, err := AddressFromSeed(address); err == nil {
+		t.Error("address is not a valid seed")
+	} else if kerr, ok := err.(*MalformedKeyError); !ok || kerr.Kind != "seed" {
+		t.Errorf("want seed MalformedKeyError, got: %v", err)
+	}
+}
+
+func TestParseMemoHash(t *testing.T) {
+	hash, err := ParseMemoHash("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if err != nil {
+		t.Fatalf("hash should be valid: %v", err)
+	}
+
+	if hash[0] != 0xe3 || hash[31] != 0x55 {
+		t.Errorf("wrong hash: %x", hash)
+	}
+
+	if _, err := ParseMemoHash("e3b0c442"); err == nil {
+		t.Error("short hash should not be valid")
+	}
+
+	if _, err := ParseMemoHash("zz"); err == nil {
+		t.Error("non-hex hash should not be valid")
+	}
+}
+
+func TestParseTxHash(t *testing.T) {
+	hexHash, err := ParseTxHash("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if err != nil {
+		t.Fatalf("hex hash should be valid: %v", err)
+	}
+
+	b64Hash, err := ParseTxHash("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")
+	if err != nil {
+		t.Fatalf("base64 hash should be valid: %v", err)
+	}
+
+	if hexHash != b64Hash {
+		t.Errorf("hashes should match: %x != %x", hexHash, b64Hash)
+	}
+
+	if _, err := ParseTxHash("47DEQpj8HBSa"); err == nil {
+		t.Error("short hash should not be valid")
+	}
+}
+
 func TestValidAddressOrSeed(t *testing.T) {
 	if !ValidAddressOrSeed("GAB6FX3WVKZZRUE64H77BRWLDIOIOR4MU27L3ATNVUYKXPX5GF22TOZO") {
 		t.Error("this is a valid address")
@@ -64,3 +150,167 @@ func TestDecodeTx(t *testing.T) {
 
 	log.Printf("txeJSON: %+v", txeJSON)
 }
+
+func TestStroopsFromLumens(t *testing.T) {
+	tests := map[string]int64{
+		"0":                    0,
+		"2.5":                  25000000,
+		".25":                  2500000,
+		"-1":                   -10000000,
+		"0.0000001":            1,
+		"922337203685.4775807": 9223372036854775807,
+		"-92233720368.5477580": -922337203685477580,
+		"0000000000000000001":  10000000,
+	}
+
+	for lumens, want := range tests {
+		got, err := StroopsFromLumens(lumens)
+		if err != nil || got != want {
+			t.Errorf("StroopsFromLumens(%q) = %d, %v, want %d", lumens, got, err, want)
+		}
+	}
+
+	for _, v := range []string{"", "-", ".", "abc", "1,000", "1e5", "1/2", "1.12345678", "922337203685.4775808", "99999999999999999999999999", "+1"} {
+		if _, err := StroopsFromLumens(v); err == nil {
+			t.Errorf("StroopsFromLumens(%q) should fail", v)
+		}
+	}
+
+	for stroops, want := range map[int64]string{0: "0.0000000", 25000000: "2.5000000", -1: "-0.0000001", 9223372036854775807: "922337203685.4775807"} {
+		if got := LumensFromStroops(stroops); got != want {
+			t.Errorf("LumensFromStroops(%d) = %s, want %s", stroops, got, want)
+		}
+	}
+}
+
+func TestValidAmount(t *testing.T) {
+	for _, v := range []string{"0", "1", "10.5", "0.0000001", "922337203685.4775807"} {
+		if err := ValidAmount(v); err != nil {
+			t.Errorf("ValidAmount(%q) failed: %v", v, err)
+		}
+	}
+
+	for _, v := range []string{"", "-1", "abc", "1,000", "1.12345678", "1e5", "922337203685.4775808"} {
+		if err := ValidAmount(v); err == nil {
+			t.Errorf("ValidAmount(%q) should fail", v)
+		}
+	}
+
+	ms := New("fake")
+	source := "SCSMBQYTXKZYY7CLVT6NPPYWVDQYDOQ6BB3QND4OIXC7762JYJYZ3RMK"
+	target := "GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E"
+	USD := NewAsset("USD", "GAIUIQNMSXTTR4TGZETSQCGBTIF32G2L5P4AML4LFTMTHKM44UHIN6XQ", Credit4Type)
+
+	if err := ms.PayNative(source, target, "1.12345678"); err == nil {
+		t.Errorf("PayNative should reject over-precise amounts")
+	}
+
+	if err := ms.FundAccount(source, target, "-5"); err == nil {
+		t.Errorf("FundAccount should reject negative amounts")
+	}
+
+	if err := ms.CreateTrustLine(source, USD, "lots"); err == nil {
+		t.Errorf("CreateTrustLine should reject bad limits")
+	}
+
+	if err := ms.CreateOffer(source, USD, NativeAsset, "1", "-2"); err == nil {
+		t.Errorf("CreateOffer should reject negative amounts")
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	horizonError := func(codes string) error {
+		herr := &horizon.Error{Problem: horizon.Problem{
+			Status: 400,
+			Extras: map[string]json.RawMessage{"result_codes": json.RawMessage(codes)},
+		}}
+		return errors.Wrap(herr, "could not submit transaction")
+	}
+
+	underfunded := horizonError(`{"transaction": "tx_failed", "operations": ["op_success", "op_underfunded"]}`)
+	noTrust := horizonError(`{"transaction": "tx_failed", "operations": ["op_no_trust"]}`)
+	badSeq := horizonError(`{"transaction": "tx_bad_seq"}`)
+	tooLate := horizonError(`{"transaction": "tx_too_late"}`)
+	insufficient := horizonError(`{"transaction": "tx_insufficient_balance"}`)
+
+	tests := []struct {
+		name  string
+		check func(error) bool
+		yes   []error
+		no    []error
+	}{
+		{"IsInsufficientBalance", IsInsufficientBalance, []error{underfunded, insufficient}, []error{noTrust, badSeq}},
+		{"IsNoTrust", IsNoTrust, []error{noTrust}, []error{underfunded, tooLate}},
+		{"IsBadSequence", IsBadSequence, []error{badSeq}, []error{tooLate, underfunded}},
+		{"IsTxTooLate", IsTxTooLate, []error{tooLate}, []error{badSeq, noTrust}},
+	}
+
+	for _, test := range tests {
+		for _, err := range test.yes {
+			if !test.check(err) {
+				t.Errorf("%s: want true for %v", test.name, ErrorString(err))
+			}
+		}
+
+		for _, err := range append(test.no, nil, errors.New("tx_bad_seq op_no_trust"), horizonError(`{}`)) {
+			if test.check(err) {
+				t.Errorf("%s: want false for %v", test.name, ErrorString(err))
+			}
+		}
+	}
+}
+
+func TestGetHorizonError(t *testing.T) {
+	herr := &horizon.Error{Problem: horizon.Problem{
+		Status: 400,
+		Type:   "https://stellar.org/horizon-errors/transaction_failed",
+		Title:  "Transaction Failed",
+		Detail: "The transaction failed when submitted to the stellar network.",
+		Extras: map[string]json.RawMessage{
+			"envelope_xdr": json.RawMessage(`"AAAAAGL8HQvQkbK2HA3WVjRrKmjX00fG8sLI7m0ERwJW/AX3AAAAZAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAA=="`),
+			"result_xdr":   json.RawMessage(`"AAAAAAAAAGT/////AAAAAQAAAAAAAAAB////+wAAAAA="`),
+			"result_codes": json.RawMessage(`{"transaction": "tx_failed", "operations": ["op_underfunded"]}`),
+			"hash":         json.RawMessage(`"3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889"`),
+		},
+	}}
+
+	detail, ok := GetHorizonError(errors.Wrap(herr, "could not submit transaction"))
+	if !ok {
+		t.Fatalf("want Horizon error detail")
+	}
+
+	if detail.Status != 400 || detail.Title != "Transaction Failed" || detail.Type != herr.Problem.Type || detail.Detail != herr.Problem.Detail {
+		t.Errorf("unexpected problem: %+v", detail)
+	}
+
+	if detail.EnvelopeXDR != "AAAAAGL8HQvQkbK2HA3WVjRrKmjX00fG8sLI7m0ERwJW/AX3AAAAZAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAA==" {
+		t.Errorf("unexpected envelope: %s", detail.EnvelopeXDR)
+	}
+
+	if detail.ResultXDR != "AAAAAAAAAGT/////AAAAAQAAAAAAAAAB////+wAAAAA=" {
+		t.Errorf("unexpected result: %s", detail.ResultXDR)
+	}
+
+	if detail.Hash != "3389e9f0f1a65f19736cacf544c2e825313e8447f569233bb8db39aa607c8889" {
+		t.Errorf("unexpected hash: %s", detail.Hash)
+	}
+
+	codes := detail.ResultCodes
+	if codes == nil || codes.TransactionCode != "tx_failed" || len(codes.OperationCodes) != 1 || codes.OperationCodes[0] != "op_underfunded" {
+		t.Errorf("unexpected result codes: %+v", codes)
+	}
+
+	if len(detail.Extras) != 4 {
+		t.Errorf("want 4 extras, got %d", len(detail.Extras))
+	}
+
+	// Errors without extras.
+	detail, ok = GetHorizonError(&horizon.Error{Problem: horizon.Problem{Status: 404, Title: "Resource Missing"}})
+	if !ok || detail.Status != 404 || detail.EnvelopeXDR != "" || detail.ResultCodes != nil {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+
+	if _, ok := GetHorizonError(errors.New("boom")); ok {
+		t.Errorf("want no detail for non-Horizon errors")
+	}
+}
@@ -2,13 +2,24 @@ package microstellar
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stellar/go/clients/horizon"
 )
 
+// ErrCursorGap is returned by the Watch* methods if the cursor set with Options.WithCursor is
+// older than the oldest ledger in Horizon's history, so some of the entries after it can't be
+// streamed. Use errors.Cause to check for it, and catch up with the Load* methods, or resume with
+// WithCursor("now").
+var ErrCursorGap = errors.New("cursor is older than horizon's history")
+
 // Watcher is an abstract watcher struct.
 type Watcher struct {
 	// Call Done to stop watching the ledger. This closes Ch.
@@ -17,12 +28,72 @@ type Watcher struct {
 	// This is set if the stream terminates unexpectedly. Safe to check
 	// after Ch is closed.
 	Err *error
+
+	cursor *watchCursor
+}
+
+// watchCursor is the paging token of the last entry a watcher delivered.
+type watchCursor struct {
+	mu sync.Mutex
+	pt string
+}
+
+// newWatcher returns a Watcher whose last cursor starts at the cursor in options, if any.
+func newWatcher(options []*Options) Watcher {
+	var streamError error
+	w := Watcher{Err: &streamError, Done: func() {}, cursor: &watchCursor{}}
+
+	if opts := mergeOptions(options); opts.hasCursor && opts.cursor != "now" {
+		w.cursor.pt = opts.cursor
+	}
+
+	return w
+}
+
+// delivered records pt as the paging token of the last entry received from Ch.
+func (w *Watcher) delivered(pt string) {
+	w.cursor.mu.Lock()
+	defer w.cursor.mu.Unlock()
+	w.cursor.pt = pt
+}
+
+// LastCursor returns the paging token of the last entry received from Ch, or the starting cursor
+// if there hasn't been one. Persist it, and pass it to Options.WithCursor to resume watching
+// where you left off (see ErrCursorGap.)
+func (w *Watcher) LastCursor() string {
+	if w.cursor == nil {
+		return ""
+	}
+
+	w.cursor.mu.Lock()
+	defer w.cursor.mu.Unlock()
+	return w.cursor.pt
 }
 
 // Ledger represents an entry in the ledger. You can subscribe a continuous stream of ledger
-// updates on the Stellar network via the WatchLedgers call.
+// updates on the Stellar network via the WatchLedgers call. Each entry carries the ledger
+// Sequence, its close time (ClosedAt), the TransactionCount, and the BaseFee in stroops.
 type Ledger horizon.Ledger
 
+// fakeLedgerEpoch is the close time of the first ledger emitted by the fake network.
+var fakeLedgerEpoch = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newFakeLedger returns a deterministic synthetic ledger for the fake network. Ledgers
+// close every 5 seconds, starting at fakeLedgerEpoch.
+func newFakeLedger(sequence int32) *Ledger {
+	return &Ledger{
+		ID:               fmt.Sprintf("fake-%d", sequence),
+		PT:               fmt.Sprintf("%d", sequence),
+		Sequence:         sequence,
+		ClosedAt:         fakeLedgerEpoch.Add(time.Duration(sequence-1) * 5 * time.Second),
+		TransactionCount: sequence % 10,
+		OperationCount:   2 * (sequence % 10),
+		TotalCoins:       "0",
+		BaseFee:          100,
+		BaseReserve:      5000000,
+	}
+}
+
 // LedgerWatcher is returned by WatchLedgers, which watches the stellar network for ledger updates.
 type LedgerWatcher struct {
 	Watcher
@@ -32,28 +103,42 @@ type LedgerWatcher struct {
 }
 
 // WatchLedgers watches the the stellar network for entries and streams them to LedgerWatcher.Ch. Use
-// Options.WithContext to set a context.Context, and Options.WithCursor to set a cursor.
+// Options.WithContext to set a context.Context, and Options.WithCursor to set a cursor (e.g.,
+// "now" to only stream new ledgers, or the LastCursor of an earlier watcher.)
+//
+// On the fake network, WatchLedgers emits a deterministic stream of ledgers with sequence numbers
+// 1, 2, 3, ... (or starting right after the cursor, if it's numeric.)
 func (ms *MicroStellar) WatchLedgers(options ...*Options) (*LedgerWatcher, error) {
-	var streamError error
 	w := &LedgerWatcher{
 		Ch:      make(chan *Ledger),
-		Watcher: Watcher{Err: &streamError, Done: func() {}},
+		Watcher: newWatcher(options),
 	}
 
+	var fakeSequence int32
 	watcherFunc := func(params streamParams) {
 		if params.tx.fake {
-			w.Ch <- &Ledger{ID: "fake", TotalCoins: "0"}
+			if fakeSequence == 0 && params.cursor != nil {
+				if seq, err := strconv.ParseInt(string(*params.cursor), 10, 32); err == nil {
+					fakeSequence = int32(seq)
+				}
+			}
+
+			fakeSequence++
+			ledger := newFakeLedger(fakeSequence)
+			w.Ch <- ledger
+			w.delivered(ledger.PT)
 			return
 		}
 
 		err := params.tx.GetClient().StreamLedgers(params.ctx, params.cursor, func(ledger horizon.Ledger) {
-			debugf("WatchLedger", "entry (%s) total_coins: %s, tx_count: %v, op_count: %v", ledger.ID, ledger.TotalCoins, ledger.TransactionCount, ledger.OperationCount)
+			ms.debugf("WatchLedger", "entry (%d) closed_at: %v, tx_count: %v, base_fee: %v", ledger.Sequence, ledger.ClosedAt, ledger.TransactionCount, ledger.BaseFee)
 			l := Ledger(ledger)
 			w.Ch <- &l
+			w.delivered(l.PT)
 		})
 
 		if err != nil {
-			debugf("WatchLedger", "stream unexpectedly disconnected", err)
+			ms.debugf("WatchLedger", "stream unexpectedly disconnected: %v", err)
 			*w.Err = errors.Wrapf(err, "stream disconnected")
 			w.Done()
 		}
@@ -80,29 +165,55 @@ type TransactionWatcher struct {
 	Ch chan *Transaction
 }
 
+// newFakeTransaction returns a deterministic synthetic transaction on address for the fake network.
+func newFakeTransaction(address string, sequence int32) *Transaction {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", address, sequence)))
+	return &Transaction{
+		ID:              hex.EncodeToString(hash[:]),
+		PT:              fmt.Sprintf("%d", sequence),
+		Hash:            hex.EncodeToString(hash[:]),
+		Ledger:          sequence,
+		LedgerCloseTime: fakeLedgerEpoch.Add(time.Duration(sequence-1) * 5 * time.Second),
+		Account:         address,
+		AccountSequence: fmt.Sprintf("%d", sequence),
+		FeePaid:         100,
+		OperationCount:  1,
+		MemoType:        "text",
+		Memo:            fmt.Sprintf("fake %d", sequence),
+	}
+}
+
 // WatchTransactions watches the ledger for transactions to and from address and streams them on a channel . Use
-// Options.WithContext to set a context.Context, and Options.WithCursor to set a cursor.
+// Options.WithContext to set a context.Context, and Options.WithCursor to set a cursor. Each *Transaction
+// carries the transaction Hash, Memo, FeePaid, and OperationCount.
+//
+// Use Options.WithCursor("now") to ignore historical transactions and only stream new ones. If the stream
+// terminates unexpectedly, TransactionWatcher.Ch is closed and TransactionWatcher.Err is set.
 func (ms *MicroStellar) WatchTransactions(address string, options ...*Options) (*TransactionWatcher, error) {
-	var streamError error
 	w := &TransactionWatcher{
 		Ch:      make(chan *Transaction),
-		Watcher: Watcher{Err: &streamError, Done: func() {}},
+		Watcher: newWatcher(options),
 	}
 
+	var fakeSequence int32
 	watcherFunc := func(params streamParams) {
 		if params.tx.fake {
-			w.Ch <- &Transaction{Account: "FAKE"}
+			fakeSequence++
+			transaction := newFakeTransaction(params.address, fakeSequence)
+			w.Ch <- transaction
+			w.delivered(transaction.PT)
 			return
 		}
 
 		err := params.tx.GetClient().StreamTransactions(params.ctx, params.address, params.cursor, func(transaction horizon.Transaction) {
-			debugf("WatchTransaction", "found transaction (%s) on %s", transaction.ID, transaction.Account)
+			ms.debugf("WatchTransaction", "found transaction (%s) on %s, fee_paid: %v, op_count: %v", transaction.Hash, transaction.Account, transaction.FeePaid, transaction.OperationCount)
 			t := Transaction(transaction)
 			w.Ch <- &t
+			w.delivered(t.PT)
 		})
 
 		if err != nil {
-			debugf("WatchTransaction", "stream unexpectedly disconnected", err)
+			ms.debugf("WatchTransaction", "stream unexpectedly disconnected: %v", err)
 			*w.Err = errors.Wrapf(err, "stream disconnected")
 			w.Done()
 		}
@@ -130,12 +241,12 @@ type PaymentWatcher struct {
 }
 
 // WatchPayments watches the ledger for payments to and from address and streams them on a channel . Use
-// Options.WithContext to set a context.Context, and Options.WithCursor to set a cursor.
+// Options.WithContext to set a context.Context, and Options.WithCursor to set a cursor (e.g., "now" to
+// only stream new payments.)
 func (ms *MicroStellar) WatchPayments(address string, options ...*Options) (*PaymentWatcher, error) {
-	var streamError error
 	w := &PaymentWatcher{
 		Ch:      make(chan *Payment),
-		Watcher: Watcher{Err: &streamError, Done: func() {}},
+		Watcher: newWatcher(options),
 	}
 
 	watcherFunc := func(params streamParams) {
@@ -145,14 +256,15 @@ func (ms *MicroStellar) WatchPayments(address string, options ...*Options) (*Pay
 		}
 
 		err := params.tx.GetClient().StreamPayments(params.ctx, params.address, params.cursor, func(payment horizon.Payment) {
-			debugf("WatchPayments", "found payment (%s) at %s, loading memo", payment.Type, address)
+			ms.debugf("WatchPayments", "found payment (%s) at %s, loading memo", payment.Type, address)
 			params.tx.GetClient().LoadMemo(&payment)
 			p := Payment(payment)
 			w.Ch <- &p
+			w.delivered(p.PagingToken)
 		})
 
 		if err != nil {
-			debugf("WatchPayment", "stream unexpectedly disconnected", err)
+			ms.debugf("WatchPayment", "stream unexpectedly disconnected: %v", err)
 			*w.Err = errors.Wrapf(err, "stream disconnected")
 			w.Done()
 		}
@@ -182,7 +294,7 @@ type streamFunc func(streamParams)
 // watch is a helper method to work with the Horizon Stream* methods. Returns a cancelFunc and error.
 func (ms *MicroStellar) watch(entity string, address string, streamer streamFunc, options ...*Options) (func(), error) {
 	logField := fmt.Sprintf("watch:%s", entity)
-	debugf(logField, "watching address: %s", address)
+	ms.debugf(logField, "watching address: %s", address)
 
 	if err := ValidAddress(address); address != "" && err != nil {
 		return nil, ms.errorf("can't watch %s, invalid address: %s", entity, address)
@@ -200,11 +312,17 @@ func (ms *MicroStellar) watch(entity string, address string, streamer streamFunc
 			// Ugh! Why do I have to do this?
 			c := horizon.Cursor(options[0].cursor)
 			cursor = &c
-			debugf(logField, "starting stream at cursor: %s", string(*cursor))
+			ms.debugf(logField, "starting stream at cursor: %s", string(*cursor))
 		}
 		ctx = options[0].ctx
 	}
 
+	if !tx.fake && cursor != nil {
+		if err := checkCursorGap(tx.GetClient(), entity, string(*cursor)); err != nil {
+			return nil, ms.wrapf(err, "can't watch %s", entity)
+		}
+	}
+
 	if ctx == nil {
 		ctx, cancelFunc = context.WithCancel(context.Background())
 	} else {
@@ -237,3 +355,124 @@ func (ms *MicroStellar) watch(entity string, address string, streamer streamFunc
 
 	return cancelFunc, ms.success()
 }
+
+// checkCursorGap returns ErrCursorGap (wrapped) if Horizon has pruned entries after cursor, i.e.,
+// the ledger of the paging token cursor is older than Horizon's oldest ledger. Paging tokens are
+// TOIDs, with the ledger sequence in the high 32 bits, so non-numeric cursors (e.g., "now") can't
+// have gaps.
+func checkCursorGap(client *horizon.Client, entity string, cursor string) error {
+	toid, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || toid <= 0 {
+		return nil
+	}
+
+	root, err := client.Root()
+	if err != nil {
+		return errors.Wrap(err, "can't check cursor")
+	}
+
+	// Ledger streams resume at the next ledger; the others, later in the same ledger.
+	next := int32(toid >> 32)
+	if entity == "ledger" {
+		next++
+	}
+
+	if next < root.HistoryElderSequence {
+		return errors.Wrapf(ErrCursorGap, "cursor %s is in ledger %d, oldest ledger is %d", cursor, int32(toid>>32), root.HistoryElderSequence)
+	}
+
+	return nil
+}
+
+// DataChange is a change to an account data entry, streamed by WatchData.
+type DataChange struct {
+	Type  EffectType // EffectDataCreated, EffectDataUpdated, or EffectDataRemoved
+	Name  string
+	Value []byte // the new value, or nil if the entry was removed
+
+	// Effect is the effect that changed the entry. Use its PT as the cursor to resume watching.
+	Effect *Effect
+}
+
+// DataWatcher is returned by WatchData, which watches an account data entry for changes.
+type DataWatcher struct {
+	Watcher
+
+	// Ch gets a *DataChange every time the entry is created, updated, or removed.
+	Ch chan *DataChange
+}
+
+// WatchData watches the account at address, and streams every change to its data entry named key
+// (see SetData) on a channel, with the decoded value. Use Options.WithContext to set a
+// context.Context, and Options.WithCursor to set a cursor (e.g., "now" to skip past changes, or the
+// LastCursor of an earlier watcher.)
+//
+//   w, err := ms.WatchData("GAGTJGMT55IDNTFTF2F553VQBWRBLGTWLU4YOOIFYBR2F6H6S4AEC45E", "config",
+//     microstellar.Opts().WithCursor("now"))
+//
+//   for change := range w.Ch {
+//       log.Printf("config is now %q", change.Value)
+//   }
+//
+// Call Done to stop watching. If the stream terminates unexpectedly, DataWatcher.Ch is closed and
+// DataWatcher.Err is set.
+func (ms *MicroStellar) WatchData(address, key string, options ...*Options) (*DataWatcher, error) {
+	if err := ValidAddress(address); err != nil {
+		return nil, ms.errorf("can't watch data, invalid address: %s", address)
+	}
+
+	if key == "" {
+		return nil, ms.errorf("can't watch data: data key must not be empty")
+	}
+
+	w := &DataWatcher{
+		Ch:      make(chan *DataChange),
+		Watcher: newWatcher(options),
+	}
+
+	watcherFunc := func(params streamParams) {
+		if params.tx.fake {
+			w.Ch <- &DataChange{Type: EffectDataUpdated, Name: key, Value: []byte("fake")}
+			return
+		}
+
+		err := streamEffects(params.ctx, params.tx.GetClient(), ms.params, params.address, params.cursor, func(effect Effect) {
+			switch effect.Type {
+			case EffectDataCreated, EffectDataUpdated, EffectDataRemoved:
+			default:
+				return
+			}
+
+			if effect.Name != key {
+				return
+			}
+
+			change := &DataChange{Type: effect.Type, Name: effect.Name, Effect: &effect}
+			if effect.Type != EffectDataRemoved {
+				value, err := base64.StdEncoding.DecodeString(effect.Value)
+				if err != nil {
+					ms.debugf("WatchData", "can't decode value of %s: %v", key, err)
+					return
+				}
+				change.Value = value
+			}
+
+			ms.debugf("WatchData", "%s on %s: %s", effect.Type, address, key)
+			w.Ch <- change
+			w.delivered(effect.PT)
+		})
+
+		if err != nil {
+			ms.debugf("WatchData", "stream unexpectedly disconnected: %v", err)
+			*w.Err = errors.Wrapf(err, "stream disconnected")
+			w.Done()
+		}
+
+		close(w.Ch)
+	}
+
+	cancelFunc, err := ms.watch("data", address, watcherFunc, options...)
+	w.Done = cancelFunc
+
+	return w, err
+}
@@ -2,9 +2,16 @@ package microstellar
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 func ExampleMicroStellar_WatchPayments() {
@@ -110,3 +117,213 @@ func ExampleMicroStellar_WatchLedgers() {
 	fmt.Printf("%d entries seen", entries)
 	// Output: 5 entries seen
 }
+
+func TestWatchLedgersFake(t *testing.T) {
+	ms := New("fake")
+
+	watcher, err := ms.WatchLedgers(Opts().WithCursor("41"))
+	if err != nil {
+		t.Fatalf("WatchLedgers: %v", err)
+	}
+	defer watcher.Done()
+
+	for want := int32(42); want < 45; want++ {
+		l := <-watcher.Ch
+		if l.Sequence != want {
+			t.Errorf("wrong sequence: want %v, got %v", want, l.Sequence)
+		}
+
+		if l.BaseFee != 100 {
+			t.Errorf("wrong base fee: want %v, got %v", 100, l.BaseFee)
+		}
+
+		if wantClose := fakeLedgerEpoch.Add(time.Duration(want-1) * 5 * time.Second); !l.ClosedAt.Equal(wantClose) {
+			t.Errorf("wrong close time: want %v, got %v", wantClose, l.ClosedAt)
+		}
+	}
+
+	// The fake network waits between ledgers, so the last one has been delivered by now.
+	time.Sleep(50 * time.Millisecond)
+	if cursor := watcher.LastCursor(); cursor != "44" {
+		t.Errorf("wrong last cursor: want 44, got %v", cursor)
+	}
+}
+
+func TestWatchCursorGap(t *testing.T) {
+	defer func(d time.Duration) { effectStreamRetry = d }(effectStreamRetry)
+	effectStreamRetry = 10 * time.Millisecond
+
+	address := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	pt := func(ledger int64) string { return fmt.Sprintf("%d", ledger<<32+1) }
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `{"history_latest_ledger": 200, "history_elder_ledger": 100}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "id: %s\ndata: {\"paging_token\": \"%s\", \"type\": \"data_updated\", \"name\": \"config\", \"value\": \"\"}\n\n", pt(150), pt(150))
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+
+	if _, err := ms.WatchData(address, "config", Opts().WithCursor(pt(99))); errors.Cause(err) != ErrCursorGap {
+		t.Fatalf("want ErrCursorGap, got %v", err)
+	}
+
+	watcher, err := ms.WatchData(address, "config", Opts().WithCursor(pt(100)))
+	if err != nil {
+		t.Fatalf("WatchData: %v", err)
+	}
+
+	// Wait for the streams to stop before effectStreamRetry is reset.
+	stop := func(w *DataWatcher) {
+		w.Done()
+		for range w.Ch {
+		}
+	}
+	defer stop(watcher)
+
+	if cursor := watcher.LastCursor(); cursor != pt(100) {
+		t.Errorf("want starting cursor before the first change, got %v", cursor)
+	}
+
+	<-watcher.Ch
+	time.Sleep(10 * time.Millisecond)
+	if cursor := watcher.LastCursor(); cursor != pt(150) {
+		t.Errorf("wrong last cursor: want %v, got %v", pt(150), cursor)
+	}
+
+	now, err := ms.WatchData(address, "config", Opts().WithCursor("now"))
+	if err != nil {
+		t.Fatalf("WatchData with cursor now: %v", err)
+	}
+	defer stop(now)
+
+	if cursor := now.LastCursor(); cursor != "" {
+		t.Errorf("want empty last cursor, got %v", cursor)
+	}
+}
+
+func TestWatchTransactionsFake(t *testing.T) {
+	ms := New("fake")
+	address := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+
+	watcher, err := ms.WatchTransactions(address, Opts().WithCursor("now"))
+	if err != nil {
+		t.Fatalf("WatchTransactions: %v", err)
+	}
+	defer watcher.Done()
+
+	first := <-watcher.Ch
+	second := <-watcher.Ch
+
+	if first.Account != address {
+		t.Errorf("wrong account: want %v, got %v", address, first.Account)
+	}
+
+	if first.Hash == "" || first.Hash == second.Hash {
+		t.Errorf("fake transactions should have distinct hashes: got %v and %v", first.Hash, second.Hash)
+	}
+
+	if first.FeePaid != 100 || first.OperationCount != 1 {
+		t.Errorf("wrong fee or op count: got %v, %v", first.FeePaid, first.OperationCount)
+	}
+}
+
+func TestWatchData(t *testing.T) {
+	defer func(d time.Duration) { effectStreamRetry = d }(effectStreamRetry)
+	effectStreamRetry = 10 * time.Millisecond
+
+	address := "GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A"
+	effect := func(id, effectType, name, value string) string {
+		return fmt.Sprintf("id: %s\ndata: {\"id\": \"%s\", \"paging_token\": \"%s\", \"type\": \"%s\", \"name\": \"%s\", \"value\": \"%s\"}\n\n",
+			id, id, id, effectType, name, base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+
+	var connections int32
+	var resumedAt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/"+address+"/effects" || r.Header.Get("Accept") != "text/event-stream" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&connections, 1) == 1 {
+			fmt.Fprint(w, "retry: 1000\nevent: open\ndata: \"hello\"\n\n")
+			fmt.Fprint(w, effect("1", "data_created", "config", "v1"))
+			fmt.Fprint(w, effect("2", "data_created", "other", "x"))
+			fmt.Fprint(w, effect("3", "account_credited", "", ""))
+			fmt.Fprint(w, effect("4", "data_updated", "config", "v2"))
+			return
+		}
+
+		// Horizon closed the first stream, so the watcher reconnects after the last effect.
+		resumedAt = r.URL.Query().Get("cursor")
+		fmt.Fprint(w, effect("5", "data_removed", "config", ""))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ms := New("custom", Params{"url": server.URL, "passphrase": "test"})
+	watcher, err := ms.WatchData(address, "config", Opts().WithCursor("now"))
+	if err != nil {
+		t.Fatalf("WatchData: %v", err)
+	}
+
+	want := []struct {
+		effectType EffectType
+		value      string
+	}{
+		{EffectDataCreated, "v1"},
+		{EffectDataUpdated, "v2"},
+		{EffectDataRemoved, ""},
+	}
+
+	for i, w := range want {
+		select {
+		case change := <-watcher.Ch:
+			if change.Type != w.effectType || change.Name != "config" || string(change.Value) != w.value {
+				t.Errorf("change %d: want %s %q, got %+v", i, w.effectType, w.value, change)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for change %d", i)
+		}
+	}
+
+	if resumedAt != "4" {
+		t.Errorf("want stream resumed at cursor 4, got %q", resumedAt)
+	}
+
+	watcher.Done()
+	for range watcher.Ch {
+	}
+
+	if *watcher.Err != nil {
+		t.Errorf("unexpected stream error: %v", *watcher.Err)
+	}
+
+	if _, err := ms.WatchData("BAD", "config"); err == nil {
+		t.Errorf("want error for bad address")
+	}
+
+	if _, err := ms.WatchData(address, ""); err == nil {
+		t.Errorf("want error for empty key")
+	}
+}
+
+func TestWatchDataFake(t *testing.T) {
+	watcher, err := New("fake").WatchData("GCCRUJJGPYWKQWM5NLAXUCSBCJKO37VVJ74LIZ5AQUKT6KPVCPNAGC4A", "config")
+	if err != nil {
+		t.Fatalf("WatchData: %v", err)
+	}
+	defer watcher.Done()
+
+	if change := <-watcher.Ch; change.Name != "config" || string(change.Value) != "fake" {
+		t.Errorf("unexpected fake change: %+v", change)
+	}
+}